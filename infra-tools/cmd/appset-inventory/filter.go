@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+// selection restricts which Applications are listed. An empty field means
+// "no restriction" for that dimension; non-empty fields are combined with
+// AND.
+type selection struct {
+	envs     map[string]bool
+	clusters map[string]bool
+}
+
+// parseSelection builds a selection from the comma-separated --env and
+// --cluster flag values, validating environment names.
+func parseSelection(envs, clusters string) (selection, error) {
+	var sel selection
+	for _, e := range splitList(envs) {
+		switch detector.Environment(e) {
+		case detector.Development, detector.Staging, detector.Production:
+		default:
+			return selection{}, fmt.Errorf("invalid --env %q: must be one of development, staging, production", e)
+		}
+		if sel.envs == nil {
+			sel.envs = make(map[string]bool)
+		}
+		sel.envs[e] = true
+	}
+	for _, c := range splitList(clusters) {
+		if sel.clusters == nil {
+			sel.clusters = make(map[string]bool)
+		}
+		sel.clusters[c] = true
+	}
+	return sel, nil
+}
+
+// apply returns the entries that match the selection.
+//
+// An Application listed for allClusters is kept for a --cluster filter unless
+// its ApplicationSet names that cluster, in which case the named entry
+// replaces it on that cluster.
+func (s selection) apply(entries []inventoryEntry) []inventoryEntry {
+	if len(s.envs) == 0 && len(s.clusters) == 0 {
+		return entries
+	}
+
+	// For each ApplicationSet, the selected clusters it names.
+	type appSetKey struct{ overlay, appSet string }
+	named := make(map[appSetKey]map[string]bool)
+	for _, e := range entries {
+		if s.clusters[e.Cluster] {
+			k := appSetKey{e.Overlay, e.AppSet}
+			if named[k] == nil {
+				named[k] = make(map[string]bool)
+			}
+			named[k][e.Cluster] = true
+		}
+	}
+
+	kept := []inventoryEntry{}
+	for _, e := range entries {
+		if !s.keepsEnv(e.Environment) {
+			continue
+		}
+		if !s.keepsCluster(e.Cluster) {
+			if e.Cluster != allClusters || len(named[appSetKey{e.Overlay, e.AppSet}]) == len(s.clusters) {
+				continue
+			}
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// keepsEnv reports whether the selection includes env.
+func (s selection) keepsEnv(env string) bool {
+	return len(s.envs) == 0 || s.envs[env]
+}
+
+// keepsCluster reports whether the selection includes cluster.
+func (s selection) keepsCluster(cluster string) bool {
+	return len(s.clusters) == 0 || s.clusters[cluster]
+}
+
+// splitList splits a comma-separated flag value, trimming spaces and
+// dropping empty entries.
+func splitList(raw string) []string {
+	var out []string
+	for s := range strings.SplitSeq(raw, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
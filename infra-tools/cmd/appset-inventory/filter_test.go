@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func entryNames(entries []inventoryEntry) []string {
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestParseSelection_Invalid(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := parseSelection("qa", "")
+	g.Expect(err).To(MatchError(ContainSubstring(`invalid --env "qa"`)))
+}
+
+func TestSelection_Empty_KeepsEverything(t *testing.T) {
+	g := NewWithT(t)
+
+	sel, err := parseSelection("", " , ")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	entries := buildInventory(testOverlays(), "abc123").Applications
+	g.Expect(sel.apply(entries)).To(Equal(entries))
+}
+
+func TestSelection_Env(t *testing.T) {
+	g := NewWithT(t)
+
+	sel, err := parseSelection("production", "")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	entries := buildInventory(testOverlays(), "abc123").Applications
+	g.Expect(entryNames(sel.apply(entries))).To(Equal([]string{"smee-{{nameNormalized}}", "smee-stone-prd-rh01"}))
+}
+
+func TestSelection_Cluster(t *testing.T) {
+	g := NewWithT(t)
+
+	entries := buildInventory(testOverlays(), "abc123").Applications
+
+	// A named cluster gets its own Application instead of the one for all
+	// clusters.
+	sel, err := parseSelection("", "stone-prd-rh01")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(entryNames(sel.apply(entries))).To(Equal([]string{"smee-stone-prd-rh01"}))
+
+	// Any other cluster the clusters generator selects gets the shared one.
+	sel, err = parseSelection("", "stone-prd-m01")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(entryNames(sel.apply(entries))).To(Equal([]string{"smee-{{nameNormalized}}"}))
+
+	sel, err = parseSelection("", "in-cluster,stone-prd-rh01")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(entryNames(sel.apply(entries))).To(Equal([]string{"all-in-one", "smee-{{nameNormalized}}", "smee-stone-prd-rh01"}))
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+// inventorySchemaVersion identifies the layout of the json output. Bump it
+// whenever a field is renamed or removed; adding fields does not require a
+// bump.
+const inventorySchemaVersion = "appset-inventory/v1"
+
+// allClusters is the cluster listed for an Application that a clusters
+// generator creates on every cluster it selects, except those a merge names
+// explicitly. Which clusters those are is only known to Argo CD.
+const allClusters = "*"
+
+// inventory is the document written by every output format.
+type inventory struct {
+	SchemaVersion string           `json:"schemaVersion"`
+	Revision      string           `json:"revision"`
+	Applications  []inventoryEntry `json:"applications"`
+	Warnings      []string         `json:"warnings,omitempty"`
+}
+
+// inventoryEntry is one generated Application.
+type inventoryEntry struct {
+	Environment    string                 `json:"environment"`
+	Overlay        string                 `json:"overlay"`
+	AppSet         string                 `json:"appSet"`
+	Name           string                 `json:"name"`
+	Cluster        string                 `json:"cluster"`
+	Path           string                 `json:"path"`
+	RepoURL        string                 `json:"repoURL,omitempty"`
+	TargetRevision string                 `json:"targetRevision,omitempty"`
+	Server         string                 `json:"server,omitempty"`
+	Namespace      string                 `json:"namespace"`
+	Project        string                 `json:"project"`
+	SyncPolicy     map[string]interface{} `json:"syncPolicy,omitempty"`
+}
+
+// envOrder sorts environments in promotion order rather than alphabetically.
+var envOrder = map[string]int{
+	string(detector.Development): 0,
+	string(detector.Staging):     1,
+	string(detector.Production):  2,
+}
+
+// buildInventory flattens the per-overlay Applications into sorted entries.
+func buildInventory(overlays []detector.OverlayApplications, revision string) inventory {
+	inv := inventory{
+		SchemaVersion: inventorySchemaVersion,
+		Revision:      revision,
+		Applications:  []inventoryEntry{},
+		Warnings:      overlayWarnings(overlays),
+	}
+	for _, o := range overlays {
+		for _, app := range o.Applications {
+			syncPolicy, _ := app.Spec["syncPolicy"].(map[string]interface{})
+			inv.Applications = append(inv.Applications, inventoryEntry{
+				Environment:    string(o.Environment),
+				Overlay:        o.Overlay,
+				AppSet:         app.AppSet,
+				Name:           app.Name,
+				Cluster:        clusterOf(app),
+				Path:           app.Source.Path,
+				RepoURL:        app.Source.RepoURL,
+				TargetRevision: app.Source.TargetRevision,
+				Server:         app.Destination.Server,
+				Namespace:      app.Destination.Namespace,
+				Project:        app.Project,
+				SyncPolicy:     syncPolicy,
+			})
+		}
+	}
+	slices.SortStableFunc(inv.Applications, func(a, b inventoryEntry) int {
+		return cmp.Or(
+			cmp.Compare(envOrder[a.Environment], envOrder[b.Environment]),
+			cmp.Compare(a.Overlay, b.Overlay),
+			cmp.Compare(a.AppSet, b.AppSet),
+			cmp.Compare(a.Cluster, b.Cluster),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+	return inv
+}
+
+// overlayWarnings prefixes every overlay's warnings with its name.
+func overlayWarnings(overlays []detector.OverlayApplications) []string {
+	var warnings []string
+	for _, o := range overlays {
+		for _, w := range o.Warnings {
+			warnings = append(warnings, o.Overlay+": "+w)
+		}
+	}
+	return warnings
+}
+
+// clusterOf returns the cluster an Application is deployed to: the cluster
+// a merge names, allClusters for the clusters generator's own Application,
+// and otherwise the destination from the template.
+func clusterOf(app appset.Application) string {
+	switch {
+	case app.Cluster != "":
+		return app.Cluster
+	case app.AllClusters:
+		return allClusters
+	case app.Destination.Name != "":
+		return app.Destination.Name
+	default:
+		return app.Destination.Server
+	}
+}
+
+// syncPolicySummary describes an Application's syncPolicy in a few words,
+// e.g. "automated (prune, selfHeal)" or "manual".
+func syncPolicySummary(policy map[string]interface{}) string {
+	automated, ok := policy["automated"].(map[string]interface{})
+	if !ok {
+		return "manual"
+	}
+	if enabled, ok := automated["enabled"].(bool); ok && !enabled {
+		return "manual"
+	}
+	var opts []string
+	for _, opt := range []string{"prune", "selfHeal", "allowEmpty"} {
+		if on, _ := automated[opt].(bool); on {
+			opts = append(opts, opt)
+		}
+	}
+	if len(opts) == 0 {
+		return "automated"
+	}
+	return "automated (" + strings.Join(opts, ", ") + ")"
+}
+
+// writers maps each --format value to the function that writes it.
+var writers = map[string]func(io.Writer, inventory) error{
+	"json":     writeJSON,
+	"csv":      writeCSV,
+	"markdown": writeMarkdown,
+}
+
+func writeJSON(w io.Writer, inv inventory) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(inv)
+}
+
+// csvHeader lists the columns written by writeCSV.
+var csvHeader = []string{
+	"environment", "overlay", "appset", "name", "cluster", "path",
+	"namespace", "project", "sync_policy", "repo_url", "target_revision", "server",
+}
+
+func writeCSV(w io.Writer, inv inventory) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, e := range inv.Applications {
+		record := []string{
+			e.Environment, e.Overlay, e.AppSet, e.Name, e.Cluster, e.Path,
+			e.Namespace, e.Project, syncPolicySummary(e.SyncPolicy), e.RepoURL, e.TargetRevision, e.Server,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeMarkdown(w io.Writer, inv inventory) error {
+	var b strings.Builder
+	b.WriteString("# Argo CD Application inventory\n\n")
+	fmt.Fprintf(&b, "Revision: `%s` — %d applications\n\n", inv.Revision, len(inv.Applications))
+
+	b.WriteString("| Environment | Overlay | Cluster | Application | Path | Namespace | Sync policy |\n")
+	b.WriteString("|-------------|---------|---------|-------------|------|-----------|-------------|\n")
+	anyAll := false
+	for _, e := range inv.Applications {
+		anyAll = anyAll || e.Cluster == allClusters
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | `%s` | %s | %s |\n",
+			e.Environment, e.Overlay, escapeCell(e.Cluster), escapeCell(e.Name),
+			escapeCell(e.Path), escapeCell(e.Namespace), syncPolicySummary(e.SyncPolicy))
+	}
+	if anyAll {
+		fmt.Fprintf(&b, "\n`%s`: every cluster the ApplicationSet's clusters generator selects, except those listed by name.\n", allClusters)
+	}
+
+	if len(inv.Warnings) > 0 {
+		b.WriteString("\n## Warnings\n\n")
+		for _, warning := range inv.Warnings {
+			fmt.Fprintf(&b, "- %s\n", warning)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeCell keeps a value from breaking out of its markdown table cell.
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+func testOverlays() []detector.OverlayApplications {
+	automated := map[string]interface{}{
+		"syncPolicy": map[string]interface{}{
+			"automated": map[string]interface{}{"prune": true, "selfHeal": true},
+		},
+	}
+	return []detector.OverlayApplications{
+		{
+			Overlay:     "production-downstream",
+			Environment: detector.Production,
+			Applications: []appset.Application{
+				{
+					AppSet:      "smee",
+					Name:        "smee-{{nameNormalized}}",
+					AllClusters: true,
+					Source:      appset.ApplicationSource{Path: "components/smee/production/base"},
+					Destination: appset.ApplicationDestination{Server: "{{server}}", Namespace: "smee"},
+					Spec:        automated,
+				},
+				{
+					AppSet:      "smee",
+					Name:        "smee-stone-prd-rh01",
+					Cluster:     "stone-prd-rh01",
+					Source:      appset.ApplicationSource{Path: "components/smee/production/stone-prd-rh01"},
+					Destination: appset.ApplicationDestination{Server: "{{server}}", Namespace: "smee"},
+				},
+			},
+			Warnings: []string{"ApplicationSet x: scmProvider generator is not supported"},
+		},
+		{
+			Overlay:     "development",
+			Environment: detector.Development,
+			Applications: []appset.Application{
+				{
+					AppSet:      "all-in-one",
+					Name:        "all-in-one",
+					Project:     "default",
+					Source:      appset.ApplicationSource{Path: "components/all-in-one", RepoURL: "https://github.com/example/repo.git"},
+					Destination: appset.ApplicationDestination{Name: "in-cluster", Namespace: "a|b"},
+				},
+			},
+		},
+	}
+}
+
+func TestBuildInventory(t *testing.T) {
+	g := NewWithT(t)
+
+	inv := buildInventory(testOverlays(), "abc123")
+	g.Expect(inv.SchemaVersion).To(Equal(inventorySchemaVersion))
+	g.Expect(inv.Revision).To(Equal("abc123"))
+	g.Expect(inv.Warnings).To(Equal([]string{"production-downstream: ApplicationSet x: scmProvider generator is not supported"}))
+
+	// Sorted in promotion order, then by cluster.
+	g.Expect(inv.Applications).To(HaveLen(3))
+	g.Expect(inv.Applications[0].Name).To(Equal("all-in-one"))
+	g.Expect(inv.Applications[0].Cluster).To(Equal("in-cluster"))
+	g.Expect(inv.Applications[1].Cluster).To(Equal(allClusters))
+	g.Expect(inv.Applications[2].Cluster).To(Equal("stone-prd-rh01"))
+	g.Expect(inv.Applications[1].SyncPolicy).To(HaveKey("automated"))
+}
+
+func TestSyncPolicySummary(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(syncPolicySummary(nil)).To(Equal("manual"))
+	g.Expect(syncPolicySummary(map[string]interface{}{"syncOptions": []interface{}{"CreateNamespace=true"}})).To(Equal("manual"))
+	g.Expect(syncPolicySummary(map[string]interface{}{"automated": map[string]interface{}{}})).To(Equal("automated"))
+	g.Expect(syncPolicySummary(map[string]interface{}{"automated": map[string]interface{}{"enabled": false, "prune": true}})).To(Equal("manual"))
+	g.Expect(syncPolicySummary(map[string]interface{}{
+		"automated": map[string]interface{}{"selfHeal": true, "prune": true},
+	})).To(Equal("automated (prune, selfHeal)"))
+}
+
+func TestWriteJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeJSON(&buf, buildInventory(testOverlays(), "abc123"))).To(Succeed())
+
+	var decoded map[string]interface{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &decoded)).To(Succeed())
+	g.Expect(decoded["schemaVersion"]).To(Equal(inventorySchemaVersion))
+	apps := decoded["applications"].([]interface{})
+	g.Expect(apps).To(HaveLen(3))
+	g.Expect(apps[1]).To(HaveKeyWithValue("cluster", "*"))
+	g.Expect(apps[1]).To(HaveKeyWithValue("namespace", "smee"))
+	g.Expect(apps[1]).To(HaveKey("syncPolicy"))
+	g.Expect(apps[2]).NotTo(HaveKey("syncPolicy"))
+}
+
+func TestWriteJSON_Empty(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeJSON(&buf, buildInventory(nil, "abc123"))).To(Succeed())
+	g.Expect(buf.String()).To(ContainSubstring(`"applications": []`))
+}
+
+func TestWriteCSV(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeCSV(&buf, buildInventory(testOverlays(), "abc123"))).To(Succeed())
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(records).To(HaveLen(4))
+	g.Expect(records[0]).To(Equal(csvHeader))
+	g.Expect(records[2]).To(Equal([]string{
+		"production", "production-downstream", "smee", "smee-{{nameNormalized}}", "*",
+		"components/smee/production/base", "smee", "", "automated (prune, selfHeal)", "", "", "{{server}}",
+	}))
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeMarkdown(&buf, buildInventory(testOverlays(), "abc123"))).To(Succeed())
+
+	out := buf.String()
+	g.Expect(out).To(ContainSubstring("Revision: `abc123` — 3 applications"))
+	g.Expect(out).To(ContainSubstring("| development | development | in-cluster | all-in-one | `components/all-in-one` | a\\|b | manual |"))
+	g.Expect(out).To(ContainSubstring("| production | production-downstream | * | smee-{{nameNormalized}} | `components/smee/production/base` | smee | automated (prune, selfHeal) |"))
+	g.Expect(out).To(ContainSubstring("`*`: every cluster"))
+	g.Expect(out).To(ContainSubstring("## Warnings\n\n- production-downstream: ApplicationSet x"))
+}
@@ -0,0 +1,148 @@
+// Command appset-inventory renders every ArgoCD ApplicationSet overlay and
+// lists the Applications they generate: name, cluster, path, destination
+// namespace and sync policy, as JSON, CSV or markdown. With --report matrix
+// it instead lays them out as an environment × cluster × component matrix
+// and flags staging/production and cluster parity gaps.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/buildcache"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fleet"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/git"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/logging"
+)
+
+func main() {
+	var (
+		repoRoot    = flag.String("repo-root", "", "Path to the repository root (default: auto-detect via git)")
+		ref         = flag.String("ref", "", "Git ref to inventory from a worktree (default: the working copy)")
+		gitBackend  = flag.String("git-backend", "exec", "Git backend: exec (git binary and worktrees), go (in-process, reads trees from the object database)")
+		overlaysDir = flag.String("overlays-dir", "argo-cd-apps/overlays", "Path to overlays directory relative to repo root")
+		report      = flag.String("report", "inventory", "Report: inventory (one row per Application), matrix (environment × cluster × component, with parity findings)")
+		format      = flag.String("format", "markdown", "Output format: json, csv, markdown")
+		outputFile  = flag.String("output", "", "Write the inventory to this file instead of stdout")
+		envFilter   = flag.String("env", "", "Only list these environments (comma-separated: development, staging, production)")
+		cluster     = flag.String("cluster", "", "Only list Applications on these clusters (comma-separated)")
+		logFile     = flag.String("log-file", "", "Write debug-level logs to this file")
+		noCache     = flag.Bool("no-cache", false, "Disable the persistent kustomize build cache")
+		cacheDir    = flag.String("cache-dir", "", "Directory for the kustomize build cache (default: user cache dir)")
+	)
+	flag.Parse()
+
+	switch *report {
+	case "inventory", "matrix":
+		// valid
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --report %q: must be one of inventory, matrix\n", *report)
+		os.Exit(1)
+	}
+
+	if _, ok := writers[*format]; !ok {
+		fmt.Fprintf(os.Stderr, "invalid --format %q: must be one of json, csv, markdown\n", *format)
+		os.Exit(1)
+	}
+
+	switch git.Backend(*gitBackend) {
+	case git.BackendExec, git.BackendGo:
+		// valid
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --git-backend %q: must be one of exec, go\n", *gitBackend)
+		os.Exit(1)
+	}
+
+	sel, err := parseSelection(*envFilter, *cluster)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logCleanup, err := logging.Setup(*logFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up logging: %v\n", err)
+		os.Exit(1)
+	}
+	if logCleanup != nil {
+		defer logCleanup()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	repo, err := git.Open(ctx, git.Backend(*gitBackend), *repoRoot)
+	if err != nil {
+		logging.Fatal("opening repository; use --repo-root to specify explicitly", "err", err)
+	}
+
+	// Inventory the working copy unless a ref is given.
+	tree := git.WorkingTree(repo.Root())
+	revision := "working copy"
+	if *ref != "" {
+		revision, err = repo.ResolveRef(ctx, *ref)
+		if err != nil {
+			logging.Fatal("resolving ref", "ref", *ref, "err", err)
+		}
+		var cleanup func()
+		tree, cleanup, err = repo.Checkout(ctx, *ref)
+		if err != nil {
+			logging.Fatal("checking out ref", "ref", *ref, "err", err)
+		}
+		defer cleanup()
+	}
+
+	var buildCache *buildcache.Cache
+	if !*noCache {
+		buildCache, err = buildcache.Open(*cacheDir)
+		if err != nil {
+			slog.Warn("build cache unavailable, building without cache", "err", err)
+		}
+	}
+	repoRef := detector.NewRepoRef(tree.Root, detector.WithFileSystem(tree.FS), detector.WithBuildCache(buildCache))
+
+	slog.Info("Expanding ApplicationSets...", "ref", revision)
+	overlays, err := detector.Inventory(repoRef, *overlaysDir)
+	if err != nil {
+		logging.Fatal("building inventory", "err", err)
+	}
+	buildCache.LogStats()
+
+	var write func(io.Writer) error
+	if *report == "matrix" {
+		rep := buildMatrixReport(fleet.Build(overlays, repoRef), revision, overlayWarnings(overlays), sel)
+		for _, w := range rep.Warnings {
+			slog.Warn(w)
+		}
+		slog.Info("Parity findings", "count", len(rep.Findings))
+		write = func(w io.Writer) error { return matrixWriters[*format](w, rep) }
+	} else {
+		inv := buildInventory(overlays, revision)
+		for _, w := range inv.Warnings {
+			slog.Warn(w)
+		}
+		inv.Applications = sel.apply(inv.Applications)
+		slog.Info("Applications found", "count", len(inv.Applications))
+		write = func(w io.Writer) error { return writers[*format](w, inv) }
+	}
+
+	var out io.Writer = os.Stdout
+	if *outputFile != "" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			logging.Fatal("creating output file", "err", err)
+		}
+		defer func() { _ = f.Close() }()
+		out = f
+	}
+	if err := write(out); err != nil {
+		logging.Fatal("writing report", "report", *report, "err", err)
+	}
+}
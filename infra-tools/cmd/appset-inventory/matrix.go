@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fleet"
+)
+
+// matrixSchemaVersion identifies the layout of the json matrix report, with
+// the same bump rules as inventorySchemaVersion.
+const matrixSchemaVersion = "appset-inventory-matrix/v1"
+
+// matrixReport is the document written by every output format for
+// --report matrix.
+type matrixReport struct {
+	SchemaVersion string              `json:"schemaVersion"`
+	Revision      string              `json:"revision"`
+	Environments  []matrixEnvironment `json:"environments"`
+	Findings      []fleet.Finding     `json:"findings"`
+	Warnings      []string            `json:"warnings,omitempty"`
+}
+
+// matrixEnvironment is one environment's cluster × component table.
+type matrixEnvironment struct {
+	Name string `json:"name"`
+	// Clusters are the table's columns, ending with allClusters when any
+	// component is deployed on clusters not known by name.
+	Clusters   []string          `json:"clusters"`
+	Components []matrixComponent `json:"components"`
+}
+
+// matrixComponent is one row of an environment's table.
+type matrixComponent struct {
+	Name string `json:"name"`
+	// Cells maps cluster to deployment; clusters without it are omitted.
+	Cells map[string]fleet.Cell `json:"cells"`
+}
+
+// buildMatrixReport audits m and lays it out for the writers, keeping the
+// environments and clusters sel selects. Staging/production parity is
+// audited on the whole matrix, so filtering doesn't hide a missing
+// component.
+func buildMatrixReport(m *fleet.Matrix, revision string, warnings []string, sel selection) matrixReport {
+	rep := matrixReport{
+		SchemaVersion: matrixSchemaVersion,
+		Revision:      revision,
+		Environments:  []matrixEnvironment{},
+		Findings:      []fleet.Finding{},
+		Warnings:      warnings,
+	}
+	for _, env := range m.Environments() {
+		if !sel.keepsEnv(string(env)) {
+			continue
+		}
+		var clusters []string
+		for _, c := range m.Clusters(env) {
+			if sel.keepsCluster(c) {
+				clusters = append(clusters, c)
+			}
+		}
+		columns := append(slices.Clone(clusters), fleet.AllClusters)
+		shared := false
+		me := matrixEnvironment{Name: string(env), Components: []matrixComponent{}}
+		for _, component := range m.Components(env) {
+			mc := matrixComponent{Name: component, Cells: make(map[string]fleet.Cell)}
+			for _, c := range columns {
+				if cell := m.Cell(env, c, component); cell.Placement != fleet.Absent {
+					mc.Cells[c] = cell
+				}
+			}
+			if len(mc.Cells) == 0 {
+				continue
+			}
+			_, all := mc.Cells[fleet.AllClusters]
+			shared = shared || all
+			me.Components = append(me.Components, mc)
+		}
+		if shared {
+			clusters = append(clusters, fleet.AllClusters)
+		}
+		me.Clusters = append([]string{}, clusters...)
+		rep.Environments = append(rep.Environments, me)
+	}
+	for _, f := range m.Audit() {
+		if sel.keepsEnv(string(f.Environment)) && (f.Cluster == "" || sel.keepsCluster(f.Cluster)) {
+			rep.Findings = append(rep.Findings, f)
+		}
+	}
+	return rep
+}
+
+// matrixWriters maps each --format value to the function that writes the
+// matrix report.
+var matrixWriters = map[string]func(io.Writer, matrixReport) error{
+	"json":     writeMatrixJSON,
+	"csv":      writeMatrixCSV,
+	"markdown": writeMatrixMarkdown,
+}
+
+func writeMatrixJSON(w io.Writer, rep matrixReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// matrixCSVHeader lists the columns written by writeMatrixCSV.
+var matrixCSVHeader = []string{"environment", "cluster", "component", "placement", "path"}
+
+// writeMatrixCSV writes one row per deployed cell. Findings are only in the
+// json and markdown reports.
+func writeMatrixCSV(w io.Writer, rep matrixReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(matrixCSVHeader); err != nil {
+		return err
+	}
+	for _, env := range rep.Environments {
+		for _, mc := range env.Components {
+			for _, c := range env.Clusters {
+				cell, ok := mc.Cells[c]
+				if !ok {
+					continue
+				}
+				if err := cw.Write([]string{env.Name, c, mc.Name, string(cell.Placement), cell.Path}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// placementMarks are the markdown table's symbols for each placement.
+var placementMarks = map[fleet.Placement]string{
+	fleet.Specific: "●",
+	fleet.Shared:   "○",
+}
+
+func writeMatrixMarkdown(w io.Writer, rep matrixReport) error {
+	var b strings.Builder
+	b.WriteString("# Argo CD deployment matrix\n\n")
+	fmt.Fprintf(&b, "Revision: `%s`\n", rep.Revision)
+
+	for _, env := range rep.Environments {
+		fmt.Fprintf(&b, "\n## %s\n\n", env.Name)
+		if len(env.Components) == 0 {
+			b.WriteString("No components deployed.\n")
+			continue
+		}
+		b.WriteString("| Component |")
+		for _, c := range env.Clusters {
+			fmt.Fprintf(&b, " %s |", escapeCell(c))
+		}
+		b.WriteString("\n|-----------|")
+		b.WriteString(strings.Repeat("---|", len(env.Clusters)))
+		b.WriteString("\n")
+		for _, mc := range env.Components {
+			fmt.Fprintf(&b, "| %s |", escapeCell(mc.Name))
+			for _, c := range env.Clusters {
+				fmt.Fprintf(&b, " %s |", placementMarks[mc.Cells[c].Placement])
+			}
+			b.WriteString("\n")
+		}
+	}
+	fmt.Fprintf(&b, "\n●: cluster-specific path. ○: path shared by the clusters the ApplicationSet doesn't name. `%s`: clusters the clusters generator selects that aren't known by name.\n", allClusters)
+
+	b.WriteString("\n## Findings\n\n")
+	if len(rep.Findings) == 0 {
+		b.WriteString("No parity findings.\n")
+	} else {
+		b.WriteString("| Finding | Environment | Cluster | Component | Detail |\n")
+		b.WriteString("|---------|-------------|---------|-----------|--------|\n")
+		for _, f := range rep.Findings {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n",
+				f.Kind, f.Environment, escapeCell(f.Cluster), escapeCell(f.Component), escapeCell(f.Detail))
+		}
+	}
+
+	if len(rep.Warnings) > 0 {
+		b.WriteString("\n## Warnings\n\n")
+		for _, warning := range rep.Warnings {
+			fmt.Fprintf(&b, "- %s\n", warning)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
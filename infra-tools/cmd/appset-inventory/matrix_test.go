@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fleet"
+)
+
+// testMatrix builds the matrix of a staging and a production overlay: smee
+// on its own path on two production clusters and one staging cluster, and
+// monitoring on a shared path in production only.
+func testMatrix(g *WithT) *fleet.Matrix {
+	files := make(map[string][]byte)
+	for _, dir := range []string{
+		"components/smee/production/p1",
+		"components/smee/production/p2",
+		"components/smee/staging/s1",
+		"components/monitoring/production/base",
+	} {
+		files[dir+"/kustomization.yaml"] = []byte("resources: []\n")
+	}
+	repo, err := detector.NewMemRepoRef(files)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	smee := func(env string) []appset.Application {
+		var apps []appset.Application
+		for _, c := range []string{"p1", "p2", "s1"} {
+			apps = append(apps, appset.Application{
+				AppSet:  "smee",
+				Cluster: c,
+				Source:  appset.ApplicationSource{Path: "components/smee/" + env + "/" + c},
+			})
+		}
+		return apps
+	}
+	return fleet.Build([]detector.OverlayApplications{
+		{Overlay: "staging-downstream", Environment: detector.Staging, Applications: smee("staging")},
+		{
+			Overlay:     "production-downstream",
+			Environment: detector.Production,
+			Applications: append(smee("production"), appset.Application{
+				AppSet:      "monitoring",
+				AllClusters: true,
+				Source:      appset.ApplicationSource{Path: "components/monitoring/production/base"},
+			}),
+		},
+	}, repo)
+}
+
+func TestBuildMatrixReport(t *testing.T) {
+	g := NewWithT(t)
+
+	rep := buildMatrixReport(testMatrix(g), "abc123", []string{"w"}, selection{})
+	g.Expect(rep.SchemaVersion).To(Equal(matrixSchemaVersion))
+	g.Expect(rep.Warnings).To(Equal([]string{"w"}))
+	g.Expect(rep.Environments).To(HaveLen(2))
+
+	stg, prd := rep.Environments[0], rep.Environments[1]
+	g.Expect(stg.Name).To(Equal("staging"))
+	g.Expect(stg.Clusters).To(Equal([]string{"s1"}))
+	g.Expect(prd.Clusters).To(Equal([]string{"p1", "p2", allClusters}))
+	g.Expect(prd.Components).To(HaveLen(2))
+	g.Expect(prd.Components[0].Name).To(Equal("monitoring"))
+	g.Expect(prd.Components[0].Cells).To(HaveLen(3))
+	g.Expect(prd.Components[1].Cells).To(Equal(map[string]fleet.Cell{
+		"p1": {Placement: fleet.Specific, Path: "components/smee/production/p1"},
+		"p2": {Placement: fleet.Specific, Path: "components/smee/production/p2"},
+	}))
+
+	g.Expect(rep.Findings).To(ConsistOf(
+		HaveField("Component", "monitoring"),
+	))
+	g.Expect(rep.Findings[0].Kind).To(Equal(fleet.MissingInStaging))
+}
+
+func TestBuildMatrixReport_Selection(t *testing.T) {
+	g := NewWithT(t)
+
+	sel, err := parseSelection("production", "p2")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	rep := buildMatrixReport(testMatrix(g), "abc123", nil, sel)
+	g.Expect(rep.Environments).To(HaveLen(1))
+	g.Expect(rep.Environments[0].Clusters).To(Equal([]string{"p2", allClusters}))
+	g.Expect(rep.Findings).To(BeEmpty())
+
+	// Parity is audited on the whole matrix, so a staging-only report still
+	// knows what production has.
+	sel, err = parseSelection("staging", "")
+	g.Expect(err).NotTo(HaveOccurred())
+	rep = buildMatrixReport(testMatrix(g), "abc123", nil, sel)
+	g.Expect(rep.Environments).To(HaveLen(1))
+	g.Expect(rep.Findings).To(HaveLen(1))
+	g.Expect(rep.Findings[0].Environment).To(Equal(detector.Staging))
+}
+
+func TestWriteMatrixJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeMatrixJSON(&buf, buildMatrixReport(testMatrix(g), "abc123", nil, selection{}))).To(Succeed())
+
+	var decoded map[string]interface{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &decoded)).To(Succeed())
+	g.Expect(decoded["schemaVersion"]).To(Equal(matrixSchemaVersion))
+	g.Expect(decoded["environments"]).To(HaveLen(2))
+	findings := decoded["findings"].([]interface{})
+	g.Expect(findings[0]).To(HaveKeyWithValue("kind", "missing-in-staging"))
+	g.Expect(findings[0]).NotTo(HaveKey("cluster"))
+}
+
+func TestWriteMatrixCSV(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeMatrixCSV(&buf, buildMatrixReport(testMatrix(g), "abc123", nil, selection{}))).To(Succeed())
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(records).To(Equal([][]string{
+		matrixCSVHeader,
+		{"staging", "s1", "smee", "cluster", "components/smee/staging/s1"},
+		{"production", "p1", "monitoring", "shared", "components/monitoring/production/base"},
+		{"production", "p2", "monitoring", "shared", "components/monitoring/production/base"},
+		{"production", "*", "monitoring", "shared", "components/monitoring/production/base"},
+		{"production", "p1", "smee", "cluster", "components/smee/production/p1"},
+		{"production", "p2", "smee", "cluster", "components/smee/production/p2"},
+	}))
+}
+
+func TestWriteMatrixMarkdown(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeMatrixMarkdown(&buf, buildMatrixReport(testMatrix(g), "abc123", nil, selection{}))).To(Succeed())
+
+	out := buf.String()
+	g.Expect(out).To(ContainSubstring("## production\n\n| Component | p1 | p2 | * |\n|-----------|---|---|---|\n"))
+	g.Expect(out).To(ContainSubstring("| monitoring | ○ | ○ | ○ |"))
+	g.Expect(out).To(ContainSubstring("| smee | ● | ● |  |"))
+	g.Expect(out).To(ContainSubstring("| missing-in-staging | staging |  | monitoring | deployed on production but not on staging |"))
+	g.Expect(out).NotTo(ContainSubstring("## Warnings"))
+}
+
+func TestWriteMatrixMarkdown_NoFindings(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeMatrixMarkdown(&buf, buildMatrixReport(&fleet.Matrix{}, "abc123", nil, selection{}))).To(Succeed())
+	g.Expect(buf.String()).To(ContainSubstring("No parity findings."))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+// findGuardedDeletions renders every affected component on both refs and
+// returns the Namespace, CRD and PVC deletions between them. Components that
+// fail to build are logged and skipped; they are reported by render-diff.
+func findGuardedDeletions(ctx context.Context, d *detector.Detector, head, base renderdiff.RepoBuilder, changedFiles []string) ([]renderdiff.GuardedDeletion, error) {
+	affected, err := d.AffectedComponents(changedFiles)
+	if err != nil {
+		return nil, fmt.Errorf("detecting affected components: %w", err)
+	}
+	total := 0
+	for _, paths := range affected {
+		total += len(paths)
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	slog.Info("Rendering affected components to check for destructive changes...", "count", total)
+	result, err := renderdiff.NewEngine(head, base, total).Run(ctx, affected)
+	if err != nil {
+		return nil, err
+	}
+	for _, cd := range result.Diffs {
+		if cd.Error != "" && !cd.SkipOutput {
+			slog.Warn("could not render component; destructive change check is incomplete", "path", cd.Path, "env", cd.Env, "err", cd.Error)
+		}
+	}
+	return result.GuardedDeletions(), nil
+}
+
+// formatDestructiveChanges returns a markdown message listing Namespace, CRD
+// and PVC deletions. When enforced, the message explains that the check failed.
+func formatDestructiveChanges(deletions []renderdiff.GuardedDeletion, enforced bool) string {
+	var b strings.Builder
+	if enforced {
+		b.WriteString("\n## Destructive Change Violation\n\n")
+	} else {
+		b.WriteString("\n## Destructive Change Warning\n\n")
+	}
+	b.WriteString("This PR removes resources whose deletion cascades into everything inside them.\n")
+	b.WriteString("Argo CD will prune them together with every tenant object in a deleted Namespace,\n")
+	b.WriteString("every custom resource of a deleted CRD, and the data on a deleted PVC's volume.\n\n")
+	for _, gd := range deletions {
+		fmt.Fprintf(&b, "- `%s` in `%s` (%s)\n", gd.Change.ID(), gd.Path, gd.Env)
+	}
+	if enforced {
+		b.WriteString("\nIf the deletion is intended, remove the resources in a dedicated PR and get it\n")
+		b.WriteString("reviewed by the owners of everything they contain.\n")
+	}
+	return b.String()
+}
@@ -10,17 +10,19 @@ import (
 	"maps"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	charmlog "github.com/charmbracelet/log"
 
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/buildcache"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/git"
 	ghclient "github.com/redhat-appstudio/infra-deployments/infra-tools/internal/github"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
 )
 
 func main() {
@@ -36,6 +38,14 @@ func main() {
 		logFile              = flag.String("log-file", "", "Write debug-level logs to this file (in addition to INFO-level logs on stdout)")
 		enforceRingDeploy    = flag.Bool("enforce-ring-deployment", false, "Fail when both staging and production overlays are directly modified in the same PR")
 		ringReportFile       = flag.String("ring-report-file", "", "Write ring deployment check result (markdown) to this file for external consumers like PR comments")
+		minSoakTime          = flag.Duration("min-soak-time", 0, "Warn when a production image or ?ref= value has been in the staging overlays on --base-ref for less than this (e.g. 24h; 0 disables)")
+		enforceSoakTime      = flag.Bool("enforce-soak-time", false, "Fail instead of warn when a production value has not soaked in staging for --min-soak-time")
+		destructiveGuard     = flag.Bool("destructive-guard", false, "Render affected components and label the PR "+ghclient.DestructiveChangeLabel+" when a Namespace, CRD or PVC is deleted")
+		enforceDestructive   = flag.Bool("enforce-destructive-guard", false, "Fail when a Namespace, CRD or PVC is deleted from the rendered manifests (implies --destructive-guard)")
+		noCache              = flag.Bool("no-cache", false, "Disable the persistent kustomize build cache")
+		cacheDir             = flag.String("cache-dir", "", "Directory for the kustomize build cache (default: user cache dir)")
+		gitBackend           = flag.String("git-backend", "exec", "Git backend: exec (git binary and worktrees), go (in-process, reads trees from the object database)")
+		includeUncommitted   = flag.Bool("include-uncommitted", false, "Treat staged, unstaged and untracked files as changed (for local runs)")
 	)
 	flag.Parse()
 
@@ -49,6 +59,23 @@ func main() {
 		defer logCleanup()
 	}
 
+	switch git.Backend(*gitBackend) {
+	case git.BackendExec, git.BackendGo:
+		// valid
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --git-backend %q: must be one of exec, go\n", *gitBackend)
+		os.Exit(1)
+	}
+
+	if *minSoakTime < 0 {
+		fmt.Fprintln(os.Stderr, "--min-soak-time must not be negative")
+		os.Exit(1)
+	}
+	if *enforceSoakTime && *minSoakTime == 0 {
+		fmt.Fprintln(os.Stderr, "--enforce-soak-time requires --min-soak-time")
+		os.Exit(1)
+	}
+
 	if !*dryRun {
 		if *prNumber == 0 || *githubToken == "" || *repo == "" {
 			fatal("--pr-number, --github-token, and --repo are required when not using --dry-run")
@@ -59,38 +86,46 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	// Resolve repo root to absolute path
-	absRepoRoot, err := filepath.Abs(*repoRoot)
+	// Open the repository at the given root
+	gitRepo, err := git.Open(ctx, git.Backend(*gitBackend), *repoRoot)
 	if err != nil {
-		fatal("resolving repo root", "err", err)
+		fatal("opening repository", "err", err)
 	}
 
 	// Resolve base ref: use merge-base so the diff only contains the PR's
 	// own changes, even when the branch is not rebased on top of base-ref
 	// or the GitHub merge ref is stale.  This mirrors the approach used by
 	// render-diff.
-	effectiveBaseRef, err := git.MergeBase(ctx, absRepoRoot, *baseRef)
+	effectiveBaseRef, err := gitRepo.MergeBase(ctx, "HEAD", *baseRef)
 	if err != nil {
 		fatal("computing merge-base", "ref", *baseRef, "err", err)
 	}
 	slog.Debug("Resolved merge-base", "baseRef", *baseRef, "mergeBase", effectiveBaseRef)
 
 	// Resolve HEAD and effective base to short commit SHAs for the summary.
-	headSHA, err := git.ResolveRef(ctx, absRepoRoot, "HEAD")
+	headSHA, err := gitRepo.ResolveRef(ctx, "HEAD")
 	if err != nil {
 		fatal("resolving HEAD", "err", err)
 	}
-	baseSHA, err := git.ResolveRef(ctx, absRepoRoot, effectiveBaseRef)
+	baseSHA, err := gitRepo.ResolveRef(ctx, effectiveBaseRef)
 	if err != nil {
 		fatal("resolving base ref", "err", err)
 	}
 
 	// Step 1: Get changed files via git diff
 	slog.Info("Getting changed files...")
-	changedFiles, err := git.ChangedFiles(ctx, absRepoRoot, effectiveBaseRef)
+	var changedFiles, uncommitted []string
+	if *includeUncommitted {
+		changedFiles, uncommitted, err = git.ChangedFilesWithUncommitted(ctx, gitRepo, effectiveBaseRef)
+	} else {
+		changedFiles, err = gitRepo.ChangedFiles(ctx, effectiveBaseRef, "HEAD")
+	}
 	if err != nil {
 		fatal("getting changed files", "err", err)
 	}
+	if len(uncommitted) > 0 {
+		slog.Info("Including uncommitted changes", "count", len(uncommitted))
+	}
 	if len(changedFiles) == 0 {
 		slog.Info("No changed files detected")
 		if !*dryRun {
@@ -101,21 +136,26 @@ func main() {
 		return
 	}
 
-	// Step 2: Create a temporary git worktree at the merge-base
-	slog.Info("Creating worktree...", "ref", effectiveBaseRef)
-	worktreePath, cleanup, err := git.CreateWorktree(ctx, absRepoRoot, effectiveBaseRef)
+	// Step 2: Check out the merge-base
+	slog.Info("Checking out base...", "ref", effectiveBaseRef)
+	baseTree, cleanup, err := gitRepo.Checkout(ctx, effectiveBaseRef)
 	if err != nil {
-		fatal("creating worktree", "err", err)
+		fatal("checking out base ref", "err", err)
 	}
 	defer cleanup()
 
 	// Step 3: Run detection
 	slog.Info("Running detection...")
-	d, err := detector.NewDetector(
-		detector.NewRepoRef(absRepoRoot),
-		detector.NewRepoRef(worktreePath),
-		*overlaysDir,
-	)
+	var buildCache *buildcache.Cache
+	if !*noCache {
+		buildCache, err = buildcache.Open(*cacheDir)
+		if err != nil {
+			slog.Warn("build cache unavailable, building without cache", "err", err)
+		}
+	}
+	headRepo := detector.NewRepoRef(gitRepo.Root(), detector.WithBuildCache(buildCache))
+	baseRepo := detector.NewRepoRef(baseTree.Root, detector.WithFileSystem(baseTree.FS), detector.WithBuildCache(buildCache))
+	d, err := detector.NewDetector(headRepo, baseRepo, *overlaysDir)
 	if err != nil {
 		fatal("initializing detector", "err", err)
 	}
@@ -124,6 +164,17 @@ func main() {
 		fatal("detection failed", "err", err)
 	}
 
+	// Render affected components when the destructive change guard is on,
+	// so the label is part of the synced set below.
+	var guarded []renderdiff.GuardedDeletion
+	if *destructiveGuard || *enforceDestructive {
+		guarded, err = findGuardedDeletions(ctx, d, headRepo, baseRepo, changedFiles)
+		if err != nil {
+			fatal("checking for destructive changes", "err", err)
+		}
+	}
+	buildCache.LogStats()
+
 	// Step 4: Output results
 	labelSet := result.Labels()
 
@@ -147,7 +198,12 @@ func main() {
 		labels = append(labels, ghclient.NeedsApprovalProductionLabel)
 	}
 
-	printSummary(result, labels, headSHA, baseSHA)
+	// Deleting a Namespace, CRD or PVC cascades into everything inside it.
+	if len(guarded) > 0 {
+		labels = append(labels, ghclient.DestructiveChangeLabel)
+	}
+
+	printSummary(result, labels, uncommitted, headSHA, baseSHA)
 
 	if !*dryRun {
 		// Step 5: Sync labels via GitHub API
@@ -158,23 +214,52 @@ func main() {
 	}
 
 	// Step 6: Ring deployment enforcement (runs in both dry-run and normal mode)
+	failed := false
+	var ringReport []string
 	if *enforceRingDeploy {
 		ringResult := detector.CheckRingDeployment(changedFiles, result.AffectedEnvironments)
 		if ringResult.DirectConflict {
-			msg := formatRingViolation(ringResult)
-			fmt.Println(msg)
-			writeStepSummary(msg)
-			writeReportFile(*ringReportFile, msg)
-			os.Exit(1)
+			ringReport = append(ringReport, formatRingViolation(ringResult))
+			failed = true
 		}
 		if ringResult.IndirectConflict {
-			msg := formatRingWarning()
-			fmt.Println(msg)
-			writeStepSummary(msg)
-			writeReportFile(*ringReportFile, msg)
+			ringReport = append(ringReport, formatRingWarning())
+		}
+	}
+	if *minSoakTime > 0 {
+		now := time.Now()
+		unsoaked, err := findUnsoaked(ctx, gitRepo, *baseRef, changedFiles, baseRepo, headRepo, *minSoakTime, now)
+		if err != nil {
+			fatal("checking soak time", "err", err)
+		}
+		if len(unsoaked) > 0 {
+			ringReport = append(ringReport, formatSoakTime(unsoaked, *baseRef, *minSoakTime, now, *enforceSoakTime))
+			if *enforceSoakTime {
+				failed = true
+			}
+		}
+	}
+	for _, msg := range ringReport {
+		fmt.Println(msg)
+		writeStepSummary(msg)
+	}
+	if len(ringReport) > 0 {
+		writeReportFile(*ringReportFile, strings.Join(ringReport, "\n"))
+	}
+
+	// Step 7: Destructive change guard (runs in both dry-run and normal mode)
+	if len(guarded) > 0 {
+		msg := formatDestructiveChanges(guarded, *enforceDestructive)
+		fmt.Println(msg)
+		writeStepSummary(msg)
+		if *enforceDestructive {
+			failed = true
 		}
 	}
 
+	if failed {
+		os.Exit(1)
+	}
 	slog.Info("Done!")
 }
 
@@ -260,14 +345,19 @@ func (m *multiHandler) WithGroup(name string) slog.Handler {
 }
 
 // printSummary prints the detection results in a human-friendly format.
-func printSummary(result *detector.Result, labels []string, headSHA, baseSHA string) {
+// Files listed in uncommitted are marked as coming from the working tree.
+func printSummary(result *detector.Result, labels, uncommitted []string, headSHA, baseSHA string) {
 	fmt.Printf("\nHEAD: %s\n", headSHA)
 	fmt.Printf("Base: %s\n", baseSHA)
 
 	fmt.Println("\nChanged files:")
 	sort.Strings(result.ChangedFiles)
 	for _, f := range result.ChangedFiles {
-		fmt.Printf("  %s\n", f)
+		if slices.Contains(uncommitted, f) {
+			fmt.Printf("  %s (uncommitted)\n", f)
+		} else {
+			fmt.Printf("  %s\n", f)
+		}
 	}
 
 	fmt.Println("\nAffected environments:")
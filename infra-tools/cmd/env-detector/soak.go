@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/promotion"
+)
+
+// findUnsoaked returns the values the production edits among changedFiles
+// set that have not been in staging on ref for at least minSoak as of now.
+func findUnsoaked(ctx context.Context, repo promotion.History, ref string, changedFiles []string, base, head detector.RepoQuerier, minSoak time.Duration, now time.Time) ([]promotion.Soak, error) {
+	slog.Info("Checking staging soak time of production values...", "ref", ref, "minSoak", minSoak)
+	soaks, err := promotion.CheckSoak(ctx, repo, ref, changedFiles, base, head, now.Add(-minSoak))
+	if err != nil {
+		return nil, err
+	}
+	var unsoaked []promotion.Soak
+	for _, s := range soaks {
+		if !s.InStaging() || now.Sub(s.Since) < minSoak {
+			unsoaked = append(unsoaked, s)
+			continue
+		}
+		slog.Debug("Value soaked in staging", "file", s.File, "value", s.Name(), "to", s.To, "since", s.Since)
+	}
+	return unsoaked, nil
+}
+
+// formatSoakTime returns a markdown message listing production values that
+// have not soaked in staging for minSoak. When enforced, the message explains
+// that the check failed.
+func formatSoakTime(unsoaked []promotion.Soak, ref string, minSoak time.Duration, now time.Time, enforced bool) string {
+	var b strings.Builder
+	if enforced {
+		b.WriteString("\n## Soak Time Violation\n\n")
+	} else {
+		b.WriteString("\n## Soak Time Warning\n\n")
+	}
+	fmt.Fprintf(&b, "This PR sets production values that have not been in the staging overlays on `%s` for %s.\n", ref, minSoak)
+	b.WriteString("Changes must run in staging for the minimum soak time before they are promoted to production.\n\n")
+	b.WriteString("| Production file | Value | In staging |\n")
+	b.WriteString("|-----------------|-------|------------|\n")
+	for _, s := range unsoaked {
+		staging := fmt.Sprintf("not in `%s` on `%s`", s.Staging, ref)
+		if s.InStaging() {
+			staging = fmt.Sprintf("for %s (since `%s`)", now.Sub(s.Since).Round(time.Minute), shortSHA(s.Commit))
+		}
+		fmt.Fprintf(&b, "| `%s` | `%s` → `%s` | %s |\n", s.File, s.Name(), s.To, staging)
+	}
+	return b.String()
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
@@ -0,0 +1,113 @@
+// Command kustomize-fix rewrites the deprecated fields of the kustomization
+// files under the given directories (bases, commonLabels, imageTags,
+// patchesJson6902, patchesStrategicMerge) to their modern equivalents. Each
+// rewrite is kept only if every kustomization in the repository that
+// includes the file renders byte-identical output, after normalization,
+// before and after it; the others are refused and left untouched.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/git"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/kustfix"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/logging"
+)
+
+func main() {
+	var (
+		repoRoot   = flag.String("repo-root", "", "Path to the repository root (default: auto-detect via git)")
+		dryRun     = flag.Bool("dry-run", false, "Report the rewrites without writing any file")
+		format     = flag.String("format", "text", "Output format: json, text")
+		outputFile = flag.String("output", "", "Write the report to this file instead of stdout")
+		logFile    = flag.String("log-file", "", "Write debug-level logs to this file")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <dir>...\n\nDirectories are relative to the repository root.\n\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	write, ok := writers[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "invalid --format %q: must be one of json, text\n", *format)
+		os.Exit(1)
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	logCleanup, err := logging.Setup(*logFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up logging: %v\n", err)
+		os.Exit(1)
+	}
+	if logCleanup != nil {
+		defer logCleanup()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	root := *repoRoot
+	if root == "" {
+		repo, err := git.Open(ctx, git.BackendExec, "")
+		if err != nil {
+			logging.Fatal("opening repository; use --repo-root to specify explicitly", "err", err)
+		}
+		root = repo.Root()
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		logging.Fatal("resolving repository root", "err", err)
+	}
+
+	fSys := filesys.MakeFsOnDisk()
+	var results []kustfix.FileResult
+	for _, dir := range flag.Args() {
+		slog.Info("Rewriting deprecated fields...", "dir", dir)
+		res, err := kustfix.Fix(fSys, root, filepath.Clean(dir))
+		if err != nil {
+			logging.Fatal("fixing kustomizations", "dir", dir, "err", err)
+		}
+		results = append(results, res...)
+	}
+
+	rep := buildReport(results, *dryRun)
+	if !*dryRun {
+		if err := kustfix.Apply(fSys, root, results); err != nil {
+			logging.Fatal("writing kustomizations", "err", err)
+		}
+	}
+	slog.Info("Done", "fixed", rep.Fixed, "refused", rep.Refused, "manual", rep.Manual)
+
+	var out io.Writer = os.Stdout
+	if *outputFile != "" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			logging.Fatal("creating output file", "err", err)
+		}
+		defer func() { _ = f.Close() }()
+		out = f
+	}
+	if err := write(out, rep); err != nil {
+		logging.Fatal("writing report", "err", err)
+	}
+
+	if rep.Refused > 0 {
+		// Files are written unbuffered, so skipping the deferred closes
+		// loses nothing.
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/deptree"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/kustfix"
+)
+
+// reportSchemaVersion identifies the layout of the json output. Bump it
+// whenever a field is renamed or removed; adding fields does not require a
+// bump.
+const reportSchemaVersion = "kustomize-fix/v1"
+
+// report is the document written by every output format.
+type report struct {
+	SchemaVersion string `json:"schemaVersion"`
+	// DryRun is set when the fixed files were not written.
+	DryRun  bool `json:"dryRun"`
+	Fixed   int  `json:"fixed"`
+	Refused int  `json:"refused"`
+	Manual  int  `json:"manual"`
+	// Files lists every kustomization file that uses deprecated fields.
+	Files []kustfix.FileResult `json:"files"`
+}
+
+func buildReport(results []kustfix.FileResult, dryRun bool) report {
+	rep := report{SchemaVersion: reportSchemaVersion, DryRun: dryRun, Files: []kustfix.FileResult{}}
+	for _, r := range results {
+		switch r.Status {
+		case kustfix.StatusFixed:
+			rep.Fixed++
+		case kustfix.StatusRefused:
+			rep.Refused++
+		case kustfix.StatusManual:
+			rep.Manual++
+		}
+		rep.Files = append(rep.Files, r)
+	}
+	return rep
+}
+
+// writers maps each --format value to the function that writes it.
+var writers = map[string]func(io.Writer, report) error{
+	"json": writeJSON,
+	"text": writeText,
+}
+
+func writeJSON(w io.Writer, rep report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// writeText writes one line per file with its status and fields, followed
+// by the reason for refused files and the builds that proved fixed ones.
+func writeText(w io.Writer, rep report) error {
+	var b strings.Builder
+	for _, f := range rep.Files {
+		fmt.Fprintf(&b, "%-8s %s", f.Status, f.Path)
+		if len(f.Fixed) > 0 {
+			fmt.Fprintf(&b, ": %s", fields(f.Fixed))
+		}
+		if len(f.Remaining) > 0 {
+			sep := ": "
+			if len(f.Fixed) > 0 {
+				sep = "; "
+			}
+			fmt.Fprintf(&b, "%smanual %s", sep, fields(f.Remaining))
+		}
+		b.WriteString("\n")
+		switch f.Status {
+		case kustfix.StatusFixed:
+			fmt.Fprintf(&b, "         renders unchanged: %s\n", strings.Join(f.Builds, ", "))
+		case kustfix.StatusRefused:
+			fmt.Fprintf(&b, "         %s\n", f.Reason)
+		}
+	}
+	verb := "fixed"
+	if rep.DryRun {
+		verb = "would fix"
+	}
+	fmt.Fprintf(&b, "\n%s %d, refused %d, manual %d\n", verb, rep.Fixed, rep.Refused, rep.Manual)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fields formats deprecated fields as "bases → resources, vars → replacements".
+func fields(deprecations []deptree.Deprecation) string {
+	parts := make([]string, 0, len(deprecations))
+	for _, d := range deprecations {
+		parts = append(parts, d.Field+" → "+d.Replacement)
+	}
+	return strings.Join(parts, ", ")
+}
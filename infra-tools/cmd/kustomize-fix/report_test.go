@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/deptree"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/kustfix"
+)
+
+func testResults() []kustfix.FileResult {
+	return []kustfix.FileResult{
+		{
+			Path:   "components/foo/base/kustomization.yaml",
+			Status: kustfix.StatusFixed,
+			Fixed: []deptree.Deprecation{
+				{File: "components/foo/base/kustomization.yaml", Field: "commonLabels", Replacement: "labels"},
+			},
+			Remaining: []deptree.Deprecation{
+				{File: "components/foo/base/kustomization.yaml", Field: "vars", Replacement: "replacements"},
+			},
+			Builds:  []string{"components/foo/base", "components/foo/staging"},
+			Content: []byte("labels: []\n"),
+		},
+		{
+			Path:   "components/foo/staging/kustomization.yaml",
+			Status: kustfix.StatusRefused,
+			Fixed: []deptree.Deprecation{
+				{File: "components/foo/staging/kustomization.yaml", Field: "patchesJson6902", Replacement: "patches"},
+			},
+			Builds: []string{"components/foo/staging"},
+			Reason: "render of components/foo/staging changed",
+		},
+		{
+			Path:   "components/foo/vars/kustomization.yaml",
+			Status: kustfix.StatusManual,
+			Remaining: []deptree.Deprecation{
+				{File: "components/foo/vars/kustomization.yaml", Field: "vars", Replacement: "replacements"},
+			},
+		},
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeText(&buf, buildReport(testResults(), true))).To(Succeed())
+	g.Expect(buf.String()).To(Equal(`fixed    components/foo/base/kustomization.yaml: commonLabels → labels; manual vars → replacements
+         renders unchanged: components/foo/base, components/foo/staging
+refused  components/foo/staging/kustomization.yaml: patchesJson6902 → patches
+         render of components/foo/staging changed
+manual   components/foo/vars/kustomization.yaml: manual vars → replacements
+
+would fix 1, refused 1, manual 1
+`))
+}
+
+func TestWriteJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeJSON(&buf, buildReport(testResults(), false))).To(Succeed())
+	var rep map[string]interface{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &rep)).To(Succeed())
+	g.Expect(rep).To(HaveKeyWithValue("schemaVersion", "kustomize-fix/v1"))
+	g.Expect(rep).To(HaveKeyWithValue("fixed", 1.0))
+	g.Expect(rep).To(HaveKeyWithValue("refused", 1.0))
+	g.Expect(rep["files"]).To(HaveLen(3))
+	g.Expect(buf.String()).NotTo(ContainSubstring("labels: []"), "rewritten contents are not reported")
+}
+
+func TestBuildReport_Empty(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeJSON(&buf, buildReport(nil, false))).To(Succeed())
+	g.Expect(buf.String()).To(ContainSubstring(`"files": []`))
+}
@@ -0,0 +1,149 @@
+// Command promotion-drift renders the staging and production overlays of
+// every component on the same ref and reports how production differs from
+// staging: image digests, ?ref= pins and other resource differences, after
+// normalizing the expected environment-specific differences with
+// configurable rules. The result is what is pending promotion.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/buildcache"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fleet"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/git"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/logging"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/promotion"
+)
+
+func main() {
+	var (
+		repoRoot    = flag.String("repo-root", "", "Path to the repository root (default: auto-detect via git)")
+		ref         = flag.String("ref", "", "Git ref to compare from a worktree (default: the working copy)")
+		gitBackend  = flag.String("git-backend", "exec", "Git backend: exec (git binary and worktrees), go (in-process, reads trees from the object database)")
+		overlaysDir = flag.String("overlays-dir", "argo-cd-apps/overlays", "Path to overlays directory relative to repo root")
+		rulesFile   = flag.String("rules", "", "Normalize environment-specific differences with the rewrite/ignore rules in this YAML file")
+		component   = flag.String("component", "", "Only compare these components (comma-separated ApplicationSet names)")
+		format      = flag.String("format", "markdown", "Output format: json, markdown")
+		outputFile  = flag.String("output", "", "Write the report to this file instead of stdout")
+		logFile     = flag.String("log-file", "", "Write debug-level logs to this file")
+		noCache     = flag.Bool("no-cache", false, "Disable the persistent kustomize build cache")
+		cacheDir    = flag.String("cache-dir", "", "Directory for the kustomize build cache (default: user cache dir)")
+	)
+	flag.Parse()
+
+	write, ok := writers[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "invalid --format %q: must be one of json, markdown\n", *format)
+		os.Exit(1)
+	}
+
+	switch git.Backend(*gitBackend) {
+	case git.BackendExec, git.BackendGo:
+		// valid
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --git-backend %q: must be one of exec, go\n", *gitBackend)
+		os.Exit(1)
+	}
+
+	// Load rules up front so a broken rules file fails before any git work.
+	var rules *promotion.Rules
+	if *rulesFile != "" {
+		var err error
+		rules, err = promotion.Load(*rulesFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	logCleanup, err := logging.Setup(*logFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up logging: %v\n", err)
+		os.Exit(1)
+	}
+	if logCleanup != nil {
+		defer logCleanup()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	repo, err := git.Open(ctx, git.Backend(*gitBackend), *repoRoot)
+	if err != nil {
+		logging.Fatal("opening repository; use --repo-root to specify explicitly", "err", err)
+	}
+
+	// Compare the working copy unless a ref is given.
+	tree := git.WorkingTree(repo.Root())
+	revision := "working copy"
+	if *ref != "" {
+		revision, err = repo.ResolveRef(ctx, *ref)
+		if err != nil {
+			logging.Fatal("resolving ref", "ref", *ref, "err", err)
+		}
+		var cleanup func()
+		tree, cleanup, err = repo.Checkout(ctx, *ref)
+		if err != nil {
+			logging.Fatal("checking out ref", "ref", *ref, "err", err)
+		}
+		defer cleanup()
+	}
+
+	var buildCache *buildcache.Cache
+	if !*noCache {
+		buildCache, err = buildcache.Open(*cacheDir)
+		if err != nil {
+			slog.Warn("build cache unavailable, building without cache", "err", err)
+		}
+	}
+	repoRef := detector.NewRepoRef(tree.Root, detector.WithFileSystem(tree.FS), detector.WithBuildCache(buildCache))
+
+	slog.Info("Expanding ApplicationSets...", "ref", revision)
+	overlays, err := detector.Inventory(repoRef, *overlaysDir)
+	if err != nil {
+		logging.Fatal("expanding ApplicationSets", "err", err)
+	}
+	for _, o := range overlays {
+		for _, w := range o.Warnings {
+			slog.Warn(w, "overlay", o.Overlay)
+		}
+	}
+
+	pairs, unpaired := promotion.Pairs(fleet.Build(overlays, repoRef))
+	if components := splitList(*component); len(components) > 0 {
+		pairs = slices.DeleteFunc(pairs, func(p promotion.Pair) bool { return !slices.Contains(components, p.Component) })
+		unpaired = slices.DeleteFunc(unpaired, func(c string) bool { return !slices.Contains(components, c) })
+	}
+
+	slog.Info("Comparing staging and production renders...", "paths", len(pairs))
+	drifts, err := promotion.Compare(ctx, repoRef, pairs, rules)
+	if err != nil {
+		logging.Fatal("comparing renders", "err", err)
+	}
+	buildCache.LogStats()
+
+	rep := buildReport(drifts, unpaired, revision)
+	slog.Info("Pending promotion", "paths", len(rep.Drift), "in-sync", len(rep.InSync))
+
+	var out io.Writer = os.Stdout
+	if *outputFile != "" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			logging.Fatal("creating output file", "err", err)
+		}
+		defer func() { _ = f.Close() }()
+		out = f
+	}
+	if err := write(out, rep); err != nil {
+		logging.Fatal("writing report", "err", err)
+	}
+}
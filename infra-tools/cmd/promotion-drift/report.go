@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/promotion"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+// reportSchemaVersion identifies the layout of the json output. Bump it
+// whenever a field is renamed or removed; adding fields does not require a
+// bump.
+const reportSchemaVersion = "promotion-drift/v1"
+
+// report is the document written by every output format.
+type report struct {
+	SchemaVersion string `json:"schemaVersion"`
+	Revision      string `json:"revision"`
+	// Drift lists the compared paths where production differs from
+	// staging or that failed to build.
+	Drift []promotion.Drift `json:"drift"`
+	// InSync lists the compared paths without drift.
+	InSync []promotion.Pair `json:"inSync"`
+	// Unpaired lists the components deployed in both environments whose
+	// staging paths have no production counterpart to compare with.
+	Unpaired []string `json:"unpaired,omitempty"`
+}
+
+func buildReport(drifts []promotion.Drift, unpaired []string, revision string) report {
+	rep := report{
+		SchemaVersion: reportSchemaVersion,
+		Revision:      revision,
+		Drift:         []promotion.Drift{},
+		InSync:        []promotion.Pair{},
+		Unpaired:      unpaired,
+	}
+	for _, d := range drifts {
+		if d.HasDrift() || d.Error != "" {
+			rep.Drift = append(rep.Drift, d)
+		} else {
+			rep.InSync = append(rep.InSync, d.Pair)
+		}
+	}
+	return rep
+}
+
+// writers maps each --format value to the function that writes it.
+var writers = map[string]func(io.Writer, report) error{
+	"json":     writeJSON,
+	"markdown": writeMarkdown,
+}
+
+func writeJSON(w io.Writer, rep report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+func writeMarkdown(w io.Writer, rep report) error {
+	var b strings.Builder
+	b.WriteString("# Promotion drift: staging → production\n\n")
+	fmt.Fprintf(&b, "Revision: `%s` — %d of %d compared paths pending promotion\n",
+		rep.Revision, len(rep.Drift), len(rep.Drift)+len(rep.InSync))
+
+	for _, d := range rep.Drift {
+		fmt.Fprintf(&b, "\n## %s\n\n`%s` → `%s`\n", d.Component, d.Staging, d.Production)
+		if d.Error != "" {
+			fmt.Fprintf(&b, "\n**Build failed:** %s\n", d.Error)
+		}
+		if len(d.Images) > 0 {
+			b.WriteString("\n| Image | Resource | Production | Staging |\n")
+			b.WriteString("|-------|----------|------------|---------|\n")
+			for _, img := range d.Images {
+				fmt.Fprintf(&b, "| `%s` | %s | `%s` | `%s` |\n",
+					escapeCell(img.Field), escapeCell(img.Resource), escapeCell(img.Production), escapeCell(img.Staging))
+			}
+		}
+		if len(d.Refs) > 0 {
+			b.WriteString("\n| Remote base | Production ref | Staging ref |\n")
+			b.WriteString("|-------------|----------------|-------------|\n")
+			for _, r := range d.Refs {
+				fmt.Fprintf(&b, "| `%s` | %s | %s |\n", escapeCell(r.Remote), refCell(r.Production), refCell(r.Staging))
+			}
+		}
+		if len(d.Resources) > 0 {
+			fmt.Fprintf(&b, "\n```diff\n%s```\n", renderdiff.FormatSemantic(d.Resources))
+		}
+		if d.Ignored > 0 {
+			fmt.Fprintf(&b, "\n%d differences ignored by rules.\n", d.Ignored)
+		}
+	}
+
+	if len(rep.InSync) > 0 {
+		b.WriteString("\n## In sync\n\n")
+		for _, p := range rep.InSync {
+			fmt.Fprintf(&b, "- %s: `%s` → `%s`\n", p.Component, p.Staging, p.Production)
+		}
+	}
+	if len(rep.Unpaired) > 0 {
+		b.WriteString("\n## Not compared\n\n")
+		b.WriteString("Deployed in both environments, but only from cluster-specific or differently named paths:\n\n")
+		for _, c := range rep.Unpaired {
+			fmt.Fprintf(&b, "- %s\n", c)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// refCell formats a ?ref= revision, or a dash when the environment doesn't
+// use the base.
+func refCell(ref string) string {
+	if ref == "" {
+		return "—"
+	}
+	return "`" + escapeCell(ref) + "`"
+}
+
+// escapeCell keeps a value from breaking out of its markdown table cell.
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/promotion"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+func testDrifts() []promotion.Drift {
+	return []promotion.Drift{
+		{
+			Pair: promotion.Pair{Component: "smee", Staging: "components/smee/staging", Production: "components/smee/production"},
+			Images: []promotion.ImageDrift{{
+				Resource:   "apps/v1 Deployment smee/smee",
+				Field:      "spec.template.spec.containers[name=smee].image",
+				Staging:    "quay.io/smee@sha256:bbbb",
+				Production: "quay.io/smee@sha256:aaaa",
+			}},
+			Refs: []promotion.RefDrift{{Remote: "https://github.com/example/smee/config", Staging: "new"}},
+			Resources: []renderdiff.ResourceChange{
+				{APIVersion: "v1", Kind: "ConfigMap", Namespace: "smee", Name: "legacy", Type: renderdiff.ChangeRemoved},
+			},
+			Ignored: 2,
+		},
+		{Pair: promotion.Pair{Component: "has", Staging: "components/has/staging", Production: "components/has/production"}},
+		{
+			Pair:  promotion.Pair{Component: "release", Staging: "components/release/staging", Production: "components/release/production"},
+			Error: "building components/release/staging: boom",
+		},
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	g := NewWithT(t)
+
+	rep := buildReport(testDrifts(), []string{"backup"}, "abc123")
+	g.Expect(rep.SchemaVersion).To(Equal(reportSchemaVersion))
+	g.Expect(rep.Drift).To(HaveLen(2))
+	g.Expect(rep.Drift[1].Component).To(Equal("release"))
+	g.Expect(rep.InSync).To(Equal([]promotion.Pair{testDrifts()[1].Pair}))
+	g.Expect(rep.Unpaired).To(Equal([]string{"backup"}))
+}
+
+func TestWriteJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeJSON(&buf, buildReport(testDrifts(), nil, "abc123"))).To(Succeed())
+
+	var decoded map[string]interface{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &decoded)).To(Succeed())
+	g.Expect(decoded["schemaVersion"]).To(Equal(reportSchemaVersion))
+	g.Expect(decoded).NotTo(HaveKey("unpaired"))
+	drift := decoded["drift"].([]interface{})
+	g.Expect(drift[0]).To(HaveKeyWithValue("component", "smee"))
+	g.Expect(drift[0]).To(HaveKeyWithValue("production", "components/smee/production"))
+	g.Expect(drift[0]).To(HaveKey("images"))
+	g.Expect(drift[1]).NotTo(HaveKey("images"))
+}
+
+func TestWriteJSON_Empty(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeJSON(&buf, buildReport(nil, nil, "abc123"))).To(Succeed())
+	g.Expect(buf.String()).To(ContainSubstring(`"drift": []`))
+	g.Expect(buf.String()).To(ContainSubstring(`"inSync": []`))
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(writeMarkdown(&buf, buildReport(testDrifts(), []string{"backup"}, "abc123"))).To(Succeed())
+
+	out := buf.String()
+	g.Expect(out).To(ContainSubstring("Revision: `abc123` — 2 of 3 compared paths pending promotion"))
+	g.Expect(out).To(ContainSubstring("## smee\n\n`components/smee/staging` → `components/smee/production`\n"))
+	g.Expect(out).To(ContainSubstring("| `spec.template.spec.containers[name=smee].image` | apps/v1 Deployment smee/smee | `quay.io/smee@sha256:aaaa` | `quay.io/smee@sha256:bbbb` |"))
+	g.Expect(out).To(ContainSubstring("| `https://github.com/example/smee/config` | — | `new` |"))
+	g.Expect(out).To(ContainSubstring("```diff\n- v1 ConfigMap smee/legacy\n```"))
+	g.Expect(out).To(ContainSubstring("2 differences ignored by rules."))
+	g.Expect(out).To(ContainSubstring("**Build failed:** building components/release/staging: boom"))
+	g.Expect(out).To(ContainSubstring("## In sync\n\n- has: `components/has/staging` → `components/has/production`"))
+	g.Expect(out).To(ContainSubstring("## Not compared"))
+	g.Expect(out).To(ContainSubstring("- backup\n"))
+}
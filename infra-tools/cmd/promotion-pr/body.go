@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/promotion"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+// writePlan prints the promotion plan for the terminal.
+func writePlan(w io.Writer, plan *promotion.Plan) {
+	if len(plan.Files) == 0 {
+		_, _ = fmt.Fprintln(w, "Nothing to promote.")
+	}
+	for _, f := range plan.Files {
+		for _, e := range f.Edits {
+			_, _ = fmt.Fprintf(w, "%s:%d: %s: %s → %s\n", f.Path, e.Line, e.Name(), e.Production, e.To)
+		}
+	}
+	if len(plan.Skipped) > 0 {
+		_, _ = fmt.Fprintln(w, "\nNot promoted:")
+		for _, s := range plan.Skipped {
+			_, _ = fmt.Fprintf(w, "  %s: %s\n", s.Name(), s.Reason)
+		}
+	}
+	if len(plan.Manual) > 0 {
+		_, _ = fmt.Fprintln(w, "\nPromote by hand:")
+		for _, m := range plan.Manual {
+			_, _ = fmt.Fprintf(w, "  %s: %s\n", m.Path, m.Reason)
+		}
+	}
+}
+
+// commitMessage returns the message of the promotion commit.
+func commitMessage(plan *promotion.Plan, baseSHA, headSHA string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Promote %s..%s to production\n\n", baseSHA, headSHA)
+	for _, f := range plan.Files {
+		for _, e := range f.Edits {
+			fmt.Fprintf(&b, "- %s: %s → %s\n", e.Name(), e.Production, e.To)
+		}
+	}
+	return b.String()
+}
+
+// buildPRBody generates the markdown description of the promotion PR: the
+// values promoted, what was left out, and the render-diff summary of the
+// promotion.
+func buildPRBody(plan *promotion.Plan, result *renderdiff.DiffResult, baseSHA, headSHA string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "### Promote `%s..%s` to production\n\n", baseSHA, headSHA)
+	fmt.Fprintln(&b, "Sets the image and `?ref=` values these commits changed in staging in the production overlays.")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "| File | Value | Production | Staging |")
+	fmt.Fprintln(&b, "|------|-------|------------|---------|")
+	for _, f := range plan.Files {
+		for _, e := range f.Edits {
+			fmt.Fprintf(&b, "| `%s:%d` | `%s` | `%s` | `%s` |\n", escapeCell(f.Path), e.Line, escapeCell(e.Name()), escapeCell(e.Production), escapeCell(e.To))
+		}
+	}
+	fmt.Fprintln(&b)
+
+	if len(plan.Skipped) > 0 {
+		fmt.Fprintln(&b, "#### Not promoted")
+		fmt.Fprintln(&b)
+		for _, s := range plan.Skipped {
+			fmt.Fprintf(&b, "- `%s` → `%s`: %s\n", s.Name(), s.To, s.Reason)
+		}
+		fmt.Fprintln(&b)
+	}
+	if len(plan.Manual) > 0 {
+		fmt.Fprintln(&b, "#### Promote by hand")
+		fmt.Fprintln(&b)
+		for _, m := range plan.Manual {
+			fmt.Fprintf(&b, "- `%s`: %s\n", m.Path, m.Reason)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	fmt.Fprintln(&b, "#### Render diff")
+	fmt.Fprintln(&b)
+	if len(result.Diffs) == 0 {
+		fmt.Fprintln(&b, "No render differences detected.")
+		return b.String()
+	}
+	fmt.Fprint(&b, renderdiff.Summary(result))
+	return b.String()
+}
+
+// escapeCell escapes pipe characters so a value doesn't break a markdown
+// table row.
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/promotion"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+func testPlan() *promotion.Plan {
+	return &promotion.Plan{
+		Files: []promotion.FilePatch{{
+			Path: "components/smee/production/base/kustomization.yaml",
+			Edits: []promotion.Edit{
+				{Change: promotion.Change{Kind: promotion.ChangeRef, Key: "https://github.com/example/smee/config", From: "aaa", To: "bbb"}, Line: 3, Production: "aaa"},
+				{Change: promotion.Change{Kind: promotion.ChangeImageEntry, Key: "quay.io/smee", Field: "newTag", From: "1", To: "2"}, Line: 6, Production: "0"},
+			},
+		}},
+		Skipped: []promotion.Skipped{{
+			Change: promotion.Change{Kind: promotion.ChangeImage, Key: "quay.io/other", From: "quay.io/other:1", To: "quay.io/other:2"},
+			Reason: "nothing under components/smee/production refers to it",
+		}},
+		Manual: []promotion.ManualEdit{{Path: "components/smee/staging/base/new.yaml", Reason: "added in staging"}},
+	}
+}
+
+func TestWritePlan(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	writePlan(&buf, testPlan())
+	g.Expect(buf.String()).To(Equal(`components/smee/production/base/kustomization.yaml:3: https://github.com/example/smee/config ref: aaa → bbb
+components/smee/production/base/kustomization.yaml:6: quay.io/smee newTag: 0 → 2
+
+Not promoted:
+  quay.io/other: nothing under components/smee/production refers to it
+
+Promote by hand:
+  components/smee/staging/base/new.yaml: added in staging
+`))
+
+	buf.Reset()
+	writePlan(&buf, &promotion.Plan{})
+	g.Expect(buf.String()).To(Equal("Nothing to promote.\n"))
+}
+
+func TestCommitMessage(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(commitMessage(testPlan(), "abc123", "def456")).To(Equal(`Promote abc123..def456 to production
+
+- https://github.com/example/smee/config ref: aaa → bbb
+- quay.io/smee newTag: 0 → 2
+`))
+}
+
+func TestBuildPRBody(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &renderdiff.DiffResult{
+		Diffs: []renderdiff.ComponentDiff{
+			{Path: "components/smee/production/base", Env: "production", Added: 2, Removed: 2},
+		},
+		TotalAdded:   2,
+		TotalRemoved: 2,
+	}
+	body := buildPRBody(testPlan(), result, "abc123", "def456")
+
+	g.Expect(body).To(ContainSubstring("### Promote `abc123..def456` to production"))
+	g.Expect(body).To(ContainSubstring("| `components/smee/production/base/kustomization.yaml:6` | `quay.io/smee newTag` | `0` | `2` |"))
+	g.Expect(body).To(ContainSubstring("#### Not promoted\n\n- `quay.io/other` → `quay.io/other:2`: nothing under components/smee/production refers to it"))
+	g.Expect(body).To(ContainSubstring("#### Promote by hand\n\n- `components/smee/staging/base/new.yaml`: added in staging"))
+	g.Expect(body).To(ContainSubstring("| `components/smee/production/base` | production | +2 -2 |"))
+	g.Expect(body).To(ContainSubstring("**Total:** 1 components, +2 -2 lines"))
+
+	body = buildPRBody(&promotion.Plan{}, &renderdiff.DiffResult{}, "abc123", "def456")
+	g.Expect(body).To(ContainSubstring("No render differences detected."))
+	g.Expect(body).NotTo(ContainSubstring("Not promoted"))
+}
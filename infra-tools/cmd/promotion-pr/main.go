@@ -0,0 +1,179 @@
+// Command promotion-pr promotes a staging change to production: it finds
+// the image, kustomize images: and ?ref= values a range of commits changed
+// in staging overlays, sets them in the corresponding production overlay
+// files on a new branch, and optionally writes a PR description with the
+// render diff of the promotion.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/buildcache"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/git"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/logging"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/promotion"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+func main() {
+	var (
+		repoRoot    = flag.String("repo-root", "", "Path to the repository root (default: auto-detect via git)")
+		baseRef     = flag.String("base-ref", "", "Start of the staging change range (default: the first parent of --head-ref)")
+		headRef     = flag.String("head-ref", "HEAD", "End of the staging change range, e.g. the merge commit of a staging PR")
+		onto        = flag.String("onto", "", "Ref to branch the promotion off (default: --head-ref)")
+		branch      = flag.String("branch", "", "Name of the branch to create (default: promote/<head SHA>)")
+		prBody      = flag.String("pr-body", "", "Write a PR description with the render diff of the promotion to this file")
+		dryRun      = flag.Bool("dry-run", false, "Print the promotion plan without creating the branch")
+		gitBackend  = flag.String("git-backend", "exec", "Git backend: exec (git binary and worktrees), go (in-process, reads trees from the object database)")
+		overlaysDir = flag.String("overlays-dir", "argo-cd-apps/overlays", "Path to overlays directory relative to repo root")
+		logFile     = flag.String("log-file", "", "Write debug-level logs to this file")
+		noCache     = flag.Bool("no-cache", false, "Disable the persistent kustomize build cache")
+		cacheDir    = flag.String("cache-dir", "", "Directory for the kustomize build cache (default: user cache dir)")
+	)
+	flag.Parse()
+
+	switch git.Backend(*gitBackend) {
+	case git.BackendExec, git.BackendGo:
+		// valid
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --git-backend %q: must be one of exec, go\n", *gitBackend)
+		os.Exit(1)
+	}
+
+	if *dryRun && *prBody != "" {
+		fmt.Fprintln(os.Stderr, "--pr-body renders the promotion branch and cannot be combined with --dry-run")
+		os.Exit(1)
+	}
+
+	logCleanup, err := logging.Setup(*logFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up logging: %v\n", err)
+		os.Exit(1)
+	}
+	if logCleanup != nil {
+		defer logCleanup()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	repo, err := git.Open(ctx, git.Backend(*gitBackend), *repoRoot)
+	if err != nil {
+		logging.Fatal("opening repository; use --repo-root to specify explicitly", "err", err)
+	}
+
+	effectiveBaseRef := *baseRef
+	if effectiveBaseRef == "" {
+		effectiveBaseRef = *headRef + "^"
+	}
+	effectiveOnto := *onto
+	if effectiveOnto == "" {
+		effectiveOnto = *headRef
+	}
+	baseSHA, err := repo.ResolveRef(ctx, effectiveBaseRef)
+	if err != nil {
+		logging.Fatal("resolving base ref", "err", err)
+	}
+	headSHA, err := repo.ResolveRef(ctx, *headRef)
+	if err != nil {
+		logging.Fatal("resolving head ref", "err", err)
+	}
+	ontoSHA, err := repo.ResolveRef(ctx, effectiveOnto)
+	if err != nil {
+		logging.Fatal("resolving --onto", "err", err)
+	}
+	slog.Info("Promoting staging changes", "base", baseSHA, "head", headSHA, "onto", ontoSHA)
+
+	changedFiles, err := repo.ChangedFiles(ctx, baseSHA, headSHA)
+	if err != nil {
+		logging.Fatal("getting changed files", "err", err)
+	}
+
+	var buildCache *buildcache.Cache
+	if *prBody != "" && !*noCache {
+		buildCache, err = buildcache.Open(*cacheDir)
+		if err != nil {
+			slog.Warn("build cache unavailable, building without cache", "err", err)
+		}
+	}
+
+	var cleanups []func()
+	defer func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}()
+	checkout := func(ref string) *detector.RepoRef {
+		tree, cleanup, err := repo.Checkout(ctx, ref)
+		if err != nil {
+			logging.Fatal("checking out ref", "ref", ref, "err", err)
+		}
+		cleanups = append(cleanups, cleanup)
+		return detector.NewRepoRef(tree.Root, detector.WithFileSystem(tree.FS), detector.WithBuildCache(buildCache))
+	}
+	ontoRepo := checkout(ontoSHA)
+	plan, err := promotion.BuildPlan(changedFiles, checkout(baseSHA), checkout(headSHA), ontoRepo)
+	if err != nil {
+		logging.Fatal("planning promotion", "err", err)
+	}
+	writePlan(os.Stdout, plan)
+	if len(plan.Files) == 0 || *dryRun {
+		return
+	}
+
+	if *branch == "" {
+		*branch = "promote/" + headSHA
+	}
+	files := make(map[string][]byte, len(plan.Files))
+	for _, f := range plan.Files {
+		files[f.Path] = f.Content
+	}
+	sha, err := git.CommitFiles(ctx, repo.Root(), ontoSHA, *branch, commitMessage(plan, baseSHA, headSHA), files)
+	if err != nil {
+		logging.Fatal("committing promotion", "err", err)
+	}
+	fmt.Printf("\nCreated branch %s at %s\n", *branch, sha)
+
+	if *prBody == "" {
+		return
+	}
+	result, err := renderPromotion(ctx, ontoRepo, checkout(sha), plan, *overlaysDir)
+	if err != nil {
+		logging.Fatal("rendering promotion", "err", err)
+	}
+	buildCache.LogStats()
+	if err := os.WriteFile(*prBody, []byte(buildPRBody(plan, result, baseSHA, headSHA)), 0o644); err != nil {
+		logging.Fatal("writing PR body", "err", err)
+	}
+	slog.Info("PR body written", "file", *prBody)
+}
+
+// renderPromotion diffs the renders of the components the promotion
+// commit affects against the ref it was branched off.
+func renderPromotion(ctx context.Context, onto, promoted *detector.RepoRef, plan *promotion.Plan, overlaysDir string) (*renderdiff.DiffResult, error) {
+	d, err := detector.NewDetector(promoted, onto, overlaysDir)
+	if err != nil {
+		return nil, fmt.Errorf("initializing detector: %w", err)
+	}
+	paths := make([]string, 0, len(plan.Files))
+	for _, f := range plan.Files {
+		paths = append(paths, f.Path)
+	}
+	affected, err := d.AffectedComponents(paths)
+	if err != nil {
+		return nil, fmt.Errorf("detecting affected components: %w", err)
+	}
+	total := 0
+	for _, p := range affected {
+		total += len(p)
+	}
+	slog.Info("Rendering affected component paths", "count", total)
+	return renderdiff.NewEngine(promoted, onto, total).Run(ctx, affected)
+}
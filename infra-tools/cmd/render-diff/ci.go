@@ -16,16 +16,16 @@ import (
 // runOutputMode executes a single output mode against a pre-computed result.
 // Returns an error instead of calling Fatal, so the caller can continue with
 // remaining modes.
-func runOutputMode(ctx context.Context, mode OutputMode, result *renderdiff.DiffResult, colorMode string, openDiff bool, outputDir, headSHA, baseSHA string) error {
+func runOutputMode(ctx context.Context, mode OutputMode, result *renderdiff.DiffResult, opts outputOptions) error {
 	switch mode {
 	case OutputModeLocal:
-		useColor := shouldUseColor(colorMode)
-		if outputDir != "" {
-			if err := writeDiffFiles(result, outputDir); err != nil {
+		useColor := shouldUseColor(opts.colorMode)
+		if opts.outputDir != "" {
+			if err := writeDiffFiles(result, opts.outputDir); err != nil {
 				return fmt.Errorf("writing diff files: %w", err)
 			}
 		}
-		if openDiff {
+		if opts.openDiff {
 			if err := openInDiffTool(result); err != nil {
 				return fmt.Errorf("opening diff tool: %w", err)
 			}
@@ -40,17 +40,25 @@ func runOutputMode(ctx context.Context, mode OutputMode, result *renderdiff.Diff
 			return err
 		}
 	case OutputModeCIComment:
-		if err := postCIComment(ctx, result, headSHA, baseSHA); err != nil {
+		if err := postCIComment(ctx, result, opts.headSHA, opts.baseSHA); err != nil {
 			return err
 		}
 	case OutputModeCIArtifact:
-		if outputDir == "" {
+		if opts.outputDir == "" {
 			return fmt.Errorf("--output-dir is required for ci-artifact-dir mode")
 		}
-		if err := writeDiffFiles(result, outputDir); err != nil {
+		if err := writeDiffFiles(result, opts.outputDir); err != nil {
 			return fmt.Errorf("writing artifact diff files: %w", err)
 		}
-		fmt.Printf("Wrote %d diff files to %s\n", len(result.Diffs), outputDir)
+		slog.Info("Wrote diff files", "count", len(result.Diffs), "dir", opts.outputDir)
+	case OutputModeJSON:
+		if err := writeJSONReport(result, opts.jsonFile, opts.headSHA, opts.baseSHA); err != nil {
+			return err
+		}
+	case OutputModeHTML:
+		if err := writeHTMLReport(result, opts.htmlFile, opts.headSHA, opts.baseSHA); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -74,14 +82,24 @@ func writeCISummary(result *renderdiff.DiffResult) error {
 
 	if len(result.Diffs) == 0 {
 		_, _ = fmt.Fprintln(w, "No render differences detected.")
+		_, _ = fmt.Fprint(w, filteredNote(result.Filtered))
+		_, _ = fmt.Fprint(w, renderdiff.SuppressedNote(result))
 		return w.Flush()
 	}
 
-	sortDiffs(result.Diffs)
+	renderdiff.SortDiffs(result.Diffs)
 
 	_, _ = fmt.Fprintln(w, "# Kustomize Render Diff")
 	_, _ = fmt.Fprintln(w)
 	_, _ = fmt.Fprintf(w, "**%d components** with differences (+%d -%d lines)\n\n", len(result.Diffs), result.TotalAdded, result.TotalRemoved)
+	if result.TotalViolations > 0 {
+		_, _ = fmt.Fprintf(w, "⚠️ **%d schema violations** in rendered manifests\n\n", result.TotalViolations)
+	}
+	_, _ = fmt.Fprint(w, renderdiff.PolicyNote(result))
+	_, _ = fmt.Fprint(w, renderdiff.DeprecationNote(result))
+	_, _ = fmt.Fprint(w, filteredNote(result.Filtered))
+	_, _ = fmt.Fprint(w, renderdiff.SuppressedNote(result))
+	_, _ = fmt.Fprint(w, renderdiff.DangerousNote(result))
 
 	const truncateThreshold = 50 * 1024 // 50KB
 	for _, d := range result.Diffs {
@@ -96,12 +114,38 @@ func writeCISummary(result *renderdiff.DiffResult) error {
 			_, _ = fmt.Fprintln(w)
 			continue
 		}
-		summary := fmt.Sprintf("%s (%s) — +%d -%d", d.Path, d.Env, d.Added, d.Removed)
+		summary := fmt.Sprintf("%s (%s) — +%d -%d%s", d.Path, d.Env, d.Added, d.Removed, renderdiff.IssueSuffix(d))
 		_, _ = fmt.Fprintf(w, "<details>\n<summary>%s</summary>\n\n", summary)
-		if len(d.Diff) > truncateThreshold {
+		if len(d.Violations) > 0 {
+			_, _ = fmt.Fprintln(w, "**Schema violations:**")
+			_, _ = fmt.Fprintln(w)
+			for _, v := range d.Violations {
+				_, _ = fmt.Fprintf(w, "- `%s`\n", v)
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+		if len(d.Findings) > 0 {
+			_, _ = fmt.Fprintln(w, "**Policy findings:**")
+			_, _ = fmt.Fprintln(w)
+			for _, f := range d.Findings {
+				_, _ = fmt.Fprintf(w, "- `%s`\n", f)
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+		if len(d.Deprecations) > 0 {
+			_, _ = fmt.Fprintln(w, "**Deprecated kustomization fields:**")
+			_, _ = fmt.Fprintln(w)
+			for _, dep := range d.Deprecations {
+				_, _ = fmt.Fprintf(w, "- `%s`\n", dep)
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+		switch {
+		case d.Diff == "":
+		case len(d.Diff) > truncateThreshold:
 			_, _ = fmt.Fprintf(w, "```diff\n%s\n```\n\n", d.Diff[:truncateThreshold])
 			_, _ = fmt.Fprintln(w, "⚠️ Diff truncated. Download the full artifact for the complete diff.")
-		} else {
+		default:
 			_, _ = fmt.Fprintf(w, "```diff\n%s\n```\n\n", d.Diff)
 		}
 		_, _ = fmt.Fprintln(w, "</details>")
@@ -176,25 +220,13 @@ func buildCommentBody(result *renderdiff.DiffResult, headSHA, baseSHA, runURL st
 
 	if len(result.Diffs) == 0 {
 		fmt.Fprintln(&b, "No render differences detected.")
+		fmt.Fprint(&b, filteredNote(result.Filtered))
+		fmt.Fprint(&b, renderdiff.SuppressedNote(result))
 		return b.String()
 	}
 
-	sortDiffs(result.Diffs)
-
-	fmt.Fprintln(&b, "| Component | Environment | Changes |")
-	fmt.Fprintln(&b, "|-----------|-------------|---------|")
-	for _, d := range result.Diffs {
-		if d.SkipOutput {
-			continue
-		}
-		if d.Error != "" {
-			fmt.Fprintf(&b, "| `%s` | %s | build error |\n", d.Path, d.Env)
-		} else {
-			fmt.Fprintf(&b, "| `%s` | %s | +%d -%d |\n", d.Path, d.Env, d.Added, d.Removed)
-		}
-	}
-	fmt.Fprintln(&b)
-	fmt.Fprintf(&b, "**Total:** %d components, +%d -%d lines\n\n", len(result.Diffs), result.TotalAdded, result.TotalRemoved)
+	fmt.Fprint(&b, renderdiff.Summary(result))
+	fmt.Fprint(&b, filteredNote(result.Filtered))
 	link := "../actions"
 	if runURL != "" {
 		link = runURL
@@ -202,3 +234,12 @@ func buildCommentBody(result *renderdiff.DiffResult, headSHA, baseSHA, runURL st
 	fmt.Fprintf(&b, "📋 Full diff available in the [workflow summary](%s) and as a downloadable artifact.\n", link)
 	return b.String()
 }
+
+// filteredNote returns a markdown paragraph noting how many jobs the
+// selection flags excluded, or an empty string when none were.
+func filteredNote(filtered int) string {
+	if filtered == 0 {
+		return ""
+	}
+	return fmt.Sprintf("_%d component paths were filtered out by --env/--cluster/--component._\n\n", filtered)
+}
@@ -7,7 +7,10 @@ import (
 
 	. "github.com/onsi/gomega"
 
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/deptree"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/policy"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/schema"
 )
 
 func TestBuildCommentBody_WithBuildError(t *testing.T) {
@@ -68,6 +71,38 @@ func TestBuildCommentBody_NoDiffs(t *testing.T) {
 	g.Expect(body).To(ContainSubstring("No render differences detected."))
 }
 
+func TestBuildCommentBody_Suppressed(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &renderdiff.DiffResult{
+		Suppressed: []renderdiff.Suppression{{Rule: "generator-hash-suffixes", Lines: 12}, {Rule: "env-order", Lines: 4}},
+	}
+
+	body := buildCommentBody(result, "abc123", "def456", "")
+
+	g.Expect(body).To(ContainSubstring("No render differences detected."))
+	g.Expect(body).To(ContainSubstring("_Normalize rules suppressed 16 diff lines: `generator-hash-suffixes` 12, `env-order` 4._"))
+}
+
+func TestBuildCommentBody_NewDeprecations(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &renderdiff.DiffResult{
+		Diffs: []renderdiff.ComponentDiff{
+			{Path: "components/foo/staging", Env: "staging", Deprecations: []renderdiff.Deprecation{
+				{Deprecation: deptree.Deprecation{File: "components/foo/base/kustomization.yaml", Field: "commonLabels", Replacement: "labels"}},
+				{Deprecation: deptree.Deprecation{File: "components/foo/staging/kustomization.yaml", Field: "vars", Replacement: "replacements"}, New: true},
+			}},
+		},
+	}
+
+	body := buildCommentBody(result, "abc123", "def456", "")
+
+	g.Expect(body).To(ContainSubstring("**1 new deprecated kustomization fields.**"))
+	g.Expect(body).To(ContainSubstring("`components/foo/staging/kustomization.yaml`: `vars` is deprecated, use `replacements` instead"))
+	g.Expect(body).NotTo(ContainSubstring("commonLabels"), "deprecations already on the base are not flagged")
+}
+
 func TestBuildCommentBody_MixedDiffsAndErrors(t *testing.T) {
 	g := NewWithT(t)
 
@@ -128,6 +163,76 @@ func TestBuildCommentBody_WithoutRunURL(t *testing.T) {
 	g.Expect(body).To(ContainSubstring("[workflow summary](../actions)"))
 }
 
+func TestBuildCommentBody_PolicyDenials(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &renderdiff.DiffResult{
+		Diffs: []renderdiff.ComponentDiff{
+			{
+				Path: "components/foo/production", Env: "production",
+				Added: 1, Removed: 1, Diff: "-a\n+b\n",
+				Findings: []policy.Finding{
+					{Rule: "no-latest-images", Severity: policy.SeverityDeny, Resource: "apps/v1 Deployment ns/foo", Message: "pin images", Paths: []string{"spec.template.spec.containers[name=foo].image"}},
+					{Rule: "resource-requests", Severity: policy.SeverityWarn, Resource: "apps/v1 Deployment ns/foo", Message: "set requests"},
+				},
+			},
+		},
+		TotalAdded: 1, TotalRemoved: 1, TotalDenied: 1, TotalWarned: 1,
+	}
+
+	body := buildCommentBody(result, "abc123", "def456", "")
+
+	g.Expect(body).To(ContainSubstring("| `components/foo/production` | production | +1 -1, 1 policy denials, 1 policy warnings |"))
+	g.Expect(body).To(ContainSubstring("⛔ **1 policy denials**, 1 warnings"))
+	g.Expect(body).To(ContainSubstring("- `components/foo/production` (production): `DENY no-latest-images: apps/v1 Deployment ns/foo: pin images (spec.template.spec.containers[name=foo].image)`"))
+	g.Expect(body).NotTo(ContainSubstring("WARN resource-requests"), "warnings are only listed in the summary")
+}
+
+func TestBuildCommentBody_PolicyWarningsOnly(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &renderdiff.DiffResult{
+		Diffs: []renderdiff.ComponentDiff{
+			{
+				Path: "components/foo/staging", Env: "staging", Diff: "+a\n", Added: 1,
+				Findings: []policy.Finding{{Rule: "r", Severity: policy.SeverityWarn, Resource: "v1 ConfigMap x", Message: "m"}},
+			},
+		},
+		TotalAdded: 1, TotalWarned: 1,
+	}
+
+	body := buildCommentBody(result, "abc123", "def456", "")
+
+	g.Expect(body).To(ContainSubstring("⚠️ **1 policy warnings**"))
+	g.Expect(body).NotTo(ContainSubstring("⛔"))
+}
+
+func TestBuildCommentBody_DestructiveChangesFirst(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &renderdiff.DiffResult{
+		Diffs: []renderdiff.ComponentDiff{
+			{
+				Path: "components/foo/staging", Env: "staging", Diff: "-a\n+b\n", Added: 1, Removed: 1,
+				Changes: []renderdiff.ClassifiedChange{
+					{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "cm", Class: renderdiff.ClassModify},
+					{APIVersion: "v1", Kind: "Secret", Namespace: "ns", Name: "old", Class: renderdiff.ClassDelete},
+					{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "foo", Class: renderdiff.ClassImmutable, ImmutableFields: []string{"spec.selector.matchLabels.app"}},
+				},
+			},
+		},
+		TotalAdded: 1, TotalRemoved: 1, TotalDangerous: 2,
+	}
+
+	body := buildCommentBody(result, "abc123", "def456", "")
+
+	g.Expect(body).To(ContainSubstring("#### ⚠️ 2 destructive changes"))
+	g.Expect(body).To(ContainSubstring("| `components/foo/staging` | staging | delete | `v1 Secret ns/old` |"))
+	g.Expect(body).To(ContainSubstring("| `components/foo/staging` | staging | immutable-field-change | `apps/v1 Deployment ns/foo` (`spec.selector.matchLabels.app`) |"))
+	g.Expect(body).NotTo(ContainSubstring("ConfigMap"))
+	g.Expect(strings.Index(body, "destructive changes")).To(BeNumerically("<", strings.Index(body, "| Component | Environment | Changes |")))
+}
+
 func TestBuildRunURL(t *testing.T) {
 	g := NewWithT(t)
 
@@ -192,6 +297,30 @@ func TestWriteCISummary_WithDiff(t *testing.T) {
 	g.Expect(body).To(ContainSubstring("```diff"))
 }
 
+func TestWriteCISummary_ViolationsWithoutDiff(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &renderdiff.DiffResult{
+		Diffs: []renderdiff.ComponentDiff{
+			{
+				Path: "components/foo/staging",
+				Env:  "staging",
+				Violations: []schema.Violation{
+					{Resource: "apps/v1 Deployment ns/foo", Path: "spec.replica", Message: "unknown field"},
+				},
+			},
+		},
+		TotalViolations: 1,
+	}
+
+	body := writeCISummaryToString(t, result)
+
+	g.Expect(body).To(ContainSubstring("**1 schema violations**"))
+	g.Expect(body).To(ContainSubstring("components/foo/staging (staging) — +0 -0, 1 schema violations"))
+	g.Expect(body).To(ContainSubstring("- `apps/v1 Deployment ns/foo: spec.replica: unknown field`"))
+	g.Expect(body).NotTo(ContainSubstring("```diff"))
+}
+
 func TestWriteCISummary_WithBuildError(t *testing.T) {
 	g := NewWithT(t)
 
@@ -6,26 +6,59 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
 )
 
-// writeDiffFiles writes per-component .diff files to a directory.
+// writeDiffFiles writes per-component .diff files to a directory. Components
+// with schema violations, policy findings or deprecated kustomization fields
+// also get matching .violations.txt, .policy.txt and .deprecations.txt files.
 func writeDiffFiles(result *renderdiff.DiffResult, dir string) error {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("creating output dir: %w", err)
 	}
 	seen := make(map[string]int)
 	for _, d := range result.Diffs {
-		if d.Error != "" || d.Diff == "" {
+		if d.Error != "" || (d.Diff == "" && len(d.Violations) == 0 && len(d.Findings) == 0 && d.NewDeprecations() == 0) {
 			continue
 		}
 		name := dedupeFileName(diffFileName(d), seen)
-		path := filepath.Join(dir, name)
-		if err := os.WriteFile(path, []byte(d.Diff), 0o644); err != nil {
-			return fmt.Errorf("writing %s: %w", path, err)
+		if d.Diff != "" {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(d.Diff), 0o644); err != nil {
+				return fmt.Errorf("writing %s: %w", path, err)
+			}
+		}
+		if len(d.Violations) > 0 {
+			var b strings.Builder
+			for _, v := range d.Violations {
+				fmt.Fprintln(&b, v)
+			}
+			path := filepath.Join(dir, strings.TrimSuffix(name, ".diff")+".violations.txt")
+			if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+				return fmt.Errorf("writing %s: %w", path, err)
+			}
+		}
+		if len(d.Findings) > 0 {
+			var b strings.Builder
+			for _, f := range d.Findings {
+				fmt.Fprintln(&b, f)
+			}
+			path := filepath.Join(dir, strings.TrimSuffix(name, ".diff")+".policy.txt")
+			if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+				return fmt.Errorf("writing %s: %w", path, err)
+			}
+		}
+		if len(d.Deprecations) > 0 {
+			var b strings.Builder
+			for _, dep := range d.Deprecations {
+				fmt.Fprintln(&b, dep)
+			}
+			path := filepath.Join(dir, strings.TrimSuffix(name, ".diff")+".deprecations.txt")
+			if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+				return fmt.Errorf("writing %s: %w", path, err)
+			}
 		}
 	}
 	return nil
@@ -114,13 +147,3 @@ func openInDiffTool(result *renderdiff.DiffResult) error {
 	}
 	return nil
 }
-
-// sortDiffs sorts diffs by environment then path for consistent output.
-func sortDiffs(diffs []renderdiff.ComponentDiff) {
-	sort.Slice(diffs, func(i, j int) bool {
-		if diffs[i].Env != diffs[j].Env {
-			return diffs[i].Env < diffs[j].Env
-		}
-		return diffs[i].Path < diffs[j].Path
-	})
-}
@@ -121,21 +121,3 @@ func TestWriteDiffFiles_SkipsErrorsAndEmptyDiffs(t *testing.T) {
 	g.Expect(entries).To(HaveLen(1))
 	g.Expect(entries[0].Name()).To(Equal("components__good__staging__staging.diff"))
 }
-
-func TestSortDiffs(t *testing.T) {
-	g := NewWithT(t)
-
-	diffs := []renderdiff.ComponentDiff{
-		{Path: "components/b/staging", Env: "staging"},
-		{Path: "components/a/production", Env: "production"},
-		{Path: "components/a/staging", Env: "staging"},
-		{Path: "components/c/production", Env: "production"},
-	}
-
-	sortDiffs(diffs)
-
-	g.Expect(diffs[0].Path).To(Equal("components/a/production"))
-	g.Expect(diffs[1].Path).To(Equal("components/c/production"))
-	g.Expect(diffs[2].Path).To(Equal("components/a/staging"))
-	g.Expect(diffs[3].Path).To(Equal("components/b/staging"))
-}
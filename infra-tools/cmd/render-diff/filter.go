@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+// selection restricts which affected component paths are rendered. An empty
+// field means "no restriction" for that dimension; non-empty fields are
+// combined with AND.
+type selection struct {
+	envs       map[detector.Environment]bool
+	clusters   map[string]bool
+	components []string
+}
+
+// parseSelection builds a selection from the comma-separated --env, --cluster
+// and --component flag values, validating environment names and glob syntax.
+func parseSelection(envs, clusters, components string) (selection, error) {
+	var sel selection
+	for _, e := range splitList(envs) {
+		env := detector.Environment(e)
+		switch env {
+		case detector.Development, detector.Staging, detector.Production:
+		default:
+			return selection{}, fmt.Errorf("invalid --env %q: must be one of development, staging, production", e)
+		}
+		if sel.envs == nil {
+			sel.envs = make(map[detector.Environment]bool)
+		}
+		sel.envs[env] = true
+	}
+	for _, c := range splitList(clusters) {
+		if sel.clusters == nil {
+			sel.clusters = make(map[string]bool)
+		}
+		sel.clusters[c] = true
+	}
+	for _, pattern := range splitList(components) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return selection{}, fmt.Errorf("invalid --component glob %q: %w", pattern, err)
+		}
+		sel.components = append(sel.components, strings.TrimSuffix(pattern, "/"))
+	}
+	return sel, nil
+}
+
+// isEmpty reports whether the selection has no restrictions.
+func (s selection) isEmpty() bool {
+	return len(s.envs) == 0 && len(s.clusters) == 0 && len(s.components) == 0
+}
+
+// apply returns the subset of affected that matches the selection and the
+// number of (environment, path) jobs that were filtered out.
+func (s selection) apply(affected map[detector.Environment][]appset.ComponentPath) (map[detector.Environment][]appset.ComponentPath, int) {
+	if s.isEmpty() {
+		return affected, 0
+	}
+	kept := make(map[detector.Environment][]appset.ComponentPath)
+	filtered := 0
+	for env, paths := range affected {
+		for _, cp := range paths {
+			if !s.matches(env, cp) {
+				filtered++
+				continue
+			}
+			kept[env] = append(kept[env], cp)
+		}
+	}
+	return kept, filtered
+}
+
+// matches reports whether a single component path passes every filter.
+// Paths without a ClusterDir never match a --cluster filter, since they are
+// not specific to one cluster.
+func (s selection) matches(env detector.Environment, cp appset.ComponentPath) bool {
+	if len(s.envs) > 0 && !s.envs[env] {
+		return false
+	}
+	if len(s.clusters) > 0 && !s.clusters[cp.ClusterDir] {
+		return false
+	}
+	if len(s.components) > 0 && !matchesAnyGlob(s.components, cp.Path) {
+		return false
+	}
+	return true
+}
+
+// matchesAnyGlob reports whether p, or any of its parent directories, matches
+// one of the patterns. Matching parents lets "components/build-service" or
+// "components/*-service" select every path below a component.
+func matchesAnyGlob(patterns []string, p string) bool {
+	p = strings.TrimSuffix(p, "/")
+	for candidate := p; candidate != "." && candidate != "/" && candidate != ""; candidate = path.Dir(candidate) {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, candidate); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitList splits a comma-separated flag value, trimming spaces and
+// dropping empty entries.
+func splitList(raw string) []string {
+	var out []string
+	for s := range strings.SplitSeq(raw, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+func testAffected() map[detector.Environment][]appset.ComponentPath {
+	return map[detector.Environment][]appset.ComponentPath{
+		detector.Development: {
+			{Path: "components/build-service/development"},
+		},
+		detector.Staging: {
+			{Path: "components/build-service/staging"},
+			{Path: "components/smee/staging/stone-stg-rh01", ClusterDir: "stone-stg-rh01"},
+		},
+		detector.Production: {
+			{Path: "components/build-service/production/base"},
+			{Path: "components/smee/production/stone-prod-p02", ClusterDir: "stone-prod-p02"},
+		},
+	}
+}
+
+func TestParseSelection_Invalid(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := parseSelection("qa", "", "")
+	g.Expect(err).To(MatchError(ContainSubstring(`invalid --env "qa"`)))
+
+	_, err = parseSelection("", "", "components/[")
+	g.Expect(err).To(MatchError(ContainSubstring("invalid --component glob")))
+}
+
+func TestSelection_Empty_KeepsEverything(t *testing.T) {
+	g := NewWithT(t)
+
+	sel, err := parseSelection("", "", "")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	kept, filtered := sel.apply(testAffected())
+	g.Expect(filtered).To(Equal(0))
+	g.Expect(kept).To(Equal(testAffected()))
+}
+
+func TestSelection_ByEnv(t *testing.T) {
+	g := NewWithT(t)
+
+	sel, err := parseSelection("staging", "", "")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	kept, filtered := sel.apply(testAffected())
+	g.Expect(filtered).To(Equal(3))
+	g.Expect(kept).To(HaveKey(detector.Staging))
+	g.Expect(kept[detector.Staging]).To(HaveLen(2))
+	g.Expect(kept).NotTo(HaveKey(detector.Production))
+}
+
+func TestSelection_ByCluster(t *testing.T) {
+	g := NewWithT(t)
+
+	sel, err := parseSelection("", "stone-prod-p02, stone-stg-rh01", "")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	kept, filtered := sel.apply(testAffected())
+	g.Expect(filtered).To(Equal(3))
+	g.Expect(kept[detector.Staging]).To(ConsistOf(appset.ComponentPath{Path: "components/smee/staging/stone-stg-rh01", ClusterDir: "stone-stg-rh01"}))
+	g.Expect(kept[detector.Production]).To(ConsistOf(appset.ComponentPath{Path: "components/smee/production/stone-prod-p02", ClusterDir: "stone-prod-p02"}))
+}
+
+func TestSelection_ByComponentGlob(t *testing.T) {
+	g := NewWithT(t)
+
+	// A pattern matching a parent directory selects every path below it.
+	sel, err := parseSelection("", "", "components/build-*")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	kept, filtered := sel.apply(testAffected())
+	g.Expect(filtered).To(Equal(2))
+	g.Expect(kept[detector.Production]).To(ConsistOf(appset.ComponentPath{Path: "components/build-service/production/base"}))
+}
+
+func TestSelection_Combined(t *testing.T) {
+	g := NewWithT(t)
+
+	sel, err := parseSelection("production", "", "components/smee/")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	kept, filtered := sel.apply(testAffected())
+	g.Expect(filtered).To(Equal(4))
+	g.Expect(kept).To(HaveLen(1))
+	g.Expect(kept[detector.Production]).To(HaveLen(1))
+}
+
+func TestBuildCommentBody_ReportsFiltered(t *testing.T) {
+	g := NewWithT(t)
+
+	body := buildCommentBody(&renderdiff.DiffResult{Filtered: 4}, "abc123", "def456", "")
+
+	g.Expect(body).To(ContainSubstring("4 component paths were filtered out"))
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/policy"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/schema"
+)
+
+// htmlContextLines is the number of unchanged lines shown around each change
+// in the side-by-side diff.
+const htmlContextLines = 3
+
+//go:embed report.html.tmpl
+var htmlTemplateText string
+
+var htmlTemplate = template.Must(template.New("report").Parse(htmlTemplateText))
+
+// htmlReport is the data rendered into the HTML report template.
+type htmlReport struct {
+	HeadSHA      string
+	BaseSHA      string
+	TotalAdded   int
+	TotalRemoved int
+	Errors       int
+	Dangerous    int
+	Guarded      int
+	Violations   int
+	Denied       int
+	Warned       int
+	Filtered     int
+	Suppressed   []renderdiff.Suppression
+	Envs         []htmlEnv
+}
+
+// htmlEnv groups the reported components of one environment for the sidebar
+// tree.
+type htmlEnv struct {
+	Name       string
+	Components []htmlComponent
+}
+
+// htmlComponent is one reported component. Anchor is the element ID of its
+// section; resources and rows use Anchor-prefixed IDs.
+type htmlComponent struct {
+	Anchor     string
+	Path       string
+	ClusterDir string
+	Added      int
+	Removed    int
+	Error      string
+	// Search is the lowercase text the search box matches against.
+	Search     string
+	Resources  []htmlResource
+	Hunks      []htmlHunk
+	Dangerous  []renderdiff.ClassifiedChange
+	Violations []schema.Violation
+	Findings   []policy.Finding
+	// Deprecations lists the deprecated kustomization fields of the HEAD build.
+	Deprecations []renderdiff.Deprecation
+	// NewDeprecations counts those introduced on HEAD.
+	NewDeprecations int
+}
+
+// htmlResource is a changed resource linking to its first row in the diff.
+// Anchor is empty when the resource has no row, e.g. when both sides render
+// the same text after normalization.
+type htmlResource struct {
+	Anchor string
+	ID     string
+	Class  renderdiff.ChangeClass
+}
+
+type htmlHunk struct {
+	Rows []htmlRow
+}
+
+// htmlRow is a side-by-side row. Anchor is set on the first row of each
+// changed resource.
+type htmlRow struct {
+	renderdiff.SideBySideRow
+	Anchor string
+}
+
+// buildHTMLReport converts a DiffResult into the HTML report data. Components
+// excluded from output (SkipOutput) are left out.
+func buildHTMLReport(result *renderdiff.DiffResult, headSHA, baseSHA string) htmlReport {
+	renderdiff.SortDiffs(result.Diffs)
+
+	report := htmlReport{
+		HeadSHA:      headSHA,
+		BaseSHA:      baseSHA,
+		TotalAdded:   result.TotalAdded,
+		TotalRemoved: result.TotalRemoved,
+		Dangerous:    result.TotalDangerous,
+		Guarded:      len(result.GuardedDeletions()),
+		Violations:   result.TotalViolations,
+		Denied:       result.TotalDenied,
+		Warned:       result.TotalWarned,
+		Filtered:     result.Filtered,
+		Suppressed:   result.Suppressed,
+	}
+	reported := 0
+	for _, d := range result.Diffs {
+		if d.SkipOutput {
+			continue
+		}
+		if d.Error != "" {
+			report.Errors++
+		}
+		c := buildHTMLComponent(fmt.Sprintf("c-%d", reported), d)
+		reported++
+		env := string(d.Env)
+		if n := len(report.Envs); n == 0 || report.Envs[n-1].Name != env {
+			report.Envs = append(report.Envs, htmlEnv{Name: env})
+		}
+		last := &report.Envs[len(report.Envs)-1]
+		last.Components = append(last.Components, c)
+	}
+	return report
+}
+
+func buildHTMLComponent(anchor string, d renderdiff.ComponentDiff) htmlComponent {
+	c := htmlComponent{
+		Anchor:          anchor,
+		Path:            d.Path,
+		ClusterDir:      d.ClusterDir,
+		Added:           d.Added,
+		Removed:         d.Removed,
+		Error:           d.Error,
+		Dangerous:       d.DangerousChanges(),
+		Violations:      d.Violations,
+		Findings:        d.Findings,
+		Deprecations:    d.Deprecations,
+		NewDeprecations: d.NewDeprecations(),
+	}
+	search := []string{d.Path, d.ClusterDir, string(d.Env)}
+
+	if d.Error == "" && d.HasDiff() {
+		// The first row of each resource gets the anchor its entry in the
+		// resource list links to.
+		anchors := make(map[string]string, len(d.Changes))
+		for i, ch := range d.Changes {
+			anchors[ch.ID()] = fmt.Sprintf("%s-r-%d", anchor, i)
+		}
+		linked := make(map[string]bool, len(anchors))
+		for _, h := range d.SideBySide(htmlContextLines) {
+			var hunk htmlHunk
+			for _, row := range h.Rows {
+				r := htmlRow{SideBySideRow: row}
+				if row.Op != renderdiff.RowEqual && !linked[row.Resource] && anchors[row.Resource] != "" {
+					r.Anchor = anchors[row.Resource]
+					linked[row.Resource] = true
+				}
+				hunk.Rows = append(hunk.Rows, r)
+			}
+			c.Hunks = append(c.Hunks, hunk)
+		}
+		for _, ch := range d.Changes {
+			res := htmlResource{ID: ch.ID(), Class: ch.Class}
+			if linked[res.ID] {
+				res.Anchor = anchors[res.ID]
+			}
+			c.Resources = append(c.Resources, res)
+			search = append(search, res.ID)
+		}
+	}
+
+	c.Search = strings.ToLower(strings.Join(search, " "))
+	return c
+}
+
+// writeHTMLReport writes a self-contained HTML report to path.
+func writeHTMLReport(result *renderdiff.DiffResult, path, headSHA, baseSHA string) error {
+	if path == "" {
+		return fmt.Errorf("--html-file is required for html mode")
+	}
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, buildHTMLReport(result, headSHA, baseSHA)); err != nil {
+		return fmt.Errorf("rendering HTML report: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("writing HTML report: %w", err)
+	}
+	slog.Info("Wrote HTML report", "path", path)
+	return nil
+}
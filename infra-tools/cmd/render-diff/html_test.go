@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+func htmlTestResult() *renderdiff.DiffResult {
+	return &renderdiff.DiffResult{
+		Diffs: []renderdiff.ComponentDiff{
+			{
+				Path: "components/foo/staging", Env: "staging", Added: 1, Removed: 1, Diff: "some diff",
+				BaseYAML: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: ns\ndata:\n  key: old\n"),
+				HeadYAML: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: ns\ndata:\n  key: <new>\n"),
+				Changes: []renderdiff.ClassifiedChange{
+					{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "a", Class: renderdiff.ClassModify},
+				},
+			},
+			{Path: "components/bar/production", ClusterDir: "stone-prod-p02", Env: "production", Error: "accumulating resources"},
+			{Path: "components/plain/development", Env: "development", Error: "unable to find one of", SkipOutput: true},
+		},
+		TotalAdded:   1,
+		TotalRemoved: 1,
+	}
+}
+
+func TestBuildHTMLReport(t *testing.T) {
+	g := NewWithT(t)
+
+	report := buildHTMLReport(htmlTestResult(), "abc123", "def456")
+
+	// Sorted by environment; skipped components are left out.
+	g.Expect(report.Errors).To(Equal(1))
+	g.Expect(report.Envs).To(HaveLen(2))
+	g.Expect(report.Envs[0].Name).To(Equal("production"))
+	g.Expect(report.Envs[0].Components[0].Error).To(Equal("accumulating resources"))
+	g.Expect(report.Envs[0].Components[0].Hunks).To(BeEmpty())
+
+	foo := report.Envs[1].Components[0]
+	g.Expect(foo.Search).To(Equal("components/foo/staging  staging v1 configmap ns/a"))
+	g.Expect(foo.Resources).To(Equal([]htmlResource{
+		{Anchor: foo.Anchor + "-r-0", ID: "v1 ConfigMap ns/a", Class: renderdiff.ClassModify},
+	}))
+	g.Expect(foo.Hunks).To(HaveLen(1))
+
+	var anchored []htmlRow
+	for _, row := range foo.Hunks[0].Rows {
+		if row.Anchor != "" {
+			anchored = append(anchored, row)
+		}
+	}
+	g.Expect(anchored).To(HaveLen(1))
+	g.Expect(anchored[0].Anchor).To(Equal(foo.Anchor + "-r-0"))
+	g.Expect(anchored[0].Head).To(Equal("  key: <new>"))
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	g := NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), "render-diff.html")
+	result := htmlTestResult()
+	result.Suppressed = []renderdiff.Suppression{{Rule: "env-order", Lines: 4}}
+	g.Expect(writeHTMLReport(result, path, "abc123", "def456")).To(Succeed())
+
+	data, err := os.ReadFile(path)
+	g.Expect(err).NotTo(HaveOccurred())
+	out := string(data)
+	g.Expect(out).To(ContainSubstring(`<a href="#c-1-r-0">v1 ConfigMap ns/a</a>`))
+	g.Expect(out).To(ContainSubstring(`id="c-1-r-0"`))
+	g.Expect(out).To(ContainSubstring("accumulating resources"))
+	g.Expect(out).To(ContainSubstring("key: &lt;new&gt;"))
+	g.Expect(out).NotTo(ContainSubstring("components/plain/development"))
+	g.Expect(out).To(ContainSubstring("Normalize rules suppressed <code>env-order</code> 4 diff lines."))
+	// Self-contained: no external stylesheets or scripts.
+	g.Expect(out).NotTo(ContainSubstring(`src="http`))
+	g.Expect(out).NotTo(ContainSubstring(`rel="stylesheet"`))
+}
+
+func TestWriteHTMLReport_RequiresPath(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(writeHTMLReport(&renderdiff.DiffResult{}, "", "abc123", "def456")).NotTo(Succeed())
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/policy"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/schema"
+)
+
+// jsonSchemaVersion identifies the layout of jsonReport. Bump it whenever a
+// field is renamed or removed so consumers can detect incompatible output;
+// adding fields does not require a bump.
+const jsonSchemaVersion = "render-diff/v1"
+
+// jsonReport is the top-level document written by the json output mode.
+type jsonReport struct {
+	SchemaVersion    string          `json:"schemaVersion"`
+	HeadSHA          string          `json:"headSHA"`
+	BaseSHA          string          `json:"baseSHA"`
+	UncommittedFiles []string        `json:"uncommittedFiles,omitempty"`
+	Summary          jsonSummary     `json:"summary"`
+	Components       []jsonComponent `json:"components"`
+}
+
+// jsonSummary holds aggregate statistics over all reported components.
+type jsonSummary struct {
+	Components   int `json:"components"`
+	Errors       int `json:"errors"`
+	Skipped      int `json:"skipped"`
+	TotalAdded   int `json:"totalAdded"`
+	TotalRemoved int `json:"totalRemoved"`
+	Filtered     int `json:"filtered"`
+	Violations   int `json:"violations"`
+	Dangerous    int `json:"dangerous"`
+	Denied       int `json:"denied"`
+	Warned       int `json:"warned"`
+	// Deprecations is the number of deprecated kustomization fields
+	// introduced on HEAD, counting each kustomization file once.
+	Deprecations int `json:"deprecations"`
+	// Suppressed totals the diff lines each normalize rule removed.
+	Suppressed []renderdiff.Suppression `json:"suppressed,omitempty"`
+}
+
+// jsonComponent is the serialized form of a renderdiff.ComponentDiff. The
+// rendered YAML is omitted to keep the document small; the diff text is kept.
+type jsonComponent struct {
+	Path         string                        `json:"path"`
+	ClusterDir   string                        `json:"clusterDir,omitempty"`
+	Environment  string                        `json:"environment"`
+	Added        int                           `json:"added"`
+	Removed      int                           `json:"removed"`
+	Error        string                        `json:"error,omitempty"`
+	Skipped      bool                          `json:"skipped"`
+	Diff         string                        `json:"diff,omitempty"`
+	Resources    []renderdiff.ResourceChange   `json:"resources,omitempty"`
+	Changes      []renderdiff.ClassifiedChange `json:"changes,omitempty"`
+	Violations   []schema.Violation            `json:"violations,omitempty"`
+	Findings     []policy.Finding              `json:"findings,omitempty"`
+	Suppressed   []renderdiff.Suppression      `json:"suppressed,omitempty"`
+	Deprecations []renderdiff.Deprecation      `json:"deprecations,omitempty"`
+}
+
+// buildJSONReport converts a DiffResult into the versioned JSON schema.
+// Components skipped as non-kustomization directories are listed too, so
+// consumers can tell them from components the change does not affect.
+func buildJSONReport(result *renderdiff.DiffResult, headSHA, baseSHA string) jsonReport {
+	diffs := append(slices.Clone(result.Diffs), result.Skipped...)
+	renderdiff.SortDiffs(diffs)
+
+	report := jsonReport{
+		SchemaVersion:    jsonSchemaVersion,
+		HeadSHA:          headSHA,
+		BaseSHA:          baseSHA,
+		UncommittedFiles: result.Uncommitted,
+		Components:       make([]jsonComponent, 0, len(diffs)),
+		Summary: jsonSummary{
+			TotalAdded:   result.TotalAdded,
+			TotalRemoved: result.TotalRemoved,
+			Filtered:     result.Filtered,
+			Violations:   result.TotalViolations,
+			Dangerous:    result.TotalDangerous,
+			Denied:       result.TotalDenied,
+			Warned:       result.TotalWarned,
+			Deprecations: len(result.NewDeprecations()),
+			Suppressed:   result.Suppressed,
+		},
+	}
+	for _, d := range diffs {
+		switch {
+		case d.SkipOutput:
+			report.Summary.Skipped++
+		case d.Error != "":
+			report.Summary.Errors++
+		}
+		report.Components = append(report.Components, jsonComponent{
+			Path:         d.Path,
+			ClusterDir:   d.ClusterDir,
+			Environment:  string(d.Env),
+			Added:        d.Added,
+			Removed:      d.Removed,
+			Error:        d.Error,
+			Skipped:      d.SkipOutput,
+			Diff:         d.Diff,
+			Resources:    d.Resources,
+			Changes:      d.Changes,
+			Violations:   d.Violations,
+			Findings:     d.Findings,
+			Suppressed:   d.Suppressed,
+			Deprecations: d.Deprecations,
+		})
+	}
+	report.Summary.Components = len(report.Components)
+	return report
+}
+
+// writeJSONReport writes the JSON report to path, or to stdout when path is
+// empty.
+func writeJSONReport(result *renderdiff.DiffResult, path, headSHA, baseSHA string) error {
+	var dest io.Writer = os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("creating JSON report: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		dest = f
+	}
+
+	enc := json.NewEncoder(dest)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(buildJSONReport(result, headSHA, baseSHA)); err != nil {
+		return fmt.Errorf("encoding JSON report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+func TestBuildJSONReport(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &renderdiff.DiffResult{
+		Diffs: []renderdiff.ComponentDiff{
+			{Path: "components/foo/staging", Env: "staging", Added: 3, Removed: 1, Diff: "some diff"},
+			{Path: "components/bar/production", ClusterDir: "stone-prod-p02", Env: "production", Error: "accumulating resources"},
+		},
+		Skipped: []renderdiff.ComponentDiff{
+			{Path: "components/plain/development", Env: "development", Error: "unable to find one of", SkipOutput: true},
+		},
+		TotalAdded:   3,
+		TotalRemoved: 1,
+	}
+
+	report := buildJSONReport(result, "abc123", "def456")
+
+	g.Expect(report.SchemaVersion).To(Equal(jsonSchemaVersion))
+	g.Expect(report.HeadSHA).To(Equal("abc123"))
+	g.Expect(report.BaseSHA).To(Equal("def456"))
+	g.Expect(report.Summary).To(Equal(jsonSummary{Components: 3, Errors: 1, Skipped: 1, TotalAdded: 3, TotalRemoved: 1}))
+
+	// Sorted by environment, then path, skipped components included.
+	g.Expect(report.Components).To(HaveLen(3))
+	g.Expect(report.Components[0].Environment).To(Equal("development"))
+	g.Expect(report.Components[0].Skipped).To(BeTrue())
+	g.Expect(report.Components[1].ClusterDir).To(Equal("stone-prod-p02"))
+	g.Expect(report.Components[1].Error).To(Equal("accumulating resources"))
+	g.Expect(report.Components[1].Skipped).To(BeFalse())
+	g.Expect(report.Components[2].Path).To(Equal("components/foo/staging"))
+	g.Expect(report.Components[2].Added).To(Equal(3))
+}
+
+func TestBuildJSONReport_Empty(t *testing.T) {
+	g := NewWithT(t)
+
+	out, err := json.Marshal(buildJSONReport(&renderdiff.DiffResult{}, "abc123", "def456"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).To(ContainSubstring(`"components":[]`))
+}
+
+func TestBuildJSONReport_Suppressed(t *testing.T) {
+	g := NewWithT(t)
+
+	out, err := json.Marshal(buildJSONReport(&renderdiff.DiffResult{
+		Suppressed: []renderdiff.Suppression{{Rule: "env-order", Lines: 4}},
+	}, "abc123", "def456"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).To(ContainSubstring(`"suppressed":[{"rule":"env-order","lines":4}]`))
+}
+
+func TestWriteJSONReport_ToFile(t *testing.T) {
+	g := NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), "render-diff.json")
+	result := &renderdiff.DiffResult{
+		Diffs: []renderdiff.ComponentDiff{
+			{
+				Path: "components/foo/staging", Env: "staging", Added: 1, Removed: 1, Diff: "~ v1 ConfigMap ns/a\n",
+				Resources: []renderdiff.ResourceChange{{
+					APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "a", Type: renderdiff.ChangeModified,
+					Fields: []renderdiff.FieldChange{{Path: "data.key", Type: renderdiff.ChangeModified, Old: "x", New: "y"}},
+				}},
+			},
+		},
+	}
+
+	g.Expect(writeJSONReport(result, path, "abc123", "def456")).To(Succeed())
+
+	data, err := os.ReadFile(path)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var decoded map[string]any
+	g.Expect(json.Unmarshal(data, &decoded)).To(Succeed())
+	g.Expect(decoded["schemaVersion"]).To(Equal("render-diff/v1"))
+
+	components := decoded["components"].([]any)
+	g.Expect(components).To(HaveLen(1))
+	resources := components[0].(map[string]any)["resources"].([]any)
+	g.Expect(resources[0].(map[string]any)["kind"]).To(Equal("ConfigMap"))
+}
+
+func TestBuildJSONReport_UncommittedFiles(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &renderdiff.DiffResult{Uncommitted: []string{"components/foo/base/new.yaml"}}
+	out, err := json.Marshal(buildJSONReport(result, "abc123", "def456"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).To(ContainSubstring(`"uncommittedFiles":["components/foo/base/new.yaml"]`))
+
+	out, err = json.Marshal(buildJSONReport(&renderdiff.DiffResult{}, "abc123", "def456"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).NotTo(ContainSubstring("uncommittedFiles"))
+}
@@ -9,13 +9,15 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"syscall"
 
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/buildcache"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/git"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/logging"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/policy"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/schema"
 )
 
 // version is set via -ldflags at build time.
@@ -23,15 +25,32 @@ var version = "dev"
 
 func main() {
 	var (
-		repoRoot    = flag.String("repo-root", "", "Path to the repository root (default: auto-detect via git)")
-		baseRef     = flag.String("base-ref", "", "Base git ref to compare against (default: merge-base with main)")
-		overlaysDir = flag.String("overlays-dir", "argo-cd-apps/overlays", "Path to overlays directory relative to repo root")
-		color       = flag.String("color", "auto", "Color output: auto, always, never")
-		openDiff    = flag.Bool("open", false, "Open diffs in $DIFFTOOL or git difftool")
-		outputDir   = flag.String("output-dir", "", "Write per-component .diff files to this directory")
-		outputMode  = flag.String("output-mode", "local", "Output mode: local, ci-summary, ci-comment, ci-artifact-dir")
-		showVersion = flag.Bool("version", false, "Print version and exit")
-		logFile     = flag.String("log-file", "", "Write debug-level logs to this file")
+		repoRoot      = flag.String("repo-root", "", "Path to the repository root (default: auto-detect via git)")
+		baseRef       = flag.String("base-ref", "", "Base git ref to compare against (default: merge-base with main)")
+		headRef       = flag.String("head-ref", "", "Git ref to render as HEAD from a worktree (default: the working copy)")
+		gitBackend    = flag.String("git-backend", "exec", "Git backend: exec (git binary and worktrees), go (in-process, reads trees from the object database)")
+		uncommitted   = flag.Bool("include-uncommitted", false, "Treat staged, unstaged and untracked files as changed when detecting affected components")
+		overlaysDir   = flag.String("overlays-dir", "argo-cd-apps/overlays", "Path to overlays directory relative to repo root")
+		color         = flag.String("color", "auto", "Color output: auto, always, never")
+		openDiff      = flag.Bool("open", false, "Open diffs in $DIFFTOOL or git difftool")
+		outputDir     = flag.String("output-dir", "", "Write per-component .diff files to this directory")
+		outputMode    = flag.String("output-mode", "local", "Output mode: local, ci-summary, ci-comment, ci-artifact-dir, json, html")
+		jsonFile      = flag.String("json-file", "", "Write json output mode to this file instead of stdout")
+		htmlFile      = flag.String("html-file", "render-diff.html", "Write the html output mode report to this file")
+		envFilter     = flag.String("env", "", "Only render these environments (comma-separated: development, staging, production)")
+		cluster       = flag.String("cluster", "", "Only render these cluster directories (comma-separated)")
+		component     = flag.String("component", "", "Only render component paths matching these globs (comma-separated)")
+		diffFormat    = flag.String("diff-format", "unified", "Diff format: unified (line-based), semantic (per-resource field changes)")
+		showVersion   = flag.Bool("version", false, "Print version and exit")
+		logFile       = flag.String("log-file", "", "Write debug-level logs to this file")
+		schemaDir     = flag.String("schema-dir", "", "Validate HEAD renders against OpenAPI/CRD schemas vendored in this directory")
+		validate      = flag.Bool("validate", false, "Validate HEAD renders against the CRDs the affected components render (implied by --schema-dir)")
+		policyFile    = flag.String("policy-file", "", "Evaluate deny/warn rules from this YAML file against changed resources")
+		redact        = flag.String("redact", "auto", "Replace Secret data and --redact-file fields with hashes: auto (in every output mode but local), always, never")
+		redactFile    = flag.String("redact-file", "", "Also redact the fields selected by the rules in this YAML file")
+		normalizeFile = flag.String("normalize-file", "", "Apply the normalize rules in this YAML file to both renders before diffing")
+		noCache       = flag.Bool("no-cache", false, "Disable the persistent kustomize build cache")
+		cacheDir      = flag.String("cache-dir", "", "Directory for the kustomize build cache (default: user cache dir)")
 	)
 	flag.Parse()
 
@@ -43,7 +62,7 @@ func main() {
 	// Parse and validate output modes (comma-separated).
 	modes := parseOutputModes(*outputMode)
 	if len(modes) == 0 {
-		fmt.Fprintf(os.Stderr, "invalid --output-mode %q: must be one or more of local, ci-summary, ci-comment, ci-artifact-dir (comma-separated)\n", *outputMode)
+		fmt.Fprintf(os.Stderr, "invalid --output-mode %q: must be one or more of local, ci-summary, ci-comment, ci-artifact-dir, json, html (comma-separated)\n", *outputMode)
 		os.Exit(1)
 	}
 
@@ -55,6 +74,73 @@ func main() {
 		os.Exit(1)
 	}
 
+	switch *redact {
+	case "auto", "always", "never":
+		// valid
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --redact %q: must be one of auto, always, never\n", *redact)
+		os.Exit(1)
+	}
+
+	switch renderdiff.DiffFormat(*diffFormat) {
+	case renderdiff.DiffFormatUnified, renderdiff.DiffFormatSemantic:
+		// valid
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --diff-format %q: must be one of unified, semantic\n", *diffFormat)
+		os.Exit(1)
+	}
+
+	switch git.Backend(*gitBackend) {
+	case git.BackendExec, git.BackendGo:
+		// valid
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --git-backend %q: must be one of exec, go\n", *gitBackend)
+		os.Exit(1)
+	}
+
+	if *uncommitted && *headRef != "" {
+		fmt.Fprintln(os.Stderr, "--include-uncommitted cannot be combined with --head-ref")
+		os.Exit(1)
+	}
+
+	sel, err := parseSelection(*envFilter, *cluster, *component)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Load rules up front so a broken policy file fails before any git work.
+	var rules *policy.RuleSet
+	if *policyFile != "" {
+		rules, err = policy.Load(*policyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	var normalizer *renderdiff.Normalizer
+	if *normalizeFile != "" {
+		normalizer, err = renderdiff.LoadNormalizer(*normalizeFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	var redactor *renderdiff.Redactor
+	if redacts(*redact, modes) {
+		if *redactFile != "" {
+			redactor, err = renderdiff.LoadRedactor(*redactFile)
+		} else {
+			redactor, err = renderdiff.NewRedactor()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
 	// Set up logging
 	logCleanup, err := logging.Setup(*logFile)
 	if err != nil {
@@ -68,67 +154,100 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	// Auto-detect repo root via git if not specified.
-	if *repoRoot == "" {
-		detected, err := git.TopLevel(ctx)
-		if err != nil {
-			logging.Fatal("auto-detecting repo root; use --repo-root to specify explicitly", "err", err)
-		}
-		repoRoot = &detected
-	}
-
-	absRepoRoot, err := filepath.Abs(*repoRoot)
+	// Open the repository, auto-detecting the root if not specified.
+	repo, err := git.Open(ctx, git.Backend(*gitBackend), *repoRoot)
 	if err != nil {
-		logging.Fatal("resolving repo root", "err", err)
+		logging.Fatal("opening repository; use --repo-root to specify explicitly", "err", err)
 	}
 
-	// Resolve base ref: default to merge-base with main
+	// Resolve base ref: default to merge-base with main. With --head-ref the
+	// merge-base is taken from that ref rather than the checked-out HEAD.
+	effectiveHeadRef := *headRef
+	if effectiveHeadRef == "" {
+		effectiveHeadRef = "HEAD"
+	}
 	effectiveBaseRef := *baseRef
 	if effectiveBaseRef == "" {
-		effectiveBaseRef, err = git.MergeBase(ctx, absRepoRoot, "main")
+		effectiveBaseRef, err = repo.MergeBase(ctx, effectiveHeadRef, "main")
 		if err != nil {
 			logging.Fatal("could not compute merge-base with main; use --base-ref to specify explicitly", "err", err)
 		}
 	}
 
-	baseSHA, err := git.ResolveRef(ctx, absRepoRoot, effectiveBaseRef)
+	baseSHA, err := repo.ResolveRef(ctx, effectiveBaseRef)
 	if err != nil {
 		logging.Fatal("resolving base ref", "err", err)
 	}
-
-	headSHA, err := git.ResolveRef(ctx, absRepoRoot, "HEAD")
+	headSHA, err := repo.ResolveRef(ctx, effectiveHeadRef)
 	if err != nil {
-		logging.Fatal("resolving HEAD", "err", err)
+		logging.Fatal("resolving head ref", "err", err)
 	}
 	slog.Info("Comparing refs", "head", headSHA, "base", baseSHA)
 
+	outOpts := outputOptions{
+		colorMode: *color,
+		openDiff:  *openDiff,
+		outputDir: *outputDir,
+		jsonFile:  *jsonFile,
+		htmlFile:  *htmlFile,
+		headSHA:   headSHA,
+		baseSHA:   baseSHA,
+	}
+
 	// Step 1: Get changed files
-	changedFiles, err := git.ChangedFiles(ctx, absRepoRoot, effectiveBaseRef)
+	var changedFiles []string
+	if *uncommitted {
+		changedFiles, outOpts.uncommitted, err = git.ChangedFilesWithUncommitted(ctx, repo, effectiveBaseRef)
+	} else {
+		changedFiles, err = repo.ChangedFiles(ctx, effectiveBaseRef, effectiveHeadRef)
+	}
 	if err != nil {
 		logging.Fatal("getting changed files", "err", err)
 	}
 	if len(changedFiles) == 0 {
-		fmt.Println("No changed files detected — nothing to diff.")
+		slog.Info("No changed files detected — nothing to diff")
 		// Best-effort: update CI comment/summary so they don't stay stale.
 		// Failures here are non-fatal since there is nothing to report.
-		_ = runAllOutputModes(ctx, modes, &renderdiff.DiffResult{}, *color, *openDiff, *outputDir, headSHA, baseSHA)
+		_ = runAllOutputModes(ctx, modes, &renderdiff.DiffResult{}, outOpts)
 		return
 	}
-	slog.Info("Changed files detected", "count", len(changedFiles))
+	slog.Info("Changed files detected", "count", len(changedFiles), "uncommitted", len(outOpts.uncommitted))
+	for _, f := range outOpts.uncommitted {
+		slog.Debug("Uncommitted change", "file", f)
+	}
 
-	// Step 2: Create worktree at base ref
-	worktreePath, cleanup, err := git.CreateWorktree(ctx, absRepoRoot, effectiveBaseRef)
+	// Step 2: Check out the base ref, and the head ref when one is given;
+	// otherwise HEAD is rendered from the working copy.
+	baseTree, cleanup, err := repo.Checkout(ctx, effectiveBaseRef)
 	if err != nil {
-		logging.Fatal("creating worktree", "err", err)
+		logging.Fatal("checking out base ref", "err", err)
 	}
 	defer cleanup()
 
-	headRef := detector.NewRepoRef(absRepoRoot)
-	baseRefRepo := detector.NewRepoRef(worktreePath)
+	headTree := git.WorkingTree(repo.Root())
+	if *headRef != "" {
+		var headCleanup func()
+		headTree, headCleanup, err = repo.Checkout(ctx, *headRef)
+		if err != nil {
+			logging.Fatal("checking out head ref", "err", err)
+		}
+		defer headCleanup()
+	}
+
+	var buildCache *buildcache.Cache
+	if !*noCache {
+		buildCache, err = buildcache.Open(*cacheDir)
+		if err != nil {
+			slog.Warn("build cache unavailable, building without cache", "err", err)
+		}
+	}
+
+	headRepo := detector.NewRepoRef(headTree.Root, detector.WithFileSystem(headTree.FS), detector.WithBuildCache(buildCache))
+	baseRepo := detector.NewRepoRef(baseTree.Root, detector.WithFileSystem(baseTree.FS), detector.WithBuildCache(buildCache))
 
 	// Step 3: Detect affected components
 	slog.Info("Detecting affected components...")
-	d, err := detector.NewDetector(headRef, baseRefRepo, *overlaysDir)
+	d, err := detector.NewDetector(headRepo, baseRepo, *overlaysDir)
 	if err != nil {
 		logging.Fatal("initializing detector", "err", err)
 	}
@@ -137,26 +256,63 @@ func main() {
 		logging.Fatal("detecting affected components", "err", err)
 	}
 
+	// Apply --env / --cluster / --component selection.
+	affected, filtered := sel.apply(affected)
+	outOpts.filtered = filtered
+	if filtered > 0 {
+		slog.Info("Filtered out component paths by selection", "count", filtered)
+	}
+
 	// Count total jobs
 	totalJobs := 0
 	for _, paths := range affected {
 		totalJobs += len(paths)
 	}
 	if totalJobs == 0 {
-		fmt.Println("No affected components detected — nothing to diff.")
+		if filtered > 0 {
+			slog.Info("No affected components match the selection — nothing to diff", "filtered", filtered)
+		} else {
+			slog.Info("No affected components detected — nothing to diff")
+		}
 		// Best-effort: update CI comment/summary so they don't stay stale.
 		// Failures here are non-fatal since there is nothing to report.
-		_ = runAllOutputModes(ctx, modes, &renderdiff.DiffResult{}, *color, *openDiff, *outputDir, headSHA, baseSHA)
+		_ = runAllOutputModes(ctx, modes, &renderdiff.DiffResult{Filtered: filtered, Uncommitted: outOpts.uncommitted}, outOpts)
 		return
 	}
 	slog.Info("Affected component paths detected", "count", totalJobs)
 
 	// Step 4: Run render-diff engine (once for all output modes).
-	engine := renderdiff.NewEngine(headRef, baseRefRepo, totalJobs)
+	engineOpts := []renderdiff.EngineOption{renderdiff.WithDiffFormat(renderdiff.DiffFormat(*diffFormat))}
+	if *schemaDir != "" {
+		validator, err := schema.Load(*schemaDir)
+		if err != nil {
+			logging.Fatal("loading schemas", "err", err)
+		}
+		slog.Info("Schema validation enabled", "dir", *schemaDir, "kinds", validator.Kinds())
+		engineOpts = append(engineOpts, renderdiff.WithValidator(validator))
+	} else if *validate {
+		slog.Info("Schema validation enabled against rendered CRDs")
+		engineOpts = append(engineOpts, renderdiff.WithValidator(schema.New()))
+	}
+	if rules != nil {
+		slog.Info("Policy evaluation enabled", "file", *policyFile, "rules", len(rules.Rules))
+		engineOpts = append(engineOpts, renderdiff.WithPolicy(rules))
+	}
+	if normalizer != nil {
+		slog.Info("Normalize rules enabled", "file", *normalizeFile)
+		engineOpts = append(engineOpts, renderdiff.WithNormalizer(normalizer))
+	}
+	if redactor != nil {
+		slog.Info("Redacting sensitive values", "file", *redactFile)
+		engineOpts = append(engineOpts, renderdiff.WithRedactor(redactor))
+	}
+	engine := renderdiff.NewEngine(headRepo, baseRepo, totalJobs, engineOpts...)
 
 	// For local mode (single mode only), use progressive output.
 	if len(modes) == 1 && modes[0] == OutputModeLocal {
-		runLocal(ctx, engine, affected, *color, *openDiff, *outputDir)
+		result := runLocal(ctx, engine, affected, outOpts)
+		buildCache.LogStats()
+		exitOnDenials(result)
 		return
 	}
 
@@ -165,8 +321,26 @@ func main() {
 	if err != nil {
 		logging.Fatal("render-diff failed", "err", err)
 	}
+	result.Filtered = filtered
+	result.Uncommitted = outOpts.uncommitted
+	buildCache.LogStats()
 
-	if hadError := runAllOutputModes(ctx, modes, result, *color, *openDiff, *outputDir, headSHA, baseSHA); hadError {
+	if hadError := runAllOutputModes(ctx, modes, result, outOpts); hadError {
 		os.Exit(1)
 	}
+	exitOnDenials(result)
+}
+
+// exitPolicyDenied is the exit code when a deny rule fired, distinct from the
+// exit code 1 of other failures so that CI can tell them apart.
+const exitPolicyDenied = 3
+
+// exitOnDenials exits with exitPolicyDenied when any policy rule with
+// severity deny fired. It runs after every output mode so the findings are
+// always reported first.
+func exitOnDenials(result *renderdiff.DiffResult) {
+	if result.TotalDenied > 0 {
+		slog.Error("policy check failed", "denied", result.TotalDenied, "warned", result.TotalWarned)
+		os.Exit(exitPolicyDenied)
+	}
 }
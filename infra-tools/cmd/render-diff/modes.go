@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
@@ -16,14 +17,33 @@ const (
 	OutputModeCISummary  OutputMode = "ci-summary"
 	OutputModeCIComment  OutputMode = "ci-comment"
 	OutputModeCIArtifact OutputMode = "ci-artifact-dir"
+	OutputModeJSON       OutputMode = "json"
+	OutputModeHTML       OutputMode = "html"
 )
 
+// outputOptions carries the flag values and ref metadata shared by the
+// output modes.
+type outputOptions struct {
+	colorMode string
+	openDiff  bool
+	outputDir string
+	jsonFile  string
+	htmlFile  string
+	headSHA   string
+	baseSHA   string
+	// filtered is the number of jobs excluded by the selection flags.
+	filtered int
+	// uncommitted lists the changed files that came from the working tree
+	// with --include-uncommitted.
+	uncommitted []string
+}
+
 // runAllOutputModes runs every configured output mode against the given result.
 // Returns true if any mode failed.
-func runAllOutputModes(ctx context.Context, modes []OutputMode, result *renderdiff.DiffResult, colorMode string, openDiff bool, outputDir, headSHA, baseSHA string) bool {
+func runAllOutputModes(ctx context.Context, modes []OutputMode, result *renderdiff.DiffResult, opts outputOptions) bool {
 	var hadError bool
 	for _, m := range modes {
-		if err := runOutputMode(ctx, m, result, colorMode, openDiff, outputDir, headSHA, baseSHA); err != nil {
+		if err := runOutputMode(ctx, m, result, opts); err != nil {
 			slog.Error("output mode failed", "mode", m, "err", err)
 			hadError = true
 		}
@@ -43,7 +63,7 @@ func parseOutputModes(raw string) []OutputMode {
 		}
 		m := OutputMode(s)
 		switch m {
-		case OutputModeLocal, OutputModeCISummary, OutputModeCIComment, OutputModeCIArtifact:
+		case OutputModeLocal, OutputModeCISummary, OutputModeCIComment, OutputModeCIArtifact, OutputModeJSON, OutputModeHTML:
 			if !seen[m] {
 				seen[m] = true
 				modes = append(modes, m)
@@ -54,3 +74,19 @@ func parseOutputModes(raw string) []OutputMode {
 	}
 	return modes
 }
+
+// redacts reports whether a run with the given modes redacts sensitive
+// values under --redact=auto: only the local terminal output stays on the
+// machine that ran it, so any other mode turns redaction on for the whole
+// run.
+func redacts(redact string, modes []OutputMode) bool {
+	switch redact {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return slices.ContainsFunc(modes, func(m OutputMode) bool {
+		return m != OutputModeLocal
+	})
+}
@@ -17,6 +17,7 @@ func TestParseOutputModes_Single(t *testing.T) {
 	g.Expect(parseOutputModes("ci-summary")).To(Equal([]OutputMode{OutputModeCISummary}))
 	g.Expect(parseOutputModes("ci-comment")).To(Equal([]OutputMode{OutputModeCIComment}))
 	g.Expect(parseOutputModes("ci-artifact-dir")).To(Equal([]OutputMode{OutputModeCIArtifact}))
+	g.Expect(parseOutputModes("json")).To(Equal([]OutputMode{OutputModeJSON}))
 }
 
 func TestParseOutputModes_Multiple(t *testing.T) {
@@ -63,7 +64,7 @@ func TestRunAllOutputModes_RunsAllModes(t *testing.T) {
 	t.Setenv("GITHUB_STEP_SUMMARY", f.Name())
 
 	result := &renderdiff.DiffResult{}
-	hadError := runAllOutputModes(context.Background(), []OutputMode{OutputModeCISummary}, result, "never", false, "", "", "")
+	hadError := runAllOutputModes(context.Background(), []OutputMode{OutputModeCISummary}, result, outputOptions{colorMode: "never"})
 
 	g.Expect(hadError).To(BeFalse())
 
@@ -77,7 +78,19 @@ func TestRunAllOutputModes_ReportsFailure(t *testing.T) {
 
 	// ci-artifact-dir without --output-dir should fail.
 	result := &renderdiff.DiffResult{}
-	hadError := runAllOutputModes(context.Background(), []OutputMode{OutputModeCIArtifact}, result, "never", false, "", "", "")
+	hadError := runAllOutputModes(context.Background(), []OutputMode{OutputModeCIArtifact}, result, outputOptions{colorMode: "never"})
 
 	g.Expect(hadError).To(BeTrue())
 }
+
+func TestRedacts(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(redacts("auto", []OutputMode{OutputModeLocal})).To(BeFalse())
+	g.Expect(redacts("auto", []OutputMode{OutputModeJSON, OutputModeCIComment})).To(BeTrue())
+	g.Expect(redacts("auto", []OutputMode{OutputModeCIArtifact})).To(BeTrue())
+	g.Expect(redacts("auto", []OutputMode{OutputModeJSON})).To(BeTrue())
+	g.Expect(redacts("auto", []OutputMode{OutputModeLocal, OutputModeHTML})).To(BeTrue())
+	g.Expect(redacts("never", []OutputMode{OutputModeCISummary})).To(BeFalse())
+	g.Expect(redacts("always", []OutputMode{OutputModeLocal})).To(BeTrue())
+}
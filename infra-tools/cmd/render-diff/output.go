@@ -11,27 +11,31 @@ import (
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/logging"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/policy"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
 )
 
 // runLocal handles the default local output mode with progressive output.
-func runLocal(ctx context.Context, engine *renderdiff.Engine, affected map[detector.Environment][]appset.ComponentPath, colorMode string, openDiff bool, outputDir string) {
-	useColor := shouldUseColor(colorMode)
+// It returns the aggregate result so the caller can act on policy denials.
+func runLocal(ctx context.Context, engine *renderdiff.Engine, affected map[detector.Environment][]appset.ComponentPath, opts outputOptions) *renderdiff.DiffResult {
+	useColor := shouldUseColor(opts.colorMode)
 
-	if outputDir != "" {
+	if opts.outputDir != "" {
 		// Write to directory mode
 		result, err := engine.Run(ctx, affected)
 		if err != nil {
 			logging.Fatal("render-diff failed", "err", err)
 		}
-		if err := writeDiffFiles(result, outputDir); err != nil {
+		result.Filtered = opts.filtered
+		result.Uncommitted = opts.uncommitted
+		if err := writeDiffFiles(result, opts.outputDir); err != nil {
 			logging.Fatal("writing diff files", "err", err)
 		}
 		printSummary(result)
-		return
+		return result
 	}
 
-	if openDiff {
+	if opts.openDiff {
 		// Open in external diff tool
 		result, err := engine.Run(ctx, affected)
 		if err != nil {
@@ -40,12 +44,14 @@ func runLocal(ctx context.Context, engine *renderdiff.Engine, affected map[detec
 		if err := openInDiffTool(result); err != nil {
 			logging.Fatal("opening diff tool", "err", err)
 		}
-		return
+		return result
 	}
 
 	// Progressive output to stdout
 	ch := make(chan renderdiff.ComponentDiff, 10)
+	printed := make(chan struct{})
 	go func() {
+		defer close(printed)
 		for cd := range ch {
 			printComponentDiff(cd, useColor)
 		}
@@ -55,7 +61,13 @@ func runLocal(ctx context.Context, engine *renderdiff.Engine, affected map[detec
 	if err != nil {
 		logging.Fatal("render-diff failed", "err", err)
 	}
+	// RunProgressive closes ch; wait for the last diff to be printed so it
+	// doesn't interleave with the summary.
+	<-printed
+	result.Filtered = opts.filtered
+	result.Uncommitted = opts.uncommitted
 	printSummary(result)
+	return result
 }
 
 // printComponentDiff prints a single component's diff to stdout.
@@ -75,7 +87,7 @@ func printComponentDiff(cd renderdiff.ComponentDiff, useColor bool) {
 		fmt.Println()
 		return
 	}
-	header := fmt.Sprintf("=== %s (%s) === +%d -%d", cd.Path, cd.Env, cd.Added, cd.Removed)
+	header := fmt.Sprintf("=== %s (%s) === +%d -%d%s", cd.Path, cd.Env, cd.Added, cd.Removed, renderdiff.IssueSuffix(cd))
 	if useColor {
 		fmt.Printf("\033[1;36m%s\033[0m\n", header)
 		colorDiff(cd.Diff)
@@ -83,10 +95,65 @@ func printComponentDiff(cd renderdiff.ComponentDiff, useColor bool) {
 		fmt.Println(header)
 		fmt.Print(cd.Diff)
 	}
+	printDangerous(cd, useColor)
+	printViolations(cd, useColor)
+	printFindings(cd, useColor)
+	printDeprecations(cd, useColor)
 	fmt.Println()
 }
 
-// colorDiff prints a unified diff with ANSI colors.
+// printDangerous lists the deletions and immutable-field changes in a
+// component's diff.
+func printDangerous(cd renderdiff.ComponentDiff, useColor bool) {
+	dangerous := cd.DangerousChanges()
+	if len(dangerous) == 0 {
+		return
+	}
+	fmt.Println("Destructive changes:")
+	for _, c := range dangerous {
+		if useColor {
+			fmt.Printf("\033[1;31m  ! %s\033[0m\n", c)
+		} else {
+			fmt.Printf("  ! %s\n", c)
+		}
+	}
+}
+
+// printViolations lists the schema violations found in a component's HEAD render.
+func printViolations(cd renderdiff.ComponentDiff, useColor bool) {
+	if len(cd.Violations) == 0 {
+		return
+	}
+	fmt.Println("Schema violations:")
+	for _, v := range cd.Violations {
+		if useColor {
+			fmt.Printf("\033[33m  ! %s\033[0m\n", v)
+		} else {
+			fmt.Printf("  ! %s\n", v)
+		}
+	}
+}
+
+// printFindings lists the policy findings for a component's changes. Denials
+// are shown in red and warnings in yellow.
+func printFindings(cd renderdiff.ComponentDiff, useColor bool) {
+	if len(cd.Findings) == 0 {
+		return
+	}
+	fmt.Println("Policy findings:")
+	for _, f := range cd.Findings {
+		switch {
+		case !useColor:
+			fmt.Printf("  %s\n", f)
+		case f.Severity == policy.SeverityDeny:
+			fmt.Printf("\033[31m  %s\033[0m\n", f)
+		default:
+			fmt.Printf("\033[33m  %s\033[0m\n", f)
+		}
+	}
+}
+
+// colorDiff prints a unified or semantic diff with ANSI colors.
 func colorDiff(diff string) {
 	for _, line := range strings.Split(diff, "\n") {
 		if len(line) == 0 {
@@ -102,6 +169,8 @@ func colorDiff(diff string) {
 			fmt.Printf("\033[32m%s\033[0m\n", line)
 		case line[0] == '-':
 			fmt.Printf("\033[31m%s\033[0m\n", line)
+		case line[0] == '~':
+			fmt.Printf("\033[1;33m%s\033[0m\n", line)
 		default:
 			fmt.Println(line)
 		}
@@ -112,11 +181,14 @@ func colorDiff(diff string) {
 func printSummary(result *renderdiff.DiffResult) {
 	if len(result.Diffs) == 0 {
 		fmt.Println("\nNo render differences detected.")
+		printFiltered(result.Filtered)
+		printSuppressed(result.Suppressed)
+		printUncommitted(result.Uncommitted)
 		return
 	}
 
 	fmt.Println("\n--- Summary ---")
-	sortDiffs(result.Diffs)
+	renderdiff.SortDiffs(result.Diffs)
 	for _, d := range result.Diffs {
 		if d.SkipOutput {
 			continue
@@ -124,10 +196,80 @@ func printSummary(result *renderdiff.DiffResult) {
 		if d.Error != "" {
 			fmt.Printf("  %s (%s): BUILD ERROR\n", d.Path, d.Env)
 		} else {
-			fmt.Printf("  %s (%s): +%d -%d\n", d.Path, d.Env, d.Added, d.Removed)
+			fmt.Printf("  %s (%s): +%d -%d%s\n", d.Path, d.Env, d.Added, d.Removed, renderdiff.IssueSuffix(d))
 		}
 	}
 	fmt.Printf("\nTotal: %d components, +%d -%d lines\n", len(result.Diffs), result.TotalAdded, result.TotalRemoved)
+	if result.TotalDangerous > 0 {
+		fmt.Printf("Destructive changes: %d (%d Namespace/CRD/PVC deletions)\n", result.TotalDangerous, len(result.GuardedDeletions()))
+		for _, d := range result.Diffs {
+			for _, c := range d.DangerousChanges() {
+				fmt.Printf("  %s (%s): %s\n", d.Path, d.Env, c)
+			}
+		}
+	}
+	if result.TotalViolations > 0 {
+		fmt.Printf("Schema violations: %d\n", result.TotalViolations)
+	}
+	if result.TotalDenied > 0 || result.TotalWarned > 0 {
+		fmt.Printf("Policy findings: %d denied, %d warnings\n", result.TotalDenied, result.TotalWarned)
+	}
+	if n := len(result.NewDeprecations()); n > 0 {
+		fmt.Printf("New deprecated kustomization fields: %d\n", n)
+	}
+	printFiltered(result.Filtered)
+	printSuppressed(result.Suppressed)
+	printUncommitted(result.Uncommitted)
+}
+
+// printDeprecations lists the deprecated kustomization fields of a
+// component's HEAD build. Those introduced on HEAD are shown in yellow.
+func printDeprecations(cd renderdiff.ComponentDiff, useColor bool) {
+	if len(cd.Deprecations) == 0 {
+		return
+	}
+	fmt.Println("Deprecated kustomization fields:")
+	for _, d := range cd.Deprecations {
+		if useColor && d.New {
+			fmt.Printf("\033[33m  %s\033[0m\n", d)
+		} else {
+			fmt.Printf("  %s\n", d)
+		}
+	}
+}
+
+// printFiltered reports how many jobs the selection flags excluded.
+func printFiltered(filtered int) {
+	if filtered > 0 {
+		fmt.Printf("Filtered out: %d component paths (--env/--cluster/--component)\n", filtered)
+	}
+}
+
+// printSuppressed reports the diff lines each normalize rule removed.
+func printSuppressed(suppressed []renderdiff.Suppression) {
+	if len(suppressed) == 0 {
+		return
+	}
+	total := 0
+	for _, s := range suppressed {
+		total += s.Lines
+	}
+	fmt.Printf("Suppressed by normalize rules: %d lines\n", total)
+	for _, s := range suppressed {
+		fmt.Printf("  %s: %d\n", s.Rule, s.Lines)
+	}
+}
+
+// printUncommitted lists the changed files that were taken from the working
+// tree with --include-uncommitted.
+func printUncommitted(files []string) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Printf("Uncommitted changes included: %d files\n", len(files))
+	for _, f := range files {
+		fmt.Printf("  %s\n", f)
+	}
 }
 
 // shouldUseColor determines whether to use ANSI colors based on the --color flag.
@@ -0,0 +1,626 @@
+package appset
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RepoTree is read access to the repository tree that git generators are
+// evaluated against. Paths are relative to the repo root.
+type RepoTree interface {
+	DirExists(rel string) bool
+	ReadDir(rel string) ([]string, error)
+	ReadFile(rel string) ([]byte, error)
+}
+
+// unsupportedGenerators need live cluster or SCM state that isn't available
+// offline, so the applications they produce can't be detected.
+var unsupportedGenerators = []string{"clusterDecisionResource", "plugin", "pullRequest", "scmProvider"}
+
+// paramSet is one set of template parameters produced by a generator. In
+// fasttemplate mode the keys are Argo CD's flat ones ("values.environment",
+// "path[0]") with string values; in goTemplate mode parameters are nested
+// (.values.environment, .path.segments).
+type paramSet struct {
+	params map[string]interface{}
+	// cluster is set when the parameters came from a clusters generator.
+	cluster bool
+	// anyCluster marks the placeholder a clusters generator emits in place of
+	// the real clusters, which only exist as secrets on the Argo CD instance.
+	// Its name, nameNormalized and server are left as unresolved tags, and
+	// it stands for every cluster not named by a merge.
+	anyCluster bool
+}
+
+// clusterName returns the name of the cluster a parameter set targets, or ""
+// for the placeholder and for sets that don't come from a clusters generator.
+func (ps paramSet) clusterName() string {
+	if !ps.cluster || ps.anyCluster {
+		return ""
+	}
+	for _, key := range []string{"nameNormalized", "name"} {
+		v, _ := lookup(ps.params, key)
+		if n := scalarString(v); n != "" && unresolvedIndex(n) < 0 {
+			return n
+		}
+	}
+	return ""
+}
+
+// lookup returns the parameter key names: a flat key, or a dotted path into
+// nested goTemplate parameters.
+func lookup(params map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := params[key]; ok {
+		return v, true
+	}
+	var cur interface{} = params
+	for _, seg := range strings.Split(key, ".") {
+		switch t := cur.(type) {
+		case map[string]interface{}:
+			v, ok := t[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			cur = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// mergeParams overlays override on base. Nested goTemplate parameters are
+// merged recursively, as Argo CD does; flat ones replace whole values.
+func mergeParams(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		bm, bok := merged[k].(map[string]interface{})
+		om, ook := v.(map[string]interface{})
+		if bok && ook {
+			merged[k] = mergeParams(bm, om)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// evaluator expands ApplicationSet generators into parameter sets and
+// renders templates with them.
+type evaluator struct {
+	appSet            string
+	tree              RepoTree // nil when git generators can't be evaluated
+	goTemplate        bool
+	goTemplateOptions []string
+	warnings          []string
+}
+
+func (e *evaluator) warnf(format string, args ...any) {
+	e.warnings = append(e.warnings, fmt.Sprintf("ApplicationSet %s: ", e.appSet)+fmt.Sprintf(format, args...))
+}
+
+// generateAll evaluates a list of generators whose results are independent,
+// as in spec.generators.
+func (e *evaluator) generateAll(generators []interface{}) []paramSet {
+	var sets []paramSet
+	for _, gen := range generators {
+		genMap, ok := gen.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		sets = append(sets, e.generate(genMap)...)
+	}
+	return sets
+}
+
+// generate evaluates a single generator.
+func (e *evaluator) generate(gen map[string]interface{}) []paramSet {
+	if v, ok := gen["list"].(map[string]interface{}); ok {
+		return e.list(v)
+	}
+	if v, ok := gen["clusters"].(map[string]interface{}); ok {
+		return e.clusters(v)
+	}
+	if v, ok := gen["merge"].(map[string]interface{}); ok {
+		return e.merge(v)
+	}
+	if v, ok := gen["matrix"].(map[string]interface{}); ok {
+		return e.matrix(v)
+	}
+	if v, ok := gen["git"].(map[string]interface{}); ok {
+		return e.git(v)
+	}
+	for _, name := range unsupportedGenerators {
+		if _, ok := gen[name]; ok {
+			e.warnf("%s generator is not supported; its applications are not detected", name)
+			return nil
+		}
+	}
+	keys := make([]string, 0, len(gen))
+	for k := range gen {
+		if k != "selector" && k != "template" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	e.warnf("unknown generator %v; its applications are not detected", keys)
+	return nil
+}
+
+// clusters returns the placeholder standing for the clusters a clusters
+// generator would select. Its values are rendered with the placeholder's own
+// parameters, so those referring to the cluster stay unresolved.
+func (e *evaluator) clusters(gen map[string]interface{}) []paramSet {
+	params := map[string]interface{}{
+		"name":           "{{name}}",
+		"nameNormalized": "{{nameNormalized}}",
+		"server":         "{{server}}",
+	}
+	values := make(map[string]interface{})
+	raw, _ := gen["values"].(map[string]interface{})
+	for k, v := range raw {
+		rendered, err := e.render(scalarString(v), params)
+		if err != nil {
+			rendered = scalarString(v)
+		}
+		values[k] = rendered
+	}
+	if e.goTemplate {
+		params["metadata"] = map[string]interface{}{
+			"labels":      map[string]interface{}{},
+			"annotations": map[string]interface{}{},
+		}
+		params["values"] = values
+	} else {
+		for k, v := range values {
+			params["values."+k] = v
+		}
+	}
+	return []paramSet{{params: params, cluster: true, anyCluster: true}}
+}
+
+// list returns one parameter set per element. In fasttemplate mode a
+// "values" map is flattened into values.<key> parameters, matching Argo CD;
+// in goTemplate mode elements are used as they are.
+func (e *evaluator) list(gen map[string]interface{}) []paramSet {
+	elements, _ := gen["elements"].([]interface{})
+	if raw, ok := gen["elementsYaml"].(string); ok && raw != "" {
+		var extra []interface{}
+		if err := yaml.Unmarshal([]byte(raw), &extra); err != nil {
+			e.warnf("list generator elementsYaml: %v", err)
+		}
+		elements = append(elements, extra...)
+	}
+
+	var sets []paramSet
+	for _, elem := range elements {
+		elemMap, ok := elem.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		params := make(map[string]interface{}, len(elemMap))
+		for k, v := range elemMap {
+			if e.goTemplate {
+				params[k] = v
+				continue
+			}
+			if k == "values" {
+				for vk, vv := range prefixValues(v) {
+					params[vk] = vv
+				}
+				continue
+			}
+			params[k] = scalarString(v)
+		}
+		sets = append(sets, paramSet{params: params})
+	}
+	return sets
+}
+
+// merge combines its child generators like Argo CD's merge generator: the
+// first child supplies the base parameter sets, and each set from a later
+// child overrides the base sets whose merge keys it matches.
+//
+// A clusters placeholder matches any set that agrees with it on the merge
+// keys it has. A set that also supplies the keys it lacks (e.g.
+// nameNormalized) names a cluster and is added as one, since Argo CD would
+// only produce it if that cluster exists; the placeholder is kept for the
+// clusters no set names.
+func (e *evaluator) merge(gen map[string]interface{}) []paramSet {
+	children, _ := gen["generators"].([]interface{})
+	if len(children) == 0 {
+		return nil
+	}
+	var mergeKeys []string
+	keys, _ := gen["mergeKeys"].([]interface{})
+	for _, k := range keys {
+		if s, ok := k.(string); ok {
+			mergeKeys = append(mergeKeys, s)
+		}
+	}
+
+	first, _ := children[0].(map[string]interface{})
+	base := e.generate(first)
+	for _, child := range children[1:] {
+		childMap, ok := child.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		overrides := e.generate(childMap)
+		var added []paramSet
+		for i := range base {
+			b := &base[i]
+			for _, o := range overrides {
+				match, names := mergeKeysMatch(b, o, mergeKeys)
+				if !match {
+					continue
+				}
+				merged := paramSet{params: mergeParams(b.params, o.params), cluster: b.cluster || o.cluster}
+				if names {
+					added = append(added, merged)
+				} else {
+					merged.anyCluster = b.anyCluster
+					*b = merged
+				}
+			}
+		}
+		base = append(base, added...)
+	}
+	return base
+}
+
+// mergeKeysMatch reports whether override o applies to base set b, and
+// whether it does so by naming a cluster: supplying merge keys the clusters
+// placeholder lacks.
+func mergeKeysMatch(b *paramSet, o paramSet, mergeKeys []string) (match, names bool) {
+	if len(mergeKeys) == 0 {
+		return false, false
+	}
+	for _, k := range mergeKeys {
+		ov, ok := lookup(o.params, k)
+		if !ok {
+			return false, false
+		}
+		bv, ok := lookup(b.params, k)
+		if b.anyCluster && (!ok || unresolvedIndex(scalarString(bv)) >= 0) {
+			names = true
+			continue
+		}
+		if !ok || scalarString(bv) != scalarString(ov) {
+			return false, false
+		}
+	}
+	return true, names
+}
+
+// matrix returns the product of its two child generators. Parameters of the
+// first child are interpolated into the second before it is evaluated, so
+// e.g. a git generator can use a path from a list generator.
+func (e *evaluator) matrix(gen map[string]interface{}) []paramSet {
+	children, _ := gen["generators"].([]interface{})
+	if len(children) != 2 {
+		e.warnf("matrix generator needs exactly 2 child generators, got %d", len(children))
+		return nil
+	}
+	first, _ := children[0].(map[string]interface{})
+	second, _ := children[1].(map[string]interface{})
+
+	var sets []paramSet
+	for _, a := range e.generate(first) {
+		v, err := e.interpolate(second, a.params)
+		if err != nil {
+			e.warnf("matrix generator: %v", err)
+			continue
+		}
+		interpolated, _ := v.(map[string]interface{})
+		for _, b := range e.generate(interpolated) {
+			sets = append(sets, paramSet{
+				params:     mergeParams(a.params, b.params),
+				cluster:    a.cluster || b.cluster,
+				anyCluster: a.anyCluster || b.anyCluster,
+			})
+		}
+	}
+	return sets
+}
+
+// git evaluates a git directory or file generator against the local tree.
+// repoURL and revision are ignored: the generator is assumed to point at the
+// ref being analysed, which holds for every ApplicationSet in this repo.
+func (e *evaluator) git(gen map[string]interface{}) []paramSet {
+	if e.tree == nil {
+		e.warnf("git generator needs the repository tree; its applications are not detected")
+		return nil
+	}
+	prefix, _ := gen["pathParamPrefix"].(string)
+	values, _ := gen["values"].(map[string]interface{})
+
+	var sets []paramSet
+	add := func(params map[string]interface{}) {
+		rendered := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			r, err := e.render(scalarString(v), params)
+			if err != nil {
+				e.warnf("git generator values.%s: %v", k, err)
+			}
+			rendered[k] = r
+		}
+		if e.goTemplate {
+			if len(rendered) > 0 {
+				params["values"] = rendered
+			}
+		} else {
+			for k, v := range rendered {
+				params["values."+k] = v
+			}
+		}
+		sets = append(sets, paramSet{params: params})
+	}
+
+	if dirs, ok := gen["directories"].([]interface{}); ok {
+		for _, dir := range e.gitDirectories(dirs) {
+			params := make(map[string]interface{})
+			e.addPathParams(params, prefix, dir, "")
+			add(params)
+		}
+	}
+	if files, ok := gen["files"].([]interface{}); ok {
+		for _, f := range files {
+			fMap, _ := f.(map[string]interface{})
+			pattern, _ := fMap["path"].(string)
+			for _, file := range e.glob(pattern, false) {
+				for _, content := range e.gitFileContents(file) {
+					params := content
+					if !e.goTemplate {
+						params = flatten(content)
+					}
+					e.addPathParams(params, prefix, path.Dir(file), path.Base(file))
+					add(params)
+				}
+			}
+		}
+	}
+	return sets
+}
+
+// gitDirectories returns the directories matching any included pattern and
+// no excluded one.
+func (e *evaluator) gitDirectories(entries []interface{}) []string {
+	var include []string
+	var exclude []string
+	for _, entry := range entries {
+		m, _ := entry.(map[string]interface{})
+		pattern, _ := m["path"].(string)
+		if pattern == "" {
+			continue
+		}
+		if excluded, _ := m["exclude"].(bool); excluded {
+			exclude = append(exclude, pattern)
+		} else {
+			include = append(include, pattern)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, pattern := range include {
+		for _, dir := range e.glob(pattern, true) {
+			if seen[dir] || matchesAny(exclude, dir) {
+				continue
+			}
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// glob expands a slash-separated pattern one segment at a time against the
+// tree, returning directories when dirs is set and files otherwise. Like
+// path.Match, '*' never crosses a '/'.
+func (e *evaluator) glob(pattern string, dirs bool) []string {
+	if pattern == "" {
+		return nil
+	}
+	segments := strings.Split(strings.Trim(path.Clean(pattern), "/"), "/")
+	matches := []string{""}
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		var next []string
+		for _, parent := range matches {
+			if !strings.ContainsAny(seg, `*?[\`) {
+				next = append(next, path.Join(parent, seg))
+				continue
+			}
+			names, err := e.tree.ReadDir(parent)
+			if err != nil {
+				continue
+			}
+			for _, name := range names {
+				if ok, _ := path.Match(seg, name); ok {
+					next = append(next, path.Join(parent, name))
+				}
+			}
+		}
+		matches = matches[:0]
+		for _, m := range next {
+			// Intermediate segments must be directories; the last one must
+			// be the requested kind.
+			isDir := e.tree.DirExists(m)
+			if (!last && isDir) || (last && isDir == dirs && (isDir || e.fileExists(m))) {
+				matches = append(matches, m)
+			}
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func (e *evaluator) fileExists(rel string) bool {
+	_, err := e.tree.ReadFile(rel)
+	return err == nil
+}
+
+// gitFileContents parses a JSON or YAML file from a git files generator. A
+// file holding a list yields one parameter set per element.
+func (e *evaluator) gitFileContents(file string) []map[string]interface{} {
+	data, err := e.tree.ReadFile(file)
+	if err != nil {
+		e.warnf("git generator reading %s: %v", file, err)
+		return nil
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		e.warnf("git generator parsing %s: %v", file, err)
+		return nil
+	}
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}
+	case []interface{}:
+		var out []map[string]interface{}
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				out = append(out, m)
+			}
+		}
+		return out
+	case nil:
+		return []map[string]interface{}{{}}
+	default:
+		e.warnf("git generator file %s is not an object or list", file)
+		return nil
+	}
+}
+
+// addPathParams sets the path parameters Argo CD's git generator derives
+// from a directory and, for the files generator, the file in it.
+func (e *evaluator) addPathParams(params map[string]interface{}, prefix, dir, file string) {
+	segments := strings.Split(dir, "/")
+	if e.goTemplate {
+		p := map[string]interface{}{
+			"path":               dir,
+			"basename":           path.Base(dir),
+			"basenameNormalized": sanitizeName(path.Base(dir)),
+		}
+		segs := make([]interface{}, len(segments))
+		for i, seg := range segments {
+			segs[i] = seg
+		}
+		p["segments"] = segs
+		if file != "" {
+			p["filename"] = file
+			p["filenameNormalized"] = sanitizeName(file)
+		}
+		if prefix != "" {
+			params[prefix] = map[string]interface{}{"path": p}
+		} else {
+			params["path"] = p
+		}
+		return
+	}
+
+	if prefix != "" {
+		prefix += "."
+	}
+	params[prefix+"path"] = dir
+	params[prefix+"path.basename"] = path.Base(dir)
+	params[prefix+"path.basenameNormalized"] = sanitizeName(path.Base(dir))
+	for i, seg := range segments {
+		if seg != "" {
+			params[prefix+"path["+strconv.Itoa(i)+"]"] = seg
+		}
+	}
+	if file != "" {
+		params[prefix+"path.filename"] = file
+		params[prefix+"path.filenameNormalized"] = sanitizeName(file)
+	}
+}
+
+var invalidNameChars = regexp.MustCompile(`[^-a-z0-9.]`)
+
+// sanitizeName normalizes a name into a valid DNS subdomain the way Argo CD
+// derives nameNormalized and path.basenameNormalized.
+func sanitizeName(name string) string {
+	name = invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return strings.Trim(name, "-.")
+}
+
+// prefixValues flattens a generator's values map into values.<key> params.
+func prefixValues(v interface{}) map[string]interface{} {
+	params := make(map[string]interface{})
+	values, _ := v.(map[string]interface{})
+	for k, val := range values {
+		params["values."+k] = scalarString(val)
+	}
+	return params
+}
+
+// flatten converts nested maps and lists into dotted keys ("a.b", "a.0"),
+// as Argo CD does for git file generator parameters.
+func flatten(doc map[string]interface{}) map[string]interface{} {
+	params := make(map[string]interface{})
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for k, val := range t {
+				walk(joinKey(prefix, k), val)
+			}
+		case []interface{}:
+			for i, val := range t {
+				walk(joinKey(prefix, strconv.Itoa(i)), val)
+			}
+		default:
+			params[prefix] = scalarString(t)
+		}
+	}
+	walk("", doc)
+	return params
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func scalarString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	default:
+		return fmt.Sprint(t)
+	}
+}
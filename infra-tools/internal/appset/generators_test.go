@@ -0,0 +1,325 @@
+package appset
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// fakeTree implements RepoTree over a map of repo-relative file paths.
+type fakeTree map[string]string
+
+func (t fakeTree) DirExists(rel string) bool {
+	if rel == "" {
+		return true
+	}
+	for f := range t {
+		if strings.HasPrefix(f, rel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (t fakeTree) ReadDir(rel string) ([]string, error) {
+	if !t.DirExists(rel) {
+		return nil, fmt.Errorf("no such dir: %s", rel)
+	}
+	prefix := rel + "/"
+	if rel == "" {
+		prefix = ""
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for f := range t {
+		if !strings.HasPrefix(f, prefix) {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(f, prefix), "/")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (t fakeTree) ReadFile(rel string) ([]byte, error) {
+	if c, ok := t[rel]; ok {
+		return []byte(c), nil
+	}
+	return nil, fmt.Errorf("no such file: %s", rel)
+}
+
+// appSetYAML wraps generators (indented under spec.generators) and a path
+// template into an ApplicationSet.
+func appSetYAML(path, generators string) string {
+	return `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: test
+spec:
+  generators:
+` + generators + `
+  template:
+    metadata:
+      name: test-{{nameNormalized}}
+    spec:
+      source:
+        path: '` + path + `'
+        repoURL: https://github.com/example/repo.git
+      destination:
+        server: '{{server}}'
+`
+}
+
+func pathsOf(result *ParseResult) []string {
+	var paths []string
+	for _, p := range result.Paths {
+		paths = append(paths, p.Path+"|"+p.ClusterDir)
+	}
+	return paths
+}
+
+func TestParseApplicationSets_ListGenerator(t *testing.T) {
+	g := NewWithT(t)
+
+	yaml := appSetYAML("components/{{component}}/{{values.env}}", `    - list:
+        elements:
+          - component: foo
+            values:
+              env: staging
+          - component: bar
+            values:
+              env: production
+        elementsYaml: |
+          - component: baz
+            values:
+              env: staging`)
+
+	result, err := ParseApplicationSets([]byte(yaml))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Warnings).To(BeEmpty())
+	g.Expect(pathsOf(result)).To(Equal([]string{
+		"components/foo/staging|",
+		"components/bar/production|",
+		"components/baz/staging|",
+	}))
+	g.Expect(result.Clusters).To(BeEmpty())
+}
+
+func TestParseApplicationSets_MergeOverridesClusterValues(t *testing.T) {
+	g := NewWithT(t)
+
+	// The list element overrides environment for one cluster, and a
+	// parameter only the clusters generator sets (useCaseDir) reaches the
+	// path of both the shared and the named cluster.
+	yaml := appSetYAML("{{values.sourceRoot}}/{{values.environment}}/{{values.useCaseDir}}", `    - merge:
+        mergeKeys:
+          - nameNormalized
+        generators:
+          - clusters:
+              values:
+                sourceRoot: components/groups
+                environment: staging-downstream
+                useCaseDir: rover
+          - list:
+              elements:
+                - nameNormalized: dev-01
+                  values.environment: staging
+                  values.useCaseDir: fas`)
+
+	result, err := ParseApplicationSets([]byte(yaml))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pathsOf(result)).To(Equal([]string{
+		"components/groups/staging-downstream/rover|",
+		"components/groups/staging/fas|dev-01",
+	}))
+	g.Expect(result.Clusters).To(Equal(map[string][]string{
+		"dev-01": {"components/groups/staging/fas"},
+	}))
+}
+
+func TestParseApplicationSets_MergeOnSharedKeyOverridesPlaceholder(t *testing.T) {
+	g := NewWithT(t)
+
+	// environment is known to the placeholder, so the list element applies
+	// to every cluster rather than naming a new one.
+	yaml := appSetYAML("{{values.sourceRoot}}/{{values.environment}}/{{values.variant}}", `    - merge:
+        mergeKeys:
+          - values.environment
+        generators:
+          - clusters:
+              values:
+                sourceRoot: components/foo
+                environment: staging
+                variant: a
+          - list:
+              elements:
+                - values.environment: staging
+                  values.variant: b
+                - values.environment: production
+                  values.variant: c`)
+
+	result, err := ParseApplicationSets([]byte(yaml))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pathsOf(result)).To(Equal([]string{"components/foo/staging/b|"}))
+}
+
+func TestParseApplicationSets_RingTemplate(t *testing.T) {
+	g := NewWithT(t)
+
+	yaml := appSetYAML("{{values.sourceRoot}}/rings/{{values.ring}}/{{values.clusterDir}}", `    - merge:
+        mergeKeys:
+          - nameNormalized
+        generators:
+          - clusters:
+              values:
+                sourceRoot: components/foo
+                ring: ring-0
+                clusterDir: base
+          - list:
+              elements:
+                - nameNormalized: prod-01
+                  values.ring: ring-2
+                  values.clusterDir: prod-01`)
+
+	result, err := ParseApplicationSets([]byte(yaml))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pathsOf(result)).To(Equal([]string{
+		"components/foo/rings/ring-0/base|base",
+		"components/foo/rings/ring-2/prod-01|prod-01",
+	}))
+}
+
+func TestParseApplicationSets_MatrixWithGitDirectories(t *testing.T) {
+	g := NewWithT(t)
+
+	tree := fakeTree{
+		"components/foo/staging/kustomization.yaml":    "",
+		"components/foo/production/kustomization.yaml": "",
+		"components/bar/staging/kustomization.yaml":    "",
+		"components/legacy/staging/kustomization.yaml": "",
+		"components/README.md":                         "",
+	}
+	// The git generator's directory uses the list generator's parameter.
+	yaml := appSetYAML("{{path}}", `    - matrix:
+        generators:
+          - list:
+              elements:
+                - env: staging
+          - git:
+              repoURL: https://github.com/example/repo.git
+              revision: main
+              directories:
+                - path: components/*/{{env}}
+                - path: components/legacy/*
+                  exclude: true`)
+
+	result, err := ParseApplicationSets([]byte(yaml), WithRepoTree(tree))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Warnings).To(BeEmpty())
+	g.Expect(pathsOf(result)).To(Equal([]string{
+		"components/bar/staging|",
+		"components/foo/staging|",
+	}))
+}
+
+func TestParseApplicationSets_MatrixWithClusters(t *testing.T) {
+	g := NewWithT(t)
+
+	tree := fakeTree{
+		"components/foo/staging/kustomization.yaml": "",
+		"components/bar/staging/kustomization.yaml": "",
+	}
+	yaml := appSetYAML("{{path}}/{{nameNormalized}}", `    - matrix:
+        generators:
+          - clusters:
+              values:
+                environment: staging
+          - git:
+              repoURL: https://github.com/example/repo.git
+              directories:
+                - path: components/*/{{values.environment}}`)
+
+	result, err := ParseApplicationSets([]byte(yaml), WithRepoTree(tree))
+	g.Expect(err).NotTo(HaveOccurred())
+	// The cluster is unknown, so each directory is a prefix for all clusters.
+	g.Expect(pathsOf(result)).To(Equal([]string{
+		"components/bar/staging/|",
+		"components/foo/staging/|",
+	}))
+}
+
+func TestParseApplicationSets_GitFiles(t *testing.T) {
+	g := NewWithT(t)
+
+	tree := fakeTree{
+		"clusters/prod-01/config.yaml": "cluster:\n  env: production\n  addons: [monitoring]\n",
+		"clusters/stg-01/config.json":  `[{"cluster": {"env": "staging"}}, {"cluster": {"env": "dev"}}]`,
+		"clusters/notes.txt":           "",
+	}
+	yaml := appSetYAML("components/{{values.addon}}/{{cluster.env}}/{{cfg.path.basename}}", `    - git:
+        repoURL: https://github.com/example/repo.git
+        pathParamPrefix: cfg
+        values:
+          addon: '{{cluster.addons.0}}'
+        files:
+          - path: clusters/*/config.yaml
+          - path: clusters/*/config.json`)
+
+	result, err := ParseApplicationSets([]byte(yaml), WithRepoTree(tree))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Warnings).To(BeEmpty())
+	g.Expect(pathsOf(result)).To(ConsistOf(
+		"components/monitoring/production/prod-01|",
+		// values.addon is unresolved without addons, so the path is cut at
+		// the last directory before it.
+		"components/|",
+	))
+}
+
+func TestParseApplicationSets_GitWithoutTree(t *testing.T) {
+	g := NewWithT(t)
+
+	yaml := appSetYAML("{{path}}", `    - git:
+        repoURL: https://github.com/example/repo.git
+        directories:
+          - path: components/*`)
+
+	result, err := ParseApplicationSets([]byte(yaml))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Paths).To(BeEmpty())
+	g.Expect(result.Warnings).To(ConsistOf(ContainSubstring("git generator needs the repository tree")))
+}
+
+func TestParseApplicationSets_UnsupportedGenerators(t *testing.T) {
+	g := NewWithT(t)
+
+	yaml := appSetYAML("components/{{branch}}", `    - pullRequest:
+        github:
+          owner: example
+          repo: repo
+    - scmProvider:
+        github:
+          organization: example
+    - somethingNew: {}`)
+
+	result, err := ParseApplicationSets([]byte(yaml))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Paths).To(BeEmpty())
+	g.Expect(result.Warnings).To(ConsistOf(
+		"ApplicationSet test: pullRequest generator is not supported; its applications are not detected",
+		"ApplicationSet test: scmProvider generator is not supported; its applications are not detected",
+		"ApplicationSet test: unknown generator [somethingNew]; its applications are not detected",
+	))
+}
+
+func TestSanitizeName(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(sanitizeName("My_Cluster.01-")).To(Equal("my-cluster.01"))
+}
@@ -1,5 +1,7 @@
 // Package appset extracts environment-to-path mappings and cluster names from
-// rendered ApplicationSet YAML produced by kustomize build.
+// rendered ApplicationSet YAML produced by kustomize build. Generators are
+// evaluated and Application templates rendered like Argo CD does, in both
+// the legacy fasttemplate and the goTemplate (with sprig) modes.
 package appset
 
 import (
@@ -7,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -24,13 +27,56 @@ type ComponentPath struct {
 	ClusterDir string
 }
 
+// Application is an Argo CD Application generated by an ApplicationSet: its
+// template rendered with one generator parameter set. Parameters that can't
+// be known offline, such as the server of a cluster the clusters generator
+// would select, are left as template tags (e.g. "{{server}}").
+type Application struct {
+	// AppSet is the name of the ApplicationSet that generated it.
+	AppSet string
+	// Name is the rendered metadata.name.
+	Name string
+	// Cluster is the name of the cluster a merge with a clusters generator
+	// targets. It is empty when the Application stands for every cluster the
+	// clusters generator selects (AllClusters), or doesn't come from one.
+	Cluster string
+	// AllClusters is set on the Application a clusters generator produces for
+	// every cluster it selects that no merge names.
+	AllClusters bool
+	Project     string
+	Source      ApplicationSource
+	Destination ApplicationDestination
+	// Spec is the whole rendered spec, for fields not covered above (e.g.
+	// syncPolicy or sources).
+	Spec map[string]interface{}
+}
+
+// ApplicationSource is the rendered spec.source of an Application.
+type ApplicationSource struct {
+	RepoURL        string
+	Path           string
+	TargetRevision string
+}
+
+// ApplicationDestination is the rendered spec.destination of an Application.
+type ApplicationDestination struct {
+	Server    string
+	Name      string
+	Namespace string
+}
+
 // ParseResult holds the extracted data from all ApplicationSets in one overlay.
 type ParseResult struct {
+	// Applications contains every Application the ApplicationSets generate.
+	Applications []Application
 	// Paths contains all resolved component/config paths.
 	Paths []ComponentPath
 	// Clusters maps cluster names found in list.elements[].nameNormalized.
 	// Key: cluster name, Value: list of component paths for that cluster.
 	Clusters map[string][]string
+	// Warnings describes generators and paths that couldn't be evaluated, so
+	// the applications they produce are missing from Paths.
+	Warnings []string
 }
 
 // AppSetsByName parses rendered YAML (multi-document) and returns all
@@ -113,9 +159,21 @@ func envFromClusters(v interface{}) (string, bool) {
 	return env, true
 }
 
+// ParseOption configures optional ParseApplicationSets behaviour.
+type ParseOption func(*evaluator)
+
+// WithRepoTree evaluates git generators against tree. Without it, git
+// generators are reported as warnings and produce no paths.
+func WithRepoTree(tree RepoTree) ParseOption {
+	return func(e *evaluator) {
+		e.tree = tree
+	}
+}
+
 // ParseApplicationSets parses rendered YAML (multi-document) and extracts
-// ComponentPaths and cluster names from all ApplicationSet resources.
-func ParseApplicationSets(renderedYAML []byte) (*ParseResult, error) {
+// the generated Applications, ComponentPaths and cluster names from all
+// ApplicationSet resources.
+func ParseApplicationSets(renderedYAML []byte, opts ...ParseOption) (*ParseResult, error) {
 	result := &ParseResult{
 		Clusters: make(map[string][]string),
 	}
@@ -139,27 +197,30 @@ func ParseApplicationSets(renderedYAML []byte) (*ParseResult, error) {
 			continue
 		}
 
-		paths, clusters, err := extractFromAppSet(doc)
-		if err != nil {
-			name := "unknown"
-			if md, ok := doc["metadata"].(map[string]interface{}); ok {
-				if n, ok := md["name"].(string); ok {
-					name = n
-				}
+		name := "unknown"
+		if md, ok := doc["metadata"].(map[string]interface{}); ok {
+			if n, ok := md["name"].(string); ok {
+				name = n
 			}
-			return nil, fmt.Errorf("extracting from ApplicationSet %s: %w", name, err)
 		}
+		e := &evaluator{appSet: name}
+		for _, opt := range opts {
+			opt(e)
+		}
+		apps, paths, clusters := e.extractFromAppSet(doc)
+		result.Applications = append(result.Applications, apps...)
 		result.Paths = append(result.Paths, paths...)
 		for cluster, cpaths := range clusters {
 			result.Clusters[cluster] = append(result.Clusters[cluster], cpaths...)
 		}
+		result.Warnings = append(result.Warnings, e.warnings...)
 	}
 
 	return result, nil
 }
 
 // extractFromAppSet processes a single ApplicationSet document.
-func extractFromAppSet(doc map[string]interface{}) ([]ComponentPath, map[string][]string, error) {
+func (e *evaluator) extractFromAppSet(doc map[string]interface{}) ([]Application, []ComponentPath, map[string][]string) {
 	spec, ok := doc["spec"].(map[string]interface{})
 	if !ok {
 		return nil, nil, nil
@@ -168,211 +229,157 @@ func extractFromAppSet(doc map[string]interface{}) ([]ComponentPath, map[string]
 	if !ok {
 		return nil, nil, nil
 	}
-	tmplSpec, ok := tmpl["spec"].(map[string]interface{})
-	if !ok {
+	generators, _ := spec["generators"].([]interface{})
+	if len(generators) == 0 {
 		return nil, nil, nil
 	}
-	source, ok := tmplSpec["source"].(map[string]interface{})
-	if !ok {
-		return nil, nil, nil
+
+	e.goTemplate, _ = spec["goTemplate"].(bool)
+	options, _ := spec["goTemplateOptions"].([]interface{})
+	for _, opt := range options {
+		if s, ok := opt.(string); ok {
+			e.goTemplateOptions = append(e.goTemplateOptions, s)
+		}
 	}
-	pathTemplate, ok := source["path"].(string)
-	if !ok {
-		return nil, nil, nil
+	if _, ok := spec["templatePatch"]; ok {
+		e.warnf("templatePatch is not applied to the generated applications")
 	}
 
-	generators, _ := spec["generators"].([]interface{})
-	if len(generators) == 0 {
-		return nil, nil, nil
+	apps := e.renderApplications(tmpl, e.generateAll(generators))
+
+	tmplSpec, _ := tmpl["spec"].(map[string]interface{})
+	source, _ := tmplSpec["source"].(map[string]interface{})
+	pathTemplate, ok := source["path"].(string)
+	if !ok {
+		return toApplications(apps), nil, nil
 	}
 
 	// Determine if this is a templated or static path
 	if !strings.Contains(pathTemplate, "{{") {
 		// Static path — no templates at all
-		return []ComponentPath{{
+		return toApplications(apps), []ComponentPath{{
 			Path: pathTemplate,
-		}}, nil, nil
+		}}, nil
 	}
 
-	// Templated path — extract values from generators
-	return extractTemplatedPaths(pathTemplate, generators)
+	// Templated path — use the path each generated Application renders
+	paths, clusters := e.resolvePaths(pathTemplate, apps)
+	return toApplications(apps), paths, clusters
 }
 
-// extractTemplatedPaths resolves templated paths from merge/cluster/list generators.
-func extractTemplatedPaths(pathTemplate string, generators []interface{}) ([]ComponentPath, map[string][]string, error) {
-	var paths []ComponentPath
-	clusters := make(map[string][]string)
-
-	for _, gen := range generators {
-		genMap, ok := gen.(map[string]interface{})
-		if !ok {
-			continue
-		}
+// renderedApp is an Application with the parameter set it was rendered from.
+type renderedApp struct {
+	Application
+	set paramSet
+}
 
-		// Handle merge generator
-		if mergeGen, ok := genMap["merge"].(map[string]interface{}); ok {
-			subGens, _ := mergeGen["generators"].([]interface{})
-			subPaths, subClusters, err := processMergeGenerators(pathTemplate, subGens)
-			if err != nil {
-				return nil, nil, err
-			}
-			paths = append(paths, subPaths...)
-			for k, v := range subClusters {
-				clusters[k] = append(clusters[k], v...)
-			}
-			continue
-		}
+func toApplications(apps []renderedApp) []Application {
+	out := make([]Application, len(apps))
+	for i, a := range apps {
+		out[i] = a.Application
+	}
+	return out
+}
 
-		// Handle direct clusters generator
-		if clustersGen, ok := genMap["clusters"].(map[string]interface{}); ok {
-			subPaths := resolveClusterGenerator(pathTemplate, clustersGen)
-			paths = append(paths, subPaths...)
+// renderApplications renders the Application template once per parameter
+// set. Sets whose rendering fails (e.g. a missing key with
+// goTemplateOptions missingkey=error) are skipped with a warning.
+func (e *evaluator) renderApplications(tmpl map[string]interface{}, sets []paramSet) []renderedApp {
+	var apps []renderedApp
+	for _, ps := range sets {
+		v, err := e.interpolate(tmpl, ps.params)
+		if err != nil {
+			e.warnf("rendering template: %v", err)
 			continue
 		}
+		rendered, _ := v.(map[string]interface{})
+		md, _ := rendered["metadata"].(map[string]interface{})
+		spec, _ := rendered["spec"].(map[string]interface{})
+		source, _ := spec["source"].(map[string]interface{})
+		dest, _ := spec["destination"].(map[string]interface{})
+		apps = append(apps, renderedApp{
+			Application: Application{
+				AppSet:      e.appSet,
+				Name:        scalarString(md["name"]),
+				Cluster:     ps.clusterName(),
+				AllClusters: ps.anyCluster,
+				Project:     scalarString(spec["project"]),
+				Source: ApplicationSource{
+					RepoURL:        scalarString(source["repoURL"]),
+					Path:           scalarString(source["path"]),
+					TargetRevision: scalarString(source["targetRevision"]),
+				},
+				Destination: ApplicationDestination{
+					Server:    scalarString(dest["server"]),
+					Name:      scalarString(dest["name"]),
+					Namespace: scalarString(dest["namespace"]),
+				},
+				Spec: spec,
+			},
+			set: ps,
+		})
 	}
-
-	return paths, clusters, nil
+	return apps
 }
 
-// processMergeGenerators processes generators inside a merge generator.
-func processMergeGenerators(pathTemplate string, subGens []interface{}) ([]ComponentPath, map[string][]string, error) {
-	// First pass: extract base values from the clusters generator
-	var sourceRoot, environment, clusterDir string
-	var hasClusterDir bool
-
-	// Collect list elements for cluster overrides
-	type listElement struct {
-		nameNormalized string
-		clusterDir     string
+// resolvePaths turns the source paths of the generated Applications into
+// ComponentPaths.
+//
+// The clusters placeholder's own parameters (e.g. nameNormalized) are
+// unresolved, so when its path needs one of them, the path is cut at the
+// last '/' before the first unresolved parameter and the resulting directory
+// prefix stands for every cluster.
+//
+// Paths rendered for a named cluster that differ from every shared path are
+// recorded under that cluster and get a ClusterDir: values.clusterDir when
+// set, otherwise the cluster's name.
+func (e *evaluator) resolvePaths(pathTemplate string, apps []renderedApp) ([]ComponentPath, map[string][]string) {
+	type resolved struct {
+		app  renderedApp
+		path string
 	}
-	var listElements []listElement
-
-	for _, sg := range subGens {
-		sgMap, ok := sg.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		if clustersGen, ok := sgMap["clusters"].(map[string]interface{}); ok {
-			values, _ := clustersGen["values"].(map[string]interface{})
-			if sr, ok := values["sourceRoot"].(string); ok {
-				sourceRoot = sr
-			}
-			if env, ok := values["environment"].(string); ok {
-				environment = env
-			}
-			if cd, ok := values["clusterDir"].(string); ok {
-				clusterDir = cd
-				hasClusterDir = true
+	var all []resolved
+	shared := make(map[string]bool)
+	for _, app := range apps {
+		p := app.Source.Path
+		if i := unresolvedIndex(p); i >= 0 {
+			p = p[:strings.LastIndex(p[:i], "/")+1]
+			if p == "" {
+				e.warnf("path %q needs parameters its generators don't provide", pathTemplate)
+				continue
 			}
+		} else {
+			// An empty parameter such as clusterDir: "" leaves a trailing
+			// or doubled slash.
+			p = path.Clean(p)
 		}
-
-		if listGen, ok := sgMap["list"].(map[string]interface{}); ok {
-			elements, _ := listGen["elements"].([]interface{})
-			for _, elem := range elements {
-				elemMap, ok := elem.(map[string]interface{})
-				if !ok {
-					continue
-				}
-				le := listElement{}
-				if nn, ok := elemMap["nameNormalized"].(string); ok {
-					le.nameNormalized = nn
-				}
-				if cd, ok := elemMap["values.clusterDir"].(string); ok {
-					le.clusterDir = cd
-				}
-				listElements = append(listElements, le)
-			}
+		all = append(all, resolved{app: app, path: p})
+		if app.Cluster == "" {
+			shared[p] = true
 		}
 	}
 
 	var paths []ComponentPath
 	clusters := make(map[string][]string)
-
-	// Resolve the path template
-	if sourceRoot == "" {
-		// Can't resolve without sourceRoot
-		return nil, nil, nil
-	}
-
-	// Check if the path uses {{nameNormalized}} — treat as prefix
-	if strings.Contains(pathTemplate, "{{nameNormalized}}") {
-		basePath := sourceRoot + "/" + environment + "/"
-		paths = append(paths, ComponentPath{
-			Path: basePath,
-		})
-
-		// Also add cluster-specific paths from list elements
-		for _, le := range listElements {
-			dir := le.clusterDir
-			if dir == "" {
-				dir = le.nameNormalized
-			}
-			if dir != "" {
-				p := sourceRoot + "/" + environment + "/" + dir
-				paths = append(paths, ComponentPath{
-					Path:       p,
-					ClusterDir: dir,
-				})
-				clusters[le.nameNormalized] = append(clusters[le.nameNormalized], p)
-			}
+	seen := make(map[ComponentPath]bool)
+	for _, r := range all {
+		v, _ := lookup(r.app.set.params, "values.clusterDir")
+		clusterDir := scalarString(v)
+		if unresolvedIndex(clusterDir) >= 0 {
+			clusterDir = ""
 		}
-		return paths, clusters, nil
-	}
-
-	// Standard template: {{values.sourceRoot}}/{{values.environment}}/{{values.clusterDir}}
-	// or: {{values.sourceRoot}}/{{values.environment}}
-
-	// Base path (from clusters generator defaults)
-	if hasClusterDir && clusterDir == "" {
-		// clusterDir is explicitly empty string — the path resolves to sourceRoot/environment/
-		basePath := sourceRoot + "/" + environment
-		paths = append(paths, ComponentPath{
-			Path: basePath,
-		})
-	} else if hasClusterDir && clusterDir != "" {
-		basePath := sourceRoot + "/" + environment + "/" + clusterDir
-		paths = append(paths, ComponentPath{
-			Path:       basePath,
-			ClusterDir: clusterDir,
-		})
-	} else {
-		// No clusterDir in template
-		basePath := sourceRoot + "/" + environment
-		paths = append(paths, ComponentPath{
-			Path: basePath,
-		})
-	}
-
-	// Add cluster-specific overrides from list elements
-	for _, le := range listElements {
-		if le.clusterDir != "" {
-			p := sourceRoot + "/" + environment + "/" + le.clusterDir
-			paths = append(paths, ComponentPath{
-				Path:       p,
-				ClusterDir: le.clusterDir,
-			})
-			if le.nameNormalized != "" {
-				clusters[le.nameNormalized] = append(clusters[le.nameNormalized], p)
+		cp := ComponentPath{Path: r.path, ClusterDir: clusterDir}
+		if name := r.app.Cluster; name != "" && !shared[r.path] {
+			if cp.ClusterDir == "" {
+				cp.ClusterDir = name
 			}
+			clusters[name] = append(clusters[name], r.path)
 		}
+		if seen[cp] {
+			continue
+		}
+		seen[cp] = true
+		paths = append(paths, cp)
 	}
-
-	return paths, clusters, nil
-}
-
-// resolveClusterGenerator processes a direct clusters generator (without merge).
-func resolveClusterGenerator(pathTemplate string, clustersGen map[string]interface{}) []ComponentPath {
-	values, _ := clustersGen["values"].(map[string]interface{})
-	sourceRoot, _ := values["sourceRoot"].(string)
-	environment, _ := values["environment"].(string)
-
-	if sourceRoot == "" {
-		return nil
-	}
-
-	basePath := sourceRoot + "/" + environment
-	return []ComponentPath{{
-		Path: basePath,
-	}}
+	return paths, clusters
 }
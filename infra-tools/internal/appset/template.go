@@ -0,0 +1,197 @@
+package appset
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	sprig "github.com/go-task/slim-sprig/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// noValue is what text/template prints for a missing map key.
+const noValue = "<no value>"
+
+// templateTag matches a fasttemplate tag. Argo CD trims spaces inside tags.
+var templateTag = regexp.MustCompile(`{{\s*([^{}]*?)\s*}}`)
+
+// render substitutes {{key}} tags with flat params, like Argo CD's legacy
+// fasttemplate mode. Tags without a parameter are left in place, as Argo CD
+// does.
+func render(tmpl string, params map[string]interface{}) string {
+	return templateTag.ReplaceAllStringFunc(tmpl, func(tag string) string {
+		key := templateTag.FindStringSubmatch(tag)[1]
+		if v, ok := params[key]; ok {
+			return scalarString(v)
+		}
+		return tag
+	})
+}
+
+// goFuncs are the functions available to goTemplate ApplicationSets: sprig
+// without the functions Argo CD disables, plus Argo CD's own additions.
+var goFuncs = func() template.FuncMap {
+	funcs := template.FuncMap(sprig.TxtFuncMap())
+	for _, name := range []string{"env", "expandenv", "getHostByName"} {
+		delete(funcs, name)
+	}
+	funcs["normalize"] = sanitizeName
+	funcs["slugify"] = slugify
+	funcs["toYaml"] = toYAML
+	funcs["fromYaml"] = fromYAML
+	funcs["fromYamlArray"] = fromYAMLArray
+	return funcs
+}()
+
+// renderGo executes tmpl as a Go template with nested params, applying
+// goTemplateOptions such as "missingkey=error".
+func renderGo(tmpl string, params map[string]interface{}, options []string) (string, error) {
+	if !strings.Contains(tmpl, "{{") {
+		return tmpl, nil
+	}
+	t := template.New("").Funcs(goFuncs)
+	for _, opt := range options {
+		t = t.Option(opt)
+	}
+	t, err := t.Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("parsing template %q: %w", tmpl, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, params); err != nil {
+		return "", fmt.Errorf("executing template %q: %w", tmpl, err)
+	}
+	return buf.String(), nil
+}
+
+// render renders tmpl with params in the ApplicationSet's template mode.
+func (e *evaluator) render(tmpl string, params map[string]interface{}) (string, error) {
+	if e.goTemplate {
+		return renderGo(tmpl, params, e.goTemplateOptions)
+	}
+	return render(tmpl, params), nil
+}
+
+// interpolate renders every string in a generator spec or Application
+// template with params.
+func (e *evaluator) interpolate(v interface{}, params map[string]interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		return e.render(t, params)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			r, err := e.interpolate(val, params)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			r, err := e.interpolate(val, params)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// unresolvedIndex returns the index of the first parameter a rendered
+// string couldn't be given, or -1. Missing parameters are left as tags in
+// fasttemplate mode and print as "<no value>" in goTemplate mode; the
+// clusters placeholder's own parameters are tags in both.
+func unresolvedIndex(s string) int {
+	i := strings.Index(s, "{{")
+	if j := strings.Index(s, noValue); j >= 0 && (i < 0 || j < i) {
+		i = j
+	}
+	return i
+}
+
+// Resolved reports whether a rendered value has every parameter it needs.
+func Resolved(s string) bool {
+	return unresolvedIndex(s) < 0
+}
+
+// ResolveCluster fills a value rendered for every cluster (an Application
+// with AllClusters) in for the named cluster, and reports whether the result
+// is fully resolved. Only the name and nameNormalized tags are filled in;
+// values derived from the cluster's server or labels, or transformed by
+// template functions, stay unresolved.
+func ResolveCluster(s, cluster string) (string, bool) {
+	if Resolved(s) {
+		return s, true
+	}
+	s = strings.NewReplacer("{{name}}", cluster, "{{nameNormalized}}", cluster).Replace(s)
+	return s, Resolved(s)
+}
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// slugify implements Argo CD's slugify template function:
+// slugify [maxSize [smartTruncate]] name. The name is lowercased, runs of
+// other characters become '-', and it is cut to maxSize (default 50) -
+// at a word boundary when smartTruncate is set (the default).
+func slugify(args ...interface{}) string {
+	maxSize, smartTruncate := 50, true
+	var name string
+	switch len(args) {
+	case 1:
+		name = scalarString(args[0])
+	case 2:
+		maxSize, _ = strconv.Atoi(scalarString(args[0]))
+		name = scalarString(args[1])
+	case 3:
+		maxSize, _ = strconv.Atoi(scalarString(args[0]))
+		smartTruncate, _ = strconv.ParseBool(scalarString(args[1]))
+		name = scalarString(args[2])
+	default:
+		return ""
+	}
+
+	slug := strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if maxSize <= 0 || len(slug) <= maxSize {
+		return slug
+	}
+	cut := slug[:maxSize]
+	if smartTruncate && slug[maxSize] != '-' {
+		if i := strings.LastIndex(cut, "-"); i > 0 {
+			cut = cut[:i]
+		}
+	}
+	return strings.Trim(cut, "-")
+}
+
+func toYAML(v interface{}) string {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+func fromYAML(s string) map[string]interface{} {
+	var m map[string]interface{}
+	if err := yaml.Unmarshal([]byte(s), &m); err != nil {
+		return map[string]interface{}{"Error": err.Error()}
+	}
+	return m
+}
+
+func fromYAMLArray(s string) []interface{} {
+	var a []interface{}
+	if err := yaml.Unmarshal([]byte(s), &a); err != nil {
+		return []interface{}{err.Error()}
+	}
+	return a
+}
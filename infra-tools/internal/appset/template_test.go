@@ -0,0 +1,222 @@
+package appset
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// goAppSetYAML is an ApplicationSet in goTemplate mode with the given
+// options, generators (indented under spec.generators) and template spec
+// (indented under spec.template.spec).
+func goAppSetYAML(options, generators, tmplSpec string) string {
+	return `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: test
+spec:
+  goTemplate: true
+  goTemplateOptions: [` + options + `]
+  generators:
+` + generators + `
+  template:
+    metadata:
+      name: '{{ .nameNormalized | default "test" }}'
+    spec:
+` + tmplSpec + `
+`
+}
+
+func TestParseApplicationSets_GoTemplateList(t *testing.T) {
+	g := NewWithT(t)
+
+	yaml := goAppSetYAML(`"missingkey=error"`, `    - list:
+        elements:
+          - component: Foo_Bar
+            nameNormalized: foo
+            values:
+              env: staging
+              replicas: 2`, `      project: '{{ .values.env }}-{{ .component | normalize }}'
+      source:
+        repoURL: https://github.com/example/repo.git
+        path: 'components/{{ .component | lower | replace "_" "-" }}/{{ .values.env }}'
+        targetRevision: '{{ if eq .values.env "staging" }}main{{ else }}release{{ end }}'
+      destination:
+        name: in-cluster
+        namespace: '{{ slugify .component }}'`)
+
+	result, err := ParseApplicationSets([]byte(yaml))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Warnings).To(BeEmpty())
+	g.Expect(pathsOf(result)).To(Equal([]string{"components/foo-bar/staging|"}))
+	g.Expect(result.Applications).To(HaveLen(1))
+
+	app := result.Applications[0]
+	g.Expect(app.AppSet).To(Equal("test"))
+	g.Expect(app.Name).To(Equal("foo"))
+	g.Expect(app.Cluster).To(BeEmpty())
+	g.Expect(app.Project).To(Equal("staging-foo-bar"))
+	g.Expect(app.Source).To(Equal(ApplicationSource{
+		RepoURL:        "https://github.com/example/repo.git",
+		Path:           "components/foo-bar/staging",
+		TargetRevision: "main",
+	}))
+	g.Expect(app.Destination).To(Equal(ApplicationDestination{Name: "in-cluster", Namespace: "foo-bar"}))
+}
+
+func TestParseApplicationSets_GoTemplateMergeWithClusters(t *testing.T) {
+	g := NewWithT(t)
+
+	yaml := goAppSetYAML("", `    - merge:
+        mergeKeys:
+          - nameNormalized
+        generators:
+          - clusters:
+              values:
+                sourceRoot: components/foo
+                clusterDir: '{{ .nameNormalized }}'
+          - list:
+              elements:
+                - nameNormalized: prod-01
+                  values:
+                    clusterDir: prod-01`, `      project: default
+      source:
+        path: '{{ .values.sourceRoot }}/{{ .values.clusterDir }}'
+      destination:
+        server: '{{ .server }}'
+        namespace: foo`)
+
+	result, err := ParseApplicationSets([]byte(yaml))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pathsOf(result)).To(Equal([]string{
+		"components/foo/|",
+		"components/foo/prod-01|prod-01",
+	}))
+	g.Expect(result.Clusters).To(Equal(map[string][]string{"prod-01": {"components/foo/prod-01"}}))
+
+	g.Expect(result.Applications).To(HaveLen(2))
+	g.Expect(result.Applications[0].Name).To(Equal("{{nameNormalized}}"))
+	g.Expect(result.Applications[0].Destination.Server).To(Equal("{{server}}"))
+	g.Expect(result.Applications[1].Name).To(Equal("prod-01"))
+	g.Expect(result.Applications[1].Cluster).To(Equal("prod-01"))
+}
+
+func TestParseApplicationSets_GoTemplateGitDirectories(t *testing.T) {
+	g := NewWithT(t)
+
+	tree := fakeTree{
+		"components/foo/staging/kustomization.yaml": "",
+		"components/bar/staging/kustomization.yaml": "",
+	}
+	yaml := goAppSetYAML("", `    - matrix:
+        generators:
+          - list:
+              elements:
+                - env: staging
+          - git:
+              repoURL: https://github.com/example/repo.git
+              pathParamPrefix: app
+              directories:
+                - path: 'components/*/{{ .env }}'`, `      project: default
+      source:
+        path: '{{ .app.path.path }}'
+      destination:
+        namespace: '{{ index .app.path.segments 1 }}-{{ .env }}'`)
+
+	result, err := ParseApplicationSets([]byte(yaml), WithRepoTree(tree))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Warnings).To(BeEmpty())
+	g.Expect(pathsOf(result)).To(Equal([]string{
+		"components/bar/staging|",
+		"components/foo/staging|",
+	}))
+	g.Expect(result.Applications[0].Destination.Namespace).To(Equal("bar-staging"))
+}
+
+func TestParseApplicationSets_GoTemplateMissingKey(t *testing.T) {
+	g := NewWithT(t)
+
+	generators := `    - list:
+        elements:
+          - component: foo`
+	tmplSpec := `      source:
+        path: 'components/{{ .component }}/{{ .environment }}'`
+
+	// Without options a missing key prints "<no value>", which is treated as
+	// unresolved.
+	result, err := ParseApplicationSets([]byte(goAppSetYAML("", generators, tmplSpec)))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pathsOf(result)).To(Equal([]string{"components/foo/|"}))
+
+	// With missingkey=error rendering fails, and Argo CD generates nothing.
+	result, err = ParseApplicationSets([]byte(goAppSetYAML(`"missingkey=error"`, generators, tmplSpec)))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Applications).To(BeEmpty())
+	g.Expect(result.Paths).To(BeEmpty())
+	g.Expect(result.Warnings).To(ConsistOf(ContainSubstring("map has no entry for key")))
+}
+
+func TestParseApplicationSets_FasttemplateApplications(t *testing.T) {
+	g := NewWithT(t)
+
+	yaml := appSetYAML("components/foo/{{values.environment}}/{{nameNormalized}}", `    - merge:
+        mergeKeys:
+          - nameNormalized
+        generators:
+          - clusters:
+              values:
+                environment: staging
+          - list:
+              elements:
+                - nameNormalized: stg-01`)
+
+	result, err := ParseApplicationSets([]byte(yaml))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Applications).To(HaveLen(2))
+
+	placeholder, named := result.Applications[0], result.Applications[1]
+	g.Expect(placeholder.AllClusters).To(BeTrue())
+	g.Expect(placeholder.Name).To(Equal("test-{{nameNormalized}}"))
+	g.Expect(placeholder.Source.Path).To(Equal("components/foo/staging/{{nameNormalized}}"))
+	g.Expect(named.Name).To(Equal("test-stg-01"))
+	g.Expect(named.Cluster).To(Equal("stg-01"))
+	g.Expect(named.AllClusters).To(BeFalse())
+	g.Expect(named.Source.Path).To(Equal("components/foo/staging/stg-01"))
+	g.Expect(named.Source.RepoURL).To(Equal("https://github.com/example/repo.git"))
+	g.Expect(named.Destination.Server).To(Equal("{{server}}"))
+}
+
+func TestSlugify(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(slugify("Hello, World!")).To(Equal("hello-world"))
+	g.Expect(slugify(11, false, "very-long-name-here")).To(Equal("very-long-n"))
+	g.Expect(slugify(11, true, "very-long-name-here")).To(Equal("very-long"))
+	g.Expect(slugify(10, "abc")).To(Equal("abc"))
+}
+
+func TestRenderGo_DisabledFunctions(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := renderGo(`{{ env "HOME" }}`, nil, nil)
+	g.Expect(err).To(MatchError(ContainSubstring(`function "env" not defined`)))
+
+	out, err := renderGo(`{{ .a | toYaml }}`, map[string]interface{}{"a": map[string]interface{}{"b": 1}}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(Equal("b: 1"))
+}
+
+func TestResolveCluster(t *testing.T) {
+	g := NewWithT(t)
+
+	p, ok := ResolveCluster("components/foo/{{nameNormalized}}", "stone-prd-rh01")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(p).To(Equal("components/foo/stone-prd-rh01"))
+
+	p, ok = ResolveCluster("components/foo/base", "stone-prd-rh01")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(p).To(Equal("components/foo/base"))
+
+	_, ok = ResolveCluster("components/foo/{{metadata.labels.ring}}", "stone-prd-rh01")
+	g.Expect(ok).To(BeFalse())
+}
@@ -0,0 +1,342 @@
+// Package buildcache provides a persistent, content-addressed cache for
+// kustomize build output. Entries are keyed by a hash of every local file in
+// a kustomization's dependency tree (as returned by deptree.Resolve) plus the
+// kustomize and helm versions, so identical inputs on different refs or
+// different runs reuse the same rendered YAML. Kustomizations that read a
+// remote reference not pinned to a commit are never cached, and entries that
+// have not been used for MaxAge are pruned.
+package buildcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/deptree"
+)
+
+// formatVersion is mixed into every key. Bump it when the build options in
+// internal/kustomize or the key derivation change so stale entries are ignored.
+const formatVersion = "1"
+
+// MaxAge is how long an entry is kept without being used. Open prunes older
+// entries; every hit renews an entry.
+const MaxAge = 14 * 24 * time.Hour
+
+// Cache stores rendered kustomize output on disk under dir.
+type Cache struct {
+	dir string
+
+	hits     atomic.Int64
+	misses   atomic.Int64
+	uncached atomic.Int64
+	errors   atomic.Int64
+}
+
+// Stats is a snapshot of cache activity since the Cache was created.
+type Stats struct {
+	Hits   int64
+	Misses int64
+	// Uncached counts builds of kustomizations with unpinned remote
+	// references, which are always built.
+	Uncached int64
+	// Errors counts keys that could not be computed and entries that could
+	// not be stored. Builds still succeed in that case, just uncached.
+	Errors int64
+}
+
+// New returns a Cache rooted at dir, creating the directory if needed.
+func New(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache dir %s: %w", dir, err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Open returns a Cache rooted at dir, or at DefaultDir when dir is empty,
+// after pruning the entries that have not been used for MaxAge.
+func Open(dir string) (*Cache, error) {
+	if dir == "" {
+		d, err := DefaultDir()
+		if err != nil {
+			return nil, fmt.Errorf("determining default cache dir: %w", err)
+		}
+		dir = d
+	}
+	c, err := New(dir)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := c.Prune(MaxAge); err != nil {
+		slog.Warn("build cache: pruning failed", "dir", dir, "err", err)
+	} else if n > 0 {
+		slog.Debug("build cache: pruned unused entries", "count", n, "dir", dir)
+	}
+	return c, nil
+}
+
+// Prune removes the entries that have not been used for maxAge, along with
+// temporary files of that age left behind by interrupted stores, and
+// returns how many files it removed.
+func (c *Cache) Prune(maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // removed concurrently
+		}
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// DefaultDir returns the default cache location under the user cache
+// directory (e.g. ~/.cache/infra-tools/kustomize-builds on Linux).
+func DefaultDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "infra-tools", "kustomize-builds"), nil
+}
+
+// Dir returns the cache root directory.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Stats returns the current hit/miss counters.
+func (c *Cache) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load(), Uncached: c.uncached.Load(), Errors: c.errors.Load()}
+}
+
+// LogStats logs the cache counters at INFO level. Safe to call on a nil Cache.
+func (c *Cache) LogStats() {
+	if c == nil {
+		return
+	}
+	s := c.Stats()
+	slog.Info("Build cache", "hits", s.Hits, "misses", s.Misses, "uncached", s.Uncached, "errors", s.Errors, "dir", c.dir)
+}
+
+// Build returns the cached output for the kustomization at rel under
+// repoRoot, or calls build and stores its output. Build errors are never
+// cached. If the key cannot be computed (e.g. the dependency tree cannot be
+// resolved), or the kustomization reads a remote reference that is not
+// pinned to a commit, build is called directly.
+func (c *Cache) Build(repoRoot, rel string, build func() ([]byte, error)) ([]byte, error) {
+	return c.BuildFS(filesys.MakeFsOnDisk(), repoRoot, rel, build)
+}
+
+// BuildFS is Build reading the kustomization's inputs from fSys.
+func (c *Cache) BuildFS(fSys filesys.FileSystem, repoRoot, rel string, build func() ([]byte, error)) ([]byte, error) {
+	key, unpinned, err := keyFS(fSys, repoRoot, rel)
+	if err != nil {
+		c.errors.Add(1)
+		slog.Debug("build cache: cannot compute key, building uncached", "path", rel, "err", err)
+		return build()
+	}
+	if unpinned != "" {
+		c.uncached.Add(1)
+		slog.Debug("build cache: unpinned remote reference, building uncached", "path", rel, "ref", unpinned)
+		return build()
+	}
+
+	path := c.entryPath(key)
+	if data, err := os.ReadFile(path); err == nil {
+		c.hits.Add(1)
+		slog.Debug("build cache hit", "path", rel, "key", key)
+		// Renew the entry so Prune keeps it.
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+		return data, nil
+	}
+
+	c.misses.Add(1)
+	data, err := build()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.store(path, data); err != nil {
+		c.errors.Add(1)
+		slog.Debug("build cache: failed to store entry", "path", rel, "err", err)
+	}
+	return data, nil
+}
+
+// entryPath shards entries by the first two hex digits of the key.
+func (c *Cache) entryPath(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".yaml")
+}
+
+// store writes data atomically so concurrent readers never see a partial
+// entry.
+func (c *Cache) store(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Key computes the cache key for the kustomization at rel under repoRoot.
+// The repo root itself is not part of the key, so a worktree of the base ref
+// and the working copy share entries when their inputs are identical.
+//
+// Remote resources are not fetched; they are keyed by the reference written
+// in the kustomization file, which is itself part of the dependency set. That
+// only identifies their content when the reference is pinned to a commit;
+// Build does not cache kustomizations with other remote references.
+func Key(repoRoot, rel string) (string, error) {
+	return KeyFS(filesys.MakeFsOnDisk(), repoRoot, rel)
+}
+
+// KeyFS is Key reading the kustomization's inputs from fSys.
+func KeyFS(fSys filesys.FileSystem, repoRoot, rel string) (string, error) {
+	key, _, err := keyFS(fSys, repoRoot, rel)
+	return key, err
+}
+
+// keyFS is KeyFS also returning the first remote reference of the
+// kustomization that is not pinned to a commit, or "".
+func keyFS(fSys filesys.FileSystem, repoRoot, rel string) (string, string, error) {
+	tree, err := deptree.ResolveTreeFS(fSys, repoRoot, rel)
+	if err != nil {
+		return "", "", fmt.Errorf("resolving dependencies of %s: %w", rel, err)
+	}
+	unpinned := ""
+	for _, ref := range tree.Remotes {
+		if !pinned(ref) {
+			unpinned = ref
+			break
+		}
+	}
+
+	deps := tree.Deps
+	files := make([]string, 0, len(deps))
+	for f := range deps {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "format=%s\nkustomize=%s\nhelm=%s\npath=%s\n", formatVersion, kustomizeVersion(), helmVersion(), filepath.ToSlash(rel))
+	for _, f := range files {
+		data, err := fSys.ReadFile(filepath.Join(repoRoot, f))
+		if err != nil {
+			// Missing or unreadable dependencies are still part of the key so
+			// that creating the file later changes it.
+			fmt.Fprintf(h, "%s\x00missing\n", filepath.ToSlash(f))
+			continue
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(f), hex.EncodeToString(sum[:]))
+	}
+	return hex.EncodeToString(h.Sum(nil)), unpinned, nil
+}
+
+// commitSHA matches a full git commit hash.
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+// pinned reports whether a remote reference names a full commit hash, in
+// its ref or version query parameter or as a path segment (as in
+// raw.githubusercontent.com URLs), so that its content cannot change.
+// Branches, tags and references without a ref are not pinned.
+func pinned(ref string) bool {
+	path, query, _ := strings.Cut(ref, "?")
+	if values, err := url.ParseQuery(query); err == nil {
+		for _, param := range []string{"ref", "version"} {
+			if commitSHA.MatchString(values.Get(param)) {
+				return true
+			}
+		}
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if commitSHA.MatchString(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// kustomizeVersion returns the version of the kustomize API module compiled
+// into this binary.
+func kustomizeVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "sigs.k8s.io/kustomize/api" {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+var (
+	helmVersionOnce  sync.Once
+	helmVersionValue string
+)
+
+// helmVersion returns the output of `helm version --short`, or "none" when
+// helm is not installed. Computed once per process.
+func helmVersion() string {
+	helmVersionOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, "helm", "version", "--short").Output()
+		if err != nil {
+			helmVersionValue = "none"
+			return
+		}
+		helmVersionValue = strings.TrimSpace(string(out))
+	})
+	return helmVersionValue
+}
@@ -0,0 +1,208 @@
+package buildcache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// writeComponent creates a minimal kustomization at <root>/component.
+func writeComponent(t *testing.T, root, configMap string) {
+	t.Helper()
+	dir := filepath.Join(root, "component")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"kustomization.yaml": "resources:\n  - configmap.yaml\n",
+		"configmap.yaml":     configMap,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestKey_IndependentOfRepoRoot(t *testing.T) {
+	g := NewWithT(t)
+
+	a, b := t.TempDir(), t.TempDir()
+	writeComponent(t, a, "kind: ConfigMap\n")
+	writeComponent(t, b, "kind: ConfigMap\n")
+
+	keyA, err := Key(a, "component")
+	g.Expect(err).NotTo(HaveOccurred())
+	keyB, err := Key(b, "component")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(keyA).To(Equal(keyB))
+}
+
+func TestKey_ChangesWithDependencyContent(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	writeComponent(t, root, "kind: ConfigMap\n")
+	before, err := Key(root, "component")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	writeComponent(t, root, "kind: ConfigMap\ndata:\n  a: b\n")
+	after, err := Key(root, "component")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(after).NotTo(Equal(before))
+}
+
+func TestBuild_HitAfterMiss(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	writeComponent(t, root, "kind: ConfigMap\n")
+	c, err := New(filepath.Join(t.TempDir(), "cache"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	calls := 0
+	build := func() ([]byte, error) {
+		calls++
+		return []byte("rendered\n"), nil
+	}
+
+	out, err := c.Build(root, "component", build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).To(Equal("rendered\n"))
+
+	out, err = c.Build(root, "component", build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).To(Equal("rendered\n"))
+
+	g.Expect(calls).To(Equal(1))
+	g.Expect(c.Stats()).To(Equal(Stats{Hits: 1, Misses: 1}))
+}
+
+func TestBuild_ErrorsAreNotCached(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	writeComponent(t, root, "kind: ConfigMap\n")
+	c, err := New(t.TempDir())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	calls := 0
+	failing := func() ([]byte, error) {
+		calls++
+		return nil, errors.New("boom")
+	}
+
+	_, err = c.Build(root, "component", failing)
+	g.Expect(err).To(MatchError("boom"))
+	_, err = c.Build(root, "component", failing)
+	g.Expect(err).To(MatchError("boom"))
+	g.Expect(calls).To(Equal(2))
+}
+
+func TestBuild_UncachedWhenKeyFails(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := New(t.TempDir())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// No kustomization exists, so the dependency tree cannot be resolved.
+	out, err := c.Build(t.TempDir(), "missing", func() ([]byte, error) { return []byte("x"), nil })
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).To(Equal("x"))
+	g.Expect(c.Stats()).To(Equal(Stats{Errors: 1}))
+}
+
+func TestBuild_UnpinnedRemoteNotCached(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "component")
+	g.Expect(os.MkdirAll(dir, 0o755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources:\n  - https://github.com/example/repo/config?ref=main\n"), 0o644)).To(Succeed())
+	c, err := New(t.TempDir())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	calls := 0
+	build := func() ([]byte, error) {
+		calls++
+		return []byte("rendered\n"), nil
+	}
+	_, err = c.Build(root, "component", build)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.Build(root, "component", build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(calls).To(Equal(2))
+	g.Expect(c.Stats()).To(Equal(Stats{Uncached: 2}))
+}
+
+func TestBuild_SchemelessRemoteNotCached(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "component")
+	g.Expect(os.MkdirAll(dir, 0o755)).To(Succeed())
+	// As in components/pipeline-service/development: a movable tag without a scheme.
+	g.Expect(os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources:\n  - github.com/minio/operator?ref=v5.0.15\n"), 0o644)).To(Succeed())
+	c, err := New(t.TempDir())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	calls := 0
+	build := func() ([]byte, error) {
+		calls++
+		return []byte("rendered\n"), nil
+	}
+	_, err = c.Build(root, "component", build)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.Build(root, "component", build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(calls).To(Equal(2))
+	g.Expect(c.Stats()).To(Equal(Stats{Uncached: 2}))
+}
+
+func TestPinned(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(pinned("https://github.com/konflux-ci/build-service/config/default?ref=547914854b06c8725dc609a872b5c62e2b935663")).To(BeTrue())
+	g.Expect(pinned("https://raw.githubusercontent.com/org/repo/547914854b06c8725dc609a872b5c62e2b935663/deploy.yaml")).To(BeTrue())
+	g.Expect(pinned("https://github.com/org/repo/config?ref=main")).To(BeFalse())
+	g.Expect(pinned("https://github.com/org/repo/config?ref=v1.2.3")).To(BeFalse())
+	g.Expect(pinned("https://github.com/org/repo/config")).To(BeFalse())
+	g.Expect(pinned("https://example.com/manifest.yaml")).To(BeFalse())
+}
+
+func TestPrune(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	writeComponent(t, root, "kind: ConfigMap\n")
+	c, err := New(t.TempDir())
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.Build(root, "component", func() ([]byte, error) { return []byte("rendered\n"), nil })
+	g.Expect(err).NotTo(HaveOccurred())
+	key, err := Key(root, "component")
+	g.Expect(err).NotTo(HaveOccurred())
+	entry := c.entryPath(key)
+
+	n, err := c.Prune(time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(n).To(BeZero())
+
+	old := time.Now().Add(-2 * time.Hour)
+	g.Expect(os.Chtimes(entry, old, old)).To(Succeed())
+	// A hit renews the entry.
+	_, err = c.Build(root, "component", func() ([]byte, error) { return nil, errors.New("not called") })
+	g.Expect(err).NotTo(HaveOccurred())
+	n, err = c.Prune(time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(n).To(BeZero())
+
+	g.Expect(os.Chtimes(entry, old, old)).To(Succeed())
+	n, err = c.Prune(time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(n).To(Equal(1))
+	g.Expect(entry).NotTo(BeAnExistingFile())
+}
@@ -0,0 +1,82 @@
+package deptree
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// Deprecation is a deprecated field used by a kustomization file.
+type Deprecation struct {
+	// File is the repo-root-relative path of the kustomization file.
+	File string `json:"file"`
+	// Field is the deprecated field, e.g. patchesStrategicMerge.
+	Field string `json:"field"`
+	// Replacement is the field to use instead.
+	Replacement string `json:"replacement"`
+}
+
+func (d Deprecation) String() string {
+	return fmt.Sprintf("%s: '%s' is deprecated, use '%s' instead", d.File, d.Field, d.Replacement)
+}
+
+// deprecatedFields lists the fields types.Kustomization.CheckDeprecatedFields
+// warns about, which kustomize prints to stderr on every build.
+//
+//nolint:staticcheck // the deprecated fields are what is being checked
+var deprecatedFields = []struct {
+	field, replacement string
+	used               func(k *types.Kustomization) bool
+}{
+	{"bases", "resources", func(k *types.Kustomization) bool { return k.Bases != nil }},
+	{"commonLabels", "labels", func(k *types.Kustomization) bool { return k.CommonLabels != nil }},
+	{"imageTags", "images", func(k *types.Kustomization) bool { return k.ImageTags != nil }},
+	{"patchesJson6902", "patches", func(k *types.Kustomization) bool { return k.PatchesJson6902 != nil }},
+	{"patchesStrategicMerge", "patches", func(k *types.Kustomization) bool { return k.PatchesStrategicMerge != nil }},
+	{"vars", "replacements", func(k *types.Kustomization) bool { return k.Vars != nil }},
+}
+
+// deprecations returns the deprecated fields the kustomization k, read from
+// file, uses.
+func deprecations(file string, k *types.Kustomization) []Deprecation {
+	var out []Deprecation
+	for _, f := range deprecatedFields {
+		if f.used(k) {
+			out = append(out, Deprecation{File: file, Field: f.field, Replacement: f.replacement})
+		}
+	}
+	return out
+}
+
+// FileDeprecations returns the deprecated fields the kustomization file
+// content data uses, reported against file.
+func FileDeprecations(file string, data []byte) ([]Deprecation, error) {
+	var k types.Kustomization
+	if err := yaml.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	return deprecations(file, &k), nil
+}
+
+// Deprecations walks the kustomization tree at dir like Resolve and returns
+// the deprecated fields its kustomization files use, sorted by file.
+func Deprecations(repoRoot, dir string) ([]Deprecation, error) {
+	return DeprecationsFS(filesys.MakeFsOnDisk(), repoRoot, dir)
+}
+
+// DeprecationsFS is Deprecations reading kustomizations from fSys, in which
+// repoRoot is an absolute path.
+func DeprecationsFS(fSys filesys.FileSystem, repoRoot, dir string) ([]Deprecation, error) {
+	r, err := walk(fSys, repoRoot, dir)
+	if err != nil {
+		return nil, err
+	}
+	// Walk order depends on the order of resources; fields keep the order of
+	// deprecatedFields within a file.
+	slices.SortStableFunc(r.deprecations, func(a, b Deprecation) int { return cmp.Compare(a.File, b.File) })
+	return r.deprecations, nil
+}
@@ -0,0 +1,57 @@
+package deptree
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestDeprecationsFS(t *testing.T) {
+	g := NewWithT(t)
+	fSys := filesys.MakeFsInMemory()
+
+	g.Expect(fSys.MkdirAll("/repo/component/base")).To(Succeed())
+	g.Expect(fSys.MkdirAll("/repo/component/production")).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/component/base/kustomization.yaml", []byte(`
+resources:
+  - deployment.yaml
+commonLabels:
+  app: foo
+`))).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/component/base/deployment.yaml", []byte("kind: Deployment"))).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/component/production/kustomization.yaml", []byte(`
+bases:
+  - ../base
+patchesStrategicMerge:
+  - patch.yaml
+`))).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/component/production/patch.yaml", []byte("kind: Deployment"))).To(Succeed())
+
+	deps, err := DeprecationsFS(fSys, "/repo", "component/production")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deps).To(Equal([]Deprecation{
+		{File: "component/base/kustomization.yaml", Field: "commonLabels", Replacement: "labels"},
+		{File: "component/production/kustomization.yaml", Field: "bases", Replacement: "resources"},
+		{File: "component/production/kustomization.yaml", Field: "patchesStrategicMerge", Replacement: "patches"},
+	}))
+	g.Expect(deps[0].String()).To(Equal("component/base/kustomization.yaml: 'commonLabels' is deprecated, use 'labels' instead"))
+
+	// Bases are followed like resources.
+	files, err := ResolveFS(fSys, "/repo", "component/production")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(files).To(HaveKey("component/base/deployment.yaml"))
+}
+
+func TestDeprecationsFS_None(t *testing.T) {
+	g := NewWithT(t)
+	fSys := filesys.MakeFsInMemory()
+
+	g.Expect(fSys.MkdirAll("/repo/component")).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/component/kustomization.yaml", []byte("resources:\n  - cm.yaml\nlabels:\n  - pairs:\n      app: foo\n"))).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/component/cm.yaml", []byte("kind: ConfigMap"))).To(Succeed())
+
+	deps, err := DeprecationsFS(fSys, "/repo", "component")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deps).To(BeEmpty())
+}
@@ -6,11 +6,13 @@ package deptree
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/yaml"
 )
 
@@ -25,8 +27,40 @@ var kustomizationFileNames = []string{
 // all local file paths (relative to repoRoot) that are dependencies. The
 // returned map keys are repo-root-relative paths.
 func Resolve(repoRoot, dir string) (map[string]bool, error) {
-	visited := make(map[string]bool)
-	deps := make(map[string]bool)
+	return ResolveFS(filesys.MakeFsOnDisk(), repoRoot, dir)
+}
+
+// ResolveFS is Resolve reading kustomizations from fSys, in which repoRoot
+// is an absolute path.
+func ResolveFS(fSys filesys.FileSystem, repoRoot, dir string) (map[string]bool, error) {
+	t, err := ResolveTreeFS(fSys, repoRoot, dir)
+	if err != nil {
+		return nil, err
+	}
+	return t.Deps, nil
+}
+
+// Tree is the resolved kustomization tree of a directory.
+type Tree struct {
+	// Deps is the set of local files the tree reads, relative to repoRoot.
+	Deps map[string]bool
+	// Remotes lists the remote references the tree reads, such as resources
+	// and components given as URLs, in the order they were found. They are
+	// not fetched.
+	Remotes []string
+}
+
+// ResolveTreeFS is ResolveFS also returning the remote references.
+func ResolveTreeFS(fSys filesys.FileSystem, repoRoot, dir string) (*Tree, error) {
+	r, err := walk(fSys, repoRoot, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{Deps: r.deps, Remotes: r.remotes}, nil
+}
+
+// walk resolves the kustomization tree at dir.
+func walk(fSys filesys.FileSystem, repoRoot, dir string) (*resolver, error) {
 	absDir, err := filepath.Abs(filepath.Join(repoRoot, dir))
 	if err != nil {
 		return nil, err
@@ -35,47 +69,71 @@ func Resolve(repoRoot, dir string) (map[string]bool, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := resolve(absRoot, absDir, deps, visited); err != nil {
+	r := &resolver{
+		fSys:     fSys,
+		repoRoot: absRoot,
+		deps:     make(map[string]bool),
+		visited:  make(map[string]bool),
+	}
+	if err := r.resolve(absDir); err != nil {
 		return nil, err
 	}
-	return deps, nil
+	return r, nil
 }
 
-// hasKustomization returns true if the directory contains a kustomization file.
-func hasKustomization(dir string) bool {
+// resolver accumulates the dependencies of one kustomization tree.
+type resolver struct {
+	fSys         filesys.FileSystem
+	repoRoot     string
+	deps         map[string]bool
+	visited      map[string]bool
+	remotes      []string
+	deprecations []Deprecation
+}
+
+// KustomizationFile returns the path of the kustomization file in dir, or ""
+// if dir has none.
+func KustomizationFile(fSys filesys.FileSystem, dir string) string {
 	for _, name := range kustomizationFileNames {
-		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
-			return true
+		if path := filepath.Join(dir, name); fSys.Exists(path) {
+			return path
 		}
 	}
-	return false
+	return ""
+}
+
+// hasKustomization returns true if the directory contains a kustomization file.
+func (r *resolver) hasKustomization(dir string) bool {
+	return KustomizationFile(r.fSys, dir) != ""
 }
 
-func resolve(repoRoot, absDir string, deps, visited map[string]bool) error {
+func (r *resolver) resolve(absDir string) error {
+	repoRoot, deps := r.repoRoot, r.deps
+
 	// Avoid infinite loops from circular references
-	if visited[absDir] {
+	if r.visited[absDir] {
 		return nil
 	}
-	visited[absDir] = true
+	r.visited[absDir] = true
 
-	k, kustomFile, err := loadKustomization(absDir)
+	k, kustomFile, err := r.loadKustomization(absDir)
 	if err != nil {
 		// No kustomization file in this directory — fall back to scanning
 		// subdirectories. This handles the common pattern where an
 		// ApplicationSet path like components/X/production/ is a parent
 		// directory containing cluster-specific subdirs (base/, kflux-ocp-p01/, etc.).
-		entries, readErr := os.ReadDir(absDir)
+		names, readErr := r.fSys.ReadDir(absDir)
 		if readErr != nil {
 			return err // return original kustomization error
 		}
 		found := false
-		for _, entry := range entries {
-			if !entry.IsDir() {
+		for _, name := range names {
+			subDir := filepath.Join(absDir, name)
+			if !r.fSys.IsDir(subDir) {
 				continue
 			}
-			subDir := filepath.Join(absDir, entry.Name())
-			if hasKustomization(subDir) {
-				if subErr := resolve(repoRoot, subDir, deps, visited); subErr == nil {
+			if r.hasKustomization(subDir) {
+				if subErr := r.resolve(subDir); subErr == nil {
 					found = true
 				}
 			}
@@ -92,20 +150,22 @@ func resolve(repoRoot, absDir string, deps, visited map[string]bool) error {
 		return err
 	}
 	deps[relPath] = true
+	r.deprecations = append(r.deprecations, deprecations(relPath, k)...)
 
-	// Resources — directories are recursed, files are added, URLs are skipped
-	for _, res := range k.Resources {
+	// Resources — directories are recursed, files are added, URLs are skipped.
+	// Kustomize appends the deprecated bases to the resources.
+	for _, res := range append(k.Resources, k.Bases...) { //nolint:staticcheck // deprecated but still in use
 		if isRemoteURL(res) {
+			r.remotes = append(r.remotes, res)
 			continue
 		}
 		absPath := filepath.Join(absDir, res)
-		info, err := os.Stat(absPath)
-		if err != nil {
+		if !r.fSys.Exists(absPath) {
 			// File/dir doesn't exist — skip gracefully (could be generated)
 			continue
 		}
-		if info.IsDir() {
-			if err := resolve(repoRoot, absPath, deps, visited); err != nil {
+		if r.fSys.IsDir(absPath) {
+			if err := r.resolve(absPath); err != nil {
 				return err
 			}
 		} else {
@@ -145,10 +205,11 @@ func resolve(repoRoot, absDir string, deps, visited map[string]bool) error {
 	// Components — these are directories with their own kustomization
 	for _, comp := range k.Components {
 		if isRemoteURL(comp) {
+			r.remotes = append(r.remotes, comp)
 			continue
 		}
 		absComp := filepath.Join(absDir, comp)
-		if err := resolve(repoRoot, absComp, deps, visited); err != nil {
+		if err := r.resolve(absComp); err != nil {
 			return err
 		}
 	}
@@ -168,12 +229,13 @@ func resolve(repoRoot, absDir string, deps, visited map[string]bool) error {
 	// changes the output of the build.
 	for _, g := range k.Generators {
 		if isRemoteURL(g) {
+			r.remotes = append(r.remotes, g)
 			continue
 		}
 		addFile(repoRoot, absDir, g, deps)
 		// If the generator is a HelmChartInflationGenerator, its valuesFile
 		// and additionalValuesFiles are also dependencies.
-		if err := addHelmGeneratorDeps(repoRoot, absDir, g, deps); err != nil {
+		if err := r.addHelmGeneratorDeps(absDir, g); err != nil {
 			return err
 		}
 	}
@@ -181,6 +243,7 @@ func resolve(repoRoot, absDir string, deps, visited map[string]bool) error {
 	// Transformers — kustomize transformer plugin config files.
 	for _, t := range k.Transformers {
 		if isRemoteURL(t) {
+			r.remotes = append(r.remotes, t)
 			continue
 		}
 		addFile(repoRoot, absDir, t, deps)
@@ -189,6 +252,7 @@ func resolve(repoRoot, absDir string, deps, visited map[string]bool) error {
 	// Validators — kustomize validator plugin config files.
 	for _, v := range k.Validators {
 		if isRemoteURL(v) {
+			r.remotes = append(r.remotes, v)
 			continue
 		}
 		addFile(repoRoot, absDir, v, deps)
@@ -208,7 +272,7 @@ func resolve(repoRoot, absDir string, deps, visited map[string]bool) error {
 	for _, hc := range k.HelmCharts {
 		if hc.Name != "" {
 			chartDir := filepath.Join(absDir, chartHome, hc.Name)
-			if err := addDirTree(repoRoot, chartDir, deps); err != nil {
+			if err := r.addDirTree(chartDir); err != nil {
 				return fmt.Errorf("walking helm chart %s: %w", hc.Name, err)
 			}
 		}
@@ -220,6 +284,13 @@ func resolve(repoRoot, absDir string, deps, visited map[string]bool) error {
 		}
 	}
 
+	// Replacements — entries with a path read the replacement from a file.
+	for _, rf := range k.Replacements {
+		if rf.Path != "" {
+			addFile(repoRoot, absDir, rf.Path, deps)
+		}
+	}
+
 	// CRDs
 	for _, crd := range k.Crds {
 		addFile(repoRoot, absDir, crd, deps)
@@ -248,9 +319,10 @@ type helmGeneratorConfig struct {
 // HelmChartInflationGenerator, adds its valuesFile, additionalValuesFiles,
 // and local chart directory (charts/<name>) as dependencies.
 // Non-HelmChartInflationGenerator files are silently skipped.
-func addHelmGeneratorDeps(repoRoot, absDir, generatorPath string, deps map[string]bool) error {
+func (r *resolver) addHelmGeneratorDeps(absDir, generatorPath string) error {
+	repoRoot, deps := r.repoRoot, r.deps
 	absPath := filepath.Join(absDir, generatorPath)
-	data, err := os.ReadFile(absPath)
+	data, err := r.fSys.ReadFile(absPath)
 	if err != nil {
 		return fmt.Errorf("reading generator %s: %w", generatorPath, err)
 	}
@@ -271,8 +343,8 @@ func addHelmGeneratorDeps(repoRoot, absDir, generatorPath string, deps map[strin
 	// If a local chart directory exists (charts/<name>), track it.
 	if cfg.Name != "" {
 		chartDir := filepath.Join(absDir, types.HelmDefaultHome, cfg.Name)
-		if info, err := os.Stat(chartDir); err == nil && info.IsDir() {
-			if err := addDirTree(repoRoot, chartDir, deps); err != nil {
+		if r.fSys.IsDir(chartDir) {
+			if err := r.addDirTree(chartDir); err != nil {
 				return fmt.Errorf("walking local chart %s: %w", cfg.Name, err)
 			}
 		}
@@ -302,8 +374,9 @@ func addGeneratorSources(repoRoot, absDir string, gen *types.GeneratorArgs, deps
 // contains to the dependency set. This is used for Helm chart directories
 // where any file change (templates, helpers, Chart.yaml, etc.) affects the
 // build output.
-func addDirTree(repoRoot, absDir string, deps map[string]bool) error {
-	return filepath.Walk(absDir, func(path string, info os.FileInfo, err error) error {
+func (r *resolver) addDirTree(absDir string) error {
+	repoRoot, deps := r.repoRoot, r.deps
+	return r.fSys.Walk(absDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -330,14 +403,14 @@ func addFile(repoRoot, absDir, relFilePath string, deps map[string]bool) {
 }
 
 // loadKustomization finds and parses the kustomization file in dir.
-func loadKustomization(dir string) (*types.Kustomization, string, error) {
+func (r *resolver) loadKustomization(dir string) (*types.Kustomization, string, error) {
 	for _, name := range kustomizationFileNames {
 		path := filepath.Join(dir, name)
-		data, err := os.ReadFile(path)
+		if !r.fSys.Exists(path) {
+			continue
+		}
+		data, err := r.fSys.ReadFile(path)
 		if err != nil {
-			if os.IsNotExist(err) {
-				continue
-			}
 			return nil, "", err
 		}
 		var k types.Kustomization
@@ -349,11 +422,46 @@ func loadKustomization(dir string) (*types.Kustomization, string, error) {
 	return nil, "", fmt.Errorf("no kustomization file found in %s", dir)
 }
 
-// isRemoteURL checks if a resource reference is a remote URL.
+// isRemoteURL reports whether a resource reference names a remote target
+// rather than a local path. Like kustomize's repo URL parsing, it accepts
+// URLs with a scheme or the scp-like git@ form, any reference with a ref or
+// version query or a // subdirectory, and scheme-less host/org/repo paths
+// such as github.com/org/repo?ref=v1.
 func isRemoteURL(s string) bool {
-	return strings.HasPrefix(s, "http://") ||
-		strings.HasPrefix(s, "https://") ||
-		strings.HasPrefix(s, "ssh://") ||
-		strings.HasPrefix(s, "git@") ||
-		strings.HasPrefix(s, "git://")
+	for _, prefix := range []string{"http://", "https://", "ssh://", "git@", "git://", "file://"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	if filepath.IsAbs(s) {
+		return false
+	}
+	path, query, _ := strings.Cut(s, "?")
+	if values, err := url.ParseQuery(query); err == nil && (values.Has("ref") || values.Has("version")) {
+		return true
+	}
+	if strings.Contains(path, "//") {
+		return true
+	}
+	host, repo, ok := strings.Cut(path, "/")
+	return ok && strings.Contains(repo, "/") && isHostname(host)
+}
+
+// isHostname reports whether s looks like a DNS name, e.g. github.com, as
+// opposed to a local directory or a file name such as base.yaml.
+func isHostname(s string) bool {
+	dot := strings.LastIndexByte(s, '.')
+	if dot <= 0 || strings.HasPrefix(s, ".") {
+		return false
+	}
+	tld := s[dot+1:]
+	if len(tld) < 2 || tld == "yaml" || tld == "yml" || tld == "json" {
+		return false
+	}
+	for _, c := range tld {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
 func TestResolve_SimpleKustomization(t *testing.T) {
@@ -77,6 +78,71 @@ resources:
 	}
 }
 
+func TestResolveTreeFS_Remotes(t *testing.T) {
+	g := NewWithT(t)
+	fSys := filesys.MakeFsInMemory()
+	g.Expect(fSys.WriteFile("/repo/component/kustomization.yaml", []byte(`resources:
+  - https://github.com/example/repo/config/default?ref=main
+  - ../base
+components:
+  - git@github.com:example/repo.git//components/x
+`))).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/base/kustomization.yaml", []byte("resources:\n  - https://example.com/manifest.yaml\n"))).To(Succeed())
+
+	tree, err := ResolveTreeFS(fSys, "/repo", "component")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tree.Remotes).To(Equal([]string{
+		"https://github.com/example/repo/config/default?ref=main",
+		"https://example.com/manifest.yaml",
+		"git@github.com:example/repo.git//components/x",
+	}))
+	g.Expect(tree.Deps).To(HaveLen(2))
+}
+
+func TestIsRemoteURL(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, ref := range []string{
+		"https://github.com/example/repo/config?ref=main",
+		"git@github.com:example/repo.git//components/x",
+		// Scheme-less, as in components/pipeline-service/development.
+		"github.com/minio/operator?ref=v5.0.15",
+		"github.com/example/repo",
+		"example.com/org/repo//config",
+		"repo/config?version=v1",
+	} {
+		g.Expect(isRemoteURL(ref)).To(BeTrue(), ref)
+	}
+	for _, ref := range []string{
+		"deployment.yaml",
+		"../base",
+		"config.d/a/b.yaml",
+		"base/v1.2/config",
+		"/abs/path",
+	} {
+		g.Expect(isRemoteURL(ref)).To(BeFalse(), ref)
+	}
+}
+
+func TestResolve_Replacements(t *testing.T) {
+	g := NewWithT(t)
+	fSys := filesys.MakeFsInMemory()
+	g.Expect(fSys.WriteFile("/repo/component/kustomization.yaml", []byte(`resources:
+  - deployment.yaml
+replacements:
+  - path: replacements/image.yaml
+  - source:
+      kind: ConfigMap
+      name: inline
+      fieldPath: data.x
+    targets: []
+`))).To(Succeed())
+
+	deps, err := ResolveFS(fSys, "/repo", "component")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deps).To(HaveKey("component/replacements/image.yaml"))
+}
+
 func TestResolve_Components(t *testing.T) {
 	g := NewWithT(t)
 	tmpDir := t.TempDir()
@@ -451,6 +517,33 @@ resources:
 	g.Expect(deps).To(HaveKey("b/kustomization.yaml"))
 }
 
+func TestResolveFS_InMemory(t *testing.T) {
+	g := NewWithT(t)
+	fSys := filesys.MakeFsInMemory()
+
+	g.Expect(fSys.MkdirAll("/repo/component/base/chart/templates")).To(Succeed())
+	g.Expect(fSys.MkdirAll("/repo/component/production")).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/component/base/chart/Chart.yaml", []byte("apiVersion: v2\nname: chart"))).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/component/base/chart/templates/cm.yaml", []byte("kind: ConfigMap"))).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/component/base/kustomization.yaml", []byte("resources:\n  - deployment.yaml\n"))).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/component/base/deployment.yaml", []byte("kind: Deployment"))).To(Succeed())
+	g.Expect(fSys.WriteFile("/repo/component/production/kustomization.yaml", []byte(`
+resources:
+  - ../base
+helmGlobals:
+  chartHome: ../base
+helmCharts:
+  - name: chart
+    releaseName: chart
+`))).To(Succeed())
+
+	deps, err := ResolveFS(fSys, "/repo", "component/production")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deps).To(HaveKey("component/production/kustomization.yaml"))
+	g.Expect(deps).To(HaveKey("component/base/deployment.yaml"))
+	g.Expect(deps).To(HaveKey("component/base/chart/templates/cm.yaml"))
+}
+
 func writeFile(t *testing.T, path, content string) {
 	t.Helper()
 	NewWithT(t).Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
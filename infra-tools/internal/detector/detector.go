@@ -125,7 +125,7 @@ func (d *Detector) AffectedComponents(changedFiles []string) (map[Environment][]
 	}
 
 	// Phase 3: Extract component paths from ApplicationSets
-	envPaths, allClusters, err := extractPathsFromOverlays(builds)
+	envPaths, allClusters, err := extractPathsFromOverlays(builds, d.head)
 	if err != nil {
 		return nil, err
 	}
@@ -164,7 +164,7 @@ func (d *Detector) Detect(changedFiles []string) (*Result, error) {
 	detectOverlayDiffs(builds, result)
 
 	// Phase 3: Extract component paths from ApplicationSets
-	envPaths, allClusters, err := extractPathsFromOverlays(builds)
+	envPaths, allClusters, err := extractPathsFromOverlays(builds, d.head)
 	if err != nil {
 		return nil, err
 	}
@@ -319,16 +319,20 @@ func appSetEnv(doc map[string]interface{}, overlayEnv Environment) Environment {
 
 // extractPathsFromOverlays parses ApplicationSets from the HEAD builds and
 // returns per-environment component paths and a mapping from cluster name to
-// the component paths deployed on that cluster.
-func extractPathsFromOverlays(builds []overlayBuild) (map[Environment][]appset.ComponentPath, map[string][]string, error) {
+// the component paths deployed on that cluster. Git generators are evaluated
+// against tree.
+func extractPathsFromOverlays(builds []overlayBuild, tree appset.RepoTree) (map[Environment][]appset.ComponentPath, map[string][]string, error) {
 	envPaths := make(map[Environment][]appset.ComponentPath)
 	allClusters := make(map[string][]string)
 
 	for _, ob := range builds {
-		parsed, err := appset.ParseApplicationSets(ob.headYAML)
+		parsed, err := appset.ParseApplicationSets(ob.headYAML, appset.WithRepoTree(tree))
 		if err != nil {
 			return nil, nil, fmt.Errorf("parsing ApplicationSets from overlay %s: %w", ob.name, err)
 		}
+		for _, w := range parsed.Warnings {
+			slog.Warn(w, "overlay", ob.name)
+		}
 
 		// Every path inherits the environment from the overlay that produced it.
 		envPaths[ob.env] = append(envPaths[ob.env], parsed.Paths...)
@@ -15,8 +15,9 @@ import (
 // ---------------------------------------------------------------------------
 
 type fakeRepo struct {
-	dirs  map[string][]string        // ListSubDirs results keyed by rel path
+	dirs  map[string][]string        // ListSubDirs and ReadDir results keyed by rel path
 	exist map[string]bool            // DirExists results keyed by rel path
+	files map[string][]byte          // ReadFile results keyed by rel path
 	yamls map[string][]byte          // BuildKustomization results keyed by rel path
 	deps  map[string]map[string]bool // ResolveDeps results keyed by rel path
 }
@@ -32,6 +33,17 @@ func (f *fakeRepo) DirExists(rel string) bool {
 	return f.exist[rel]
 }
 
+func (f *fakeRepo) ReadDir(rel string) ([]string, error) {
+	return f.ListSubDirs(rel)
+}
+
+func (f *fakeRepo) ReadFile(rel string) ([]byte, error) {
+	if b, ok := f.files[rel]; ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("no such file: %s", rel)
+}
+
 func (f *fakeRepo) BuildKustomization(rel string) ([]byte, error) {
 	if y, ok := f.yamls[rel]; ok {
 		return y, nil
@@ -1039,7 +1051,7 @@ func TestExtractPathsFromOverlays_Basic(t *testing.T) {
 		{name: "staging-downstream", env: Staging, headYAML: []byte(yaml)},
 	}
 
-	envPaths, allClusters, err := extractPathsFromOverlays(builds)
+	envPaths, allClusters, err := extractPathsFromOverlays(builds, nil)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	g.Expect(envPaths).To(HaveKey(Staging))
@@ -1065,7 +1077,7 @@ func TestExtractPathsFromOverlays_MultipleEnvs(t *testing.T) {
 		{name: "production-downstream", env: Production, headYAML: []byte(minimalAppSetYAML)},
 	}
 
-	envPaths, _, err := extractPathsFromOverlays(builds)
+	envPaths, _, err := extractPathsFromOverlays(builds, nil)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	g.Expect(envPaths).To(HaveKey(Staging))
@@ -1079,7 +1091,7 @@ func TestExtractPathsFromOverlays_InvalidYAML(t *testing.T) {
 		{name: "bad", env: Development, headYAML: []byte("not: valid: yaml: [}")},
 	}
 
-	_, _, err := extractPathsFromOverlays(builds)
+	_, _, err := extractPathsFromOverlays(builds, nil)
 	g.Expect(err).To(HaveOccurred())
 }
 
@@ -0,0 +1,65 @@
+// Package detector — see detector.go for package docs.
+package detector
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+)
+
+// OverlayApplications holds the Applications generated by the
+// ApplicationSets of one overlay.
+type OverlayApplications struct {
+	Overlay      string
+	Environment  Environment
+	Applications []appset.Application
+	// Warnings describes generators and templates that couldn't be
+	// evaluated, so some Applications may be missing.
+	Warnings []string
+}
+
+// Inventory builds every ApplicationSet overlay in overlaysDir on repo and
+// expands its ApplicationSets into the Applications Argo CD would create.
+// Overlays are built in parallel and returned sorted by name. Every overlay
+// must be known to OverlayEnvironment.
+func Inventory(repo RepoQuerier, overlaysDir string) ([]OverlayApplications, error) {
+	names, err := repo.ListSubDirs(overlaysDir)
+	if err != nil {
+		return nil, fmt.Errorf("listing overlay dirs: %w", err)
+	}
+	sort.Strings(names)
+
+	inventory := make([]OverlayApplications, len(names))
+	var g errgroup.Group
+	for i, name := range names {
+		env, ok := OverlayEnvironment[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown overlay %q in %s: not present in OverlayEnvironment map", name, overlaysDir)
+		}
+		g.Go(func() error {
+			out, err := repo.BuildKustomization(filepath.Join(overlaysDir, name))
+			if err != nil {
+				return fmt.Errorf("building overlay %s: %w", name, err)
+			}
+			parsed, err := appset.ParseApplicationSets(out, appset.WithRepoTree(repo))
+			if err != nil {
+				return fmt.Errorf("parsing ApplicationSets from overlay %s: %w", name, err)
+			}
+			inventory[i] = OverlayApplications{
+				Overlay:      name,
+				Environment:  env,
+				Applications: parsed.Applications,
+				Warnings:     parsed.Warnings,
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return inventory, nil
+}
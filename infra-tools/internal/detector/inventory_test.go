@@ -0,0 +1,49 @@
+package detector
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestInventory(t *testing.T) {
+	g := NewWithT(t)
+
+	ref, err := NewMemRepoRef(map[string][]byte{
+		"argo-cd-apps/overlays/development/kustomization.yaml":           []byte("resources:\n  - foo.yaml\n"),
+		"argo-cd-apps/overlays/development/foo.yaml":                     []byte(minimalAppSetYAML),
+		"argo-cd-apps/overlays/production-downstream/kustomization.yaml": []byte("resources:\n  - bar.yaml\n"),
+		"argo-cd-apps/overlays/production-downstream/bar.yaml":           []byte(appSetWithCluster("components/bar", "production", "stone-prd-01")),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	inventory, err := Inventory(ref, "argo-cd-apps/overlays")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(inventory).To(HaveLen(2))
+
+	dev := inventory[0]
+	g.Expect(dev.Overlay).To(Equal("development"))
+	g.Expect(dev.Environment).To(Equal(Development))
+	g.Expect(dev.Applications).To(HaveLen(1))
+	g.Expect(dev.Applications[0].Source.Path).To(Equal("components/foo"))
+	g.Expect(dev.Applications[0].AllClusters).To(BeTrue())
+
+	prod := inventory[1]
+	g.Expect(prod.Environment).To(Equal(Production))
+	g.Expect(prod.Applications).To(HaveLen(2))
+	g.Expect(prod.Applications[1].Name).To(Equal("test-stone-prd-01"))
+	g.Expect(prod.Applications[1].Cluster).To(Equal("stone-prd-01"))
+	g.Expect(prod.Applications[1].Source.Path).To(Equal("components/bar/production/stone-prd-01"))
+}
+
+func TestInventory_UnknownOverlay(t *testing.T) {
+	g := NewWithT(t)
+
+	ref, err := NewMemRepoRef(map[string][]byte{
+		"argo-cd-apps/overlays/bogus/kustomization.yaml": []byte("resources: []\n"),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = Inventory(ref, "argo-cd-apps/overlays")
+	g.Expect(err).To(MatchError(ContainSubstring(`unknown overlay "bogus"`)))
+}
@@ -0,0 +1,101 @@
+package detector
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// memRoot is the repo root of a RepoRef backed by an in-memory filesystem.
+const memRoot = "/"
+
+// NewMemRepoRef returns a RepoRef backed by an in-memory filesystem holding
+// files, keyed by repo-relative slash-separated path. Nothing is read from or
+// written to disk, which makes it suitable for hermetic tests of realistic
+// overlay layouts and for rendering trees that only exist in memory.
+func NewMemRepoRef(files map[string][]byte, opts ...RepoRefOption) (*RepoRef, error) {
+	fSys := filesys.MakeFsInMemory()
+	for name, data := range files {
+		if err := writeMemFile(fSys, name, data); err != nil {
+			return nil, err
+		}
+	}
+	return newMemRepoRef(fSys, opts), nil
+}
+
+// NewTarRepoRef returns a RepoRef backed by an in-memory copy of the tar
+// archive read from r, such as the output of `git archive`. Gzip-compressed
+// archives are detected and decompressed. Entry names are taken as
+// repo-relative paths, so archives must not carry a leading directory prefix.
+// Symlinks and hard links are not supported.
+func NewTarRepoRef(r io.Reader, opts ...RepoRefOption) (*RepoRef, error) {
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading gzip stream: %w", err)
+		}
+		defer func() { _ = zr.Close() }()
+		src = zr
+	}
+
+	fSys := filesys.MakeFsInMemory()
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar archive: %w", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := fSys.MkdirAll(memPath(hdr.Name)); err != nil {
+				return nil, fmt.Errorf("creating %s: %w", hdr.Name, err)
+			}
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s from tar archive: %w", hdr.Name, err)
+			}
+			if err := writeMemFile(fSys, hdr.Name, data); err != nil {
+				return nil, err
+			}
+		case tar.TypeSymlink, tar.TypeLink:
+			return nil, fmt.Errorf("%s: links are not supported in tar archives", hdr.Name)
+		default:
+			// Skip metadata entries such as the pax global header that
+			// git archive writes with the commit ID.
+		}
+	}
+	return newMemRepoRef(fSys, opts), nil
+}
+
+func newMemRepoRef(fSys filesys.FileSystem, opts []RepoRefOption) *RepoRef {
+	return NewRepoRef(memRoot, append([]RepoRefOption{WithFileSystem(fSys)}, opts...)...)
+}
+
+// memPath maps a repo-relative path to its absolute path in a memory
+// filesystem. Cleaning against the root keeps ".." from escaping it.
+func memPath(name string) string {
+	return path.Join(memRoot, path.Clean("/"+name))
+}
+
+func writeMemFile(fSys filesys.FileSystem, name string, data []byte) error {
+	p := memPath(name)
+	if err := fSys.MkdirAll(path.Dir(p)); err != nil {
+		return fmt.Errorf("creating parent of %s: %w", name, err)
+	}
+	if err := fSys.WriteFile(p, data); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package detector
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// memLayout is a realistic repo layout: an ApplicationSet overlay pointing at
+// components/foo, which pulls in a shared base outside its own directory.
+func memLayout(replicas string) map[string][]byte {
+	return map[string][]byte{
+		"argo-cd-apps/overlays/development/kustomization.yaml": []byte("resources:\n  - foo.yaml\n"),
+		"argo-cd-apps/overlays/development/foo.yaml":           []byte(minimalAppSetYAML),
+		"components/foo/kustomization.yaml":                    []byte("resources:\n  - ../common\n  - deploy.yaml\n"),
+		"components/foo/deploy.yaml": []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+spec:
+  replicas: ` + replicas + `
+`),
+		"components/common/kustomization.yaml": []byte("resources:\n  - cm.yaml\n"),
+		"components/common/cm.yaml":            []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: common\n"),
+		"components/bar/kustomization.yaml":    []byte("resources: []\n"),
+	}
+}
+
+func TestNewMemRepoRef_QueriesAndBuilds(t *testing.T) {
+	g := NewWithT(t)
+
+	ref, err := NewMemRepoRef(memLayout("2"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(ref.DirExists("components/foo")).To(BeTrue())
+	g.Expect(ref.DirExists("components/baz")).To(BeFalse())
+
+	dirs, err := ref.ListSubDirs("components")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dirs).To(ConsistOf("bar", "common", "foo"))
+
+	out, err := ref.BuildKustomization("components/foo")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).To(ContainSubstring("name: common"))
+	g.Expect(string(out)).To(ContainSubstring("replicas: 2"))
+
+	deps, err := ref.ResolveDeps("components/foo")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deps).To(HaveKey("components/foo/deploy.yaml"))
+	g.Expect(deps).To(HaveKey("components/common/cm.yaml"))
+	g.Expect(deps).NotTo(HaveKey("components/bar/kustomization.yaml"))
+}
+
+func TestNewMemRepoRef_PathsStayInsideRoot(t *testing.T) {
+	g := NewWithT(t)
+
+	ref, err := NewMemRepoRef(map[string][]byte{"../../outside/kustomization.yaml": []byte("resources: []\n")})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ref.DirExists("outside")).To(BeTrue())
+}
+
+func TestDetect_InMemoryRepos(t *testing.T) {
+	g := NewWithT(t)
+
+	head, err := NewMemRepoRef(memLayout("2"))
+	g.Expect(err).NotTo(HaveOccurred())
+	base, err := NewMemRepoRef(memLayout("1"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	d, err := NewDetector(head, base, "argo-cd-apps/overlays")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// A file only reachable through the dependency tree is matched.
+	result, err := d.Detect([]string{"components/common/cm.yaml"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.AffectedEnvironments).To(HaveKey(Development))
+
+	// A component no ApplicationSet deploys is not.
+	result, err = d.Detect([]string{"components/bar/kustomization.yaml"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.AffectedEnvironments).To(BeEmpty())
+}
+
+// tarEntry is a tar header with the body written after it.
+type tarEntry struct {
+	hdr  *tar.Header
+	body string
+}
+
+func tarDir(name string) tarEntry {
+	return tarEntry{hdr: &tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0o755}}
+}
+
+func tarFile(name, body string) tarEntry {
+	return tarEntry{hdr: &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(body))}, body: body}
+}
+
+// tarball writes entries to a tar archive, gzip-compressed when compress is
+// set. A pax global header is prepended, as git archive does.
+func tarball(t *testing.T, compress bool, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var zw *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if compress {
+		zw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(zw)
+	}
+	global := tarEntry{hdr: &tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		Name:       "pax_global_header",
+		PAXRecords: map[string]string{"comment": "0123456789abcdef"},
+	}}
+	for _, e := range append([]tarEntry{global}, entries...) {
+		if err := tw.WriteHeader(e.hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestNewTarRepoRef(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		g := NewWithT(t)
+
+		data := tarball(t, compress,
+			tarDir("components/"),
+			tarDir("components/empty/"),
+			tarFile("components/foo/kustomization.yaml", "resources:\n  - cm.yaml\n"),
+			tarFile("components/foo/cm.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n"),
+		)
+
+		ref, err := NewTarRepoRef(bytes.NewReader(data))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ref.DirExists("components/empty")).To(BeTrue())
+
+		out, err := ref.BuildKustomization("components/foo")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(out)).To(ContainSubstring("name: foo"))
+	}
+}
+
+func TestNewTarRepoRef_RejectsLinks(t *testing.T) {
+	g := NewWithT(t)
+
+	data := tarball(t, false, tarEntry{hdr: &tar.Header{Typeflag: tar.TypeSymlink, Name: "components/link", Linkname: "foo"}})
+	_, err := NewTarRepoRef(bytes.NewReader(data))
+	g.Expect(err).To(MatchError(ContainSubstring("links are not supported")))
+}
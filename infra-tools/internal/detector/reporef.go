@@ -2,9 +2,11 @@
 package detector
 
 import (
-	"os"
 	"path/filepath"
 
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/buildcache"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/deptree"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/kustomize"
 )
@@ -15,6 +17,8 @@ import (
 type RepoQuerier interface {
 	ListSubDirs(rel string) ([]string, error)
 	DirExists(rel string) bool
+	ReadDir(rel string) ([]string, error)
+	ReadFile(rel string) ([]byte, error)
 	BuildKustomization(rel string) ([]byte, error)
 	ResolveDeps(rel string) (map[string]bool, error)
 }
@@ -24,12 +28,38 @@ type RepoQuerier interface {
 // worktree) are represented as RepoRef values so every detection helper can
 // work against either ref without manual filepath.Join / os.Stat boilerplate.
 type RepoRef struct {
-	root string
+	root  string
+	fs    filesys.FileSystem
+	cache *buildcache.Cache // optional; nil disables build caching
+}
+
+// RepoRefOption configures optional RepoRef behaviour.
+type RepoRefOption func(*RepoRef)
+
+// WithBuildCache makes BuildKustomization reuse outputs from the given
+// persistent cache. A nil cache leaves caching disabled.
+func WithBuildCache(c *buildcache.Cache) RepoRefOption {
+	return func(r *RepoRef) {
+		r.cache = c
+	}
+}
+
+// WithFileSystem makes the RepoRef read files from fSys instead of the local
+// disk, e.g. a commit tree served by git.Repository.Checkout. root is then a
+// path within fSys.
+func WithFileSystem(fSys filesys.FileSystem) RepoRefOption {
+	return func(r *RepoRef) {
+		r.fs = fSys
+	}
 }
 
 // NewRepoRef creates a RepoRef rooted at the given absolute path.
-func NewRepoRef(root string) *RepoRef {
-	return &RepoRef{root: root}
+func NewRepoRef(root string, opts ...RepoRefOption) *RepoRef {
+	r := &RepoRef{root: root, fs: filesys.MakeFsOnDisk()}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 // Root returns the absolute root path of this ref.
@@ -44,38 +74,53 @@ func (r *RepoRef) AbsPath(rel string) string {
 
 // DirExists reports whether rel exists and is a directory.
 func (r *RepoRef) DirExists(rel string) bool {
-	info, err := os.Stat(r.AbsPath(rel))
-	return err == nil && info.IsDir()
+	return r.fs.IsDir(r.AbsPath(rel))
+}
+
+// ReadDir returns the names of the files and directories at rel.
+func (r *RepoRef) ReadDir(rel string) ([]string, error) {
+	return r.fs.ReadDir(r.AbsPath(rel))
 }
 
-// ReadDir returns the directory entries at rel.
-func (r *RepoRef) ReadDir(rel string) ([]os.DirEntry, error) {
-	return os.ReadDir(r.AbsPath(rel))
+// ReadFile returns the contents of the file at rel.
+func (r *RepoRef) ReadFile(rel string) ([]byte, error) {
+	return r.fs.ReadFile(r.AbsPath(rel))
 }
 
 // ListSubDirs returns the names of immediate subdirectories under rel.
 func (r *RepoRef) ListSubDirs(rel string) ([]string, error) {
-	entries, err := os.ReadDir(r.AbsPath(rel))
+	names, err := r.fs.ReadDir(r.AbsPath(rel))
 	if err != nil {
 		return nil, err
 	}
 	var dirs []string
-	for _, e := range entries {
-		if e.IsDir() {
-			dirs = append(dirs, e.Name())
+	for _, name := range names {
+		if r.fs.IsDir(filepath.Join(r.AbsPath(rel), name)) {
+			dirs = append(dirs, name)
 		}
 	}
 	return dirs, nil
 }
 
 // BuildKustomization runs kustomize build on the directory at rel and returns
-// the rendered YAML.
+// the rendered YAML. When a build cache is configured, outputs are reused
+// across refs and runs as long as the dependency tree is unchanged.
 func (r *RepoRef) BuildKustomization(rel string) ([]byte, error) {
-	return kustomize.Build(r.AbsPath(rel))
+	build := func() ([]byte, error) { return kustomize.BuildFS(r.fs, r.AbsPath(rel)) }
+	if r.cache == nil {
+		return build()
+	}
+	return r.cache.BuildFS(r.fs, r.root, rel, build)
 }
 
 // ResolveDeps walks the kustomization dependency tree starting at rel and
 // returns the set of all files (repo-root-relative) it depends on.
 func (r *RepoRef) ResolveDeps(rel string) (map[string]bool, error) {
-	return deptree.Resolve(r.root, rel)
+	return deptree.ResolveFS(r.fs, r.root, rel)
+}
+
+// Deprecations walks the kustomization tree at rel and returns the
+// deprecated fields its kustomization files use.
+func (r *RepoRef) Deprecations(rel string) ([]deptree.Deprecation, error) {
+	return deptree.DeprecationsFS(r.fs, r.root, rel)
 }
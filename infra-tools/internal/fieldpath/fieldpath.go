@@ -0,0 +1,192 @@
+// Package fieldpath parses and formats the JSONPath-style field paths used by
+// policy, normalize, redact and schema findings, e.g.
+// spec.template.spec.containers[name=app].image or
+// metadata.labels["app.kubernetes.io/name"].
+package fieldpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kind distinguishes the segments of a parsed Path.
+type Kind int
+
+const (
+	Key       Kind = iota // a mapping key
+	Index                 // a list index, written [n]
+	Name                  // the list item whose name field is Key, written [name=x]
+	Wildcard              // every key or list item, written * or [*]
+	Recursive             // zero or more levels, written **
+)
+
+// Segment is one element of a Path.
+type Segment struct {
+	Kind Kind
+	// Key is the mapping key, or the item name for Name.
+	Key   string
+	Index int
+}
+
+// Path is a parsed field path.
+type Path []Segment
+
+// Parse parses a field path: dotted keys, * for any key or list item, ** for
+// any depth, [n] for a list index, [name=x] for the list item named x, and
+// ["a.b"] for keys containing dots. A leading $. is optional.
+func Parse(s string) (Path, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "$"), ".")
+	if s == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	var p Path
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '.':
+			if i == 0 || i == len(s)-1 || s[i+1] == '.' || s[i+1] == '[' {
+				return nil, fmt.Errorf("invalid path %q: misplaced '.'", s)
+			}
+			i++
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unterminated '['", s)
+			}
+			inner := s[i+1 : i+end]
+			switch {
+			case inner == "*":
+				p = append(p, Segment{Kind: Wildcard})
+			case strings.HasPrefix(inner, `"`):
+				key, err := strconv.Unquote(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q: bad quoted key %s", s, inner)
+				}
+				p = append(p, Segment{Kind: Key, Key: key})
+			case strings.HasPrefix(inner, "name="):
+				p = append(p, Segment{Kind: Name, Key: strings.TrimPrefix(inner, "name=")})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid path %q: bad index [%s]", s, inner)
+				}
+				p = append(p, Segment{Kind: Index, Index: n})
+			}
+			i += end + 1
+		default:
+			end := strings.IndexAny(s[i:], ".[")
+			if end < 0 {
+				end = len(s) - i
+			}
+			switch key := s[i : i+end]; key {
+			case "*":
+				p = append(p, Segment{Kind: Wildcard})
+			case "**":
+				p = append(p, Segment{Kind: Recursive})
+			default:
+				p = append(p, Segment{Kind: Key, Key: key})
+			}
+			i += end
+		}
+	}
+	return p, nil
+}
+
+// JoinKey appends a mapping key to a field path. Keys that contain path
+// separators (e.g. "app.kubernetes.io/name") are quoted in brackets.
+func JoinKey(path, key string) string {
+	if strings.ContainsAny(key, ".[]\" ") {
+		return fmt.Sprintf("%s[%s]", path, strconv.Quote(key))
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Join appends the segments of p to a concrete location, so that a field
+// that does not exist can be reported with its full path.
+func Join(at string, p Path) string {
+	for _, seg := range p {
+		switch seg.Kind {
+		case Key:
+			at = JoinKey(at, seg.Key)
+		case Index:
+			at = fmt.Sprintf("%s[%d]", at, seg.Index)
+		case Name:
+			at = fmt.Sprintf("%s[name=%s]", at, seg.Key)
+		case Wildcard:
+			at += "[*]"
+		case Recursive:
+			at = JoinKey(at, "**")
+		}
+	}
+	return at
+}
+
+// Walk calls fn for every node p selects in n.
+func (p Path) Walk(n *yaml.Node, fn func(*yaml.Node)) {
+	if len(p) == 0 {
+		fn(n)
+		return
+	}
+	seg, rest := p[0], p[1:]
+	switch seg.Kind {
+	case Key:
+		if v := mappingValue(n, seg.Key); v != nil {
+			rest.Walk(v, fn)
+		}
+	case Index:
+		if n.Kind == yaml.SequenceNode && seg.Index < len(n.Content) {
+			rest.Walk(n.Content[seg.Index], fn)
+		}
+	case Name:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, item := range n.Content {
+			if name := mappingValue(item, "name"); name != nil && name.Kind == yaml.ScalarNode && name.Value == seg.Key {
+				rest.Walk(item, fn)
+			}
+		}
+	case Wildcard:
+		for _, child := range children(n) {
+			rest.Walk(child, fn)
+		}
+	case Recursive:
+		rest.Walk(n, fn)
+		for _, child := range children(n) {
+			p.Walk(child, fn)
+		}
+	}
+}
+
+// mappingValue returns the value of key in mapping n, or nil.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// children returns the mapping values or list items of n.
+func children(n *yaml.Node) []*yaml.Node {
+	switch n.Kind {
+	case yaml.MappingNode:
+		var out []*yaml.Node
+		for i := 1; i < len(n.Content); i += 2 {
+			out = append(out, n.Content[i])
+		}
+		return out
+	case yaml.SequenceNode:
+		return n.Content
+	}
+	return nil
+}
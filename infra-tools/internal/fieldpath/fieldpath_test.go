@@ -0,0 +1,78 @@
+package fieldpath
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParse(t *testing.T) {
+	g := NewWithT(t)
+
+	p, err := Parse(`metadata.labels["app.kubernetes.io/name"]`)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(p).To(Equal(Path{{Kind: Key, Key: "metadata"}, {Kind: Key, Key: "labels"}, {Kind: Key, Key: "app.kubernetes.io/name"}}))
+
+	p, err = Parse("$.**.containers[*].ports[0]")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(p).To(Equal(Path{{Kind: Recursive}, {Kind: Key, Key: "containers"}, {Kind: Wildcard}, {Kind: Key, Key: "ports"}, {Kind: Index, Index: 0}}))
+
+	p, err = Parse("env[name=API_KEY].value")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(p).To(Equal(Path{{Kind: Key, Key: "env"}, {Kind: Name, Key: "API_KEY"}, {Kind: Key, Key: "value"}}))
+}
+
+func TestParse_Invalid(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, s := range []string{"", "$.", "a..b", "a.", "a[0", "a[x]", "a[-1]", `a["b]`} {
+		_, err := Parse(s)
+		g.Expect(err).To(HaveOccurred(), s)
+	}
+}
+
+func TestJoinKey_QuotesDottedKeys(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(JoinKey("", "spec")).To(Equal("spec"))
+	g.Expect(JoinKey("metadata", "labels")).To(Equal("metadata.labels"))
+	g.Expect(JoinKey("metadata.labels", "app.kubernetes.io/name")).To(Equal(`metadata.labels["app.kubernetes.io/name"]`))
+}
+
+func TestJoin(t *testing.T) {
+	g := NewWithT(t)
+
+	p, err := Parse(`**.containers[name=app].ports[0]["a.b"][*]`)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(Join("spec", p)).To(Equal(`spec.**.containers[name=app].ports[0]["a.b"][*]`))
+}
+
+func TestWalk(t *testing.T) {
+	g := NewWithT(t)
+
+	var doc yaml.Node
+	g.Expect(yaml.Unmarshal([]byte(`spec:
+  containers:
+    - name: app
+      image: a
+    - name: sidecar
+      image: b
+  initContainers:
+    - name: init
+      image: c
+`), &doc)).To(Succeed())
+
+	values := func(path string) []string {
+		p, err := Parse(path)
+		g.Expect(err).NotTo(HaveOccurred())
+		var out []string
+		p.Walk(doc.Content[0], func(n *yaml.Node) { out = append(out, n.Value) })
+		return out
+	}
+	g.Expect(values("spec.containers[name=sidecar].image")).To(Equal([]string{"b"}))
+	g.Expect(values("spec.containers[0].image")).To(Equal([]string{"a"}))
+	g.Expect(values("spec.*[*].image")).To(Equal([]string{"a", "b", "c"}))
+	g.Expect(values("**.image")).To(Equal([]string{"a", "b", "c"}))
+	g.Expect(values("spec.missing.image")).To(BeEmpty())
+}
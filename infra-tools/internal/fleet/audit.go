@@ -0,0 +1,110 @@
+package fleet
+
+import (
+	"fmt"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+// FindingKind classifies a parity finding.
+type FindingKind string
+
+const (
+	// MissingInProduction: deployed on staging but not on production.
+	MissingInProduction FindingKind = "missing-in-production"
+	// MissingInStaging: deployed on production but not on staging, so it
+	// reached production without being validated in staging.
+	MissingInStaging FindingKind = "missing-in-staging"
+	// MissingOnCluster: deployed on most clusters of an environment, but
+	// not on this one.
+	MissingOnCluster FindingKind = "missing-on-cluster"
+	// OnlyOnCluster: deployed on this cluster only, of an environment with
+	// several.
+	OnlyOnCluster FindingKind = "only-on-cluster"
+)
+
+// minPeers is the number of clusters an environment needs for a cluster to
+// be compared against its peers; with fewer there is no majority to deviate
+// from.
+const minPeers = 3
+
+// Finding is one parity problem found in a Matrix.
+type Finding struct {
+	Kind        FindingKind          `json:"kind"`
+	Environment detector.Environment `json:"environment"`
+	Cluster     string               `json:"cluster,omitempty"`
+	Component   string               `json:"component"`
+	Detail      string               `json:"detail"`
+}
+
+// Audit compares staging with production, and every cluster with its peers
+// in the same environment. Only clusters known by name are compared; see
+// the package documentation.
+func (m *Matrix) Audit() []Finding {
+	var findings []Finding
+	if m.envs[detector.Staging] != nil && m.envs[detector.Production] != nil {
+		findings = append(findings, m.envParity(detector.Staging, detector.Production, MissingInProduction)...)
+		findings = append(findings, m.envParity(detector.Production, detector.Staging, MissingInStaging)...)
+	}
+	for _, env := range m.Environments() {
+		findings = append(findings, m.clusterParity(env)...)
+	}
+	return findings
+}
+
+// envParity reports the components deployed on from but not on to.
+func (m *Matrix) envParity(from, to detector.Environment, kind FindingKind) []Finding {
+	var findings []Finding
+	for _, component := range m.Components(from) {
+		if !m.Deployed(to, component) {
+			findings = append(findings, Finding{
+				Kind:        kind,
+				Environment: to,
+				Component:   component,
+				Detail:      fmt.Sprintf("deployed on %s but not on %s", from, to),
+			})
+		}
+	}
+	return findings
+}
+
+// clusterParity reports the clusters of env missing a component most of
+// their peers have, and those that are alone in having one.
+func (m *Matrix) clusterParity(env detector.Environment) []Finding {
+	clusters := m.Clusters(env)
+	if len(clusters) < minPeers {
+		return nil
+	}
+	var findings []Finding
+	for _, component := range m.Components(env) {
+		var on, off []string
+		for _, c := range clusters {
+			if m.Cell(env, c, component).Placement == Absent {
+				off = append(off, c)
+			} else {
+				on = append(on, c)
+			}
+		}
+		switch {
+		case len(on) == 1:
+			findings = append(findings, Finding{
+				Kind:        OnlyOnCluster,
+				Environment: env,
+				Cluster:     on[0],
+				Component:   component,
+				Detail:      fmt.Sprintf("not deployed on the other %d %s clusters", len(off), env),
+			})
+		case len(off) > 0 && len(on)*2 > len(clusters):
+			for _, c := range off {
+				findings = append(findings, Finding{
+					Kind:        MissingOnCluster,
+					Environment: env,
+					Cluster:     c,
+					Component:   component,
+					Detail:      fmt.Sprintf("deployed on %d of %d %s clusters", len(on), len(clusters), env),
+				})
+			}
+		}
+	}
+	return findings
+}
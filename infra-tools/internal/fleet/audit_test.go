@@ -0,0 +1,70 @@
+package fleet
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+// testMatrix builds a matrix from env → component → clusters, with every
+// cell cluster-specific.
+func testMatrix(layout map[detector.Environment]map[string][]string) *Matrix {
+	m := &Matrix{envs: make(map[detector.Environment]*envMatrix)}
+	for env, components := range layout {
+		em := &envMatrix{clusters: make(map[string]bool), cells: make(map[string]map[string]Cell)}
+		for component, clusters := range components {
+			em.cells[component] = make(map[string]Cell)
+			for _, c := range clusters {
+				em.clusters[c] = true
+				em.cells[component][c] = Cell{Placement: Specific}
+			}
+		}
+		m.envs[env] = em
+	}
+	return m
+}
+
+func TestAudit_EnvironmentParity(t *testing.T) {
+	g := NewWithT(t)
+
+	m := testMatrix(map[detector.Environment]map[string][]string{
+		detector.Staging:    {"smee": {"s1"}, "new-thing": {"s1"}},
+		detector.Production: {"smee": {"p1"}, "hotfix": {"p1"}},
+		// Development is not compared.
+		detector.Development: {"dev-only": {"in-cluster"}},
+	})
+
+	g.Expect(m.Audit()).To(Equal([]Finding{
+		{Kind: MissingInProduction, Environment: detector.Production, Component: "new-thing", Detail: "deployed on staging but not on production"},
+		{Kind: MissingInStaging, Environment: detector.Staging, Component: "hotfix", Detail: "deployed on production but not on staging"},
+	}))
+}
+
+func TestAudit_ClusterDeviation(t *testing.T) {
+	g := NewWithT(t)
+
+	m := testMatrix(map[detector.Environment]map[string][]string{
+		detector.Production: {
+			"everywhere": {"p1", "p2", "p3", "p4"},
+			"mostly":     {"p1", "p2", "p3"},
+			"half":       {"p1", "p2"},
+			"one":        {"p4"},
+		},
+	})
+
+	g.Expect(m.Audit()).To(Equal([]Finding{
+		{Kind: MissingOnCluster, Environment: detector.Production, Cluster: "p4", Component: "mostly", Detail: "deployed on 3 of 4 production clusters"},
+		{Kind: OnlyOnCluster, Environment: detector.Production, Cluster: "p4", Component: "one", Detail: "not deployed on the other 3 production clusters"},
+	}))
+}
+
+func TestAudit_TooFewPeers(t *testing.T) {
+	g := NewWithT(t)
+
+	m := testMatrix(map[detector.Environment]map[string][]string{
+		detector.Staging: {"a": {"s1", "s2"}, "b": {"s1"}},
+	})
+	g.Expect(m.Audit()).To(BeEmpty())
+}
@@ -0,0 +1,235 @@
+// Package fleet builds the environment × cluster × component deployment
+// matrix from the Applications that the ApplicationSet overlays generate,
+// and audits it for ring-deployment parity.
+//
+// Clusters only exist as secrets on the Argo CD instances, so the matrix
+// learns them from the ApplicationSets: a cluster belongs to an environment
+// when one of that environment's ApplicationSets names it and the
+// cluster-specific path it uses exists in the repository. ApplicationSets
+// name clusters of every environment in shared bases, and only those whose
+// path exists are real deployments.
+package fleet
+
+import (
+	"cmp"
+	"path"
+	"slices"
+	"sort"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+// AllClusters is the column for the clusters an ApplicationSet's clusters
+// generator selects without the matrix knowing them by name.
+const AllClusters = "*"
+
+// Placement describes how a component is deployed on a cluster.
+type Placement string
+
+const (
+	// Absent means the component is not deployed on the cluster.
+	Absent Placement = ""
+	// Shared means the cluster gets the path the ApplicationSet uses for
+	// every cluster it doesn't name.
+	Shared Placement = "shared"
+	// Specific means the ApplicationSet names the cluster and gives it its
+	// own path.
+	Specific Placement = "cluster"
+)
+
+// Cell is one component on one cluster.
+type Cell struct {
+	Placement Placement `json:"placement"`
+	Path      string    `json:"path,omitempty"`
+}
+
+// promotionOrder lists environments in the order changes are promoted.
+var promotionOrder = []detector.Environment{detector.Development, detector.Staging, detector.Production}
+
+// Matrix records, per environment, where each component is deployed. A
+// component is identified by the name of the ApplicationSet deploying it,
+// which is the same in every environment's overlays.
+type Matrix struct {
+	envs map[detector.Environment]*envMatrix
+}
+
+type envMatrix struct {
+	clusters map[string]bool
+	// cells maps component → cluster → cell; absent cells are omitted.
+	cells map[string]map[string]Cell
+}
+
+// Environments returns the environments in the matrix in promotion order.
+func (m *Matrix) Environments() []detector.Environment {
+	var envs []detector.Environment
+	for _, env := range promotionOrder {
+		if m.envs[env] != nil {
+			envs = append(envs, env)
+		}
+	}
+	return envs
+}
+
+// Clusters returns the clusters known to belong to env, sorted. It never
+// includes AllClusters.
+func (m *Matrix) Clusters(env detector.Environment) []string {
+	em := m.envs[env]
+	if em == nil {
+		return nil
+	}
+	clusters := make([]string, 0, len(em.clusters))
+	for c := range em.clusters {
+		clusters = append(clusters, c)
+	}
+	sort.Strings(clusters)
+	return clusters
+}
+
+// Components returns the components deployed anywhere in env, sorted.
+func (m *Matrix) Components(env detector.Environment) []string {
+	em := m.envs[env]
+	if em == nil {
+		return nil
+	}
+	components := make([]string, 0, len(em.cells))
+	for c := range em.cells {
+		components = append(components, c)
+	}
+	sort.Strings(components)
+	return components
+}
+
+// Cell returns how component is deployed on cluster in env.
+func (m *Matrix) Cell(env detector.Environment, cluster, component string) Cell {
+	if em := m.envs[env]; em != nil {
+		return em.cells[component][cluster]
+	}
+	return Cell{}
+}
+
+// Deployed reports whether component is deployed anywhere in env.
+func (m *Matrix) Deployed(env detector.Environment, component string) bool {
+	em := m.envs[env]
+	return em != nil && len(em.cells[component]) > 0
+}
+
+// appSetApps is what one environment's overlays generate for one component.
+type appSetApps struct {
+	// named maps every cluster the ApplicationSet names to its path,
+	// whether or not the path exists.
+	named map[string]string
+	// shared holds the paths of the Applications standing for every
+	// cluster, with the cluster's name still to be filled in.
+	shared []string
+}
+
+// Build computes the matrix from the Applications of every overlay, checking
+// paths against repo.
+func Build(overlays []detector.OverlayApplications, repo detector.RepoQuerier) *Matrix {
+	b := &builder{repo: repo, renderable: make(map[string]bool)}
+	byEnv := make(map[detector.Environment]map[string]*appSetApps)
+	for _, o := range overlays {
+		if byEnv[o.Environment] == nil {
+			byEnv[o.Environment] = make(map[string]*appSetApps)
+		}
+		for _, app := range o.Applications {
+			apps := byEnv[o.Environment][app.AppSet]
+			if apps == nil {
+				apps = &appSetApps{named: make(map[string]string)}
+				byEnv[o.Environment][app.AppSet] = apps
+			}
+			if app.AllClusters {
+				apps.shared = append(apps.shared, app.Source.Path)
+				continue
+			}
+			cluster := app.Cluster
+			if cluster == "" {
+				// Not from a clusters generator: the template names the
+				// destination itself.
+				cluster = cmp.Or(app.Destination.Name, app.Destination.Server)
+			}
+			if cluster != "" && appset.Resolved(cluster) {
+				apps.named[cluster] = app.Source.Path
+			}
+		}
+	}
+
+	m := &Matrix{envs: make(map[detector.Environment]*envMatrix)}
+	for env, components := range byEnv {
+		m.envs[env] = b.buildEnv(components)
+	}
+	return m
+}
+
+type builder struct {
+	repo       detector.RepoQuerier
+	renderable map[string]bool
+}
+
+func (b *builder) buildEnv(components map[string]*appSetApps) *envMatrix {
+	em := &envMatrix{clusters: make(map[string]bool), cells: make(map[string]map[string]Cell)}
+	set := func(component, cluster string, cell Cell) {
+		if em.cells[component] == nil {
+			em.cells[component] = make(map[string]Cell)
+		}
+		em.cells[component][cluster] = cell
+	}
+
+	// Cluster-specific deployments, which also tell which clusters exist.
+	for component, apps := range components {
+		for cluster, p := range apps.named {
+			if p != "" && b.isRenderable(p) {
+				em.clusters[cluster] = true
+				set(component, cluster, Cell{Placement: Specific, Path: path.Clean(p)})
+			}
+		}
+	}
+
+	// Shared deployments, on every known cluster the ApplicationSet doesn't
+	// name and on the ones the matrix doesn't know.
+	for component, apps := range components {
+		for _, p := range apps.shared {
+			if p == "" {
+				// Multi-source and chart Applications have no path in the
+				// repository; they deploy wherever the generator selects.
+				set(component, AllClusters, Cell{Placement: Shared})
+			} else if appset.Resolved(p) && b.isRenderable(p) {
+				set(component, AllClusters, Cell{Placement: Shared, Path: path.Clean(p)})
+			}
+			for cluster := range em.clusters {
+				if _, named := apps.named[cluster]; named {
+					continue
+				}
+				if p == "" {
+					set(component, cluster, Cell{Placement: Shared})
+				} else if resolved, ok := appset.ResolveCluster(p, cluster); ok && b.isRenderable(resolved) {
+					set(component, cluster, Cell{Placement: Shared, Path: path.Clean(resolved)})
+				}
+			}
+		}
+	}
+	return em
+}
+
+// isRenderable reports whether Argo CD can deploy from rel: a directory with
+// a kustomization or plain manifests.
+func (b *builder) isRenderable(rel string) bool {
+	rel = path.Clean(rel)
+	if ok, seen := b.renderable[rel]; seen {
+		return ok
+	}
+	ok := false
+	if b.repo.DirExists(rel) {
+		names, _ := b.repo.ReadDir(rel)
+		ok = slices.ContainsFunc(names, func(name string) bool {
+			switch path.Ext(name) {
+			case ".yaml", ".yml", ".json":
+				return true
+			}
+			return name == "Kustomization"
+		})
+	}
+	b.renderable[rel] = ok
+	return ok
+}
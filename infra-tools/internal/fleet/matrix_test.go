@@ -0,0 +1,145 @@
+package fleet
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+func testRepo(g *WithT, dirs ...string) *detector.RepoRef {
+	files := make(map[string][]byte)
+	for _, d := range dirs {
+		files[d+"/kustomization.yaml"] = []byte("resources: []\n")
+	}
+	ref, err := detector.NewMemRepoRef(files)
+	g.Expect(err).NotTo(HaveOccurred())
+	return ref
+}
+
+// clustersApps returns the Applications a clusters-generator ApplicationSet
+// with a per-cluster list element for each of named generates.
+func clustersApps(appSet, env string, named ...string) []appset.Application {
+	apps := []appset.Application{{
+		AppSet:      appSet,
+		Name:        appSet + "-{{nameNormalized}}",
+		AllClusters: true,
+		Source:      appset.ApplicationSource{Path: "components/" + appSet + "/" + env + "/{{nameNormalized}}"},
+	}}
+	for _, c := range named {
+		apps = append(apps, appset.Application{
+			AppSet:  appSet,
+			Name:    appSet + "-" + c,
+			Cluster: c,
+			Source:  appset.ApplicationSource{Path: "components/" + appSet + "/" + env + "/" + c},
+		})
+	}
+	return apps
+}
+
+func TestBuild_ClustersFromExistingPaths(t *testing.T) {
+	g := NewWithT(t)
+
+	repo := testRepo(g,
+		"components/smee/production/p1",
+		"components/smee/production/p2",
+		"components/smee/staging/s1",
+	)
+	// The shared base names clusters of both environments.
+	overlays := []detector.OverlayApplications{
+		{Overlay: "production", Environment: detector.Production, Applications: clustersApps("smee", "production", "p1", "p2", "s1")},
+		{Overlay: "staging", Environment: detector.Staging, Applications: clustersApps("smee", "staging", "p1", "p2", "s1")},
+	}
+
+	m := Build(overlays, repo)
+	g.Expect(m.Environments()).To(Equal([]detector.Environment{detector.Staging, detector.Production}))
+	g.Expect(m.Clusters(detector.Production)).To(Equal([]string{"p1", "p2"}))
+	g.Expect(m.Clusters(detector.Staging)).To(Equal([]string{"s1"}))
+	g.Expect(m.Cell(detector.Production, "p1", "smee")).To(Equal(Cell{Placement: Specific, Path: "components/smee/production/p1"}))
+	g.Expect(m.Cell(detector.Production, "s1", "smee")).To(Equal(Cell{}))
+	g.Expect(m.Cell(detector.Production, AllClusters, "smee")).To(Equal(Cell{}))
+}
+
+func TestBuild_SharedPaths(t *testing.T) {
+	g := NewWithT(t)
+
+	repo := testRepo(g,
+		"components/smee/production/p1",
+		"components/monitoring/production/base",
+		"components/registry/production/p2",
+	)
+	overlays := []detector.OverlayApplications{{
+		Overlay:     "production",
+		Environment: detector.Production,
+		Applications: append(append(clustersApps("smee", "production", "p1", "p2"),
+			// A path without the cluster's name goes to every cluster.
+			appset.Application{AppSet: "monitoring", AllClusters: true, Source: appset.ApplicationSource{Path: "components/monitoring/production/base"}}),
+			// A path with the cluster's name goes where it exists.
+			appset.Application{AppSet: "registry", AllClusters: true, Source: appset.ApplicationSource{Path: "components/registry/production/{{name}}"}},
+		),
+	}}
+
+	m := Build(overlays, repo)
+	g.Expect(m.Clusters(detector.Production)).To(Equal([]string{"p1"}))
+	g.Expect(m.Components(detector.Production)).To(Equal([]string{"monitoring", "smee"}))
+	g.Expect(m.Cell(detector.Production, AllClusters, "monitoring")).To(Equal(Cell{Placement: Shared, Path: "components/monitoring/production/base"}))
+	g.Expect(m.Cell(detector.Production, "p1", "monitoring").Placement).To(Equal(Shared))
+	g.Expect(m.Deployed(detector.Production, "registry")).To(BeFalse())
+}
+
+func TestBuild_NamedDestination(t *testing.T) {
+	g := NewWithT(t)
+
+	repo := testRepo(g, "components/all-in-one")
+	overlays := []detector.OverlayApplications{{
+		Overlay:     "development",
+		Environment: detector.Development,
+		Applications: []appset.Application{{
+			AppSet:      "all-in-one",
+			Source:      appset.ApplicationSource{Path: "components/all-in-one"},
+			Destination: appset.ApplicationDestination{Name: "in-cluster"},
+		}},
+	}}
+
+	m := Build(overlays, repo)
+	g.Expect(m.Clusters(detector.Development)).To(Equal([]string{"in-cluster"}))
+	g.Expect(m.Cell(detector.Development, "in-cluster", "all-in-one").Placement).To(Equal(Specific))
+}
+
+func TestBuild_RequiresManifests(t *testing.T) {
+	g := NewWithT(t)
+
+	ref, err := detector.NewMemRepoRef(map[string][]byte{
+		"components/smee/production/p1/README.md": []byte("docs\n"),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	overlays := []detector.OverlayApplications{
+		{Overlay: "production", Environment: detector.Production, Applications: clustersApps("smee", "production", "p1")},
+	}
+
+	m := Build(overlays, ref)
+	g.Expect(m.Clusters(detector.Production)).To(BeEmpty())
+	g.Expect(m.Deployed(detector.Production, "smee")).To(BeFalse())
+}
+
+func TestBuild_NoSourcePath(t *testing.T) {
+	g := NewWithT(t)
+
+	repo := testRepo(g, "components/smee/production/p1")
+	overlays := []detector.OverlayApplications{{
+		Overlay:     "production",
+		Environment: detector.Production,
+		Applications: append(clustersApps("smee", "production", "p1"),
+			// A chart from another repository.
+			appset.Application{AppSet: "otel", AllClusters: true},
+			appset.Application{AppSet: "otel", Cluster: "p2"},
+		),
+	}}
+
+	m := Build(overlays, repo)
+	g.Expect(m.Clusters(detector.Production)).To(Equal([]string{"p1"}))
+	g.Expect(m.Cell(detector.Production, AllClusters, "otel")).To(Equal(Cell{Placement: Shared}))
+	g.Expect(m.Cell(detector.Production, "p1", "otel")).To(Equal(Cell{Placement: Shared}))
+}
@@ -2,12 +2,18 @@
 package git
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // TopLevel returns the root directory of the git repository that contains
@@ -39,34 +45,126 @@ func ResolveRef(ctx context.Context, repoRoot, ref string) (string, error) {
 // branch name so the diff only contains the current branch's own changes,
 // regardless of whether the branch has been rebased.
 func ChangedFiles(ctx context.Context, repoRoot, baseRef string) ([]string, error) {
-	cmd := exec.CommandContext(ctx, "git", "diff", "--name-only", baseRef, "HEAD")
+	return ChangedFilesBetween(ctx, repoRoot, baseRef, "HEAD")
+}
+
+// ChangedFilesBetween returns the list of files changed between baseRef and
+// headRef, comparing the two commits' trees. Neither ref needs to be checked
+// out.
+func ChangedFilesBetween(ctx context.Context, repoRoot, baseRef, headRef string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "diff", "--name-only", baseRef, headRef)
+	cmd.Dir = repoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s %s: %w", baseRef, headRef, err)
+	}
+	return parseFileList(out), nil
+}
+
+// UncommittedFiles returns the files that differ between HEAD and the working
+// tree: staged and unstaged changes to tracked files (including deletions and
+// both sides of renames) plus untracked files that are not ignored.
+func UncommittedFiles(ctx context.Context, repoRoot string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "diff", "--name-only", "--no-renames", "HEAD")
 	cmd.Dir = repoRoot
 	out, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("git diff %s HEAD: %w", baseRef, err)
+		return nil, fmt.Errorf("git diff HEAD: %w", err)
 	}
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	files := parseFileList(out)
+
+	cmd = exec.CommandContext(ctx, "git", "ls-files", "--others", "--exclude-standard")
+	cmd.Dir = repoRoot
+	out, err = cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files --others: %w", err)
+	}
+	files = append(files, parseFileList(out)...)
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+// parseFileList splits newline-separated git output into non-empty paths.
+func parseFileList(out []byte) []string {
 	var files []string
-	for _, line := range lines {
+	for line := range strings.SplitSeq(string(out), "\n") {
 		line = strings.TrimSpace(line)
 		if line != "" {
 			files = append(files, line)
 		}
 	}
-	return files, nil
+	return files
 }
 
 // MergeBase returns the merge-base commit between HEAD and the given ref.
 func MergeBase(ctx context.Context, repoRoot, ref string) (string, error) {
-	cmd := exec.CommandContext(ctx, "git", "merge-base", "HEAD", ref)
+	return MergeBaseOf(ctx, repoRoot, "HEAD", ref)
+}
+
+// MergeBaseOf returns the merge-base commit between two refs.
+func MergeBaseOf(ctx context.Context, repoRoot, a, b string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "merge-base", a, b)
 	cmd.Dir = repoRoot
 	out, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("git merge-base HEAD %s: %w", ref, err)
+		return "", fmt.Errorf("git merge-base %s %s: %w", a, b, err)
 	}
 	return strings.TrimSpace(string(out)), nil
 }
 
+// History returns the commits on the first-parent history of ref that
+// change anything under path, newest first, down to the first one committed
+// before since. A zero since returns the whole history. The log is read as
+// git writes it, and git is stopped once that commit has been read rather
+// than left to walk the rest of the history.
+func History(ctx context.Context, repoRoot, ref, path string, since time.Time) ([]Commit, error) {
+	cmd := exec.CommandContext(ctx, "git", "log", "--first-parent", "--format=%H %ct", ref, "--", path)
+	cmd.Dir = repoRoot
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("git log %s -- %s: %w", ref, path, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git log %s -- %s: %w", ref, path, err)
+	}
+	stop := func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}
+
+	var commits []Commit
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sha, ts, ok := strings.Cut(line, " ")
+		if !ok {
+			stop()
+			return nil, fmt.Errorf("git log %s -- %s: unexpected line %q", ref, path, line)
+		}
+		secs, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("git log %s -- %s: %w", ref, path, err)
+		}
+		commits = append(commits, Commit{SHA: sha, Time: time.Unix(secs, 0)})
+		if commits[len(commits)-1].Time.Before(since) {
+			stop()
+			return commits, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		stop()
+		return nil, fmt.Errorf("git log %s -- %s: %w", ref, path, err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("git log %s -- %s: %w", ref, path, err)
+	}
+	return commits, nil
+}
+
 // CreateWorktree creates a temporary git worktree checked out at the given ref.
 // It returns the worktree path and a cleanup function that removes the worktree.
 // The cleanup function uses a background context so it always runs even if the
@@ -98,3 +196,80 @@ func CreateWorktree(ctx context.Context, repoRoot, ref string) (string, func(),
 
 	return tmpDir, cleanup, nil
 }
+
+// CommitFiles commits files, keyed by repo-relative path, on top of base as
+// the new branch, and returns the commit's SHA. The commit is made in a
+// temporary worktree, so the working copy is left alone; the branch is
+// deleted again when committing fails.
+func CommitFiles(ctx context.Context, repoRoot, base, branch, message string, files map[string][]byte) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "infra-tools-worktree-*")
+	if err != nil {
+		return "", fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	run := func(dir string, args ...string) ([]byte, error) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			var stderr string
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				stderr = strings.TrimSpace(string(exitErr.Stderr))
+			}
+			return nil, fmt.Errorf("git %s: %w: %s", args[0], err, stderr)
+		}
+		return out, nil
+	}
+
+	if _, err := run(repoRoot, "worktree", "add", "-b", branch, tmpDir, base); err != nil {
+		return "", fmt.Errorf("creating branch %s at %s: %w", branch, base, err)
+	}
+	committed := false
+	defer func() {
+		// Cleanup should not be cancelled — use a fresh background context.
+		cmd := exec.Command("git", "worktree", "remove", "--force", tmpDir)
+		cmd.Dir = repoRoot
+		if err := cmd.Run(); err != nil {
+			slog.Warn("failed to remove worktree", "path", tmpDir, "err", err)
+		}
+		if !committed {
+			cmd = exec.Command("git", "branch", "-D", branch)
+			cmd.Dir = repoRoot
+			if err := cmd.Run(); err != nil {
+				slog.Warn("failed to delete branch", "branch", branch, "err", err)
+			}
+		}
+	}()
+
+	paths := make([]string, 0, len(files))
+	for p, content := range files {
+		abs := filepath.Join(tmpDir, p)
+		mode := os.FileMode(0o644)
+		if fi, err := os.Stat(abs); err == nil {
+			mode = fi.Mode()
+		}
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(abs, content, mode); err != nil {
+			return "", err
+		}
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+
+	if _, err := run(tmpDir, append([]string{"add", "--"}, paths...)...); err != nil {
+		return "", err
+	}
+	if _, err := run(tmpDir, "commit", "--quiet", "--message", message); err != nil {
+		return "", err
+	}
+	out, err := run(tmpDir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	committed = true
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,93 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// gitRepo is an on-disk repository driven through the git binary.
+type gitRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newGitRepo(t *testing.T) *gitRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	r := &gitRepo{t: t, dir: t.TempDir()}
+	r.git(time.Time{}, "init", "-q")
+	return r
+}
+
+func (r *gitRepo) git(when time.Time, args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	if !when.IsZero() {
+		date := fmt.Sprintf("@%d +0000", when.Unix())
+		cmd.Env = append(cmd.Env, "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (r *gitRepo) commitAt(path, content string, when time.Time) string {
+	r.t.Helper()
+	full := filepath.Join(r.dir, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		r.t.Fatal(err)
+	}
+	r.git(when, "add", "-A")
+	r.git(when, "commit", "-q", "-m", path)
+	return r.git(time.Time{}, "rev-parse", "HEAD")
+}
+
+func TestHistory(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	r := newGitRepo(t)
+	first := r.commitAt("components/foo/staging/kustomization.yaml", "resources: []\n", time.Unix(100, 0))
+	r.commitAt("components/foo/base/kustomization.yaml", "resources: []\n", time.Unix(200, 0))
+	third := r.commitAt("components/foo/staging/patch.yaml", "a: 1\n", time.Unix(300, 0))
+	fourth := r.commitAt("components/foo/staging/patch.yaml", "a: 2\n", time.Unix(400, 0))
+
+	commits, err := History(ctx, r.dir, "HEAD", "components/foo/staging", time.Time{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(commits).To(Equal([]Commit{
+		{SHA: fourth, Time: time.Unix(400, 0)},
+		{SHA: third, Time: time.Unix(300, 0)},
+		{SHA: first, Time: time.Unix(100, 0)},
+	}))
+
+	// Reading stops at the first change before since.
+	commits, err = History(ctx, r.dir, "HEAD", "components/foo/staging", time.Unix(350, 0))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(commits).To(Equal([]Commit{
+		{SHA: fourth, Time: time.Unix(400, 0)},
+		{SHA: third, Time: time.Unix(300, 0)},
+	}))
+
+	_, err = History(ctx, r.dir, "no-such-branch", "components/foo/staging", time.Time{})
+	g.Expect(err).To(HaveOccurred())
+}
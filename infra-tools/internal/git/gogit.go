@@ -0,0 +1,230 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// goRepository implements Repository in-process with go-git. Checkouts are
+// served straight from the object database, so no worktree is written.
+//
+// go-git objects cache decoded state without synchronization, so every
+// access to the repository, including reads through the trees returned by
+// Checkout, is serialized on mu.
+type goRepository struct {
+	repo *gogit.Repository
+	root string
+	mu   sync.Mutex
+}
+
+// OpenGoRepository opens the repository containing dir with the in-process
+// go-git backend.
+func OpenGoRepository(dir string) (Repository, error) {
+	repo, err := gogit.PlainOpenWithOptions(dir, &gogit.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("opening git repository at %s: %w", dir, err)
+	}
+	root := dir
+	if wt, err := repo.Worktree(); err == nil {
+		root = wt.Filesystem.Root()
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolving repo root: %w", err)
+	}
+	return NewGoRepository(repo, abs), nil
+}
+
+// NewGoRepository wraps an open go-git repository, e.g. one backed by
+// in-memory storage in tests. root is reported by Root.
+func NewGoRepository(repo *gogit.Repository, root string) Repository {
+	return &goRepository{repo: repo, root: root}
+}
+
+func (r *goRepository) Root() string {
+	return r.root
+}
+
+func (r *goRepository) ResolveRef(_ context.Context, ref string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, err := r.resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	return h.String()[:7], nil
+}
+
+func (r *goRepository) MergeBase(_ context.Context, a, b string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ca, err := r.commit(a)
+	if err != nil {
+		return "", err
+	}
+	cb, err := r.commit(b)
+	if err != nil {
+		return "", err
+	}
+	bases, err := ca.MergeBase(cb)
+	if err != nil {
+		return "", fmt.Errorf("merge-base %s %s: %w", a, b, err)
+	}
+	if len(bases) == 0 {
+		return "", fmt.Errorf("merge-base %s %s: no common ancestor", a, b)
+	}
+	return bases[0].Hash.String(), nil
+}
+
+// ChangedFiles lists both sides of renamed files, since rename detection is
+// not applied.
+func (r *goRepository) ChangedFiles(ctx context.Context, baseRef, headRef string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	baseTree, err := r.tree(baseRef)
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := r.tree(headRef)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTreeWithOptions(ctx, baseTree, headTree, &object.DiffTreeOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("diffing %s and %s: %w", baseRef, headRef, err)
+	}
+	var files []string
+	for _, c := range changes {
+		if c.From.Name != "" {
+			files = append(files, c.From.Name)
+		}
+		if c.To.Name != "" {
+			files = append(files, c.To.Name)
+		}
+	}
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+func (r *goRepository) UncommittedFiles(_ context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("opening worktree: %w", err)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return nil, fmt.Errorf("computing worktree status: %w", err)
+	}
+	var files []string
+	for path, s := range status {
+		if s.Staging != gogit.Unmodified || s.Worktree != gogit.Unmodified {
+			files = append(files, path)
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// Checkout returns a read-only view of the commit's tree. It holds no
+// resources, so the cleanup function does nothing.
+func (r *goRepository) Checkout(_ context.Context, ref string) (*Tree, func(), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, err := r.tree(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Tree{FS: newTreeFS(r, t), Root: treeRoot}, func() {}, nil
+}
+
+// History compares the tree entry of path in each first-parent commit
+// with its parent's, like git log --first-parent without history
+// simplification. The walk stops at the first change before since, so the
+// lock is not held for the whole history.
+func (r *goRepository) History(_ context.Context, ref, path string, since time.Time) ([]Commit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, err := r.commit(ref)
+	if err != nil {
+		return nil, err
+	}
+	var commits []Commit
+	for c != nil {
+		var parent *object.Commit
+		if c.NumParents() > 0 {
+			if parent, err = c.Parent(0); err != nil {
+				return nil, fmt.Errorf("reading parent of %s: %w", c.Hash, err)
+			}
+		}
+		if entryHash(c, path) != entryHash(parent, path) {
+			commits = append(commits, Commit{SHA: c.Hash.String(), Time: c.Committer.When})
+			if c.Committer.When.Before(since) {
+				break
+			}
+		}
+		c = parent
+	}
+	return commits, nil
+}
+
+// entryHash returns the hash of the blob or tree at path in c, or the zero
+// hash when c is nil or has nothing at path.
+func entryHash(c *object.Commit, path string) plumbing.Hash {
+	if c == nil {
+		return plumbing.ZeroHash
+	}
+	t, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	e, err := t.FindEntry(path)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	return e.Hash
+}
+
+func (r *goRepository) resolve(ref string) (plumbing.Hash, error) {
+	h, err := r.repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("resolving %s: %w", ref, err)
+	}
+	return *h, nil
+}
+
+func (r *goRepository) commit(ref string) (*object.Commit, error) {
+	h, err := r.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.repo.CommitObject(h)
+	if err != nil {
+		return nil, fmt.Errorf("reading commit %s: %w", ref, err)
+	}
+	return c, nil
+}
+
+func (r *goRepository) tree(ref string) (*object.Tree, error) {
+	c, err := r.commit(ref)
+	if err != nil {
+		return nil, err
+	}
+	t, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("reading tree of %s: %w", ref, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,242 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/kustomize"
+)
+
+// memRepo is an in-memory git repository for tests.
+type memRepo struct {
+	t    *testing.T
+	repo *gogit.Repository
+	wt   *gogit.Worktree
+	fs   billy.Filesystem
+}
+
+func newMemRepo(t *testing.T) *memRepo {
+	t.Helper()
+	fs := memfs.New()
+	repo, err := gogit.Init(memory.NewStorage(), fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &memRepo{t: t, repo: repo, wt: wt, fs: fs}
+}
+
+func (m *memRepo) write(path, content string) {
+	m.t.Helper()
+	if err := billyWrite(m.fs, path, content); err != nil {
+		m.t.Fatal(err)
+	}
+}
+
+func billyWrite(fs billy.Filesystem, path, content string) error {
+	f, err := fs.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// commit stages every change in the worktree and commits it.
+func (m *memRepo) commit(msg string) string {
+	m.t.Helper()
+	return m.commitAt(msg, time.Unix(0, 0))
+}
+
+// commitAt is commit with the given author and committer date.
+func (m *memRepo) commitAt(msg string, when time.Time) string {
+	m.t.Helper()
+	if err := m.wt.AddWithOptions(&gogit.AddOptions{All: true}); err != nil {
+		m.t.Fatal(err)
+	}
+	h, err := m.wt.Commit(msg, &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: when},
+	})
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return h.String()
+}
+
+const (
+	baseKustomization    = "resources:\n  - configmap.yaml\n"
+	baseConfigMap        = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n  key: base\n"
+	overlayKustomization = "resources:\n  - ../base\nnamespace: staging\n"
+)
+
+func TestGoRepository_RefsAndChangedFiles(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	m := newMemRepo(t)
+	m.write("components/foo/base/kustomization.yaml", baseKustomization)
+	m.write("components/foo/base/configmap.yaml", baseConfigMap)
+	first := m.commit("first")
+	m.write("components/foo/base/configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n  key: head\n")
+	m.write("components/foo/staging/kustomization.yaml", overlayKustomization)
+	second := m.commit("second")
+
+	repo := NewGoRepository(m.repo, "/repo")
+	g.Expect(repo.Root()).To(Equal("/repo"))
+
+	short, err := repo.ResolveRef(ctx, "HEAD")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(short).To(Equal(second[:7]))
+
+	base, err := repo.MergeBase(ctx, "HEAD", first)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(base).To(Equal(first))
+
+	files, err := repo.ChangedFiles(ctx, first, "HEAD")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(files).To(Equal([]string{
+		"components/foo/base/configmap.yaml",
+		"components/foo/staging/kustomization.yaml",
+	}))
+
+	_, err = repo.ResolveRef(ctx, "no-such-branch")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestGoRepository_UncommittedFiles(t *testing.T) {
+	g := NewWithT(t)
+
+	m := newMemRepo(t)
+	m.write("a.yaml", "a: 1\n")
+	m.write("b.yaml", "b: 1\n")
+	m.commit("first")
+	m.write("a.yaml", "a: 2\n")
+	m.write("new.yaml", "new: 1\n")
+
+	files, err := NewGoRepository(m.repo, "/repo").UncommittedFiles(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(files).To(Equal([]string{"a.yaml", "new.yaml"}))
+}
+
+func TestGoRepository_History(t *testing.T) {
+	g := NewWithT(t)
+
+	m := newMemRepo(t)
+	m.write("components/foo/staging/kustomization.yaml", overlayKustomization)
+	m.write("components/foo/base/kustomization.yaml", baseKustomization)
+	first := m.commitAt("first", time.Unix(100, 0))
+	m.write("components/foo/base/configmap.yaml", baseConfigMap)
+	m.commitAt("second", time.Unix(200, 0))
+	m.write("components/foo/staging/patch.yaml", "a: 1\n")
+	third := m.commitAt("third", time.Unix(300, 0))
+
+	repo := NewGoRepository(m.repo, "/repo")
+	commits, err := repo.History(context.Background(), "HEAD", "components/foo/staging", time.Time{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(commits).To(HaveLen(2))
+	g.Expect(commits[0].SHA).To(Equal(third))
+	g.Expect(commits[0].Time.Unix()).To(Equal(int64(300)))
+	g.Expect(commits[1].SHA).To(Equal(first))
+	g.Expect(commits[1].Time.Unix()).To(Equal(int64(100)))
+
+	// The walk stops at the first change before since.
+	m.write("components/foo/staging/patch.yaml", "a: 2\n")
+	fourth := m.commitAt("fourth", time.Unix(400, 0))
+	commits, err = repo.History(context.Background(), "HEAD", "components/foo/staging", time.Unix(350, 0))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(commits).To(HaveLen(2))
+	g.Expect(commits[0].SHA).To(Equal(fourth))
+	g.Expect(commits[1].SHA).To(Equal(third))
+}
+
+func TestTreeFS_ReadsCommitTree(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	m := newMemRepo(t)
+	m.write("components/foo/base/kustomization.yaml", baseKustomization)
+	m.write("components/foo/base/configmap.yaml", baseConfigMap)
+	if err := m.fs.Symlink("base", "components/foo/link"); err != nil {
+		t.Fatal(err)
+	}
+	m.commit("first")
+	// Working copy changes must not leak into the checkout.
+	m.write("components/foo/base/configmap.yaml", "changed")
+
+	tree, cleanup, err := NewGoRepository(m.repo, "/repo").Checkout(ctx, "HEAD")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer cleanup()
+	fSys, root := tree.FS, tree.Root
+
+	data, err := fSys.ReadFile(filepath.Join(root, "components/foo/base/configmap.yaml"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(baseConfigMap))
+
+	g.Expect(fSys.IsDir(filepath.Join(root, "components/foo"))).To(BeTrue())
+	g.Expect(fSys.Exists(filepath.Join(root, "components/bar"))).To(BeFalse())
+
+	names, err := fSys.ReadDir(filepath.Join(root, "components/foo"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(names).To(Equal([]string{"base", "link"}))
+
+	// Symlinks are followed, including in intermediate path components.
+	g.Expect(fSys.IsDir(filepath.Join(root, "components/foo/link"))).To(BeTrue())
+	data, err = fSys.ReadFile(filepath.Join(root, "components/foo/link/configmap.yaml"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(baseConfigMap))
+
+	matches, err := fSys.Glob(filepath.Join(root, "components/*/base/*.yaml"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(matches).To(Equal([]string{
+		filepath.Join(root, "components/foo/base/configmap.yaml"),
+		filepath.Join(root, "components/foo/base/kustomization.yaml"),
+	}))
+
+	var walked []string
+	err = fSys.Walk(filepath.Join(root, "components/foo/base"), func(path string, info os.FileInfo, err error) error {
+		g.Expect(err).NotTo(HaveOccurred())
+		if !info.IsDir() {
+			walked = append(walked, filepath.Base(path))
+			g.Expect(info.Size()).To(BeNumerically(">", 0))
+		}
+		return nil
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(walked).To(Equal([]string{"configmap.yaml", "kustomization.yaml"}))
+
+	g.Expect(fSys.WriteFile(filepath.Join(root, "x"), nil)).To(MatchError(ContainSubstring("read-only")))
+}
+
+func TestTreeFS_KustomizeBuild(t *testing.T) {
+	g := NewWithT(t)
+
+	m := newMemRepo(t)
+	m.write("components/foo/base/kustomization.yaml", baseKustomization)
+	m.write("components/foo/base/configmap.yaml", baseConfigMap)
+	m.write("components/foo/staging/kustomization.yaml", overlayKustomization)
+	m.commit("first")
+
+	tree, cleanup, err := NewGoRepository(m.repo, "/repo").Checkout(context.Background(), "HEAD")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer cleanup()
+
+	out, err := kustomize.BuildFS(tree.FS, filepath.Join(tree.Root, "components/foo/staging"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).To(ContainSubstring("namespace: staging"))
+	g.Expect(string(out)).To(ContainSubstring("key: base"))
+}
@@ -0,0 +1,154 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// Backend selects how a Repository talks to git.
+type Backend string
+
+const (
+	// BackendExec runs the git binary and checks refs out into temporary
+	// worktrees on disk.
+	BackendExec Backend = "exec"
+	// BackendGo reads refs and trees directly from the object database
+	// in-process, without the git binary or any worktree I/O.
+	BackendGo Backend = "go"
+)
+
+// Repository is the set of git operations the tools use to compare two refs.
+type Repository interface {
+	// Root returns the absolute path of the repository's working copy.
+	Root() string
+	// ResolveRef resolves a ref to its short commit SHA.
+	ResolveRef(ctx context.Context, ref string) (string, error)
+	// MergeBase returns the merge-base commit of two refs.
+	MergeBase(ctx context.Context, a, b string) (string, error)
+	// ChangedFiles returns the repo-relative paths that differ between the
+	// trees of baseRef and headRef.
+	ChangedFiles(ctx context.Context, baseRef, headRef string) ([]string, error)
+	// UncommittedFiles returns the files that differ between HEAD and the
+	// working copy, including untracked files that are not ignored.
+	UncommittedFiles(ctx context.Context) ([]string, error)
+	// Checkout makes the files of ref readable and returns them with a
+	// cleanup function that releases any resources the checkout holds.
+	Checkout(ctx context.Context, ref string) (*Tree, func(), error)
+	// History returns the commits on the first-parent history of ref that
+	// change anything under path, newest first, down to the first one
+	// committed before since. A zero since returns the whole history.
+	History(ctx context.Context, ref, path string, since time.Time) ([]Commit, error)
+}
+
+// Commit is a commit in the history of a ref.
+type Commit struct {
+	SHA string
+	// Time is the committer date, i.e. when the commit landed on the
+	// branch rather than when it was written.
+	Time time.Time
+}
+
+// Tree is a read view of the files of one commit. Root is the repository
+// root within FS; paths passed to FS are absolute.
+type Tree struct {
+	FS   filesys.FileSystem
+	Root string
+}
+
+// WorkingTree returns the on-disk working copy at root as a Tree.
+func WorkingTree(root string) *Tree {
+	return &Tree{FS: filesys.MakeFsOnDisk(), Root: root}
+}
+
+// Open returns a Repository for the repository at dir using the given
+// backend. When dir is empty, the repository containing the current working
+// directory is used.
+func Open(ctx context.Context, backend Backend, dir string) (Repository, error) {
+	switch backend {
+	case BackendExec, "":
+		root := dir
+		if root == "" {
+			var err error
+			if root, err = TopLevel(ctx); err != nil {
+				return nil, err
+			}
+		}
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("resolving repo root: %w", err)
+		}
+		return NewExecRepository(abs), nil
+	case BackendGo:
+		if dir == "" {
+			dir = "."
+		}
+		return OpenGoRepository(dir)
+	default:
+		return nil, fmt.Errorf("unknown git backend %q: must be one of exec, go", backend)
+	}
+}
+
+// ChangedFilesWithUncommitted returns the files changed between baseRef and
+// HEAD merged with the uncommitted files in the working copy. The second
+// return value lists the uncommitted files on their own so callers can
+// report where each change came from.
+func ChangedFilesWithUncommitted(ctx context.Context, repo Repository, baseRef string) (files, uncommitted []string, err error) {
+	committed, err := repo.ChangedFiles(ctx, baseRef, "HEAD")
+	if err != nil {
+		return nil, nil, err
+	}
+	uncommitted, err = repo.UncommittedFiles(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	files = append(committed, uncommitted...)
+	slices.Sort(files)
+	return slices.Compact(files), uncommitted, nil
+}
+
+// execRepository implements Repository with the git binary.
+type execRepository struct {
+	root string
+}
+
+// NewExecRepository returns a Repository that runs the git binary in root.
+func NewExecRepository(root string) Repository {
+	return &execRepository{root: root}
+}
+
+func (r *execRepository) Root() string {
+	return r.root
+}
+
+func (r *execRepository) ResolveRef(ctx context.Context, ref string) (string, error) {
+	return ResolveRef(ctx, r.root, ref)
+}
+
+func (r *execRepository) MergeBase(ctx context.Context, a, b string) (string, error) {
+	return MergeBaseOf(ctx, r.root, a, b)
+}
+
+func (r *execRepository) ChangedFiles(ctx context.Context, baseRef, headRef string) ([]string, error) {
+	return ChangedFilesBetween(ctx, r.root, baseRef, headRef)
+}
+
+func (r *execRepository) UncommittedFiles(ctx context.Context) ([]string, error) {
+	return UncommittedFiles(ctx, r.root)
+}
+
+func (r *execRepository) Checkout(ctx context.Context, ref string) (*Tree, func(), error) {
+	path, cleanup, err := CreateWorktree(ctx, r.root, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	return WorkingTree(path), cleanup, nil
+}
+
+func (r *execRepository) History(ctx context.Context, ref, path string, since time.Time) ([]Commit, error) {
+	return History(ctx, r.root, ref, path, since)
+}
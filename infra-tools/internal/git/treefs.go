@@ -0,0 +1,381 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// treeRoot is where a commit tree is mounted in its treeFS.
+const treeRoot = "/"
+
+// maxSymlinkHops bounds symlink resolution, like the kernel's ELOOP limit.
+const maxSymlinkHops = 40
+
+// errReadOnly is returned by every treeFS method that would modify it.
+var errReadOnly = errors.New("git tree filesystem is read-only")
+
+// treeFS is a read-only filesys.FileSystem over a commit tree, mounted at
+// treeRoot. Symlinks inside the tree are followed; links that leave the tree
+// resolve to nothing.
+type treeFS struct {
+	repo *goRepository
+	tree *object.Tree
+}
+
+var _ filesys.FileSystem = (*treeFS)(nil)
+
+func newTreeFS(repo *goRepository, tree *object.Tree) *treeFS {
+	return &treeFS{repo: repo, tree: tree}
+}
+
+// treeNode is a resolved tree entry. The mounted root has an empty name.
+type treeNode struct {
+	name string
+	mode filemode.FileMode
+	hash plumbing.Hash
+}
+
+func (n treeNode) isDir() bool {
+	return n.mode == filemode.Dir
+}
+
+// treePath converts a filesystem path to a slash-separated path inside the
+// tree, with "" for the root. Relative paths are taken relative to the root.
+func treePath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+// stat resolves p, following symlinks.
+func (t *treeFS) stat(p string) (treeNode, error) {
+	t.repo.mu.Lock()
+	defer t.repo.mu.Unlock()
+	return t.lookup(treePath(p), 0)
+}
+
+// lookup walks p component by component from the root. The caller must hold
+// the repository lock.
+func (t *treeFS) lookup(p string, hops int) (treeNode, error) {
+	cur := treeNode{mode: filemode.Dir, hash: t.tree.Hash}
+	if p == "" {
+		return cur, nil
+	}
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if !cur.isDir() {
+			return treeNode{}, fs.ErrNotExist
+		}
+		sub, err := t.repo.repo.TreeObject(cur.hash)
+		if err != nil {
+			return treeNode{}, err
+		}
+		entry, err := sub.FindEntry(part)
+		if err != nil {
+			return treeNode{}, fs.ErrNotExist
+		}
+		if entry.Mode == filemode.Symlink {
+			if hops >= maxSymlinkHops {
+				return treeNode{}, fmt.Errorf("%s: too many levels of symbolic links", p)
+			}
+			target, err := t.blob(entry.Hash)
+			if err != nil {
+				return treeNode{}, err
+			}
+			dest := string(target)
+			if path.IsAbs(dest) {
+				return treeNode{}, fs.ErrNotExist
+			}
+			dest = path.Join(path.Join(parts[:i]...), dest)
+			if dest == ".." || strings.HasPrefix(dest, "../") {
+				return treeNode{}, fs.ErrNotExist
+			}
+			rest := append([]string{dest}, parts[i+1:]...)
+			return t.lookup(treePath(path.Join(rest...)), hops+1)
+		}
+		cur = treeNode{name: part, mode: entry.Mode, hash: entry.Hash}
+	}
+	return cur, nil
+}
+
+// blob reads the contents of a blob. The caller must hold the repository
+// lock.
+func (t *treeFS) blob(h plumbing.Hash) ([]byte, error) {
+	b, err := t.repo.repo.BlobObject(h)
+	if err != nil {
+		return nil, err
+	}
+	rd, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rd.Close() }()
+	return io.ReadAll(rd)
+}
+
+// info returns the os.FileInfo for a resolved node.
+func (t *treeFS) info(n treeNode, name string) (os.FileInfo, error) {
+	fi := &treeFileInfo{name: name, dir: n.isDir()}
+	if !fi.dir {
+		t.repo.mu.Lock()
+		defer t.repo.mu.Unlock()
+		b, err := t.repo.repo.BlobObject(n.hash)
+		if err != nil {
+			return nil, err
+		}
+		fi.size = b.Size
+	}
+	return fi, nil
+}
+
+// children returns the sorted entry names of the directory node.
+func (t *treeFS) children(n treeNode) ([]string, error) {
+	t.repo.mu.Lock()
+	defer t.repo.mu.Unlock()
+	sub, err := t.repo.repo.TreeObject(n.hash)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(sub.Entries))
+	for _, e := range sub.Entries {
+		names = append(names, e.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func pathError(op, p string, err error) error {
+	return &fs.PathError{Op: op, Path: p, Err: err}
+}
+
+func (t *treeFS) Create(p string) (filesys.File, error) {
+	return nil, pathError("create", p, errReadOnly)
+}
+
+func (t *treeFS) Mkdir(p string) error {
+	return pathError("mkdir", p, errReadOnly)
+}
+
+func (t *treeFS) MkdirAll(p string) error {
+	return pathError("mkdir", p, errReadOnly)
+}
+
+func (t *treeFS) RemoveAll(p string) error {
+	return pathError("remove", p, errReadOnly)
+}
+
+func (t *treeFS) WriteFile(p string, _ []byte) error {
+	return pathError("write", p, errReadOnly)
+}
+
+func (t *treeFS) Open(p string) (filesys.File, error) {
+	n, err := t.stat(p)
+	if err != nil {
+		return nil, pathError("open", p, err)
+	}
+	info, err := t.info(n, filepath.Base(p))
+	if err != nil {
+		return nil, pathError("open", p, err)
+	}
+	var data []byte
+	if !n.isDir() {
+		if data, err = t.ReadFile(p); err != nil {
+			return nil, err
+		}
+	}
+	return &treeFile{Reader: bytes.NewReader(data), info: info}, nil
+}
+
+func (t *treeFS) IsDir(p string) bool {
+	n, err := t.stat(p)
+	return err == nil && n.isDir()
+}
+
+func (t *treeFS) Exists(p string) bool {
+	_, err := t.stat(p)
+	return err == nil
+}
+
+func (t *treeFS) ReadDir(p string) ([]string, error) {
+	n, err := t.stat(p)
+	if err != nil {
+		return nil, pathError("readdir", p, err)
+	}
+	if !n.isDir() {
+		return nil, pathError("readdir", p, errors.New("not a directory"))
+	}
+	return t.children(n)
+}
+
+func (t *treeFS) ReadFile(p string) ([]byte, error) {
+	t.repo.mu.Lock()
+	defer t.repo.mu.Unlock()
+	n, err := t.lookup(treePath(p), 0)
+	if err != nil {
+		return nil, pathError("read", p, err)
+	}
+	if n.isDir() {
+		return nil, pathError("read", p, errors.New("is a directory"))
+	}
+	data, err := t.blob(n.hash)
+	if err != nil {
+		return nil, pathError("read", p, err)
+	}
+	return data, nil
+}
+
+// CleanedAbs splits an absolute path into its directory and file name, or
+// returns the whole path as the directory when it is one.
+func (t *treeFS) CleanedAbs(p string) (filesys.ConfirmedDir, string, error) {
+	abs := path.Join(treeRoot, treePath(p))
+	n, err := t.stat(abs)
+	if err != nil {
+		return "", "", fmt.Errorf("'%s' does not exist", p)
+	}
+	if n.isDir() {
+		return filesys.ConfirmedDir(abs), "", nil
+	}
+	return filesys.ConfirmedDir(path.Dir(abs)), path.Base(abs), nil
+}
+
+// Glob returns the paths matching pattern, following the semantics of
+// filepath.Glob.
+func (t *treeFS) Glob(pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasMeta(pattern) {
+		if t.Exists(pattern) {
+			return []string{pattern}, nil
+		}
+		return nil, nil
+	}
+
+	dir, file := filepath.Split(pattern)
+	dir = filepath.Clean(dir)
+	if !hasMeta(dir) {
+		return t.glob(dir, file, nil), nil
+	}
+	dirs, err := t.Glob(dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, d := range dirs {
+		matches = t.glob(d, file, matches)
+	}
+	return matches, nil
+}
+
+// glob appends the entries of dir that match pattern to matches.
+func (t *treeFS) glob(dir, pattern string, matches []string) []string {
+	names, err := t.ReadDir(dir)
+	if err != nil {
+		return matches
+	}
+	for _, name := range names {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			matches = append(matches, filepath.Join(dir, name))
+		}
+	}
+	return matches
+}
+
+func hasMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[\`)
+}
+
+// Walk walks the tree rooted at p in lexical order, like filepath.Walk.
+func (t *treeFS) Walk(p string, walkFn filepath.WalkFunc) error {
+	n, err := t.stat(p)
+	if err != nil {
+		err = walkFn(p, nil, pathError("lstat", p, err))
+	} else {
+		err = t.walk(p, n, walkFn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (t *treeFS) walk(p string, n treeNode, walkFn filepath.WalkFunc) error {
+	info, err := t.info(n, filepath.Base(p))
+	if err != nil {
+		return walkFn(p, nil, err)
+	}
+	if !n.isDir() {
+		return walkFn(p, info, nil)
+	}
+	names, err := t.children(n)
+	err1 := walkFn(p, info, err)
+	if err != nil || err1 != nil {
+		return err1
+	}
+	for _, name := range names {
+		child := filepath.Join(p, name)
+		cn, err := t.stat(child)
+		if err != nil {
+			if err := walkFn(child, nil, pathError("lstat", child, err)); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := t.walk(child, cn, walkFn); err != nil {
+			if !cn.isDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// treeFile is an open file from a treeFS.
+type treeFile struct {
+	*bytes.Reader
+	info os.FileInfo
+}
+
+func (f *treeFile) Write([]byte) (int, error) {
+	return 0, errReadOnly
+}
+
+func (f *treeFile) Close() error {
+	return nil
+}
+
+func (f *treeFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// treeFileInfo describes a file or directory in a treeFS. Commit trees carry
+// no modification times, so ModTime is always the zero time.
+type treeFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (fi *treeFileInfo) Name() string { return fi.name }
+func (fi *treeFileInfo) Size() int64  { return fi.size }
+func (fi *treeFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+func (fi *treeFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *treeFileInfo) IsDir() bool        { return fi.dir }
+func (fi *treeFileInfo) Sys() any           { return nil }
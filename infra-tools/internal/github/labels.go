@@ -32,6 +32,11 @@ const NeedsApprovalProductionLabel = "prod/needs-approval"
 // environment, signalling that the PR has been approved.
 const ApprovedProductionLabel = "prod/approved"
 
+// DestructiveChangeLabel is applied when the PR's rendered manifests delete
+// a Namespace, CustomResourceDefinition or PersistentVolumeClaim, whose
+// removal cascades into everything inside it.
+const DestructiveChangeLabel = "infra/destructive-change"
+
 // IssuesService is the subset of the GitHub Issues API used by this package.
 type IssuesService interface {
 	ListLabelsByIssue(ctx context.Context, owner, repo string, number int, opts *gh.ListOptions) ([]*gh.Label, *gh.Response, error)
@@ -155,6 +160,8 @@ func labelColor(label string) string {
 		return "c5def5" // light blue
 	case label == HoldProductionLabel:
 		return "e11d48" // bright red — blocks merge
+	case label == DestructiveChangeLabel:
+		return "b60205" // dark red
 	case strings.HasPrefix(label, "cluster/"):
 		return "1d76db" // blue
 	default:
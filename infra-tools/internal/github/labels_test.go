@@ -62,6 +62,7 @@ func TestIsManagedLabel(t *testing.T) {
 		{"hold-production label", HoldProductionLabel, true},
 		{"prod-needs-approval label", NeedsApprovalProductionLabel, true},
 		{"prod-approved label", ApprovedProductionLabel, true},
+		{"destructive-change label", DestructiveChangeLabel, true},
 		{"infra prefix", "infra/something", true},
 		{"empty label", "", false},
 		{"partial prefix", "environ", false},
@@ -0,0 +1,333 @@
+// Package kustfix rewrites deprecated kustomization fields to their modern
+// equivalents and proves each rewrite safe by building every kustomization
+// that includes the file before and after it: a rewrite is only kept when
+// all of those renders stay byte-identical after normalization.
+package kustfix
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/deptree"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/kustomize"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+// Status is the outcome for one kustomization file.
+type Status string
+
+const (
+	// StatusFixed means the file was rewritten and every render that
+	// includes it is unchanged.
+	StatusFixed Status = "fixed"
+	// StatusRefused means the rewrite changed a render, broke a build, or
+	// could not be proven, and was discarded.
+	StatusRefused Status = "refused"
+	// StatusManual means the file only uses deprecated fields that have
+	// no automatic rewrite.
+	StatusManual Status = "manual"
+)
+
+// FileResult is the outcome of fixing one kustomization file.
+type FileResult struct {
+	// Path is the repo-root-relative path of the kustomization file.
+	Path   string `json:"path"`
+	Status Status `json:"status"`
+	// Fixed lists the deprecated fields the rewrite migrates.
+	Fixed []deptree.Deprecation `json:"fixed,omitempty"`
+	// Remaining lists the deprecated fields left for a manual migration.
+	Remaining []deptree.Deprecation `json:"remaining,omitempty"`
+	// Builds lists the kustomization directories built to prove the
+	// rewrite, or the one whose render changed when refused.
+	Builds []string `json:"builds,omitempty"`
+	// Reason explains why the rewrite was refused.
+	Reason string `json:"reason,omitempty"`
+	// Content is the rewritten file, set when fixed.
+	Content []byte `json:"-"`
+}
+
+// Fix finds the kustomization files under dir that use deprecated fields,
+// rewrites them, and checks each rewrite against the builds of every
+// kustomization in the repository whose dependency tree includes the file:
+// first on its own, then together with the other accepted rewrites. Nothing
+// is written; pass the result to Apply. Results are sorted by path.
+func Fix(fSys filesys.FileSystem, repoRoot, dir string) ([]FileResult, error) {
+	dirs, err := kustomizationDirs(fSys, repoRoot, dir)
+	if err != nil {
+		return nil, err
+	}
+	// Files under dir are also included from elsewhere, e.g. a base from
+	// the overlays of another component, so every kustomization is a
+	// candidate build.
+	all, err := kustomizationDirs(fSys, repoRoot, ".")
+	if err != nil {
+		return nil, err
+	}
+	v := &verifier{fSys: fSys, repoRoot: repoRoot, before: make(map[string][]byte), failed: make(map[string]error), deps: make(map[string]map[string]bool)}
+
+	var results []FileResult
+	for _, d := range dirs {
+		res, ok, err := rewrite(fSys, repoRoot, d)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			results = append(results, res)
+		}
+	}
+
+	for i := range results {
+		r := &results[i]
+		if r.Status != StatusFixed {
+			continue
+		}
+		var broken []string
+		r.Builds, broken = v.covering(all, r.Path)
+		if len(r.Builds) == 0 {
+			if len(broken) > 0 {
+				refuse(r, "", "no kustomization that includes it builds: "+strings.Join(broken, ", ")+" failed")
+			} else {
+				refuse(r, "", "no kustomization that includes it renders anything")
+			}
+			continue
+		}
+		if d, reason := v.check(r.Builds, map[string][]byte{r.Path: r.Content}); reason != "" {
+			refuse(r, d, reason)
+		}
+	}
+
+	// Rewrites that are safe one by one are checked together too, dropping
+	// every file a changed render includes until the rest agree.
+	for {
+		files := make(map[string][]byte)
+		var builds []string
+		for _, r := range results {
+			if r.Status == StatusFixed {
+				files[r.Path] = r.Content
+				builds = append(builds, r.Builds...)
+			}
+		}
+		if len(files) < 2 {
+			break
+		}
+		slices.Sort(builds)
+		d, reason := v.check(slices.Compact(builds), files)
+		if reason == "" {
+			break
+		}
+		for i := range results {
+			if r := &results[i]; r.Status == StatusFixed && slices.Contains(r.Builds, d) {
+				refuse(r, d, reason+" together with the other rewrites")
+			}
+		}
+	}
+
+	slices.SortFunc(results, func(a, b FileResult) int { return strings.Compare(a.Path, b.Path) })
+	return results, nil
+}
+
+// Apply writes the rewritten files of the fixed results.
+func Apply(fSys filesys.FileSystem, repoRoot string, results []FileResult) error {
+	for _, r := range results {
+		if r.Status != StatusFixed {
+			continue
+		}
+		if err := fSys.WriteFile(filepath.Join(repoRoot, r.Path), r.Content); err != nil {
+			return fmt.Errorf("writing %s: %w", r.Path, err)
+		}
+	}
+	return nil
+}
+
+// refuse discards the rewrite of r.
+func refuse(r *FileResult, build, reason string) {
+	r.Status = StatusRefused
+	r.Reason = reason
+	r.Content = nil
+	if build != "" {
+		r.Builds = []string{build}
+	} else {
+		r.Builds = nil
+	}
+}
+
+// kustomizationDirs returns the repo-root-relative directories under dir,
+// including dir, that hold a kustomization file.
+func kustomizationDirs(fSys filesys.FileSystem, repoRoot, dir string) ([]string, error) {
+	root := filepath.Join(repoRoot, dir)
+	if !fSys.IsDir(root) {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	var dirs []string
+	err := fSys.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if deptree.KustomizationFile(fSys, path) != "" {
+			rel, err := filepath.Rel(repoRoot, path)
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking %s: %w", dir, err)
+	}
+	return dirs, nil
+}
+
+// rewrite returns the result for the kustomization file in dir, and false
+// when it uses no deprecated fields. A file that cannot be rewritten is
+// refused rather than failing the run.
+func rewrite(fSys filesys.FileSystem, repoRoot, dir string) (FileResult, bool, error) {
+	file := deptree.KustomizationFile(fSys, filepath.Join(repoRoot, dir))
+	rel, err := filepath.Rel(repoRoot, file)
+	if err != nil {
+		return FileResult{}, false, err
+	}
+	data, err := fSys.ReadFile(file)
+	if err != nil {
+		return FileResult{}, false, fmt.Errorf("reading %s: %w", rel, err)
+	}
+	res := FileResult{Path: rel}
+	deprecations, err := deptree.FileDeprecations(rel, data)
+	if err != nil {
+		refuse(&res, "", err.Error())
+		return res, true, nil
+	}
+	if len(deprecations) == 0 {
+		return FileResult{}, false, nil
+	}
+	var fields []string
+	for _, d := range deprecations {
+		if Fixable(d.Field) {
+			res.Fixed = append(res.Fixed, d)
+			fields = append(fields, d.Field)
+		} else {
+			res.Remaining = append(res.Remaining, d)
+		}
+	}
+	if len(fields) == 0 {
+		res.Status = StatusManual
+		return res, true, nil
+	}
+	isFile := func(p string) bool {
+		p = filepath.Join(filepath.Dir(file), p)
+		return fSys.Exists(p) && !fSys.IsDir(p)
+	}
+	res.Content, err = Rewrite(data, fields, isFile)
+	if err != nil {
+		refuse(&res, "", err.Error())
+		return res, true, nil
+	}
+	res.Status = StatusFixed
+	return res, true, nil
+}
+
+// verifier builds kustomizations before and after rewrites, remembering the
+// original renders and dependency trees.
+type verifier struct {
+	fSys     filesys.FileSystem
+	repoRoot string
+	// before holds the normalized original render of each directory, and
+	// failed the error of those that do not build.
+	before map[string][]byte
+	failed map[string]error
+	deps   map[string]map[string]bool
+}
+
+// covering returns the directories among dirs whose dependency tree includes
+// file and whose original build renders something, and those whose original
+// build fails. An empty render, such as that of a component on its own,
+// cannot show a change.
+func (v *verifier) covering(dirs []string, file string) ([]string, []string) {
+	var out, broken []string
+	for _, d := range dirs {
+		deps, ok := v.deps[d]
+		if !ok {
+			var err error
+			deps, err = deptree.ResolveFS(v.fSys, v.repoRoot, d)
+			if err != nil {
+				slog.Debug("resolving dependencies", "dir", d, "err", err)
+			}
+			v.deps[d] = deps
+		}
+		if !deps[file] {
+			continue
+		}
+		switch original, err := v.original(d); {
+		case err != nil:
+			broken = append(broken, d)
+		case len(original) > 0:
+			out = append(out, d)
+		}
+	}
+	return out, broken
+}
+
+// original returns the normalized render of dir before any rewrite.
+func (v *verifier) original(dir string) ([]byte, error) {
+	if out, ok := v.before[dir]; ok {
+		return out, v.failed[dir]
+	}
+	out, err := kustomize.BuildFS(v.fSys, filepath.Join(v.repoRoot, dir))
+	if err != nil {
+		slog.Debug("building original", "dir", dir, "err", err)
+		v.before[dir], v.failed[dir] = nil, err
+		return nil, err
+	}
+	v.before[dir] = renderdiff.NormalizeYAML(out)
+	return v.before[dir], nil
+}
+
+// check builds dirs with files, keyed by repo-root-relative path, replaced
+// and returns the first directory whose render differs from the original,
+// with the reason, or "" when all are unchanged.
+func (v *verifier) check(dirs []string, files map[string][]byte) (string, string) {
+	overlay := &overlayFS{FileSystem: v.fSys, files: make(map[string][]byte, len(files))}
+	for rel, content := range files {
+		overlay.files[filepath.Join(v.repoRoot, rel)] = content
+	}
+	for _, d := range dirs {
+		out, err := kustomize.BuildFS(overlay, filepath.Join(v.repoRoot, d))
+		if err != nil {
+			return d, fmt.Sprintf("%s no longer builds: %v", d, err)
+		}
+		if original, _ := v.original(d); !bytes.Equal(renderdiff.NormalizeYAML(out), original) {
+			return d, fmt.Sprintf("render of %s changed", d)
+		}
+	}
+	return "", ""
+}
+
+// overlayFS serves replacement contents for some files of an underlying
+// filesystem without modifying it. kustomize reads every file through
+// ReadFile.
+type overlayFS struct {
+	filesys.FileSystem
+	// files maps cleaned absolute paths to their replacement content.
+	files map[string][]byte
+}
+
+// ReadFile returns the replacement content of path, if any.
+func (o *overlayFS) ReadFile(path string) ([]byte, error) {
+	if content, ok := o.files[filepath.Clean(path)]; ok {
+		return content, nil
+	}
+	return o.FileSystem.ReadFile(path)
+}
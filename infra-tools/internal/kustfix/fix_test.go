@@ -0,0 +1,175 @@
+package kustfix
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/deptree"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      labels:
+        app: app
+    spec:
+      containers:
+        - name: app
+          image: quay.io/foo/app:v1
+`
+
+func writeFiles(g Gomega, fSys filesys.FileSystem, files map[string]string) {
+	for path, content := range files {
+		g.Expect(fSys.WriteFile(path, []byte(content))).To(Succeed())
+	}
+}
+
+func TestFix(t *testing.T) {
+	g := NewWithT(t)
+	fSys := filesys.MakeFsInMemory()
+	writeFiles(g, fSys, map[string]string{
+		"/repo/components/foo/base/deployment.yaml": deployment,
+		"/repo/components/foo/base/kustomization.yaml": `resources:
+- deployment.yaml
+commonLabels:
+  team: foo
+`,
+		"/repo/components/foo/staging/replicas.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\nspec:\n  replicas: 2\n",
+		"/repo/components/foo/staging/kustomization.yaml": `bases:
+- ../base
+patchesStrategicMerge:
+- replicas.yaml
+imageTags:
+- name: quay.io/foo/app
+  newTag: v2
+`,
+		// Renders nothing on its own, and no other kustomization includes it.
+		"/repo/components/foo/component/kustomization.yaml": "apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\ncommonLabels:\n  extra: \"true\"\n",
+		// vars have no automatic rewrite.
+		"/repo/components/foo/vars/kustomization.yaml": "resources:\n- ../base\nvars:\n- name: APP\n  objref:\n    kind: Deployment\n    name: app\n    apiVersion: apps/v1\n",
+	})
+
+	results, err := Fix(fSys, "/repo", "components/foo")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(results).To(HaveLen(4))
+
+	base := results[0]
+	g.Expect(base.Path).To(Equal("components/foo/base/kustomization.yaml"))
+	g.Expect(base.Status).To(Equal(StatusFixed))
+	g.Expect(base.Fixed).To(Equal([]deptree.Deprecation{{File: base.Path, Field: "commonLabels", Replacement: "labels"}}))
+	g.Expect(base.Builds).To(Equal([]string{"components/foo/base", "components/foo/staging", "components/foo/vars"}))
+
+	component := results[1]
+	g.Expect(component.Path).To(Equal("components/foo/component/kustomization.yaml"))
+	g.Expect(component.Status).To(Equal(StatusRefused))
+	g.Expect(component.Reason).To(Equal("no kustomization that includes it renders anything"))
+	g.Expect(component.Content).To(BeNil())
+
+	staging := results[2]
+	g.Expect(staging.Path).To(Equal("components/foo/staging/kustomization.yaml"))
+	g.Expect(staging.Status).To(Equal(StatusFixed))
+	g.Expect(staging.Fixed).To(HaveLen(3))
+	g.Expect(staging.Builds).To(Equal([]string{"components/foo/staging"}))
+	g.Expect(string(staging.Content)).To(Equal(`resources:
+  - ../base
+patches:
+  - path: replicas.yaml
+images:
+  - name: quay.io/foo/app
+    newTag: v2
+`))
+
+	vars := results[3]
+	g.Expect(vars.Status).To(Equal(StatusManual))
+	g.Expect(vars.Remaining).To(Equal([]deptree.Deprecation{{File: vars.Path, Field: "vars", Replacement: "replacements"}}))
+
+	g.Expect(Apply(fSys, "/repo", results)).To(Succeed())
+	results, err = Fix(fSys, "/repo", "components/foo")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(results).To(HaveLen(2), "only the component and vars are left")
+}
+
+func TestFix_RefusesChangedRender(t *testing.T) {
+	g := NewWithT(t)
+	fSys := filesys.MakeFsInMemory()
+	// kustomize applies patchesJson6902 after setting the namespace, and
+	// patches before, so moving this patch changes the namespace.
+	original := `namespace: foo
+resources:
+- deployment.yaml
+patchesJson6902:
+- target:
+    group: apps
+    version: v1
+    kind: Deployment
+    name: app
+  patch: |-
+    - op: replace
+      path: /metadata/namespace
+      value: bar
+`
+	writeFiles(g, fSys, map[string]string{
+		"/repo/components/bar/deployment.yaml":    deployment,
+		"/repo/components/bar/kustomization.yaml": original,
+	})
+
+	results, err := Fix(fSys, "/repo", "components/bar")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(results).To(HaveLen(1))
+	g.Expect(results[0].Status).To(Equal(StatusRefused))
+	g.Expect(results[0].Reason).To(Equal("render of components/bar changed"))
+	g.Expect(results[0].Builds).To(Equal([]string{"components/bar"}))
+
+	g.Expect(Apply(fSys, "/repo", results)).To(Succeed())
+	data, err := fSys.ReadFile("/repo/components/bar/kustomization.yaml")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(original))
+}
+
+func TestFix_NotADirectory(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := Fix(filesys.MakeFsInMemory(), "/repo", "components/missing")
+	g.Expect(err).To(MatchError("components/missing is not a directory"))
+}
+
+func TestFix_RefusesWithoutBuild(t *testing.T) {
+	g := NewWithT(t)
+	fSys := filesys.MakeFsInMemory()
+	writeFiles(g, fSys, map[string]string{
+		"/repo/components/baz/kustomization.yaml": "resources:\n- missing.yaml\ncommonLabels:\n  app: baz\n",
+	})
+
+	results, err := Fix(fSys, "/repo", "components/baz")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(results).To(HaveLen(1))
+	g.Expect(results[0].Status).To(Equal(StatusRefused))
+	g.Expect(results[0].Reason).To(Equal("no kustomization that includes it builds: components/baz failed"))
+}
+
+func TestFix_BuildsIncludersOutsideDir(t *testing.T) {
+	g := NewWithT(t)
+	fSys := filesys.MakeFsInMemory()
+	writeFiles(g, fSys, map[string]string{
+		"/repo/components/foo/base/deployment.yaml":    deployment,
+		"/repo/components/foo/base/kustomization.yaml": "resources:\n- deployment.yaml\n",
+		// Renders nothing on its own; only the overlay elsewhere uses it.
+		"/repo/components/foo/component/kustomization.yaml": "apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\ncommonLabels:\n  extra: \"true\"\n",
+		"/repo/overlays/foo/kustomization.yaml":             "resources:\n- ../../components/foo/base\ncomponents:\n- ../../components/foo/component\n",
+	})
+
+	results, err := Fix(fSys, "/repo", "components/foo/component")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(results).To(HaveLen(1))
+	g.Expect(results[0].Status).To(Equal(StatusFixed))
+	g.Expect(results[0].Builds).To(Equal([]string{"overlays/foo"}))
+}
@@ -0,0 +1,245 @@
+package kustfix
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fixer moves the value of a deprecated field, whose key has already been
+// removed from the kustomization mapping m at index at, into its modern
+// equivalent. isFile reports whether a path relative to the kustomization
+// directory is a file.
+type fixer func(m *yaml.Node, at int, key, value *yaml.Node, isFile func(string) bool) error
+
+// fixers maps each deprecated field Rewrite can migrate to its fixer. vars
+// has no mechanical equivalent: replacements select fields differently.
+var fixers = map[string]fixer{
+	"bases":                 appendItems("resources"),
+	"imageTags":             appendItems("images"),
+	"patchesJson6902":       appendItems("patches"),
+	"patchesStrategicMerge": fixPatchesStrategicMerge,
+	"commonLabels":          fixCommonLabels,
+}
+
+// Fixable reports whether Rewrite can migrate the deprecated field.
+func Fixable(field string) bool {
+	_, ok := fixers[field]
+	return ok
+}
+
+// Rewrite migrates the given deprecated fields of the kustomization file
+// content data to their modern equivalents, the way kustomize itself reads
+// them:
+//
+//   - bases are appended to resources and imageTags to images;
+//   - patchesStrategicMerge entries become patches entries with a path,
+//     or with an inline patch when no such file exists, ahead of the
+//     existing patches since kustomize applies them first;
+//   - patchesJson6902 entries are appended to patches unchanged;
+//   - commonLabels become a labels entry with includeSelectors: true.
+//
+// A field that is moved into one that does not exist yet takes the
+// deprecated field's place. Comments are kept; the file is re-encoded with
+// two-space indentation.
+func Rewrite(data []byte, fields []string, isFile func(string) bool) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("not a kustomization mapping")
+	}
+	m := doc.Content[0]
+	for _, field := range fields {
+		fix, ok := fixers[field]
+		if !ok {
+			return nil, fmt.Errorf("%s cannot be rewritten automatically", field)
+		}
+		at := indexOf(m, field)
+		if at < 0 {
+			continue
+		}
+		key, value := m.Content[at], m.Content[at+1]
+		m.Content = slices.Delete(m.Content, at, at+2)
+		if err := fix(m, at, key, value, isFile); err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// appendItems returns a fixer that appends the items of a deprecated list to
+// the list field target.
+func appendItems(target string) fixer {
+	return func(m *yaml.Node, at int, key, value *yaml.Node, _ func(string) bool) error {
+		items, err := listItems(value)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		list, err := targetList(m, at, key, target)
+		if err != nil {
+			return err
+		}
+		moveComment(key, items[0])
+		list.Content = append(list.Content, items...)
+		return nil
+	}
+}
+
+// fixPatchesStrategicMerge moves patchesStrategicMerge entries to the front
+// of patches.
+func fixPatchesStrategicMerge(m *yaml.Node, at int, key, value *yaml.Node, isFile func(string) bool) error {
+	items, err := listItems(value)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	patches := make([]*yaml.Node, 0, len(items))
+	for _, item := range items {
+		if item.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: expected a path or an inline patch", item.Line)
+		}
+		field := "path"
+		if !isFile(item.Value) {
+			field = "patch"
+			if strings.Contains(item.Value, "\n") {
+				item.Style = yaml.LiteralStyle
+			}
+		}
+		entry := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", HeadComment: item.HeadComment}
+		item.HeadComment = ""
+		entry.Content = []*yaml.Node{scalar(field), item}
+		patches = append(patches, entry)
+	}
+	list, err := targetList(m, at, key, "patches")
+	if err != nil {
+		return err
+	}
+	moveComment(key, patches[0])
+	list.Content = append(patches, list.Content...)
+	return nil
+}
+
+// fixCommonLabels turns commonLabels into a labels entry that, like
+// commonLabels, also sets selectors and pod template labels.
+func fixCommonLabels(m *yaml.Node, at int, key, value *yaml.Node, _ func(string) bool) error {
+	if value.Kind == yaml.ScalarNode && value.ShortTag() == "!!null" {
+		return nil
+	}
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", value.Line)
+	}
+	if len(value.Content) == 0 {
+		return nil
+	}
+	list, err := targetList(m, at, key, "labels")
+	if err != nil {
+		return err
+	}
+	// kustomize refuses to set the same label from both fields.
+	for _, label := range list.Content {
+		pairs := lookup(label, "pairs")
+		if pairs == nil {
+			continue
+		}
+		for i := 0; i < len(value.Content); i += 2 {
+			if lookup(pairs, value.Content[i].Value) != nil {
+				return fmt.Errorf("label %s is also set by labels", value.Content[i].Value)
+			}
+		}
+	}
+	entry := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+		scalar("pairs"), value,
+		scalar("includeSelectors"), {Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"},
+	}}
+	moveComment(key, entry)
+	list.Content = append(list.Content, entry)
+	return nil
+}
+
+// listItems returns the items of a list field's value, which may be null.
+func listItems(value *yaml.Node) ([]*yaml.Node, error) {
+	switch {
+	case value.Kind == yaml.SequenceNode:
+		return value.Content, nil
+	case value.Kind == yaml.ScalarNode && value.ShortTag() == "!!null":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("line %d: expected a list", value.Line)
+}
+
+// targetList returns the list value of field in m, inserting the field at
+// index at, with the comments of the deprecated key it replaces, if m does
+// not have it yet.
+func targetList(m *yaml.Node, at int, deprecated *yaml.Node, field string) (*yaml.Node, error) {
+	if i := indexOf(m, field); i >= 0 {
+		value := m.Content[i+1]
+		switch {
+		case value.Kind == yaml.SequenceNode:
+			return value, nil
+		case value.Kind == yaml.ScalarNode && value.ShortTag() == "!!null":
+			*value = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			return value, nil
+		}
+		return nil, fmt.Errorf("line %d: %s is not a list", value.Line, field)
+	}
+	key := scalar(field)
+	key.HeadComment, key.LineComment, key.FootComment = deprecated.HeadComment, deprecated.LineComment, deprecated.FootComment
+	deprecated.HeadComment, deprecated.LineComment, deprecated.FootComment = "", "", ""
+	list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	m.Content = slices.Insert(m.Content, at, key, list)
+	return list, nil
+}
+
+// moveComment carries the comments of a deprecated key that was merged into
+// an existing field over to the first item moved.
+func moveComment(key, item *yaml.Node) {
+	comment := strings.Join(slices.DeleteFunc([]string{key.HeadComment, key.LineComment, item.HeadComment}, func(s string) bool { return s == "" }), "\n")
+	item.HeadComment = comment
+	key.HeadComment, key.LineComment = "", ""
+}
+
+// indexOf returns the index of key in the mapping m, or -1.
+func indexOf(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookup returns the value of key in the mapping m, or nil.
+func lookup(m *yaml.Node, key string) *yaml.Node {
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+	if i := indexOf(m, key); i >= 0 {
+		return m.Content[i+1]
+	}
+	return nil
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
@@ -0,0 +1,102 @@
+package kustfix
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRewrite(t *testing.T) {
+	g := NewWithT(t)
+
+	in := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+# the shared base
+bases:
+- ../base
+resources:
+- configmap.yaml
+commonLabels:
+  app: foo
+patches:
+- path: replicas.yaml
+patchesStrategicMerge:
+- deployment-patch.yaml
+- |-
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+  data:
+    level: debug
+patchesJson6902:
+- target:
+    kind: Deployment
+    name: app
+  path: json-patch.yaml
+imageTags:
+- name: quay.io/foo/app
+  newTag: v2
+vars:
+- name: SERVICE
+`
+	isFile := func(p string) bool { return p == "deployment-patch.yaml" }
+	out, err := Rewrite([]byte(in), []string{"bases", "commonLabels", "imageTags", "patchesJson6902", "patchesStrategicMerge"}, isFile)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).To(Equal(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - configmap.yaml
+  # the shared base
+  - ../base
+labels:
+  - pairs:
+      app: foo
+    includeSelectors: true
+patches:
+  - path: deployment-patch.yaml
+  - patch: |-
+      apiVersion: v1
+      kind: ConfigMap
+      metadata:
+        name: settings
+      data:
+        level: debug
+  - path: replicas.yaml
+  - target:
+      kind: Deployment
+      name: app
+    path: json-patch.yaml
+images:
+  - name: quay.io/foo/app
+    newTag: v2
+vars:
+  - name: SERVICE
+`))
+}
+
+func TestRewrite_NewFieldTakesPlace(t *testing.T) {
+	g := NewWithT(t)
+
+	out, err := Rewrite([]byte("namespace: foo\n# bases\nbases: [../base]\nnamePrefix: x-\n"), []string{"bases"}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).To(Equal("namespace: foo\n# bases\nresources:\n  - ../base\nnamePrefix: x-\n"))
+}
+
+func TestRewrite_CommonLabelsConflict(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := Rewrite([]byte("labels:\n- pairs:\n    app: foo\ncommonLabels:\n  app: bar\n"), []string{"commonLabels"}, nil)
+	g.Expect(err).To(MatchError("commonLabels: label app is also set by labels"))
+}
+
+func TestRewrite_Invalid(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := Rewrite([]byte("bases: ../base\n"), []string{"bases"}, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("expected a list")))
+	_, err = Rewrite([]byte("resources: x\nbases: [../base]\n"), []string{"bases"}, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("resources is not a list")))
+	_, err = Rewrite([]byte("vars: []\n"), []string{"vars"}, nil)
+	g.Expect(err).To(MatchError("vars cannot be rewritten automatically"))
+}
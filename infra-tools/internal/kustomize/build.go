@@ -4,17 +4,24 @@ package kustomize
 
 import (
 	"fmt"
-	"os"
 
 	"sigs.k8s.io/kustomize/api/krusty"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
 // Build runs kustomize build on the given directory and returns the rendered
-// YAML output as a byte slice. Deprecation warnings from kustomize are
-// silenced by temporarily redirecting stderr.
+// YAML output as a byte slice. Kustomize prints its warnings about deprecated
+// kustomization fields straight to stderr, with no way to capture them per
+// build; deptree.DeprecationsFS reports the same fields per kustomization
+// file.
 func Build(dir string) ([]byte, error) {
-	fSys := filesys.MakeFsOnDisk()
+	return BuildFS(filesys.MakeFsOnDisk(), dir)
+}
+
+// BuildFS is Build reading the kustomization and everything it references
+// from fSys. Helm charts are still inflated by the helm binary, which needs
+// the chart on disk.
+func BuildFS(fSys filesys.FileSystem, dir string) ([]byte, error) {
 	opts := krusty.MakeDefaultOptions()
 	// Allow loading files from outside the kustomization root since overlays
 	// reference ../../base/ paths.
@@ -25,12 +32,7 @@ func Build(dir string) ([]byte, error) {
 	opts.PluginConfig.HelmConfig.Command = "helm"
 	k := krusty.MakeKustomizer(opts)
 
-	// Silence kustomize deprecation warnings (patchesStrategicMerge,
-	// commonLabels, etc.) which are written directly to stderr.
-	restoreStderr := muteStderr()
 	resMap, err := k.Run(fSys, dir)
-	restoreStderr()
-
 	if err != nil {
 		return nil, fmt.Errorf("kustomize build %s: %w", dir, err)
 	}
@@ -40,18 +42,3 @@ func Build(dir string) ([]byte, error) {
 	}
 	return yamlBytes, nil
 }
-
-// muteStderr redirects stderr to /dev/null and returns a function that
-// restores the original stderr.
-func muteStderr() func() {
-	origStderr := os.Stderr
-	devNull, err := os.Open(os.DevNull)
-	if err != nil {
-		return func() {} // can't mute, no-op restore
-	}
-	os.Stderr = devNull
-	return func() {
-		os.Stderr = origStderr
-		_ = devNull.Close()
-	}
-}
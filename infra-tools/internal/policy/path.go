@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fieldpath"
+)
+
+// hit is a resolved location for a path. Missing is set when the location
+// does not exist, which only matters for "absent" conditions.
+type hit struct {
+	path    string
+	value   interface{}
+	missing bool
+}
+
+// resolve returns every location in doc that p selects. Missing locations
+// are reported for key, index and name segments that do not exist, except
+// directly after ** where most candidate levels are expected not to match.
+func resolve(p fieldpath.Path, doc interface{}) []hit {
+	var hits []hit
+	resolveInto(p, doc, "", true, &hits)
+	return hits
+}
+
+func resolveInto(p fieldpath.Path, v interface{}, at string, reportMissing bool, hits *[]hit) {
+	if len(p) == 0 {
+		*hits = append(*hits, hit{path: at, value: v})
+		return
+	}
+	seg, rest := p[0], p[1:]
+	switch seg.Kind {
+	case fieldpath.Key:
+		m, ok := v.(map[string]interface{})
+		child, found := m[seg.Key]
+		if !ok || !found {
+			if reportMissing {
+				*hits = append(*hits, hit{path: fieldpath.Join(at, p), missing: true})
+			}
+			return
+		}
+		resolveInto(rest, child, fieldpath.JoinKey(at, seg.Key), true, hits)
+	case fieldpath.Index:
+		l, ok := v.([]interface{})
+		if !ok || seg.Index >= len(l) {
+			if reportMissing {
+				*hits = append(*hits, hit{path: fieldpath.Join(at, p), missing: true})
+			}
+			return
+		}
+		resolveInto(rest, l[seg.Index], fmt.Sprintf("%s[%d]", at, seg.Index), true, hits)
+	case fieldpath.Name:
+		l, _ := v.([]interface{})
+		found := false
+		for _, item := range l {
+			if m, ok := item.(map[string]interface{}); ok && m["name"] == seg.Key {
+				found = true
+				resolveInto(rest, item, fmt.Sprintf("%s[name=%s]", at, seg.Key), true, hits)
+			}
+		}
+		if !found && reportMissing {
+			*hits = append(*hits, hit{path: fieldpath.Join(at, p), missing: true})
+		}
+	case fieldpath.Wildcard:
+		forEachChild(v, at, func(child interface{}, childAt string) {
+			resolveInto(rest, child, childAt, true, hits)
+		})
+	case fieldpath.Recursive:
+		resolveInto(rest, v, at, false, hits)
+		forEachChild(v, at, func(child interface{}, childAt string) {
+			resolveInto(p, child, childAt, false, hits)
+		})
+	}
+}
+
+// forEachChild calls fn for every mapping value (in key order) or list item.
+func forEachChild(v interface{}, at string, fn func(child interface{}, childAt string)) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fn(val[k], fieldpath.JoinKey(at, k))
+		}
+	case []interface{}:
+		for i, item := range val {
+			fn(item, itemPath(at, i, item))
+		}
+	}
+}
+
+// itemPath formats a list item location. Items with a string name (containers,
+// env vars, volumes) are shown as [name=x], matching the semantic diff format.
+func itemPath(at string, i int, item interface{}) string {
+	if m, ok := item.(map[string]interface{}); ok {
+		if name, ok := m["name"].(string); ok && name != "" {
+			return fmt.Sprintf("%s[name=%s]", at, name)
+		}
+	}
+	return fmt.Sprintf("%s[%d]", at, i)
+}
@@ -0,0 +1,375 @@
+// Package policy evaluates declarative rules against the resources rendered
+// on the base and head refs, producing deny and warn findings.
+//
+// Rules are loaded from a YAML file:
+//
+//	rules:
+//	  - name: no-latest-images
+//	    severity: deny
+//	    message: container images must not use the latest tag
+//	    match:
+//	      changes: [added, modified]
+//	    when:
+//	      - path: "**.containers[*].image"
+//	        matches: ':latest$'
+//
+// A resource violates a rule when it matches every criterion under match and
+// every condition under when selects at least one location. Conditions are
+// checked against the head resource, or the base resource for removals.
+// Problems that already existed on the base ref are not reported again, so a
+// rule only fires on changes that introduce a violation.
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fieldpath"
+)
+
+// Severity controls whether a finding blocks the change.
+type Severity string
+
+const (
+	// SeverityDeny findings make render-diff exit non-zero.
+	SeverityDeny Severity = "deny"
+	// SeverityWarn findings are reported but do not fail the run.
+	SeverityWarn Severity = "warn"
+)
+
+// Change is how a resource differs between base and head.
+type Change string
+
+const (
+	ChangeAdded    Change = "added"
+	ChangeRemoved  Change = "removed"
+	ChangeModified Change = "modified"
+)
+
+// Finding is a single rule violation on a rendered resource.
+type Finding struct {
+	// Rule is the name of the rule that fired.
+	Rule string `json:"rule"`
+	// Severity is deny or warn.
+	Severity Severity `json:"severity"`
+	// Resource identifies the document, e.g. "apps/v1 Deployment ns/name".
+	Resource string `json:"resource"`
+	// Message is the rule's explanation.
+	Message string `json:"message"`
+	// Paths lists the field locations that triggered the rule, if any.
+	Paths []string `json:"paths,omitempty"`
+}
+
+// String formats the finding as "DENY rule: resource: message (paths)".
+func (f Finding) String() string {
+	s := fmt.Sprintf("%s %s: %s: %s", severityLabel(f.Severity), f.Rule, f.Resource, f.Message)
+	if len(f.Paths) > 0 {
+		s += fmt.Sprintf(" (%s)", strings.Join(f.Paths, ", "))
+	}
+	return s
+}
+
+// Rule is a single declarative check.
+type Rule struct {
+	Name     string      `yaml:"name"`
+	Severity Severity    `yaml:"severity"`
+	Message  string      `yaml:"message"`
+	Match    Match       `yaml:"match"`
+	When     []Condition `yaml:"when"`
+}
+
+// Match selects the resources a rule applies to. Empty lists match anything,
+// except Changes, which defaults to added and modified.
+type Match struct {
+	Kinds        []string               `yaml:"kinds"`
+	Namespaces   []string               `yaml:"namespaces"`
+	Environments []detector.Environment `yaml:"environments"`
+	Changes      []Change               `yaml:"changes"`
+}
+
+// Condition selects locations in a resource. Path is required; at most one of
+// Equals, Matches or Absent narrows it further. With none of them the
+// condition holds when the path exists.
+type Condition struct {
+	Path    string  `yaml:"path"`
+	Equals  *string `yaml:"equals"`
+	Matches string  `yaml:"matches"`
+	Absent  bool    `yaml:"absent"`
+
+	path fieldpath.Path
+	re   *regexp.Regexp
+}
+
+// RuleSet is a loaded and validated set of rules.
+type RuleSet struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Load reads and validates a rules file.
+func Load(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy file: %w", err)
+	}
+	rs, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// Parse decodes and validates rules from YAML.
+func Parse(data []byte) (*RuleSet, error) {
+	var rs RuleSet
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&rs); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing rules: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("rule %s: duplicate name", r.Name)
+		}
+		seen[r.Name] = true
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+	}
+	return &rs, nil
+}
+
+// compile validates a rule and prepares its conditions for evaluation.
+func (r *Rule) compile() error {
+	switch r.Severity {
+	case SeverityDeny, SeverityWarn:
+	default:
+		return fmt.Errorf("invalid severity %q: must be deny or warn", r.Severity)
+	}
+	if r.Message == "" {
+		return fmt.Errorf("message is required")
+	}
+	for _, env := range r.Match.Environments {
+		switch env {
+		case detector.Development, detector.Staging, detector.Production:
+		default:
+			return fmt.Errorf("invalid environment %q", env)
+		}
+	}
+	if len(r.Match.Changes) == 0 {
+		r.Match.Changes = []Change{ChangeAdded, ChangeModified}
+	}
+	for _, c := range r.Match.Changes {
+		switch c {
+		case ChangeAdded, ChangeRemoved, ChangeModified:
+		default:
+			return fmt.Errorf("invalid change %q: must be added, removed or modified", c)
+		}
+	}
+	for i := range r.When {
+		c := &r.When[i]
+		p, err := fieldpath.Parse(c.Path)
+		if err != nil {
+			return fmt.Errorf("condition %d: %w", i+1, err)
+		}
+		c.path = p
+		set := 0
+		if c.Equals != nil {
+			set++
+		}
+		if c.Matches != "" {
+			set++
+			if c.re, err = regexp.Compile(c.Matches); err != nil {
+				return fmt.Errorf("condition %d: %w", i+1, err)
+			}
+		}
+		if c.Absent {
+			set++
+		}
+		if set > 1 {
+			return fmt.Errorf("condition %d: only one of equals, matches or absent may be set", i+1)
+		}
+	}
+	return nil
+}
+
+// Evaluate checks every resource that differs between the base and head
+// renders of one component in env. Documents are paired by apiVersion, kind,
+// namespace and name. An error is returned only when the YAML cannot be parsed.
+func (rs *RuleSet) Evaluate(env detector.Environment, base, head []byte) ([]Finding, error) {
+	baseDocs, err := decodeResources(base)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base YAML: %w", err)
+	}
+	headDocs, err := decodeResources(head)
+	if err != nil {
+		return nil, fmt.Errorf("decoding head YAML: %w", err)
+	}
+
+	keys := make(map[resourceKey]bool)
+	for k := range baseDocs {
+		keys[k] = true
+	}
+	for k := range headDocs {
+		keys[k] = true
+	}
+	sorted := make([]resourceKey, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	slices.SortFunc(sorted, compareKeys)
+
+	var findings []Finding
+	for _, k := range sorted {
+		b, inBase := baseDocs[k]
+		h, inHead := headDocs[k]
+		var change Change
+		switch {
+		case !inBase:
+			change = ChangeAdded
+		case !inHead:
+			change = ChangeRemoved
+		default:
+			if equalYAML(b, h) {
+				continue
+			}
+			change = ChangeModified
+		}
+		for _, r := range rs.Rules {
+			if !r.Match.matches(k, env, change) {
+				continue
+			}
+			if paths, ok := r.check(change, b, h); ok {
+				findings = append(findings, Finding{
+					Rule:     r.Name,
+					Severity: r.Severity,
+					Resource: k.String(),
+					Message:  r.Message,
+					Paths:    paths,
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// matches reports whether a resource is in scope for a rule.
+func (m Match) matches(k resourceKey, env detector.Environment, change Change) bool {
+	if len(m.Kinds) > 0 && !slices.Contains(m.Kinds, k.kind) {
+		return false
+	}
+	if len(m.Namespaces) > 0 && !slices.Contains(m.Namespaces, k.namespace) {
+		return false
+	}
+	if len(m.Environments) > 0 && !slices.Contains(m.Environments, env) {
+		return false
+	}
+	return slices.Contains(m.Changes, change)
+}
+
+// check evaluates a rule's conditions and returns the triggering paths. For
+// modified resources, locations that already satisfied the rule on the base
+// ref are dropped; if nothing new remains, the rule does not fire.
+func (r Rule) check(change Change, base, head interface{}) ([]string, bool) {
+	target := head
+	if change == ChangeRemoved {
+		target = base
+	}
+	paths, ok := r.hits(target)
+	if !ok || change != ChangeModified {
+		return paths, ok
+	}
+
+	basePaths, baseOK := r.hits(base)
+	if !baseOK {
+		return paths, true
+	}
+	if len(r.When) == 0 {
+		// Without conditions the base necessarily satisfied the rule too.
+		return nil, false
+	}
+	var introduced []string
+	for _, p := range paths {
+		if !slices.Contains(basePaths, p) {
+			introduced = append(introduced, p)
+		}
+	}
+	return introduced, len(introduced) > 0
+}
+
+// hits returns the locations selected by every condition, and whether all
+// conditions held.
+func (r Rule) hits(doc interface{}) ([]string, bool) {
+	var paths []string
+	for _, c := range r.When {
+		matched := c.eval(doc)
+		if len(matched) == 0 {
+			return nil, false
+		}
+		paths = append(paths, matched...)
+	}
+	return paths, true
+}
+
+// eval returns the locations in doc that satisfy the condition.
+func (c Condition) eval(doc interface{}) []string {
+	var out []string
+	for _, h := range resolve(c.path, doc) {
+		if c.Absent {
+			if h.missing {
+				out = append(out, h.path)
+			}
+			continue
+		}
+		if h.missing {
+			continue
+		}
+		switch {
+		case c.Equals != nil:
+			if s, ok := scalarString(h.value); ok && s == *c.Equals {
+				out = append(out, h.path)
+			}
+		case c.re != nil:
+			if s, ok := scalarString(h.value); ok && c.re.MatchString(s) {
+				out = append(out, h.path)
+			}
+		default:
+			out = append(out, h.path)
+		}
+	}
+	return out
+}
+
+// scalarString formats a scalar for comparison. Mappings, lists and nulls
+// never match equals or matches.
+func scalarString(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return "", false
+	case string:
+		return val, true
+	default:
+		return fmt.Sprintf("%v", val), true
+	}
+}
+
+func severityLabel(s Severity) string {
+	if s == SeverityDeny {
+		return "DENY"
+	}
+	return "WARN"
+}
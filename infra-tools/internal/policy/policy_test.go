@@ -0,0 +1,235 @@
+package policy
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fieldpath"
+)
+
+const testRules = `rules:
+  - name: no-latest-images
+    severity: deny
+    message: container images must not use the latest tag
+    when:
+      - path: "**.containers[*].image"
+        matches: ':latest$'
+  - name: keep-production-pdbs
+    severity: deny
+    message: PodDisruptionBudgets must not be removed in production
+    match:
+      kinds: [PodDisruptionBudget]
+      environments: [production]
+      changes: [removed]
+  - name: no-cluster-admin-bindings
+    severity: deny
+    message: new bindings to cluster-admin are not allowed
+    match:
+      kinds: [ClusterRoleBinding]
+      changes: [added]
+    when:
+      - path: roleRef.name
+        equals: cluster-admin
+  - name: resource-requests
+    severity: warn
+    message: containers must set resource requests
+    match:
+      kinds: [Deployment]
+    when:
+      - path: spec.template.spec.containers[*].resources.requests
+        absent: true
+`
+
+func mustParse(t *testing.T, data string) *RuleSet {
+	t.Helper()
+	rs, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs
+}
+
+func deployment(image string, requests bool) string {
+	s := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: build
+spec:
+  template:
+    spec:
+      containers:
+        - name: manager
+          image: ` + image + "\n"
+	if requests {
+		s += "          resources:\n            requests:\n              cpu: 100m\n"
+	}
+	return s
+}
+
+func TestEvaluate_LatestImageIntroduced(t *testing.T) {
+	g := NewWithT(t)
+
+	rs := mustParse(t, testRules)
+	findings, err := rs.Evaluate(detector.Staging,
+		[]byte(deployment("quay.io/manager:v1", true)),
+		[]byte(deployment("quay.io/manager:latest", true)))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(findings).To(Equal([]Finding{{
+		Rule:     "no-latest-images",
+		Severity: SeverityDeny,
+		Resource: "apps/v1 Deployment build/controller",
+		Message:  "container images must not use the latest tag",
+		Paths:    []string{"spec.template.spec.containers[name=manager].image"},
+	}}))
+}
+
+func TestEvaluate_PreexistingViolationNotReported(t *testing.T) {
+	g := NewWithT(t)
+
+	rs := mustParse(t, testRules)
+	base := deployment("quay.io/manager:latest", false)
+	head := deployment("quay.io/manager:latest", false) + "  replicas: 2\n"
+	findings, err := rs.Evaluate(detector.Staging, []byte(base), []byte(head))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(findings).To(BeEmpty())
+}
+
+func TestEvaluate_UnchangedResourcesIgnored(t *testing.T) {
+	g := NewWithT(t)
+
+	rs := mustParse(t, testRules)
+	same := []byte(deployment("quay.io/manager:latest", false))
+	findings, err := rs.Evaluate(detector.Staging, same, same)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(findings).To(BeEmpty())
+}
+
+func TestEvaluate_RequestsRemoved(t *testing.T) {
+	g := NewWithT(t)
+
+	rs := mustParse(t, testRules)
+	findings, err := rs.Evaluate(detector.Staging,
+		[]byte(deployment("quay.io/manager:v1", true)),
+		[]byte(deployment("quay.io/manager:v1", false)))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(findings).To(HaveLen(1))
+	g.Expect(findings[0].Severity).To(Equal(SeverityWarn))
+	g.Expect(findings[0].Paths).To(Equal([]string{"spec.template.spec.containers[name=manager].resources.requests"}))
+}
+
+func TestEvaluate_PDBRemovalOnlyInProduction(t *testing.T) {
+	g := NewWithT(t)
+
+	rs := mustParse(t, testRules)
+	pdb := []byte("apiVersion: policy/v1\nkind: PodDisruptionBudget\nmetadata:\n  name: controller\n  namespace: build\n")
+
+	findings, err := rs.Evaluate(detector.Production, pdb, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(findings).To(HaveLen(1))
+	g.Expect(findings[0].String()).To(Equal("DENY keep-production-pdbs: policy/v1 PodDisruptionBudget build/controller: PodDisruptionBudgets must not be removed in production"))
+
+	findings, err = rs.Evaluate(detector.Staging, pdb, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(findings).To(BeEmpty())
+}
+
+func TestEvaluate_ClusterAdminBinding(t *testing.T) {
+	g := NewWithT(t)
+
+	rs := mustParse(t, testRules)
+	crb := func(role string) []byte {
+		return []byte("apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRoleBinding\nmetadata:\n  name: ops\nroleRef:\n  kind: ClusterRole\n  name: " + role + "\n")
+	}
+
+	findings, err := rs.Evaluate(detector.Staging, nil, crb("cluster-admin"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(findings).To(HaveLen(1))
+	g.Expect(findings[0].Rule).To(Equal("no-cluster-admin-bindings"))
+	g.Expect(findings[0].Paths).To(Equal([]string{"roleRef.name"}))
+
+	findings, err = rs.Evaluate(detector.Staging, nil, crb("view"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(findings).To(BeEmpty())
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := map[string]string{
+		"missing name":    "rules:\n  - severity: deny\n    message: m\n",
+		"bad severity":    "rules:\n  - name: r\n    severity: block\n    message: m\n",
+		"missing message": "rules:\n  - name: r\n    severity: warn\n",
+		"bad environment": "rules:\n  - name: r\n    severity: warn\n    message: m\n    match:\n      environments: [prod]\n",
+		"bad change":      "rules:\n  - name: r\n    severity: warn\n    message: m\n    match:\n      changes: [renamed]\n",
+		"bad regexp":      "rules:\n  - name: r\n    severity: warn\n    message: m\n    when:\n      - path: a\n        matches: '('\n",
+		"bad path":        "rules:\n  - name: r\n    severity: warn\n    message: m\n    when:\n      - path: a..b\n",
+		"two operators":   "rules:\n  - name: r\n    severity: warn\n    message: m\n    when:\n      - path: a\n        equals: x\n        absent: true\n",
+		"unknown field":   "rules:\n  - name: r\n    severity: warn\n    message: m\n    deny: true\n",
+		"duplicate name":  "rules:\n  - name: r\n    severity: warn\n    message: m\n  - name: r\n    severity: warn\n    message: m\n",
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			_, err := Parse([]byte(data))
+			g.Expect(err).To(HaveOccurred())
+		})
+	}
+}
+
+func TestResolve_RecursiveFindsNestedContainers(t *testing.T) {
+	g := NewWithT(t)
+
+	p, err := fieldpath.Parse("**.containers[*].image")
+	g.Expect(err).NotTo(HaveOccurred())
+	doc := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"jobTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "job", "image": "busybox"},
+					},
+				},
+			},
+		},
+	}
+	hits := resolve(p, doc)
+	g.Expect(hits).To(Equal([]hit{{path: "spec.jobTemplate.spec.containers[name=job].image", value: "busybox"}}))
+}
+
+func TestResolve_Name(t *testing.T) {
+	g := NewWithT(t)
+
+	p, err := fieldpath.Parse("spec.containers[name=app].image")
+	g.Expect(err).NotTo(HaveOccurred())
+	doc := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "sidecar", "image": "proxy"},
+				map[string]interface{}{"name": "app", "image": "busybox"},
+			},
+		},
+	}
+	g.Expect(resolve(p, doc)).To(Equal([]hit{{path: "spec.containers[name=app].image", value: "busybox"}}))
+
+	p, err = fieldpath.Parse("spec.containers[name=missing].image")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolve(p, doc)).To(Equal([]hit{{path: "spec.containers[name=missing].image", missing: true}}))
+}
+
+func TestLoad_RepositoryRules(t *testing.T) {
+	g := NewWithT(t)
+
+	rs, err := Load("../../policies/render-diff.yaml")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rs.Rules).NotTo(BeEmpty())
+
+	findings, err := rs.Evaluate(detector.Staging, nil, []byte(deployment("quay.io/manager", true)))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(findings).To(HaveLen(1))
+	g.Expect(findings[0].Rule).To(Equal("no-latest-images"))
+
+	findings, err = rs.Evaluate(detector.Staging, nil, []byte(deployment("quay.io/manager@sha256:abc", true)))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(findings).To(BeEmpty())
+}
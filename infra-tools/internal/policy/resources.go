@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// resourceKey identifies a Kubernetes resource within a rendered stream.
+type resourceKey struct {
+	apiVersion string
+	kind       string
+	namespace  string
+	name       string
+}
+
+// String formats the key as "apiVersion Kind ns/name".
+func (k resourceKey) String() string {
+	name := k.name
+	if k.namespace != "" {
+		name = k.namespace + "/" + name
+	}
+	return fmt.Sprintf("%s %s %s", k.apiVersion, k.kind, name)
+}
+
+func compareKeys(a, b resourceKey) int {
+	return cmp.Or(
+		cmp.Compare(a.apiVersion, b.apiVersion),
+		cmp.Compare(a.kind, b.kind),
+		cmp.Compare(a.namespace, b.namespace),
+		cmp.Compare(a.name, b.name),
+	)
+}
+
+// decodeResources parses a multi-document YAML stream into mappings keyed by
+// resource identity. Non-mapping documents are skipped; if an identity occurs
+// more than once the first document wins.
+func decodeResources(data []byte) (map[resourceKey]interface{}, error) {
+	docs := make(map[resourceKey]interface{})
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		apiVersion, _ := m["apiVersion"].(string)
+		kind, _ := m["kind"].(string)
+		md, _ := m["metadata"].(map[string]interface{})
+		namespace, _ := md["namespace"].(string)
+		name, _ := md["name"].(string)
+		k := resourceKey{apiVersion, kind, namespace, name}
+		if _, dup := docs[k]; !dup {
+			docs[k] = m
+		}
+	}
+	return docs, nil
+}
+
+// equalYAML reports whether two decoded documents are identical.
+func equalYAML(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
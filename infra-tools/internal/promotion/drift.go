@@ -0,0 +1,225 @@
+package promotion
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+// Drift is what promoting one staging path to production would change.
+// Differences are expressed from production to staging: a field's Old value
+// is production's and its New value staging's.
+type Drift struct {
+	Pair
+	// Images lists the container images that differ.
+	Images []ImageDrift `json:"images,omitempty"`
+	// Refs lists the remote kustomize bases pinned to different refs.
+	Refs []RefDrift `json:"refs,omitempty"`
+	// Resources holds the other differences between the renders.
+	Resources []renderdiff.ResourceChange `json:"resources,omitempty"`
+	// Ignored is the number of resource and field differences the ignore
+	// rules dropped.
+	Ignored int `json:"ignored,omitempty"`
+	// Error is set when either side failed to build.
+	Error string `json:"error,omitempty"`
+}
+
+// HasDrift reports whether production differs from staging.
+func (d Drift) HasDrift() bool {
+	return len(d.Images) > 0 || len(d.Refs) > 0 || len(d.Resources) > 0
+}
+
+// ImageDrift is a container image that differs between the environments.
+type ImageDrift struct {
+	// Resource identifies the workload, e.g. "apps/v1 Deployment ns/name".
+	Resource string `json:"resource"`
+	// Field is the image field, e.g. spec.template.spec.containers[name=manager].image.
+	Field      string `json:"field"`
+	Staging    string `json:"staging"`
+	Production string `json:"production"`
+}
+
+// RefDrift is a remote kustomize base pinned with ?ref= to a different
+// revision in each environment. An empty side means that environment
+// doesn't use the base.
+type RefDrift struct {
+	// Remote is the base's URL without its query.
+	Remote     string `json:"remote"`
+	Staging    string `json:"staging"`
+	Production string `json:"production"`
+}
+
+// Compare renders both sides of every pair on repo in parallel and reports
+// their drift, in the order of pairs. Build failures are recorded on the
+// pair's Drift; an error is returned only when ctx is cancelled.
+func Compare(ctx context.Context, repo detector.RepoQuerier, pairs []Pair, rules *Rules) ([]Drift, error) {
+	drifts := make([]Drift, len(pairs))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(runtime.NumCPU())
+	for i, p := range pairs {
+		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			drifts[i] = compare(repo, p, rules)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return drifts, nil
+}
+
+func compare(repo detector.RepoQuerier, p Pair, rules *Rules) Drift {
+	d := Drift{Pair: p}
+
+	// ?ref= pins don't survive rendering, so they are read from the
+	// kustomization files, and are reported even when a remote base fails
+	// to build.
+	stagingPins, err := pins(repo, p.Staging, rules)
+	if err != nil {
+		slog.Warn("reading ?ref= pins", "path", p.Staging, "err", err)
+	}
+	productionPins, err := pins(repo, p.Production, rules)
+	if err != nil {
+		slog.Warn("reading ?ref= pins", "path", p.Production, "err", err)
+	}
+	d.Refs = diffPins(stagingPins, productionPins)
+
+	staging, err := repo.BuildKustomization(p.Staging)
+	if err != nil {
+		d.Error = fmt.Sprintf("building %s: %v", p.Staging, err)
+		return d
+	}
+	production, err := repo.BuildKustomization(p.Production)
+	if err != nil {
+		d.Error = fmt.Sprintf("building %s: %v", p.Production, err)
+		return d
+	}
+	changes, err := renderdiff.SemanticDiff(rules.rewrite(production), rules.rewrite(staging))
+	if err != nil {
+		d.Error = fmt.Sprintf("comparing renders: %v", err)
+		return d
+	}
+	changes, d.Ignored = rules.filter(changes)
+	d.Images, d.Resources = splitImages(changes)
+	return d
+}
+
+// splitImages moves the image field changes of modified resources out of
+// changes. Resources left without field changes are dropped.
+func splitImages(changes []renderdiff.ResourceChange) ([]ImageDrift, []renderdiff.ResourceChange) {
+	var images []ImageDrift
+	var rest []renderdiff.ResourceChange
+	for _, rc := range changes {
+		if rc.Type != renderdiff.ChangeModified {
+			rest = append(rest, rc)
+			continue
+		}
+		var fields []renderdiff.FieldChange
+		for _, fc := range rc.Fields {
+			if isImageField(fc.Path) && fc.Type == renderdiff.ChangeModified {
+				images = append(images, ImageDrift{Resource: rc.ID(), Field: fc.Path, Staging: fc.New, Production: fc.Old})
+				continue
+			}
+			fields = append(fields, fc)
+		}
+		if len(fields) > 0 {
+			rc.Fields = fields
+			rest = append(rest, rc)
+		}
+	}
+	return images, rest
+}
+
+// imageField matches the path of a container's image, e.g.
+// spec.template.spec.containers[name=manager].image.
+var imageField = regexp.MustCompile(`(^|\.)(containers|initContainers|ephemeralContainers)\[[^\]]*\]\.image$`)
+
+func isImageField(field string) bool {
+	return imageField.MatchString(field)
+}
+
+// kustomizationFields are the kustomization fields that can refer to remote
+// bases.
+type kustomizationFields struct {
+	Resources  []string `yaml:"resources"`
+	Bases      []string `yaml:"bases"`
+	Components []string `yaml:"components"`
+}
+
+// pins returns the ?ref= revision of every remote base in the kustomization
+// tree at dir, keyed by the base's URL without its query, rewritten by
+// rules. A base pinned to several revisions lists them all, comma-separated.
+func pins(repo detector.RepoQuerier, dir string, rules *Rules) (map[string]string, error) {
+	deps, err := repo.ResolveDeps(dir)
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string][]string)
+	for file := range deps {
+		switch path.Base(file) {
+		case "kustomization.yaml", "kustomization.yml", "Kustomization":
+		default:
+			continue
+		}
+		data, err := repo.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var k kustomizationFields
+		if err := yaml.Unmarshal(data, &k); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", file, err)
+		}
+		for _, entry := range slices.Concat(k.Resources, k.Bases, k.Components) {
+			remote, query, ok := strings.Cut(entry, "?")
+			if !ok {
+				continue
+			}
+			values, err := url.ParseQuery(query)
+			if err != nil || values.Get("ref") == "" {
+				continue
+			}
+			remote = string(rules.rewrite([]byte(remote)))
+			if ref := values.Get("ref"); !slices.Contains(refs[remote], ref) {
+				refs[remote] = append(refs[remote], ref)
+			}
+		}
+	}
+	out := make(map[string]string, len(refs))
+	for remote, r := range refs {
+		slices.Sort(r)
+		out[remote] = strings.Join(r, ", ")
+	}
+	return out, nil
+}
+
+// diffPins returns the remote bases pinned differently, sorted by URL.
+func diffPins(staging, production map[string]string) []RefDrift {
+	var drift []RefDrift
+	for remote, ref := range staging {
+		if production[remote] != ref {
+			drift = append(drift, RefDrift{Remote: remote, Staging: ref, Production: production[remote]})
+		}
+	}
+	for remote, ref := range production {
+		if _, ok := staging[remote]; !ok {
+			drift = append(drift, RefDrift{Remote: remote, Production: ref})
+		}
+	}
+	slices.SortFunc(drift, func(a, b RefDrift) int { return strings.Compare(a.Remote, b.Remote) })
+	return drift
+}
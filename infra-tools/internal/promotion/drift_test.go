@@ -0,0 +1,165 @@
+package promotion
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+func deployment(image string, replicas int, host string) string {
+	return `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: smee
+  namespace: smee
+spec:
+  replicas: ` + strconv.Itoa(replicas) + `
+  template:
+    spec:
+      containers:
+        - name: smee
+          image: ` + image + `
+          args: ["--host=` + host + `"]
+`
+}
+
+// driftRepo lays out a component whose production overlay pins an older
+// remote base than staging. Staging refers to a missing file, which fails
+// its build before kustomize fetches anything.
+func driftRepo(g *WithT) *detector.RepoRef {
+	repo, err := detector.NewMemRepoRef(map[string][]byte{
+		"components/smee/staging/kustomization.yaml": []byte(`resources:
+  - missing.yaml
+  - https://github.com/example/smee/config?ref=new
+`),
+		"components/smee/production/kustomization.yaml": []byte(`resources:
+  - https://github.com/example/smee/config?ref=old
+`),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return repo
+}
+
+// driftPair is the pair of driftRepo.
+var driftPair = Pair{Component: "smee", Staging: "components/smee/staging", Production: "components/smee/production"}
+
+func TestPins(t *testing.T) {
+	g := NewWithT(t)
+
+	p, err := pins(driftRepo(g), "components/smee/production", nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(p).To(Equal(map[string]string{"https://github.com/example/smee/config": "old"}))
+}
+
+func TestDiffPins(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(diffPins(
+		map[string]string{"a": "1", "b": "2", "c": "3"},
+		map[string]string{"a": "1", "b": "1", "d": "4"},
+	)).To(Equal([]RefDrift{
+		{Remote: "b", Staging: "2", Production: "1"},
+		{Remote: "c", Staging: "3"},
+		{Remote: "d", Production: "4"},
+	}))
+}
+
+func TestSplitImages(t *testing.T) {
+	g := NewWithT(t)
+
+	images, rest := splitImages([]renderdiff.ResourceChange{{
+		APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "x", Type: renderdiff.ChangeModified,
+		Fields: []renderdiff.FieldChange{
+			{Path: "spec.template.spec.initContainers[name=init].image", Type: renderdiff.ChangeModified, Old: "a:1", New: "a:2"},
+			{Path: "spec.template.spec.containers[name=x].env[name=image].value", Type: renderdiff.ChangeModified, Old: "1", New: "2"},
+		},
+	}})
+	g.Expect(images).To(Equal([]ImageDrift{{
+		Resource:   "apps/v1 Deployment ns/x",
+		Field:      "spec.template.spec.initContainers[name=init].image",
+		Staging:    "a:2",
+		Production: "a:1",
+	}}))
+	g.Expect(rest).To(HaveLen(1))
+	g.Expect(rest[0].Fields).To(HaveLen(1))
+}
+
+// TestCompare_BuildError checks that pins are compared even when a build
+// fails.
+func TestCompare_BuildError(t *testing.T) {
+	g := NewWithT(t)
+
+	drifts, err := Compare(context.Background(), driftRepo(g), []Pair{driftPair}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(drifts).To(HaveLen(1))
+	g.Expect(drifts[0].Error).To(ContainSubstring("building components/smee/staging"))
+	g.Expect(drifts[0].Refs).To(Equal([]RefDrift{{Remote: "https://github.com/example/smee/config", Staging: "new", Production: "old"}}))
+	g.Expect(drifts[0].HasDrift()).To(BeTrue())
+}
+
+func TestCompare(t *testing.T) {
+	g := NewWithT(t)
+
+	// A production overlay lagging behind staging: an older image, and a
+	// ConfigMap staging doesn't have any more.
+	repo, err := detector.NewMemRepoRef(map[string][]byte{
+		"components/smee/staging/kustomization.yaml":    []byte("resources:\n  - deployment.yaml\n"),
+		"components/smee/staging/deployment.yaml":       []byte(deployment("quay.io/smee@sha256:bbbb", 1, "smee.staging.example.com")),
+		"components/smee/production/kustomization.yaml": []byte("resources:\n  - deployment.yaml\n  - configmap.yaml\n"),
+		"components/smee/production/deployment.yaml":    []byte(deployment("quay.io/smee@sha256:aaaa", 3, "smee.production.example.com")),
+		"components/smee/production/configmap.yaml":     []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: legacy\n  namespace: smee\n"),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	drifts, err := Compare(context.Background(), repo, []Pair{driftPair}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	d := drifts[0]
+	g.Expect(d.Error).To(BeEmpty())
+	g.Expect(d.Images).To(Equal([]ImageDrift{{
+		Resource:   "apps/v1 Deployment smee/smee",
+		Field:      "spec.template.spec.containers[name=smee].image",
+		Staging:    "quay.io/smee@sha256:bbbb",
+		Production: "quay.io/smee@sha256:aaaa",
+	}}))
+	g.Expect(d.Refs).To(BeEmpty())
+	// The ConfigMap would be removed from production, the replicas and
+	// host differ.
+	g.Expect(d.Resources).To(HaveLen(2))
+	g.Expect(d.Resources[0].Kind).To(Equal("Deployment"))
+	g.Expect(d.Resources[0].Fields).To(HaveLen(2))
+	g.Expect(d.Resources[1]).To(HaveField("Kind", "ConfigMap"))
+	g.Expect(d.Resources[1]).To(HaveField("Type", renderdiff.ChangeRemoved))
+
+	// Rules normalize the expected differences away.
+	rules, err := Parse([]byte(`
+rewrite:
+  - name: env
+    pattern: '\b(staging|production)\b'
+    replacement: ENV
+ignore:
+  - name: replicas
+    paths: [spec.replicas]
+`))
+	g.Expect(err).NotTo(HaveOccurred())
+	drifts, err = Compare(context.Background(), repo, []Pair{driftPair}, rules)
+	g.Expect(err).NotTo(HaveOccurred())
+	d = drifts[0]
+	g.Expect(d.Images).To(HaveLen(1))
+	g.Expect(d.Resources).To(HaveLen(1))
+	g.Expect(d.Resources[0].Kind).To(Equal("ConfigMap"))
+	g.Expect(d.Ignored).To(Equal(1))
+}
+
+func TestCompare_Cancelled(t *testing.T) {
+	g := NewWithT(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Compare(ctx, driftRepo(g), []Pair{driftPair}, nil)
+	g.Expect(err).To(MatchError(context.Canceled))
+}
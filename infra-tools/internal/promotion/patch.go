@@ -0,0 +1,564 @@
+package promotion
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+// ChangeKind is the kind of value a staging edit changed.
+type ChangeKind string
+
+const (
+	// ChangeImage is an image: field, e.g. of a container.
+	ChangeImage ChangeKind = "image"
+	// ChangeImageEntry is a newName, newTag or digest field of a
+	// kustomization images: entry.
+	ChangeImageEntry ChangeKind = "kustomize-image"
+	// ChangeRef is the ?ref= pin of a remote kustomize base.
+	ChangeRef ChangeKind = "ref"
+)
+
+// Change is a value a staging edit changed, which promoting sets in the
+// production overlay too.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	// Key identifies the value: the image name, without tag or digest, for
+	// images and images: entries; the URL without its query for refs.
+	Key string `json:"key"`
+	// Field is the images: entry field, for ChangeImageEntry.
+	Field string `json:"field,omitempty"`
+	// From and To are the staging values before and after the edit.
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Name names the value c sets, e.g. "quay.io/x newTag".
+func (c Change) Name() string {
+	switch c.Kind {
+	case ChangeImageEntry:
+		return c.Key + " " + c.Field
+	case ChangeRef:
+		return c.Key + " ref"
+	default:
+		return c.Key
+	}
+}
+
+func (c Change) slotKey() slotKey {
+	return slotKey{c.Kind, c.Key, c.Field}
+}
+
+// Edit is a change applied to one value of a production file.
+type Edit struct {
+	Change
+	Line int `json:"line"`
+	// Production is the value the edit replaces.
+	Production string `json:"production"`
+}
+
+// FilePatch is a production file rewritten by the promotion.
+type FilePatch struct {
+	Path    string `json:"path"`
+	Content []byte `json:"-"`
+	Edits   []Edit `json:"edits"`
+}
+
+// Skipped is a staging change that could not be promoted.
+type Skipped struct {
+	Change
+	Reason string `json:"reason"`
+}
+
+// ManualEdit is a staging file whose edits are not, or not entirely, changes
+// to images and ?ref= pins, so it has to be promoted by hand.
+type ManualEdit struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Plan is the set of production edits that promotes a range of staging
+// edits.
+type Plan struct {
+	Files   []FilePatch  `json:"files"`
+	Skipped []Skipped    `json:"skipped,omitempty"`
+	Manual  []ManualEdit `json:"manual,omitempty"`
+}
+
+// BuildPlan works out how to promote the staging edits among changed, the
+// files that differ between the base and head trees, onto the onto tree.
+//
+// The image, images: entry and ?ref= values a staging file's edit changed
+// are set in every YAML file under the production counterpart of the file's
+// staging directory that refers to the same image or remote, e.g. a change
+// to components/x/staging/stone-stg-rh01/kustomization.yaml is promoted to
+// every file under components/x/production. Production files are patched
+// in place, keeping their formatting and comments.
+func BuildPlan(changed []string, base, head, onto detector.RepoQuerier) (*Plan, error) {
+	plan := &Plan{}
+	byRoot := make(map[string][]Change)
+	for _, file := range changed {
+		if detector.ClassifyFileEnv(file) != detector.Staging {
+			continue
+		}
+		manual := func(reason string) {
+			plan.Manual = append(plan.Manual, ManualEdit{Path: file, Reason: reason})
+		}
+		root, ok := productionRoot(file)
+		if !ok || !onto.DirExists(root) {
+			manual("no production counterpart")
+			continue
+		}
+		if !isYAML(file) {
+			manual("not a YAML file")
+			continue
+		}
+		before, err := base.ReadFile(file)
+		if err != nil {
+			manual("added in staging")
+			continue
+		}
+		after, err := head.ReadFile(file)
+		if err != nil {
+			manual("deleted in staging")
+			continue
+		}
+		changes, err := extract(file, before, after)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		// The changes found cover the whole edit when replaying them
+		// reproduces the new file.
+		replayed, _, _, err := apply(file, before, changes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if !bytes.Equal(replayed, after) {
+			manual("has edits besides image and ?ref= values")
+		}
+		byRoot[root] = append(byRoot[root], changes...)
+	}
+
+	roots := make([]string, 0, len(byRoot))
+	for root := range byRoot {
+		roots = append(roots, root)
+	}
+	slices.Sort(roots)
+	for _, root := range roots {
+		changes, skipped := dedupe(byRoot[root])
+		plan.Skipped = append(plan.Skipped, skipped...)
+		if len(changes) == 0 {
+			continue
+		}
+		files, err := yamlFiles(onto, root)
+		if err != nil {
+			return nil, err
+		}
+		referenced := make([]bool, len(changes))
+		for _, file := range files {
+			data, err := onto.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			patched, edits, refs, err := apply(file, data, changes)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			for i, ok := range refs {
+				referenced[i] = referenced[i] || ok
+			}
+			if len(edits) > 0 {
+				plan.Files = append(plan.Files, FilePatch{Path: file, Content: patched, Edits: edits})
+			}
+		}
+		for i, c := range changes {
+			if !referenced[i] {
+				plan.Skipped = append(plan.Skipped, Skipped{Change: c, Reason: "nothing under " + root + " refers to it"})
+			}
+		}
+	}
+	return plan, nil
+}
+
+// productionRoot returns the production counterpart of the staging
+// directory of file, e.g. components/x/production for
+// components/x/staging/base/kustomization.yaml.
+func productionRoot(file string) (string, bool) {
+	segments := strings.Split(file, "/")
+	for i, seg := range segments[:len(segments)-1] {
+		if twin, ok := productionSegment(seg); ok {
+			return path.Join(append(segments[:i:i], twin)...), true
+		}
+	}
+	return "", false
+}
+
+func isYAML(file string) bool {
+	switch path.Ext(file) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return path.Base(file) == "Kustomization"
+}
+
+func isKustomization(file string) bool {
+	switch path.Base(file) {
+	case "kustomization.yaml", "kustomization.yml", "Kustomization":
+		return true
+	}
+	return false
+}
+
+// yamlFiles returns the YAML files under dir, sorted.
+func yamlFiles(repo detector.RepoQuerier, dir string) ([]string, error) {
+	names, err := repo.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(names)
+	var files []string
+	for _, name := range names {
+		p := path.Join(dir, name)
+		switch {
+		case repo.DirExists(p):
+			sub, err := yamlFiles(repo, p)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, sub...)
+		case isYAML(p):
+			files = append(files, p)
+		}
+	}
+	return files, nil
+}
+
+// dedupe merges the changes of several staging files. A value staging
+// changed to different values in different files is skipped: which one
+// production should get is up to whoever promotes it.
+func dedupe(changes []Change) ([]Change, []Skipped) {
+	targets := make(map[slotKey][]string)
+	var keys []slotKey
+	first := make(map[slotKey]Change)
+	for _, c := range changes {
+		k := c.slotKey()
+		if _, ok := first[k]; !ok {
+			first[k] = c
+			keys = append(keys, k)
+		}
+		if !slices.Contains(targets[k], c.To) {
+			targets[k] = append(targets[k], c.To)
+		}
+	}
+	slices.SortFunc(keys, compareKeys)
+	var out []Change
+	var skipped []Skipped
+	for _, k := range keys {
+		if to := targets[k]; len(to) > 1 {
+			skipped = append(skipped, Skipped{Change: first[k], Reason: "staging sets it to " + strings.Join(to, ", ")})
+			continue
+		}
+		out = append(out, first[k])
+	}
+	return out, skipped
+}
+
+// slotKey identifies what a value in a YAML file is, independent of the
+// value itself.
+type slotKey struct {
+	kind       ChangeKind
+	key, field string
+}
+
+func compareKeys(a, b slotKey) int {
+	return strings.Compare(string(a.kind)+"\x00"+a.key+"\x00"+a.field, string(b.kind)+"\x00"+b.key+"\x00"+b.field)
+}
+
+// slot is a promotable value in a YAML file: the scalar node holding it,
+// and the value itself, which for a ref is only part of the scalar.
+type slot struct {
+	slotKey
+	node  *yaml.Node
+	value string
+}
+
+// extract returns the values that differ between the before and after
+// contents of a staging file. Values that appear with several different
+// values in either version are ambiguous and left out.
+func extract(file string, before, after []byte) ([]Change, error) {
+	values := func(data []byte) (map[slotKey][]string, error) {
+		slots, err := slots(file, data)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[slotKey][]string)
+		for _, s := range slots {
+			if !slices.Contains(out[s.slotKey], s.value) {
+				out[s.slotKey] = append(out[s.slotKey], s.value)
+			}
+		}
+		return out, nil
+	}
+	old, err := values(before)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := values(after)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for k, to := range updated {
+		from := old[k]
+		if len(from) != 1 || len(to) != 1 || from[0] == to[0] {
+			continue
+		}
+		changes = append(changes, Change{Kind: k.kind, Key: k.key, Field: k.field, From: from[0], To: to[0]})
+	}
+	slices.SortFunc(changes, func(a, b Change) int { return compareKeys(a.slotKey(), b.slotKey()) })
+	return changes, nil
+}
+
+// apply sets the values of data that changes refer to, and returns the
+// patched data, the edits made and, per change, whether data refers to it
+// at all.
+func apply(file string, data []byte, changes []Change) ([]byte, []Edit, []bool, error) {
+	slots, err := slots(file, data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	type replacement struct {
+		node  *yaml.Node
+		value string
+	}
+	var (
+		edits        []Edit
+		replacements []replacement
+		referenced   = make([]bool, len(changes))
+	)
+	for _, s := range slots {
+		for i, c := range changes {
+			if c.slotKey() != s.slotKey {
+				continue
+			}
+			referenced[i] = true
+			value := c.To
+			if c.Kind == ChangeRef {
+				value = setRef(s.node.Value, c.To)
+			}
+			if value == s.node.Value {
+				continue
+			}
+			edits = append(edits, Edit{Change: c, Line: s.node.Line, Production: s.value})
+			replacements = append(replacements, replacement{s.node, value})
+		}
+	}
+	if len(replacements) == 0 {
+		return data, nil, referenced, nil
+	}
+
+	// Patch the text rather than re-encoding the document, which would
+	// reformat it and drop comments. Going from the end keeps the positions
+	// of the earlier scalars valid.
+	lines := strings.SplitAfter(string(data), "\n")
+	slices.SortFunc(replacements, func(a, b replacement) int {
+		if a.node.Line != b.node.Line {
+			return b.node.Line - a.node.Line
+		}
+		return b.node.Column - a.node.Column
+	})
+	for _, r := range replacements {
+		old, ok := rawScalar(r.node.Value, r.node.Style)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("line %d: cannot edit %q in this scalar style", r.node.Line, r.node.Value)
+		}
+		style := r.node.Style
+		if style == 0 && !isPlainString(r.value) {
+			// e.g. a tag of digits, which would turn into a number.
+			style = yaml.DoubleQuotedStyle
+		}
+		updated, ok := rawScalar(r.value, style)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("line %d: cannot write %q in this scalar style", r.node.Line, r.value)
+		}
+		line := lines[r.node.Line-1]
+		offset := runeOffset(line, r.node.Column-1)
+		if !strings.HasPrefix(line[offset:], old) {
+			return nil, nil, nil, fmt.Errorf("line %d: %q not found", r.node.Line, old)
+		}
+		lines[r.node.Line-1] = line[:offset] + updated + line[offset+len(old):]
+	}
+	slices.SortFunc(edits, func(a, b Edit) int { return a.Line - b.Line })
+	return []byte(strings.Join(lines, "")), edits, referenced, nil
+}
+
+// slots returns the promotable values of a YAML file: image: fields, the
+// images: entries of a kustomization, and URLs with a ?ref= pin.
+func slots(file string, data []byte) ([]slot, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var out []slot
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return out, nil
+			}
+			return nil, err
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		root := doc.Content[0]
+		if isKustomization(file) && root.Kind == yaml.MappingNode {
+			out = append(out, imageEntries(root)...)
+		}
+		out = appendSlots(out, root)
+	}
+}
+
+func appendSlots(out []slot, n *yaml.Node) []slot {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Value == "image" && v.Kind == yaml.ScalarNode && v.Value != "" {
+				out = append(out, slot{slotKey{ChangeImage, imageName(v.Value), ""}, v, v.Value})
+				continue
+			}
+			out = appendSlots(out, v)
+		}
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			out = appendSlots(out, item)
+		}
+	case yaml.ScalarNode:
+		if remote, ref, ok := refPin(n.Value); ok {
+			out = append(out, slot{slotKey{ChangeRef, remote, ""}, n, ref})
+		}
+	}
+	return out
+}
+
+// imageEntryFields are the fields of a kustomization images: entry that
+// select the image deployed.
+var imageEntryFields = []string{"newName", "newTag", "digest"}
+
+func imageEntries(kustomization *yaml.Node) []slot {
+	var out []slot
+	images := mapValue(kustomization, "images")
+	if images == nil || images.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, entry := range images.Content {
+		name := mapValue(entry, "name")
+		if name == nil || name.Kind != yaml.ScalarNode {
+			continue
+		}
+		for _, field := range imageEntryFields {
+			if v := mapValue(entry, field); v != nil && v.Kind == yaml.ScalarNode {
+				out = append(out, slot{slotKey{ChangeImageEntry, name.Value, field}, v, v.Value})
+			}
+		}
+	}
+	return out
+}
+
+func mapValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// imageName strips the tag and digest from an image reference.
+func imageName(image string) string {
+	name, _, _ := strings.Cut(image, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	return name
+}
+
+// refPin splits a URL with a ref query parameter into the URL without its
+// query and the ref.
+func refPin(s string) (remote, ref string, ok bool) {
+	remote, query, ok := strings.Cut(s, "?")
+	if !ok {
+		return "", "", false
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil || values.Get("ref") == "" {
+		return "", "", false
+	}
+	return remote, values.Get("ref"), true
+}
+
+var refParam = regexp.MustCompile(`([?&])ref=[^&]*`)
+
+// setRef replaces the ref query parameter of a URL, keeping the others as
+// they are.
+func setRef(s, ref string) string {
+	return refParam.ReplaceAllStringFunc(s, func(m string) string {
+		return m[:1] + "ref=" + ref
+	})
+}
+
+// rawScalar returns how a single-line scalar with the given value is written
+// in the source, for the styles that can be patched in place.
+func rawScalar(value string, style yaml.Style) (string, bool) {
+	if strings.Contains(value, "\n") {
+		return "", false
+	}
+	switch style {
+	case 0:
+		return value, true
+	case yaml.SingleQuotedStyle:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'", true
+	case yaml.DoubleQuotedStyle:
+		if strings.ContainsAny(value, `"\`) {
+			return "", false
+		}
+		return `"` + value + `"`, true
+	}
+	return "", false
+}
+
+// isPlainString reports whether s reads back as the same string when
+// written unquoted.
+func isPlainString(s string) bool {
+	var v any
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		return false
+	}
+	str, ok := v.(string)
+	return ok && str == s
+}
+
+// runeOffset returns the byte offset of the n-th rune of s.
+func runeOffset(s string, n int) int {
+	offset := 0
+	for range n {
+		if offset >= len(s) {
+			break
+		}
+		_, size := utf8.DecodeRuneInString(s[offset:])
+		offset += size
+	}
+	return offset
+}
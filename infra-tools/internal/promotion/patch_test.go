@@ -0,0 +1,167 @@
+package promotion
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+func memRepo(g *WithT, files map[string]string) *detector.RepoRef {
+	data := make(map[string][]byte, len(files))
+	for p, content := range files {
+		data[p] = []byte(content)
+	}
+	repo, err := detector.NewMemRepoRef(data)
+	g.Expect(err).NotTo(HaveOccurred())
+	return repo
+}
+
+func sidecarPatch(image, extra string) string {
+	return `spec:
+  template:
+    spec:
+      containers:
+        - name: sidecar
+          image: ` + image + `
+` + extra
+}
+
+func TestBuildPlan(t *testing.T) {
+	g := NewWithT(t)
+
+	base := memRepo(g, map[string]string{
+		"components/smee/staging/base/kustomization.yaml": `resources:
+  - https://github.com/example/smee/config?ref=aaa
+images:
+  - name: quay.io/smee
+    newTag: "1"
+`,
+		"components/smee/staging/stone-stg-rh01/patch.yaml": sidecarPatch("quay.io/sidecar:1.0", ""),
+		"components/smee/base/deployment.yaml":              sidecarPatch("quay.io/sidecar:1.0", ""),
+	})
+	head := memRepo(g, map[string]string{
+		"components/smee/staging/base/kustomization.yaml": `resources:
+  - https://github.com/example/smee/config?ref=bbb
+images:
+  - name: quay.io/smee
+    newTag: "2"
+`,
+		"components/smee/staging/stone-stg-rh01/patch.yaml": sidecarPatch("quay.io/sidecar:1.1", "          args: [--verbose]\n"),
+		"components/smee/staging/base/new.yaml":             "kind: ConfigMap\n",
+		"components/smee/base/deployment.yaml":              sidecarPatch("quay.io/sidecar:2.0", ""),
+	})
+	onto := memRepo(g, map[string]string{
+		"components/smee/production/base/kustomization.yaml": `# Production pins
+resources:
+  - https://github.com/example/smee/config?ref=aaa&timeout=2m
+images:
+  - name: quay.io/smee
+    newTag: '0' # held back
+`,
+		"components/smee/production/stone-prd-rh01/patch.yaml": sidecarPatch("quay.io/sidecar:0.9", ""),
+		"components/smee/production/stone-prd-rh02/patch.yaml": sidecarPatch("quay.io/sidecar:1.1", ""),
+	})
+
+	plan, err := BuildPlan([]string{
+		"components/smee/base/deployment.yaml",
+		"components/smee/staging/base/kustomization.yaml",
+		"components/smee/staging/base/new.yaml",
+		"components/smee/staging/stone-stg-rh01/patch.yaml",
+	}, base, head, onto)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(plan.Files).To(HaveLen(2))
+	g.Expect(plan.Files[0].Path).To(Equal("components/smee/production/base/kustomization.yaml"))
+	g.Expect(string(plan.Files[0].Content)).To(Equal(`# Production pins
+resources:
+  - https://github.com/example/smee/config?ref=bbb&timeout=2m
+images:
+  - name: quay.io/smee
+    newTag: '2' # held back
+`))
+	g.Expect(plan.Files[0].Edits).To(Equal([]Edit{
+		{Change: Change{Kind: ChangeRef, Key: "https://github.com/example/smee/config", From: "aaa", To: "bbb"}, Line: 3, Production: "aaa"},
+		{Change: Change{Kind: ChangeImageEntry, Key: "quay.io/smee", Field: "newTag", From: "1", To: "2"}, Line: 6, Production: "0"},
+	}))
+	// stone-prd-rh02 already runs the staging image.
+	g.Expect(plan.Files[1].Path).To(Equal("components/smee/production/stone-prd-rh01/patch.yaml"))
+	g.Expect(string(plan.Files[1].Content)).To(Equal(sidecarPatch("quay.io/sidecar:1.1", "")))
+
+	g.Expect(plan.Skipped).To(BeEmpty())
+	g.Expect(plan.Manual).To(Equal([]ManualEdit{
+		{Path: "components/smee/staging/base/new.yaml", Reason: "added in staging"},
+		{Path: "components/smee/staging/stone-stg-rh01/patch.yaml", Reason: "has edits besides image and ?ref= values"},
+	}))
+}
+
+func TestBuildPlan_Skipped(t *testing.T) {
+	g := NewWithT(t)
+
+	base := memRepo(g, map[string]string{
+		"components/smee/staging/a/patch.yaml": sidecarPatch("quay.io/sidecar:1.0", ""),
+		"components/smee/staging/b/patch.yaml": sidecarPatch("quay.io/sidecar:1.0", "---\nimage: quay.io/other:1\n"),
+		"components/mpc/staging/patch.yaml":    sidecarPatch("quay.io/mpc:1", ""),
+	})
+	head := memRepo(g, map[string]string{
+		"components/smee/staging/a/patch.yaml": sidecarPatch("quay.io/sidecar:1.1", ""),
+		"components/smee/staging/b/patch.yaml": sidecarPatch("quay.io/sidecar:1.2", "---\nimage: quay.io/other:2\n"),
+		"components/mpc/staging/patch.yaml":    sidecarPatch("quay.io/mpc:2", ""),
+	})
+	onto := memRepo(g, map[string]string{
+		"components/smee/production/patch.yaml": sidecarPatch("quay.io/sidecar:0.9", ""),
+	})
+
+	plan, err := BuildPlan([]string{
+		"components/mpc/staging/patch.yaml",
+		"components/smee/staging/a/patch.yaml",
+		"components/smee/staging/b/patch.yaml",
+	}, base, head, onto)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(plan.Files).To(BeEmpty())
+	g.Expect(plan.Skipped).To(Equal([]Skipped{
+		{Change: Change{Kind: ChangeImage, Key: "quay.io/sidecar", From: "quay.io/sidecar:1.0", To: "quay.io/sidecar:1.1"}, Reason: "staging sets it to quay.io/sidecar:1.1, quay.io/sidecar:1.2"},
+		{Change: Change{Kind: ChangeImage, Key: "quay.io/other", From: "quay.io/other:1", To: "quay.io/other:2"}, Reason: "nothing under components/smee/production refers to it"},
+	}))
+	g.Expect(plan.Manual).To(Equal([]ManualEdit{{Path: "components/mpc/staging/patch.yaml", Reason: "no production counterpart"}}))
+}
+
+func TestApply_UnsupportedStyle(t *testing.T) {
+	g := NewWithT(t)
+
+	_, _, _, err := apply("patch.yaml", []byte("image: >-\n  quay.io/x:1\n"), []Change{{Kind: ChangeImage, Key: "quay.io/x", To: "quay.io/x:2"}})
+	g.Expect(err).To(MatchError(ContainSubstring("cannot edit")))
+}
+
+func TestApply_QuotesNonStrings(t *testing.T) {
+	g := NewWithT(t)
+
+	out, edits, _, err := apply("kustomization.yaml", []byte("images:\n  - name: quay.io/x\n    newTag: v1\n"),
+		[]Change{{Kind: ChangeImageEntry, Key: "quay.io/x", Field: "newTag", From: "1", To: "2"}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(edits).To(HaveLen(1))
+	g.Expect(string(out)).To(Equal("images:\n  - name: quay.io/x\n    newTag: \"2\"\n"))
+}
+
+func TestImageName(t *testing.T) {
+	g := NewWithT(t)
+
+	for image, name := range map[string]string{
+		"quay.io/x/y":                 "quay.io/x/y",
+		"quay.io/x/y:v1":              "quay.io/x/y",
+		"quay.io/x/y@sha256:abc":      "quay.io/x/y",
+		"quay.io/x/y:v1@sha256:abc":   "quay.io/x/y",
+		"localhost:5000/y":            "localhost:5000/y",
+		"localhost:5000/y:v1@sha256:": "localhost:5000/y",
+	} {
+		g.Expect(imageName(image)).To(Equal(name), image)
+	}
+}
+
+func TestSetRef(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(setRef("https://github.com/x/y?ref=a", "b")).To(Equal("https://github.com/x/y?ref=b"))
+	g.Expect(setRef("https://github.com/x/y?timeout=1m&ref=a&submodules=false", "b")).To(Equal("https://github.com/x/y?timeout=1m&ref=b&submodules=false"))
+}
@@ -0,0 +1,107 @@
+// Package promotion compares what the staging and production overlays of
+// each component deploy on the same ref, to show which changes are still
+// pending promotion from staging to production.
+//
+// A component's staging and production paths are paired by swapping the
+// environment directory in the path, e.g. components/x/staging/base with
+// components/x/production/base, or overlays/staging-downstream with
+// overlays/production-downstream. Cluster-specific directories are named
+// after different clusters in each environment, so they have no counterpart
+// and are not compared.
+//
+// BuildPlan goes the other way: it turns the image and ?ref= edits of a
+// staging change into the production edits that promote it, and CheckSoak
+// finds since when staging has had the values a production edit sets.
+package promotion
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fleet"
+)
+
+// Counterpart returns the production path corresponding to a staging path,
+// by replacing its first staging directory segment: "staging" itself, or an
+// overlay directory that detector.OverlayEnvironment maps to staging and whose
+// production twin it also knows. It returns false when the path has no
+// staging segment.
+func Counterpart(stagingPath string) (string, bool) {
+	segments := strings.Split(stagingPath, "/")
+	for i, seg := range segments {
+		if twin, ok := productionSegment(seg); ok {
+			segments[i] = twin
+			return strings.Join(segments, "/"), true
+		}
+	}
+	return "", false
+}
+
+func productionSegment(seg string) (string, bool) {
+	if seg == string(detector.Staging) {
+		return string(detector.Production), true
+	}
+	if detector.OverlayEnvironment[seg] != detector.Staging {
+		return "", false
+	}
+	twin := strings.Replace(seg, string(detector.Staging), string(detector.Production), 1)
+	return twin, detector.OverlayEnvironment[twin] == detector.Production
+}
+
+// stagingSegment is the inverse of productionSegment.
+func stagingSegment(seg string) (string, bool) {
+	if seg == string(detector.Production) {
+		return string(detector.Staging), true
+	}
+	if detector.OverlayEnvironment[seg] != detector.Production {
+		return "", false
+	}
+	twin := strings.Replace(seg, string(detector.Production), string(detector.Staging), 1)
+	return twin, detector.OverlayEnvironment[twin] == detector.Staging
+}
+
+// Pair is a staging path and the production path it is promoted to.
+type Pair struct {
+	Component  string `json:"component"`
+	Staging    string `json:"staging"`
+	Production string `json:"production"`
+}
+
+// Pairs returns, for every component deployed in both staging and
+// production, the staging paths whose counterpart production deploys too,
+// sorted by component and path. Components deployed in both environments
+// that have no such pair are returned as unpaired.
+func Pairs(m *fleet.Matrix) (pairs []Pair, unpaired []string) {
+	for _, component := range m.Components(detector.Staging) {
+		if !m.Deployed(detector.Production, component) {
+			continue
+		}
+		production := paths(m, detector.Production, component)
+		found := false
+		for _, s := range paths(m, detector.Staging, component) {
+			p, ok := Counterpart(s)
+			if ok && slices.Contains(production, p) {
+				pairs = append(pairs, Pair{Component: component, Staging: s, Production: p})
+				found = true
+			}
+		}
+		if !found {
+			unpaired = append(unpaired, component)
+		}
+	}
+	return pairs, unpaired
+}
+
+// paths returns the distinct paths a component is deployed from in env,
+// sorted.
+func paths(m *fleet.Matrix, env detector.Environment, component string) []string {
+	var out []string
+	for _, c := range append(m.Clusters(env), fleet.AllClusters) {
+		if p := m.Cell(env, c, component).Path; p != "" && !slices.Contains(out, p) {
+			out = append(out, p)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
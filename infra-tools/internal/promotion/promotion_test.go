@@ -0,0 +1,75 @@
+package promotion
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fleet"
+)
+
+func TestCounterpart(t *testing.T) {
+	g := NewWithT(t)
+
+	for staging, production := range map[string]string{
+		"components/smee/staging/base":                            "components/smee/production/base",
+		"components/smee/staging":                                 "components/smee/production",
+		"components/multi-platform-controller/staging-downstream": "components/multi-platform-controller/production-downstream",
+		"components/x/konflux-public-staging/base":                "components/x/konflux-public-production/base",
+	} {
+		p, ok := Counterpart(staging)
+		g.Expect(ok).To(BeTrue(), staging)
+		g.Expect(p).To(Equal(production))
+	}
+
+	for _, path := range []string{
+		"components/enterprise-contract",
+		"components/smee/production/base",
+		"components/smee/development",
+	} {
+		_, ok := Counterpart(path)
+		g.Expect(ok).To(BeFalse(), path)
+	}
+}
+
+func TestPairs(t *testing.T) {
+	g := NewWithT(t)
+
+	files := make(map[string][]byte)
+	for _, dir := range []string{
+		"components/smee/staging/base",
+		"components/smee/production/base",
+		"components/smee/staging/s1",
+		"components/smee/production/p1",
+		"components/backup/staging/s1",
+		"components/backup/production/p1",
+		"components/shared",
+	} {
+		files[dir+"/kustomization.yaml"] = []byte("resources: []\n")
+	}
+	repo, err := detector.NewMemRepoRef(files)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	apps := func(env, cluster string) []appset.Application {
+		return []appset.Application{
+			{AppSet: "smee", AllClusters: true, Source: appset.ApplicationSource{Path: "components/smee/" + env + "/base"}},
+			{AppSet: "smee", Cluster: cluster, Source: appset.ApplicationSource{Path: "components/smee/" + env + "/" + cluster}},
+			{AppSet: "backup", Cluster: cluster, Source: appset.ApplicationSource{Path: "components/backup/" + env + "/" + cluster}},
+			{AppSet: "shared", AllClusters: true, Source: appset.ApplicationSource{Path: "components/shared"}},
+		}
+	}
+	m := fleet.Build([]detector.OverlayApplications{
+		{Overlay: "staging-downstream", Environment: detector.Staging, Applications: apps("staging", "s1")},
+		{Overlay: "production-downstream", Environment: detector.Production, Applications: apps("production", "p1")},
+	}, repo)
+
+	pairs, unpaired := Pairs(m)
+	g.Expect(pairs).To(Equal([]Pair{
+		{Component: "smee", Staging: "components/smee/staging/base", Production: "components/smee/production/base"},
+	}))
+	// shared deploys the same path in both environments, so there is nothing
+	// to compare either.
+	g.Expect(unpaired).To(Equal([]string{"backup", "shared"}))
+}
@@ -0,0 +1,211 @@
+package promotion
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+// Rules normalize the environment-specific differences between staging and
+// production renders, so that only drift is reported. They are loaded from a
+// YAML file:
+//
+//	rewrite:
+//	  - name: environment-names
+//	    pattern: '\b(staging|production)\b'
+//	    replacement: ENV
+//	ignore:
+//	  - name: replicas
+//	    kinds: [Deployment]
+//	    paths: [spec.replicas]
+//	  - name: external-secrets
+//	    kinds: [ExternalSecret]
+//
+// Rewrites are regular expression replacements applied to both rendered
+// streams before they are compared, so they also line up resources whose
+// names differ by environment. Ignore rules drop the differences in the
+// listed field paths of the matching resources, or the whole resource when no
+// paths are given.
+type Rules struct {
+	Rewrite []RewriteRule `yaml:"rewrite"`
+	Ignore  []IgnoreRule  `yaml:"ignore"`
+}
+
+// RewriteRule replaces every match of Pattern in both renders.
+type RewriteRule struct {
+	Name        string `yaml:"name"`
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"`
+
+	re *regexp.Regexp
+}
+
+// IgnoreRule drops differences from the comparison. Kinds and Names (globs)
+// select resources; empty lists match anything. Paths are field paths in
+// the semantic diff's notation, e.g. spec.template.spec.containers[*].image,
+// where * matches within one segment and ** across segments. A path also
+// matches every field below it.
+type IgnoreRule struct {
+	Name  string   `yaml:"name"`
+	Kinds []string `yaml:"kinds"`
+	Names []string `yaml:"names"`
+	Paths []string `yaml:"paths"`
+
+	paths []*regexp.Regexp
+}
+
+// Load reads and validates a rules file.
+func Load(file string) (*Rules, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading rules file: %w", err)
+	}
+	rules, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", file, err)
+	}
+	return rules, nil
+}
+
+// Parse decodes and validates rules from YAML.
+func Parse(data []byte) (*Rules, error) {
+	var rules Rules
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&rules); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing rules: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	checkName := func(kind string, i int, name string) error {
+		if name == "" {
+			return fmt.Errorf("%s rule %d: name is required", kind, i+1)
+		}
+		if seen[name] {
+			return fmt.Errorf("rule %s: duplicate name", name)
+		}
+		seen[name] = true
+		return nil
+	}
+	for i := range rules.Rewrite {
+		r := &rules.Rewrite[i]
+		if err := checkName("rewrite", i, r.Name); err != nil {
+			return nil, err
+		}
+		if r.Pattern == "" {
+			return nil, fmt.Errorf("rule %s: pattern is required", r.Name)
+		}
+		var err error
+		if r.re, err = regexp.Compile(r.Pattern); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+	}
+	for i := range rules.Ignore {
+		r := &rules.Ignore[i]
+		if err := checkName("ignore", i, r.Name); err != nil {
+			return nil, err
+		}
+		for _, g := range r.Names {
+			if _, err := path.Match(g, ""); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid name glob %q: %w", r.Name, g, err)
+			}
+		}
+		for _, p := range r.Paths {
+			if p == "" {
+				return nil, fmt.Errorf("rule %s: empty path", r.Name)
+			}
+			r.paths = append(r.paths, compilePath(p))
+		}
+	}
+	return &rules, nil
+}
+
+// compilePath turns an ignore path into a regular expression matching the
+// path and every field below it.
+func compilePath(p string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(p)
+	quoted = strings.ReplaceAll(quoted, `\*\*`, `.*`)
+	quoted = strings.ReplaceAll(quoted, `\*`, `[^.]*`)
+	return regexp.MustCompile(`^` + quoted + `($|[.\[])`)
+}
+
+// rewrite applies the rewrite rules to a rendered stream.
+func (r *Rules) rewrite(rendered []byte) []byte {
+	if r == nil {
+		return rendered
+	}
+	for _, rule := range r.Rewrite {
+		rendered = rule.re.ReplaceAll(rendered, []byte(rule.Replacement))
+	}
+	return rendered
+}
+
+// filter drops the ignored differences from changes and returns what is
+// left, with the number of resource and field differences dropped.
+func (r *Rules) filter(changes []renderdiff.ResourceChange) ([]renderdiff.ResourceChange, int) {
+	if r == nil || len(r.Ignore) == 0 {
+		return changes, 0
+	}
+	var kept []renderdiff.ResourceChange
+	ignored := 0
+	for _, rc := range changes {
+		var rules []IgnoreRule
+		whole := false
+		for _, rule := range r.Ignore {
+			if rule.selects(rc) {
+				rules = append(rules, rule)
+				whole = whole || len(rule.paths) == 0
+			}
+		}
+		switch {
+		case whole:
+			ignored++
+			continue
+		case len(rules) == 0 || rc.Type != renderdiff.ChangeModified:
+			kept = append(kept, rc)
+			continue
+		}
+		var fields []renderdiff.FieldChange
+		for _, fc := range rc.Fields {
+			if slices.ContainsFunc(rules, func(rule IgnoreRule) bool { return rule.ignores(fc.Path) }) {
+				ignored++
+				continue
+			}
+			fields = append(fields, fc)
+		}
+		if len(fields) > 0 {
+			rc.Fields = fields
+			kept = append(kept, rc)
+		}
+	}
+	return kept, ignored
+}
+
+// selects reports whether the rule applies to a resource.
+func (r IgnoreRule) selects(rc renderdiff.ResourceChange) bool {
+	if len(r.Kinds) > 0 && !slices.Contains(r.Kinds, rc.Kind) {
+		return false
+	}
+	if len(r.Names) > 0 && !slices.ContainsFunc(r.Names, func(g string) bool {
+		ok, _ := path.Match(g, rc.Name)
+		return ok
+	}) {
+		return false
+	}
+	return true
+}
+
+// ignores reports whether the rule drops differences in a field.
+func (r IgnoreRule) ignores(field string) bool {
+	return slices.ContainsFunc(r.paths, func(re *regexp.Regexp) bool { return re.MatchString(field) })
+}
@@ -0,0 +1,112 @@
+package promotion
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/renderdiff"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	g := NewWithT(t)
+
+	for rules, msg := range map[string]string{
+		"rewrite:\n- pattern: x\n":                   "rewrite rule 1: name is required",
+		"rewrite:\n- name: a\n":                      "rule a: pattern is required",
+		"rewrite:\n- name: a\n  pattern: '('\n":      "rule a: error parsing regexp",
+		"ignore:\n- name: a\n- name: a\n":            "rule a: duplicate name",
+		"ignore:\n- name: a\n  names: ['[']\n":       `invalid name glob "["`,
+		"ignore:\n- name: a\n  paths: ['']\n":        "rule a: empty path",
+		"ignore:\n- name: a\n  kind: [Deployment]\n": "field kind not found",
+	} {
+		_, err := Parse([]byte(rules))
+		g.Expect(err).To(MatchError(ContainSubstring(msg)), rules)
+	}
+}
+
+func TestParse_Empty(t *testing.T) {
+	g := NewWithT(t)
+
+	rules, err := Parse(nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rules.Ignore).To(BeEmpty())
+}
+
+func TestCompilePath(t *testing.T) {
+	g := NewWithT(t)
+
+	for pattern, fields := range map[string]map[string]bool{
+		"spec.replicas": {
+			"spec.replicas":          true,
+			"spec.replicasMax":       false,
+			"metadata.spec.replicas": false,
+		},
+		"metadata.annotations": {
+			`metadata.annotations["argocd.argoproj.io/sync-wave"]`: true,
+			"metadata.annotations.owner":                           true,
+		},
+		"spec.template.spec.containers[*].resources": {
+			"spec.template.spec.containers[name=manager].resources.limits.cpu": true,
+			"spec.template.spec.containers[0].resources":                       true,
+			"spec.template.spec.initContainers[0].resources":                   false,
+		},
+		"**.env": {
+			"spec.template.spec.containers[name=manager].env[name=FOO].value": true,
+			"spec.environment": false,
+		},
+	} {
+		re := compilePath(pattern)
+		for field, want := range fields {
+			g.Expect(re.MatchString(field)).To(Equal(want), "%s ~ %s", pattern, field)
+		}
+	}
+}
+
+func TestRules_Filter(t *testing.T) {
+	g := NewWithT(t)
+
+	rules, err := Parse([]byte(`
+ignore:
+  - name: replicas
+    kinds: [Deployment]
+    paths: [spec.replicas]
+  - name: secrets
+    kinds: [ExternalSecret]
+    names: ["*-credentials"]
+`))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	changes := []renderdiff.ResourceChange{
+		{Kind: "Deployment", Name: "a", Type: renderdiff.ChangeModified, Fields: []renderdiff.FieldChange{
+			{Path: "spec.replicas", Type: renderdiff.ChangeModified, Old: "3", New: "1"},
+			{Path: "spec.paused", Type: renderdiff.ChangeAdded, New: "true"},
+		}},
+		{Kind: "Deployment", Name: "b", Type: renderdiff.ChangeModified, Fields: []renderdiff.FieldChange{
+			{Path: "spec.replicas", Type: renderdiff.ChangeModified, Old: "3", New: "1"},
+		}},
+		{Kind: "StatefulSet", Name: "c", Type: renderdiff.ChangeModified, Fields: []renderdiff.FieldChange{
+			{Path: "spec.replicas", Type: renderdiff.ChangeModified, Old: "3", New: "1"},
+		}},
+		{Kind: "ExternalSecret", Name: "db-credentials", Type: renderdiff.ChangeAdded},
+		{Kind: "ExternalSecret", Name: "db-config", Type: renderdiff.ChangeAdded},
+	}
+
+	kept, ignored := rules.filter(changes)
+	g.Expect(ignored).To(Equal(3))
+	g.Expect(kept).To(HaveLen(3))
+	g.Expect(kept[0].Fields).To(Equal([]renderdiff.FieldChange{{Path: "spec.paused", Type: renderdiff.ChangeAdded, New: "true"}}))
+	g.Expect(kept[1].Kind).To(Equal("StatefulSet"))
+	g.Expect(kept[2].Name).To(Equal("db-config"))
+}
+
+func TestRules_Nil(t *testing.T) {
+	g := NewWithT(t)
+
+	var rules *Rules
+	g.Expect(rules.rewrite([]byte("staging"))).To(Equal([]byte("staging")))
+	changes := []renderdiff.ResourceChange{{Kind: "Deployment", Type: renderdiff.ChangeAdded}}
+	kept, ignored := rules.filter(changes)
+	g.Expect(kept).To(Equal(changes))
+	g.Expect(ignored).To(BeZero())
+}
@@ -0,0 +1,216 @@
+package promotion
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/git"
+)
+
+// History is the part of git.Repository that CheckSoak reads the staging
+// history with.
+type History interface {
+	History(ctx context.Context, ref, path string, since time.Time) ([]git.Commit, error)
+	Checkout(ctx context.Context, ref string) (*git.Tree, func(), error)
+}
+
+// Soak is a value a production edit sets, and since when staging has had
+// it.
+type Soak struct {
+	Change
+	// File is the production file the edit is in.
+	File string `json:"file"`
+	// Staging is the staging directory the value was looked up in.
+	Staging string `json:"staging"`
+	// Commit and Since are the commit that brought the value into Staging
+	// and its commit time; both are empty when staging doesn't have the
+	// value. For a value staging already had at the cutoff, they are the
+	// last change to Staging before the cutoff instead, which the value
+	// predates.
+	Commit string    `json:"commit,omitempty"`
+	Since  time.Time `json:"since,omitzero"`
+}
+
+// InStaging reports whether staging has the value at all.
+func (s Soak) InStaging() bool {
+	return !s.Since.IsZero()
+}
+
+// CheckSoak finds, for every image, images: entry and ?ref= value the
+// production edits among changed set, when the same value landed in the
+// staging counterpart of the production directory on the first-parent
+// history of ref, e.g. main. changed are the files that differ between the
+// base and head trees.
+//
+// History is searched back to cutoff only: whether a value older than that
+// landed a day or a year earlier makes no difference to a soak-time check.
+// Production directories without a staging counterpart on ref are not
+// checked.
+func CheckSoak(ctx context.Context, repo History, ref string, changed []string, base, head detector.RepoQuerier, cutoff time.Time) ([]Soak, error) {
+	byRoot := make(map[string][]Soak)
+	for _, file := range changed {
+		if detector.ClassifyFileEnv(file) != detector.Production || !isYAML(file) {
+			continue
+		}
+		root, ok := stagingRoot(file)
+		if !ok {
+			continue
+		}
+		after, err := head.ReadFile(file)
+		if err != nil {
+			// Deleted; it sets nothing.
+			continue
+		}
+		before, _ := base.ReadFile(file)
+		changes, err := setValues(file, before, after)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, c := range changes {
+			byRoot[root] = append(byRoot[root], Soak{Change: c, File: file, Staging: root})
+		}
+	}
+
+	roots := make([]string, 0, len(byRoot))
+	for root := range byRoot {
+		roots = append(roots, root)
+	}
+	slices.Sort(roots)
+	var out []Soak
+	for _, root := range roots {
+		soaks := byRoot[root]
+		ok, err := landed(ctx, repo, ref, root, soaks, cutoff)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, soaks...)
+		}
+	}
+	return out, nil
+}
+
+// landed sets Commit and Since of soaks by going back through the commits
+// that changed root until each value is missing from it. It returns false
+// when root never existed on ref.
+func landed(ctx context.Context, repo History, ref, root string, soaks []Soak, cutoff time.Time) (bool, error) {
+	commits, err := repo.History(ctx, ref, root, cutoff)
+	if err != nil {
+		return false, err
+	}
+	if len(commits) == 0 {
+		return false, nil
+	}
+	pending := make([]bool, len(soaks))
+	for i := range pending {
+		pending[i] = true
+	}
+	for _, c := range commits {
+		present, err := stagingValues(ctx, repo, c.SHA, root)
+		if err != nil {
+			return false, err
+		}
+		done := true
+		for i := range soaks {
+			if !pending[i] {
+				continue
+			}
+			if !present[valueKey{soaks[i].slotKey(), soaks[i].To}] {
+				pending[i] = false
+				continue
+			}
+			soaks[i].Commit, soaks[i].Since = c.SHA, c.Time
+			done = false
+		}
+		if done || c.Time.Before(cutoff) {
+			break
+		}
+	}
+	return true, nil
+}
+
+type valueKey struct {
+	slotKey
+	value string
+}
+
+// stagingValues returns the promotable values under root at commit.
+func stagingValues(ctx context.Context, repo History, commit, root string) (map[valueKey]bool, error) {
+	tree, cleanup, err := repo.Checkout(ctx, commit)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	ref := detector.NewRepoRef(tree.Root, detector.WithFileSystem(tree.FS))
+	present := make(map[valueKey]bool)
+	if !ref.DirExists(root) {
+		return present, nil
+	}
+	files, err := yamlFiles(ref, root)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := ref.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		slots, err := slots(file, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s at %s: %w", file, commit, err)
+		}
+		for _, s := range slots {
+			present[valueKey{s.slotKey, s.value}] = true
+		}
+	}
+	return present, nil
+}
+
+// setValues returns the values after has that before doesn't, i.e. that an
+// edit sets, including those of a new file.
+func setValues(file string, before, after []byte) ([]Change, error) {
+	old := make(map[slotKey][]string)
+	if before != nil {
+		slots, err := slots(file, before)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range slots {
+			old[s.slotKey] = append(old[s.slotKey], s.value)
+		}
+	}
+	slots, err := slots(file, after)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	seen := make(map[valueKey]bool)
+	for _, s := range slots {
+		vk := valueKey{s.slotKey, s.value}
+		if seen[vk] || slices.Contains(old[s.slotKey], s.value) {
+			continue
+		}
+		seen[vk] = true
+		from := strings.Join(slices.Compact(slices.Sorted(slices.Values(old[s.slotKey]))), ", ")
+		changes = append(changes, Change{Kind: s.kind, Key: s.key, Field: s.field, From: from, To: s.value})
+	}
+	return changes, nil
+}
+
+// stagingRoot returns the staging counterpart of the production directory
+// of file, e.g. components/x/staging for
+// components/x/production/base/kustomization.yaml.
+func stagingRoot(file string) (string, bool) {
+	segments := strings.Split(file, "/")
+	for i, seg := range segments[:len(segments)-1] {
+		if twin, ok := stagingSegment(seg); ok {
+			return path.Join(append(segments[:i:i], twin)...), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,181 @@
+package promotion
+
+import (
+	"context"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/git"
+)
+
+// fakeCommit is a commit of fakeHistory with the full tree at it.
+type fakeCommit struct {
+	git.Commit
+	files map[string]string
+}
+
+// fakeHistory serves History from commits, newest first. A commit changed a
+// path when some file under it differs from the next older commit.
+type fakeHistory struct {
+	commits   []fakeCommit
+	checkouts int
+}
+
+func (h *fakeHistory) History(_ context.Context, _ string, dir string, since time.Time) ([]git.Commit, error) {
+	var out []git.Commit
+	for i, c := range h.commits {
+		var older map[string]string
+		if i+1 < len(h.commits) {
+			older = h.commits[i+1].files
+		}
+		if !sameUnder(c.files, older, dir) {
+			out = append(out, c.Commit)
+			if c.Time.Before(since) {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func (h *fakeHistory) Checkout(_ context.Context, ref string) (*git.Tree, func(), error) {
+	h.checkouts++
+	fSys := filesys.MakeFsInMemory()
+	for _, c := range h.commits {
+		if c.SHA != ref {
+			continue
+		}
+		for name, content := range c.files {
+			p := path.Join("/", name)
+			if err := fSys.MkdirAll(path.Dir(p)); err != nil {
+				return nil, nil, err
+			}
+			if err := fSys.WriteFile(p, []byte(content)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return &git.Tree{FS: fSys, Root: "/"}, func() {}, nil
+}
+
+func sameUnder(a, b map[string]string, dir string) bool {
+	under := func(name string) bool { return strings.HasPrefix(name, dir+"/") }
+	for name, content := range a {
+		if under(name) && b[name] != content {
+			return false
+		}
+	}
+	for name := range b {
+		if _, ok := a[name]; under(name) && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func entryKustomization(tag string) string {
+	return "images:\n  - name: quay.io/smee\n    newTag: \"" + tag + "\"\n"
+}
+
+func TestCheckSoak(t *testing.T) {
+	g := NewWithT(t)
+
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+	const staging = "components/smee/staging/base/kustomization.yaml"
+	const production = "components/smee/production/base/kustomization.yaml"
+	history := &fakeHistory{commits: []fakeCommit{
+		{git.Commit{SHA: "c4", Time: day(10)}, map[string]string{staging: entryKustomization("3"), "README.md": "x"}},
+		{git.Commit{SHA: "c3", Time: day(8)}, map[string]string{staging: entryKustomization("3")}},
+		{git.Commit{SHA: "c2", Time: day(5)}, map[string]string{staging: entryKustomization("2")}},
+		{git.Commit{SHA: "c1", Time: day(1)}, map[string]string{staging: entryKustomization("1")}},
+	}}
+	base := memRepo(g, map[string]string{production: entryKustomization("1")})
+
+	for _, tc := range []struct {
+		tag    string
+		commit string
+		since  time.Time
+	}{
+		{tag: "3", commit: "c3", since: day(8)},
+		{tag: "2"},
+		{tag: "9"},
+	} {
+		head := memRepo(g, map[string]string{production: entryKustomization(tc.tag)})
+		soaks, err := CheckSoak(context.Background(), history, "main", []string{production, "README.md"}, base, head, day(1))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(soaks).To(Equal([]Soak{{
+			Change:  Change{Kind: ChangeImageEntry, Key: "quay.io/smee", Field: "newTag", From: "1", To: tc.tag},
+			File:    production,
+			Staging: "components/smee/staging",
+			Commit:  tc.commit,
+			Since:   tc.since,
+		}}), tc.tag)
+	}
+}
+
+func TestCheckSoak_Cutoff(t *testing.T) {
+	g := NewWithT(t)
+
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+	const staging = "components/smee/staging/stone-stg-rh01/patch.yaml"
+	const production = "components/smee/production/stone-prd-rh01/patch.yaml"
+	history := &fakeHistory{commits: []fakeCommit{
+		{git.Commit{SHA: "c3", Time: day(10)}, map[string]string{staging: sidecarPatch("quay.io/sidecar:2", "          args: [-v]\n")}},
+		{git.Commit{SHA: "c2", Time: day(5)}, map[string]string{staging: sidecarPatch("quay.io/sidecar:2", "")}},
+		{git.Commit{SHA: "c1", Time: day(1)}, map[string]string{staging: sidecarPatch("quay.io/sidecar:1", "")}},
+	}}
+	base := memRepo(g, map[string]string{})
+	head := memRepo(g, map[string]string{production: sidecarPatch("quay.io/sidecar:2", "")})
+
+	// The value predates the cutoff, so c1 is never looked at.
+	soaks, err := CheckSoak(context.Background(), history, "main", []string{production}, base, head, day(7))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(soaks).To(Equal([]Soak{{
+		Change:  Change{Kind: ChangeImage, Key: "quay.io/sidecar", To: "quay.io/sidecar:2"},
+		File:    production,
+		Staging: "components/smee/staging",
+		Commit:  "c2",
+		Since:   day(5),
+	}}))
+	g.Expect(history.checkouts).To(Equal(2))
+}
+
+func TestCheckSoak_NoStaging(t *testing.T) {
+	g := NewWithT(t)
+
+	const production = "components/mpc/production/patch.yaml"
+	base := memRepo(g, map[string]string{production: sidecarPatch("quay.io/mpc:1", "")})
+	head := memRepo(g, map[string]string{production: sidecarPatch("quay.io/mpc:2", "")})
+
+	soaks, err := CheckSoak(context.Background(), &fakeHistory{}, "main", []string{production}, base, head, time.Time{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(soaks).To(BeEmpty())
+}
+
+func TestSetValues(t *testing.T) {
+	g := NewWithT(t)
+
+	changes, err := setValues("kustomization.yaml",
+		[]byte("resources:\n  - https://github.com/x/y?ref=a\n"+entryKustomization("1")),
+		[]byte("resources:\n  - https://github.com/x/y?ref=b\n  - https://github.com/x/y?ref=b\n"+entryKustomization("1")))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changes).To(Equal([]Change{{Kind: ChangeRef, Key: "https://github.com/x/y", From: "a", To: "b"}}))
+}
+
+func TestStagingRoot(t *testing.T) {
+	g := NewWithT(t)
+
+	root, ok := stagingRoot("components/x/production/base/kustomization.yaml")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(root).To(Equal("components/x/staging"))
+
+	_, ok = stagingRoot("components/x/base/kustomization.yaml")
+	g.Expect(ok).To(BeFalse())
+	_, ok = stagingRoot("production")
+	g.Expect(ok).To(BeFalse())
+}
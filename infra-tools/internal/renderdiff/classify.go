@@ -0,0 +1,134 @@
+package renderdiff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ChangeClass describes what Argo CD will do to apply a resource change.
+type ChangeClass string
+
+const (
+	// ClassAdd is a resource that only exists on HEAD; Argo CD creates it.
+	ClassAdd ChangeClass = "add"
+	// ClassModify is an in-place update Argo CD can apply with a patch.
+	ClassModify ChangeClass = "modify"
+	// ClassDelete is a resource that only exists on the base; Argo CD prunes it.
+	ClassDelete ChangeClass = "delete"
+	// ClassImmutable changes a field the API server rejects on update, so the
+	// sync fails unless the resource is deleted and recreated.
+	ClassImmutable ChangeClass = "immutable-field-change"
+)
+
+// ClassifiedChange is a resource change labelled with its ChangeClass.
+type ClassifiedChange struct {
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Namespace  string      `json:"namespace,omitempty"`
+	Name       string      `json:"name"`
+	Class      ChangeClass `json:"class"`
+	// ImmutableFields lists the changed field paths that cannot be updated
+	// in place. Only set for ClassImmutable.
+	ImmutableFields []string `json:"immutableFields,omitempty"`
+}
+
+// ID returns a human-readable identity such as "apps/v1 Deployment ns/name".
+func (c ClassifiedChange) ID() string {
+	return ResourceChange{APIVersion: c.APIVersion, Kind: c.Kind, Namespace: c.Namespace, Name: c.Name}.ID()
+}
+
+// Dangerous reports whether the change deletes a resource or requires it to
+// be recreated.
+func (c ClassifiedChange) Dangerous() bool {
+	return c.Class == ClassDelete || c.Class == ClassImmutable
+}
+
+// String formats the change for reports, e.g.
+// "immutable-field-change apps/v1 Deployment ns/name (spec.selector.matchLabels.app)".
+func (c ClassifiedChange) String() string {
+	s := fmt.Sprintf("%s %s", c.Class, c.ID())
+	if len(c.ImmutableFields) > 0 {
+		s += fmt.Sprintf(" (%s)", strings.Join(c.ImmutableFields, ", "))
+	}
+	return s
+}
+
+// GuardedKinds are the kinds whose deletion cascades into every object they
+// contain or back: all resources in a Namespace, all custom resources of a
+// CRD, and the data on a PersistentVolumeClaim's volume.
+var GuardedKinds = []string{"Namespace", "CustomResourceDefinition", "PersistentVolumeClaim"}
+
+// GuardedDeletion reports whether the change deletes a resource of one of
+// the GuardedKinds.
+func (c ClassifiedChange) GuardedDeletion() bool {
+	return c.Class == ClassDelete && slices.Contains(GuardedKinds, c.Kind)
+}
+
+// immutableFields lists, per kind, the field paths the API server refuses to
+// change on an existing object. A changed field matches when its path equals
+// an entry or lies underneath it.
+var immutableFields = map[string][]string{
+	"Deployment":  {"spec.selector"},
+	"ReplicaSet":  {"spec.selector"},
+	"DaemonSet":   {"spec.selector"},
+	"StatefulSet": {"spec.selector", "spec.serviceName", "spec.volumeClaimTemplates", "spec.podManagementPolicy"},
+	"Job":         {"spec.selector", "spec.template", "spec.completionMode"},
+	"Service":     {"spec.clusterIP", "spec.clusterIPs", "spec.ipFamilies"},
+	"PersistentVolumeClaim": {
+		"spec.accessModes", "spec.storageClassName", "spec.volumeMode",
+		"spec.volumeName", "spec.selector", "spec.dataSource", "spec.dataSourceRef",
+	},
+	"PersistentVolume":         {"spec.storageClassName"},
+	"StorageClass":             {"provisioner", "parameters", "reclaimPolicy", "volumeBindingMode"},
+	"RoleBinding":              {"roleRef"},
+	"ClusterRoleBinding":       {"roleRef"},
+	"CustomResourceDefinition": {"spec.group", "spec.names.plural", "spec.scope"},
+	"ConfigMap":                {"immutable"},
+	"Secret":                   {"type", "immutable"},
+}
+
+// classifyChanges labels each resource change. Modified resources are
+// classified as ClassImmutable when any changed field falls under the
+// immutable fields of their kind.
+func classifyChanges(changes []ResourceChange) []ClassifiedChange {
+	classified := make([]ClassifiedChange, 0, len(changes))
+	for _, rc := range changes {
+		c := ClassifiedChange{APIVersion: rc.APIVersion, Kind: rc.Kind, Namespace: rc.Namespace, Name: rc.Name}
+		switch rc.Type {
+		case ChangeAdded:
+			c.Class = ClassAdd
+		case ChangeRemoved:
+			c.Class = ClassDelete
+		default:
+			c.Class = ClassModify
+			for _, fc := range rc.Fields {
+				if isImmutableField(rc.Kind, fc.Path) && !slices.Contains(c.ImmutableFields, fc.Path) {
+					c.ImmutableFields = append(c.ImmutableFields, fc.Path)
+				}
+			}
+			if len(c.ImmutableFields) > 0 {
+				c.Class = ClassImmutable
+			}
+		}
+		classified = append(classified, c)
+	}
+	return classified
+}
+
+// isImmutableField reports whether path is, or is nested under, one of the
+// immutable fields of kind.
+func isImmutableField(kind, path string) bool {
+	for _, prefix := range immutableFields[kind] {
+		if path == prefix {
+			return true
+		}
+		if strings.HasPrefix(path, prefix) {
+			switch path[len(prefix)] {
+			case '.', '[':
+				return true
+			}
+		}
+	}
+	return false
+}
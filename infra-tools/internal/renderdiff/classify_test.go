@@ -0,0 +1,206 @@
+package renderdiff
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+const classifyBaseYAML = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: build
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: controller
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: build
+spec:
+  replicas: 1
+  volumeClaimTemplates:
+    - metadata:
+        name: data
+      spec:
+        resources:
+          requests:
+            storage: 1Gi
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+  namespace: build
+spec:
+  ports:
+    - port: 80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: build
+data:
+  level: info
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: old
+  namespace: build
+`
+
+const classifyHeadYAML = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: build
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: controller-v2
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: build
+spec:
+  replicas: 3
+  volumeClaimTemplates:
+    - metadata:
+        name: data
+      spec:
+        resources:
+          requests:
+            storage: 5Gi
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+  namespace: build
+spec:
+  clusterIP: None
+  ports:
+    - port: 80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: build
+data:
+  level: debug
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: new
+  namespace: build
+`
+
+func TestClassifyChanges(t *testing.T) {
+	g := NewWithT(t)
+
+	changes, err := SemanticDiff([]byte(classifyBaseYAML), []byte(classifyHeadYAML))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	byKind := make(map[string]ClassifiedChange)
+	for _, c := range classifyChanges(changes) {
+		byKind[c.Kind] = c
+	}
+
+	g.Expect(byKind["Deployment"].Class).To(Equal(ClassImmutable))
+	g.Expect(byKind["Deployment"].ImmutableFields).To(Equal([]string{"spec.selector.matchLabels.app"}))
+	g.Expect(byKind["StatefulSet"].Class).To(Equal(ClassImmutable))
+	g.Expect(byKind["StatefulSet"].ImmutableFields).To(Equal([]string{"spec.volumeClaimTemplates[0].spec.resources.requests.storage"}))
+	g.Expect(byKind["Service"].Class).To(Equal(ClassImmutable))
+	g.Expect(byKind["Service"].ImmutableFields).To(Equal([]string{"spec.clusterIP"}))
+	g.Expect(byKind["ConfigMap"].Class).To(Equal(ClassModify))
+	g.Expect(byKind["ConfigMap"].Dangerous()).To(BeFalse())
+	g.Expect(byKind["Secret"].Class).To(Equal(ClassDelete))
+	g.Expect(byKind["Secret"].Dangerous()).To(BeTrue())
+	g.Expect(byKind["ServiceAccount"].Class).To(Equal(ClassAdd))
+}
+
+func TestIsImmutableField(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(isImmutableField("Deployment", "spec.selector")).To(BeTrue())
+	g.Expect(isImmutableField("Deployment", "spec.selector.matchLabels.app")).To(BeTrue())
+	g.Expect(isImmutableField("Deployment", "spec.selectorX")).To(BeFalse())
+	g.Expect(isImmutableField("Deployment", "spec.template.metadata.labels.app")).To(BeFalse())
+	g.Expect(isImmutableField("StatefulSet", "spec.volumeClaimTemplates[name=data].spec")).To(BeTrue())
+	g.Expect(isImmutableField("ConfigMap", "spec.selector")).To(BeFalse())
+}
+
+func TestClassifiedChange_String(t *testing.T) {
+	g := NewWithT(t)
+
+	c := ClassifiedChange{APIVersion: "v1", Kind: "Service", Namespace: "ns", Name: "api", Class: ClassImmutable, ImmutableFields: []string{"spec.clusterIP"}}
+	g.Expect(c.String()).To(Equal("immutable-field-change v1 Service ns/api (spec.clusterIP)"))
+}
+
+func TestEngine_ClassifiesUnifiedDiffs(t *testing.T) {
+	g := NewWithT(t)
+
+	head := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true},
+		yamls: map[string][]byte{"components/foo/staging": []byte(classifyHeadYAML)},
+	}
+	base := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true},
+		yamls: map[string][]byte{"components/foo/staging": []byte(classifyBaseYAML)},
+	}
+
+	engine := NewEngine(head, base, 1)
+	affected := map[detector.Environment][]appset.ComponentPath{
+		detector.Staging: {{Path: "components/foo/staging"}},
+	}
+
+	result, err := engine.Run(context.Background(), affected)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Diffs).To(HaveLen(1))
+	g.Expect(result.Diffs[0].Resources).To(BeNil(), "unified diffs do not carry semantic resources")
+	g.Expect(result.Diffs[0].Changes).To(HaveLen(6))
+	g.Expect(result.Diffs[0].DangerousChanges()).To(HaveLen(4))
+	g.Expect(result.TotalDangerous).To(Equal(4))
+}
+
+func TestDiffResult_GuardedDeletions(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &DiffResult{Diffs: []ComponentDiff{
+		{Path: "components/a/staging", Env: detector.Staging, Changes: []ClassifiedChange{
+			{APIVersion: "v1", Kind: "Namespace", Name: "tenant", Class: ClassDelete},
+			{APIVersion: "v1", Kind: "Secret", Namespace: "tenant", Name: "s", Class: ClassDelete},
+			{APIVersion: "v1", Kind: "PersistentVolumeClaim", Namespace: "tenant", Name: "data", Class: ClassImmutable},
+		}},
+		{Path: "components/b/production", Env: detector.Production, Changes: []ClassifiedChange{
+			{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition", Name: "widgets.example.com", Class: ClassDelete},
+			{APIVersion: "v1", Kind: "PersistentVolumeClaim", Namespace: "db", Name: "data", Class: ClassDelete},
+		}},
+	}}
+
+	var ids []string
+	for _, gd := range result.GuardedDeletions() {
+		ids = append(ids, string(gd.Env)+" "+gd.Change.ID())
+	}
+	g.Expect(ids).To(Equal([]string{
+		"staging v1 Namespace tenant",
+		"production apiextensions.k8s.io/v1 CustomResourceDefinition widgets.example.com",
+		"production v1 PersistentVolumeClaim db/data",
+	}))
+}
@@ -8,7 +8,10 @@ import (
 	"github.com/pmezard/go-difflib/difflib"
 
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/deptree"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/policy"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/schema"
 )
 
 // ComponentDiff holds the diff result for a single (component, environment) pair.
@@ -29,6 +32,24 @@ type ComponentDiff struct {
 	Added int
 	// Removed is the number of lines removed.
 	Removed int
+	// Resources holds the per-resource changes when the engine runs with
+	// DiffFormatSemantic. Nil for unified diffs.
+	Resources []ResourceChange
+	// Changes classifies every changed resource as add, modify, delete or
+	// immutable-field-change. Populated whenever Diff is non-empty.
+	Changes []ClassifiedChange
+	// Violations lists schema errors found in HeadYAML when the engine runs
+	// with a validator.
+	Violations []schema.Violation
+	// Findings lists the policy rules violated by this component's changes
+	// when the engine runs with a policy.
+	Findings []policy.Finding
+	// Deprecations lists the deprecated kustomization fields the HEAD build
+	// uses, when the engine's RepoBuilders can list them.
+	Deprecations []Deprecation
+	// Suppressed lists the diff lines each normalize rule removed when the
+	// engine runs with a normalizer.
+	Suppressed []Suppression
 	// Error is non-empty when the kustomize build failed for this component.
 	// The component is still included in results so formatters can report it.
 	Error string
@@ -39,10 +60,14 @@ type ComponentDiff struct {
 
 // computeDiff populates the Diff, Added, and Removed fields from BaseYAML and HeadYAML.
 // Both sides are normalized (sorted by resource identity) before diffing to
-// minimize noise from resource reordering across kustomize builds.
-func (cd *ComponentDiff) computeDiff() error {
-	baseStr := string(normalizeYAML(cd.BaseYAML))
-	headStr := string(normalizeYAML(cd.HeadYAML))
+// minimize noise from resource reordering across kustomize builds, and
+// compact JSON embedded in string values is indented (see diffTexts).
+func (cd *ComponentDiff) computeDiff(format DiffFormat) error {
+	if format == DiffFormatSemantic {
+		return cd.computeSemanticDiff()
+	}
+
+	baseStr, headStr := diffTexts(cd.BaseYAML, cd.HeadYAML)
 
 	if baseStr == headStr {
 		return nil
@@ -66,11 +91,78 @@ func (cd *ComponentDiff) computeDiff() error {
 	return nil
 }
 
+// computeSemanticDiff populates Resources with per-resource changes and
+// renders them into Diff so that text-based output modes can display them.
+// Added and Removed count the + and - lines of that rendering.
+func (cd *ComponentDiff) computeSemanticDiff() error {
+	changes, err := SemanticDiff(cd.BaseYAML, cd.HeadYAML)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	cd.Resources = changes
+	cd.Diff = FormatSemantic(changes)
+	cd.Added, cd.Removed = countStats(cd.Diff)
+	return nil
+}
+
 // HasDiff returns true if this component has a non-empty diff.
 func (cd *ComponentDiff) HasDiff() bool {
 	return cd.Diff != ""
 }
 
+// DangerousChanges returns the deletions and immutable-field changes, which
+// make Argo CD prune a resource or fail the sync.
+func (cd *ComponentDiff) DangerousChanges() []ClassifiedChange {
+	var out []ClassifiedChange
+	for _, c := range cd.Changes {
+		if c.Dangerous() {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Denied returns the number of deny findings for this component.
+func (cd *ComponentDiff) Denied() int {
+	n := 0
+	for _, f := range cd.Findings {
+		if f.Severity == policy.SeverityDeny {
+			n++
+		}
+	}
+	return n
+}
+
+// NewDeprecations returns the number of deprecated kustomization fields
+// introduced on HEAD.
+func (cd *ComponentDiff) NewDeprecations() int {
+	n := 0
+	for _, d := range cd.Deprecations {
+		if d.New {
+			n++
+		}
+	}
+	return n
+}
+
+// Deprecation is a deprecated kustomization field used by a component's
+// HEAD build.
+type Deprecation struct {
+	deptree.Deprecation
+	// New is true when the base build does not use the field in that file.
+	New bool `json:"new"`
+}
+
+func (d Deprecation) String() string {
+	if d.New {
+		return d.Deprecation.String() + " (new)"
+	}
+	return d.Deprecation.String()
+}
+
 // FromComponentPath creates a ComponentDiff from an appset.ComponentPath and environment.
 func FromComponentPath(cp appset.ComponentPath, env detector.Environment) *ComponentDiff {
 	return &ComponentDiff{
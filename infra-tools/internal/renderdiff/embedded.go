@@ -0,0 +1,166 @@
+package renderdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+)
+
+// embeddedSep separates the path of a string holding a YAML or JSON document
+// from the path of a field inside that document, e.g.
+// data["rules.yaml"]::groups[name=api].rules[0].expr.
+const embeddedSep = "::"
+
+// diffStrings compares two differing strings. Strings that both hold a YAML
+// or JSON document, such as Prometheus rules or a controller config stored
+// in a ConfigMap, are diffed structurally with paths nested under the
+// string's own. Other multi-line strings carry a line diff instead of
+// being compared as one scalar.
+func diffStrings(path, base, head string, changes []FieldChange) []FieldChange {
+	if bv, ok := parseEmbedded(base); ok {
+		if hv, ok := parseEmbedded(head); ok {
+			// Documents that differ only in formatting or comments are
+			// reported as a line diff below rather than not at all.
+			if nested := diffValues("", bv, hv, nil); len(nested) > 0 {
+				for _, fc := range nested {
+					if fc.Path == "" {
+						fc.Path = path
+					} else {
+						fc.Path = path + embeddedSep + fc.Path
+					}
+					changes = append(changes, fc)
+				}
+				return changes
+			}
+		}
+	}
+	fc := FieldChange{Path: path, Type: ChangeModified, Old: renderValue(base), New: renderValue(head)}
+	if strings.Contains(base, "\n") || strings.Contains(head, "\n") {
+		fc.Diff = diffStringLines(base, head)
+	}
+	return append(changes, fc)
+}
+
+// parseEmbedded decodes s when it holds a single YAML or JSON mapping or
+// list. Only strings spanning several lines or starting with { or [ are
+// considered, so that a one-line value such as "a: b" stays a string.
+func parseEmbedded(s string) (interface{}, bool) {
+	trimmed := strings.TrimSpace(s)
+	if !strings.Contains(trimmed, "\n") && !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return nil, false
+	}
+	decoder := yaml.NewDecoder(strings.NewReader(s))
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, false
+	}
+	var next interface{}
+	if err := decoder.Decode(&next); !errors.Is(err, io.EOF) {
+		return nil, false
+	}
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
+// diffStringLines returns the hunks of a unified diff between the lines of
+// two strings, without file headers.
+func diffStringLines(base, head string) string {
+	text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:       difflib.SplitLines(base),
+		B:       difflib.SplitLines(head),
+		Context: 2,
+	})
+	if err != nil {
+		// Writing to a strings.Builder does not fail.
+		return ""
+	}
+	return text
+}
+
+// diffTexts returns the texts computeDiff line-diffs for two renders: their
+// documents sorted by identity, with the string values that hold compact,
+// single-line JSON documents rewritten as indented JSON literal blocks, so
+// that a change inside one diffs as the lines that changed rather than as
+// the whole value. Both renders are re-encoded when either had such values,
+// so that they keep the same formatting.
+func diffTexts(base, head []byte) (string, string) {
+	base, head = NormalizeYAML(base), NormalizeYAML(head)
+	baseDocs, err := decodeDocs(base)
+	if err != nil {
+		return string(base), string(head)
+	}
+	headDocs, err := decodeDocs(head)
+	if err != nil {
+		return string(base), string(head)
+	}
+	expanded := false
+	for _, doc := range append(slices.Clip(baseDocs), headDocs...) {
+		if expandScalars(doc) {
+			expanded = true
+		}
+	}
+	if !expanded {
+		return string(base), string(head)
+	}
+	return encodeNormalized(baseDocs), encodeNormalized(headDocs)
+}
+
+// encodeNormalized encodes documents with the formatting NormalizeYAML uses.
+func encodeNormalized(docs []*yaml.Node) string {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	for _, doc := range docs {
+		// Decoded nodes always re-encode.
+		_ = encoder.Encode(doc)
+	}
+	_ = encoder.Close()
+	return buf.String()
+}
+
+// expandScalars indents the compact JSON string values under n and reports
+// whether it found any.
+func expandScalars(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.ShortTag() != "!!str" || strings.Contains(n.Value, "\n") {
+			return false
+		}
+		trimmed := strings.TrimSpace(n.Value)
+		if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+			return false
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(trimmed), "", "  "); err != nil || !bytes.Contains(buf.Bytes(), []byte("\n")) {
+			return false
+		}
+		n.Value = buf.String()
+		n.Style = yaml.LiteralStyle
+		return true
+	case yaml.MappingNode:
+		expanded := false
+		for i := 1; i < len(n.Content); i += 2 {
+			if expandScalars(n.Content[i]) {
+				expanded = true
+			}
+		}
+		return expanded
+	case yaml.SequenceNode, yaml.DocumentNode:
+		expanded := false
+		for _, child := range n.Content {
+			if expandScalars(child) {
+				expanded = true
+			}
+		}
+		return expanded
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package renderdiff
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func configMapWithRules(expr string) string {
+	return `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: rules
+data:
+  rules.yaml: |
+    groups:
+      - name: api
+        rules:
+          - alert: APIDown
+            expr: ` + expr + `
+            for: 5m
+`
+}
+
+func TestSemanticDiff_EmbeddedYAML(t *testing.T) {
+	g := NewWithT(t)
+
+	changes, err := SemanticDiff([]byte(configMapWithRules("up == 0")), []byte(configMapWithRules("up{job=\"api\"} == 0")))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changes).To(HaveLen(1))
+	g.Expect(changes[0].Fields).To(Equal([]FieldChange{
+		{Path: `data["rules.yaml"]::groups[name=api].rules[0].expr`, Type: ChangeModified, Old: "up == 0", New: `up{job="api"} == 0`},
+	}))
+}
+
+func TestSemanticDiff_EmbeddedJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	annotated := func(replicas string) []byte {
+		return []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  annotations:
+    example.com/config: '{"spec":{"replicas":` + replicas + `,"name":"x"}}'
+`)
+	}
+	changes, err := SemanticDiff(annotated("1"), annotated("2"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changes).To(HaveLen(1))
+	g.Expect(changes[0].Fields).To(Equal([]FieldChange{
+		{Path: `metadata.annotations["example.com/config"]::spec.replicas`, Type: ChangeModified, Old: "1", New: "2"},
+	}))
+}
+
+func TestSemanticDiff_MultilineString(t *testing.T) {
+	g := NewWithT(t)
+
+	script := func(line string) []byte {
+		return []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: scripts
+data:
+  run.sh: |
+    #!/bin/sh
+    set -e
+    ` + line + `
+    exit 0
+`)
+	}
+	changes, err := SemanticDiff(script("echo one"), script("echo two"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changes).To(HaveLen(1))
+	g.Expect(changes[0].Fields).To(HaveLen(1))
+	fc := changes[0].Fields[0]
+	g.Expect(fc.Path).To(Equal(`data["run.sh"]`))
+	g.Expect(fc.Diff).To(Equal("@@ -1,5 +1,5 @@\n #!/bin/sh\n set -e\n-echo one\n+echo two\n exit 0\n \n"))
+
+	text := FormatSemantic(changes)
+	g.Expect(text).To(Equal("~ v1 ConfigMap scripts\n" +
+		"~   data[\"run.sh\"]:\n" +
+		"      @@ -1,5 +1,5 @@\n" +
+		"        #!/bin/sh\n" +
+		"        set -e\n" +
+		"-       echo one\n" +
+		"+       echo two\n" +
+		"        exit 0\n" +
+		"        \n"))
+	added, removed := countStats(text)
+	g.Expect(added).To(Equal(1))
+	g.Expect(removed).To(Equal(1))
+}
+
+func TestSemanticDiff_EmbeddedFormattingOnly(t *testing.T) {
+	g := NewWithT(t)
+
+	base := configMapWithRules("up == 0")
+	head := base + "    # alert when the API is down\n"
+	changes, err := SemanticDiff([]byte(base), []byte(head))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changes).To(HaveLen(1))
+	g.Expect(changes[0].Fields).To(HaveLen(1))
+	g.Expect(changes[0].Fields[0].Path).To(Equal(`data["rules.yaml"]`))
+	g.Expect(changes[0].Fields[0].Diff).To(ContainSubstring("+# alert when the API is down"))
+}
+
+func TestParseEmbedded(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, s := range []string{"a: b", "plain text", "line one\nline two", "{{ .Values.x }}", "[unterminated", "a: 1\n---\nb: 2\n", "42"} {
+		_, ok := parseEmbedded(s)
+		g.Expect(ok).To(BeFalse(), s)
+	}
+	for _, s := range []string{`{"a": 1}`, "[1, 2]", "a: 1\nb: 2\n", "- x\n- y\n"} {
+		_, ok := parseEmbedded(s)
+		g.Expect(ok).To(BeTrue(), s)
+	}
+}
+
+func TestComputeDiff_ExpandsEmbeddedJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	config := func(level string) []byte {
+		return []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  config.json: '{"log":{"level":"` + level + `","format":"json"},"port":8080}'
+`)
+	}
+	cd := &ComponentDiff{Path: "components/foo/staging", BaseYAML: config("info"), HeadYAML: config("debug")}
+	g.Expect(cd.computeDiff(DiffFormatUnified)).To(Succeed())
+	g.Expect(cd.Added).To(Equal(1))
+	g.Expect(cd.Removed).To(Equal(1))
+	g.Expect(cd.Diff).To(ContainSubstring(`-            "level": "info",`))
+	g.Expect(cd.Diff).To(ContainSubstring(`+            "level": "debug",`))
+}
+
+func TestComputeDiff_ExpandsOneSide(t *testing.T) {
+	g := NewWithT(t)
+
+	// Only the head has embedded JSON, so both renders must be re-encoded the
+	// same way for the unchanged lines to match.
+	cd := &ComponentDiff{
+		Path: "components/foo/staging",
+		BaseYAML: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  config: none
+  list:
+  - a
+`),
+		HeadYAML: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  config: '{"a":1}'
+  list:
+  - a
+`),
+	}
+	g.Expect(cd.computeDiff(DiffFormatUnified)).To(Succeed())
+	g.Expect(cd.Removed).To(Equal(1))
+	g.Expect(cd.Added).To(Equal(4))
+}
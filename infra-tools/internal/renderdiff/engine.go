@@ -5,11 +5,17 @@ import (
 	"fmt"
 	"log/slog"
 	"runtime"
+	"slices"
+	"strings"
+	"sync"
 
 	"golang.org/x/sync/errgroup"
 
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/deptree"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/policy"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/schema"
 )
 
 // RepoBuilder abstracts the ability to check directory existence and build
@@ -19,18 +25,94 @@ type RepoBuilder interface {
 	BuildKustomization(rel string) ([]byte, error)
 }
 
+// DeprecationLister is implemented by RepoBuilders that can list the
+// deprecated kustomization fields a component's build uses.
+type DeprecationLister interface {
+	Deprecations(rel string) ([]deptree.Deprecation, error)
+}
+
+// ManifestValidator checks rendered manifests against resource schemas.
+// AddCRDs is called with every HEAD render before the first Validate, so
+// that custom resources are checked against CRDs any component renders.
+type ManifestValidator interface {
+	AddCRDs(rendered []byte)
+	Validate(rendered []byte) ([]schema.Violation, error)
+}
+
+// PolicyEvaluator checks the resources that changed between two renders of
+// a component against a set of rules.
+type PolicyEvaluator interface {
+	Evaluate(env detector.Environment, base, head []byte) ([]policy.Finding, error)
+}
+
 // Engine computes kustomize render diffs for affected component paths.
 type Engine struct {
 	head        RepoBuilder
 	base        RepoBuilder
 	affected    int
 	concurrency int
+	format      DiffFormat
+	validator   ManifestValidator // optional; nil disables validation
+	policy      PolicyEvaluator   // optional; nil disables policy checks
+	redactor    *Redactor         // optional; nil disables redaction
+	normalizer  *Normalizer       // optional; nil disables normalize rules
+}
+
+// EngineOption configures optional Engine behaviour.
+type EngineOption func(*Engine)
+
+// WithDiffFormat selects how component diffs are computed. The default is
+// DiffFormatUnified.
+func WithDiffFormat(format DiffFormat) EngineOption {
+	return func(e *Engine) {
+		e.format = format
+	}
+}
+
+// WithValidator enables schema validation of every HEAD render. Components
+// with violations are reported even when their render did not change.
+// Validation waits until every component has been built, so that the CRDs
+// all of them render are known; until then no diff is sent.
+func WithValidator(v ManifestValidator) EngineOption {
+	return func(e *Engine) {
+		e.validator = v
+	}
+}
+
+// WithPolicy enables policy evaluation of every component whose render
+// changed. Components with findings are always included in the result.
+func WithPolicy(p PolicyEvaluator) EngineOption {
+	return func(e *Engine) {
+		e.policy = p
+	}
+}
+
+// WithRedactor replaces sensitive values in both renders before they are
+// diffed, so neither the diff nor BaseYAML and HeadYAML reveal them. Schema
+// validation and policy evaluation still see the values.
+func WithRedactor(r *Redactor) EngineOption {
+	return func(e *Engine) {
+		e.redactor = r
+	}
+}
+
+// WithNormalizer applies normalize rules to both renders before they are
+// diffed, and reports the diff lines each rule suppressed. Like redaction,
+// it does not affect schema validation and policy evaluation.
+func WithNormalizer(n *Normalizer) EngineOption {
+	return func(e *Engine) {
+		e.normalizer = n
+	}
 }
 
 // NewEngine creates an Engine with the given head and base repo references.
 // Concurrency defaults to runtime.NumCPU() if zero.
-func NewEngine(head, base RepoBuilder, affected int) *Engine {
-	return &Engine{head: head, base: base, affected: affected, concurrency: runtime.NumCPU()}
+func NewEngine(head, base RepoBuilder, affected int, opts ...EngineOption) *Engine {
+	e := &Engine{head: head, base: base, affected: affected, concurrency: runtime.NumCPU(), format: DiffFormatUnified}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // DiffResult holds the complete output of a render-diff run.
@@ -41,6 +123,67 @@ type DiffResult struct {
 	TotalAdded int
 	// TotalRemoved is the aggregate lines removed across all diffs.
 	TotalRemoved int
+	// TotalViolations is the aggregate number of schema violations.
+	TotalViolations int
+	// TotalDangerous is the aggregate number of resource deletions and
+	// immutable-field changes.
+	TotalDangerous int
+	// TotalDenied is the aggregate number of deny policy findings.
+	TotalDenied int
+	// TotalWarned is the aggregate number of warn policy findings.
+	TotalWarned int
+	// Filtered is the number of jobs excluded by the caller's selection
+	// filters before the engine ran. The engine never sets it.
+	Filtered int
+	// Uncommitted lists the changed files the caller took from the working
+	// tree rather than from commits. The engine never sets it.
+	Uncommitted []string
+	// Suppressed totals the diff lines each normalize rule removed, most
+	// first, including those of components left without any diff.
+	Suppressed []Suppression
+	// Skipped lists the affected components that were not rendered because
+	// their directory holds no kustomization. They are not part of Diffs or
+	// the totals; SkipOutput is set and Error says why.
+	Skipped []ComponentDiff
+}
+
+// GuardedDeletion locates a deletion of one of the GuardedKinds.
+type GuardedDeletion struct {
+	Path   string
+	Env    detector.Environment
+	Change ClassifiedChange
+}
+
+// GuardedDeletions returns every deletion of a Namespace, CRD or PVC across
+// all components, in component order.
+func (r *DiffResult) GuardedDeletions() []GuardedDeletion {
+	var out []GuardedDeletion
+	for _, d := range r.Diffs {
+		for _, c := range d.Changes {
+			if c.GuardedDeletion() {
+				out = append(out, GuardedDeletion{Path: d.Path, Env: d.Env, Change: c})
+			}
+		}
+	}
+	return out
+}
+
+// NewDeprecations returns the deprecated kustomization fields introduced on
+// HEAD across all components, each once even when several components share
+// the kustomization file, sorted by file.
+func (r *DiffResult) NewDeprecations() []deptree.Deprecation {
+	seen := make(map[deptree.Deprecation]bool)
+	var out []deptree.Deprecation
+	for _, d := range r.Diffs {
+		for _, dep := range d.Deprecations {
+			if dep.New && !seen[dep.Deprecation] {
+				seen[dep.Deprecation] = true
+				out = append(out, dep.Deprecation)
+			}
+		}
+	}
+	slices.SortStableFunc(out, func(a, b deptree.Deprecation) int { return strings.Compare(a.File, b.File) })
+	return out
 }
 
 // Run builds each affected component path on both refs in parallel, computes
@@ -82,9 +225,26 @@ func (e *Engine) RunProgressive(ctx context.Context, affected map[detector.Envir
 				result.TotalAdded += cd.Added
 				result.TotalRemoved += cd.Removed
 			}
+			result.TotalViolations += len(cd.Violations)
+			result.TotalDangerous += len(cd.DangerousChanges())
+			denied := cd.Denied()
+			result.TotalDenied += denied
+			result.TotalWarned += len(cd.Findings) - denied
 		}
 	}()
 
+	// Suppressions are totalled here rather than by the collector, since
+	// components whose whole diff was suppressed are never sent to it.
+	var mu sync.Mutex
+	suppressed := make(map[string]int)
+	var skipped []ComponentDiff
+	// Components waiting for validation, with their unredacted HEAD render.
+	type validation struct {
+		cd   *ComponentDiff
+		head []byte
+	}
+	var pending []validation
+
 	g, ctx := errgroup.WithContext(ctx)
 	g.SetLimit(e.concurrency)
 
@@ -102,6 +262,9 @@ func (e *Engine) RunProgressive(ctx context.Context, affected map[detector.Envir
 						slog.Warn("skipping non-kustomization directory",
 							"path", cp.Path, "env", env, "err", err)
 						cd.SkipOutput = true
+						mu.Lock()
+						skipped = append(skipped, *cd)
+						mu.Unlock()
 						return nil
 					}
 					slog.Warn("build error for component",
@@ -110,11 +273,39 @@ func (e *Engine) RunProgressive(ctx context.Context, affected map[detector.Envir
 					return nil
 				}
 
-				if err := cd.computeDiff(); err != nil {
+				e.checkDeprecations(cd)
+				base, head := cd.BaseYAML, cd.HeadYAML
+				e.normalize(cd)
+				if len(cd.Suppressed) > 0 {
+					mu.Lock()
+					for _, s := range cd.Suppressed {
+						suppressed[s.Rule] += s.Lines
+					}
+					mu.Unlock()
+				}
+				if err := e.redact(cd); err != nil {
+					slog.Warn("redaction error for component",
+						"path", cp.Path, "env", env, "err", err)
+					cd.Error = err.Error()
+					results <- *cd
+					return nil
+				}
+
+				if err := cd.computeDiff(e.format); err != nil {
 					return fmt.Errorf("computing diff for %s (%s): %w", cp.Path, env, err)
 				}
 
-				if cd.HasDiff() {
+				e.classify(cd)
+				e.evaluatePolicy(cd, base, head)
+
+				if e.validator != nil {
+					mu.Lock()
+					e.validator.AddCRDs(head)
+					pending = append(pending, validation{cd, head})
+					mu.Unlock()
+					return nil
+				}
+				if cd.reported() {
 					results <- *cd
 				}
 				return nil
@@ -127,8 +318,25 @@ func (e *Engine) RunProgressive(ctx context.Context, affected map[detector.Envir
 		<-done
 		return nil, err
 	}
+	for _, v := range pending {
+		e.validate(v.cd, v.head)
+		if v.cd.reported() {
+			results <- *v.cd
+		}
+	}
 	close(results)
 	<-done
+	result.Skipped = skipped
+	SortDiffs(result.Skipped)
+	for rule, lines := range suppressed {
+		result.Suppressed = append(result.Suppressed, Suppression{Rule: rule, Lines: lines})
+	}
+	slices.SortFunc(result.Suppressed, func(a, b Suppression) int {
+		if a.Lines != b.Lines {
+			return b.Lines - a.Lines
+		}
+		return strings.Compare(a.Rule, b.Rule)
+	})
 	return &result, nil
 }
 
@@ -166,3 +374,120 @@ func (e *Engine) buildPair(cd *ComponentDiff) error {
 
 	return nil
 }
+
+// checkDeprecations stores the deprecated kustomization fields cd's HEAD
+// build uses on cd, marking those its base build does not use as new. It
+// does nothing unless both refs are DeprecationLister implementations. Errors
+// are logged and otherwise ignored, like validator errors, since the builds
+// themselves succeeded.
+func (e *Engine) checkDeprecations(cd *ComponentDiff) {
+	head, ok := e.head.(DeprecationLister)
+	if !ok || cd.HeadYAML == nil {
+		return
+	}
+	base, ok := e.base.(DeprecationLister)
+	if !ok {
+		return
+	}
+	headDeps, err := head.Deprecations(cd.Path)
+	if err != nil {
+		slog.Warn("deprecation check skipped", "path", cd.Path, "env", cd.Env, "err", err)
+		return
+	}
+	existing := make(map[deptree.Deprecation]bool)
+	if cd.BaseYAML != nil {
+		baseDeps, err := base.Deprecations(cd.Path)
+		if err != nil {
+			slog.Warn("deprecation check skipped", "path", cd.Path, "env", cd.Env, "err", err)
+			return
+		}
+		for _, d := range baseDeps {
+			existing[d] = true
+		}
+	}
+	for _, d := range headDeps {
+		cd.Deprecations = append(cd.Deprecations, Deprecation{Deprecation: d, New: !existing[d]})
+	}
+}
+
+// normalize applies the normalize rules to both renders of cd.
+func (e *Engine) normalize(cd *ComponentDiff) {
+	if e.normalizer == nil {
+		return
+	}
+	cd.BaseYAML, cd.HeadYAML, cd.Suppressed = e.normalizer.Normalize(cd.BaseYAML, cd.HeadYAML)
+}
+
+// redact replaces the sensitive values in both renders of cd. On error, both
+// renders are dropped so that nothing unredacted is reported.
+func (e *Engine) redact(cd *ComponentDiff) error {
+	if e.redactor == nil {
+		return nil
+	}
+	base, err := e.redactor.Redact(cd.BaseYAML)
+	if err == nil {
+		cd.HeadYAML, err = e.redactor.Redact(cd.HeadYAML)
+	}
+	if err != nil {
+		cd.BaseYAML, cd.HeadYAML = nil, nil
+		return fmt.Errorf("redacting %s: %w", cd.Path, err)
+	}
+	cd.BaseYAML = base
+	return nil
+}
+
+// classify labels each changed resource with its ChangeClass. Semantic diffs
+// already carry the resource changes; unified diffs are re-parsed, and a
+// parse failure only skips classification since the text diff still stands.
+func (e *Engine) classify(cd *ComponentDiff) {
+	if !cd.HasDiff() {
+		return
+	}
+	changes := cd.Resources
+	if e.format != DiffFormatSemantic {
+		var err error
+		changes, err = SemanticDiff(cd.BaseYAML, cd.HeadYAML)
+		if err != nil {
+			slog.Warn("change classification skipped", "path", cd.Path, "env", cd.Env, "err", err)
+			return
+		}
+	}
+	cd.Changes = classifyChanges(changes)
+}
+
+// reported reports whether cd belongs in the result: its render changed, or
+// it has violations, findings or new deprecations to show.
+func (cd *ComponentDiff) reported() bool {
+	return cd.HasDiff() || len(cd.Violations) > 0 || len(cd.Findings) > 0 || cd.NewDeprecations() > 0
+}
+
+// validate runs the configured validator against the unredacted HEAD render
+// and stores any violations on cd. Validator errors (unparseable YAML) are
+// logged and otherwise ignored, since the diff itself is still meaningful.
+func (e *Engine) validate(cd *ComponentDiff, head []byte) {
+	if e.validator == nil || head == nil {
+		return
+	}
+	violations, err := e.validator.Validate(head)
+	if err != nil {
+		slog.Warn("schema validation skipped", "path", cd.Path, "env", cd.Env, "err", err)
+		return
+	}
+	cd.Violations = violations
+}
+
+// evaluatePolicy runs the configured policy against both unredacted renders
+// and stores any findings on cd. Components without a diff are skipped since rules only
+// fire on changed resources. Evaluation errors are logged and otherwise
+// ignored, like validator errors.
+func (e *Engine) evaluatePolicy(cd *ComponentDiff, base, head []byte) {
+	if e.policy == nil || !cd.HasDiff() {
+		return
+	}
+	findings, err := e.policy.Evaluate(cd.Env, base, head)
+	if err != nil {
+		slog.Warn("policy evaluation skipped", "path", cd.Path, "env", cd.Env, "err", err)
+		return
+	}
+	cd.Findings = findings
+}
@@ -8,7 +8,10 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/deptree"
 	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/policy"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/schema"
 )
 
 // fakeBuilder implements RepoBuilder for testing.
@@ -161,6 +164,10 @@ func TestEngine_NonKustomizationError_ExcludedFromDiffs(t *testing.T) {
 	result, err := engine.Run(context.Background(), affected)
 	g.Expect(err).NotTo(HaveOccurred())
 	g.Expect(result.Diffs).To(BeEmpty(), "non-kustomization errors should be excluded from Diffs")
+	g.Expect(result.Skipped).To(HaveLen(1))
+	g.Expect(result.Skipped[0].Path).To(Equal("components/plain/staging"))
+	g.Expect(result.Skipped[0].Env).To(Equal(detector.Staging))
+	g.Expect(result.Skipped[0].SkipOutput).To(BeTrue())
 }
 
 func TestEngine_MixedErrors_OnlyGenuineInDiffs(t *testing.T) {
@@ -226,6 +233,99 @@ func TestEngine_NoEffectiveChange(t *testing.T) {
 	g.Expect(result.Diffs).To(BeEmpty()) // identical YAML → omitted
 }
 
+// fakeValidator implements ManifestValidator for testing.
+type fakeValidator struct {
+	violations []schema.Violation
+	rendered   []byte
+	crds       [][]byte
+}
+
+func (f *fakeValidator) AddCRDs(rendered []byte) {
+	f.crds = append(f.crds, rendered)
+}
+
+func (f *fakeValidator) Validate(rendered []byte) ([]schema.Violation, error) {
+	f.rendered = rendered
+	return f.violations, nil
+}
+
+func TestEngine_ViolationsReportedWithoutDiff(t *testing.T) {
+	g := NewWithT(t)
+
+	sameYAML := []byte("apiVersion: v1\nkind: ConfigMap\ndata:\n  key: same\n")
+	head := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true},
+		yamls: map[string][]byte{"components/foo/staging": sameYAML},
+	}
+	base := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true},
+		yamls: map[string][]byte{"components/foo/staging": sameYAML},
+	}
+	validator := &fakeValidator{violations: []schema.Violation{
+		{Resource: "v1 ConfigMap foo", Path: "data.key", Message: "unknown field"},
+	}}
+
+	engine := NewEngine(head, base, 2, WithValidator(validator))
+	affected := map[detector.Environment][]appset.ComponentPath{
+		detector.Staging: {{Path: "components/foo/staging"}},
+	}
+
+	result, err := engine.Run(context.Background(), affected)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Diffs).To(HaveLen(1), "violations alone should keep the component in the result")
+	g.Expect(result.Diffs[0].HasDiff()).To(BeFalse())
+	g.Expect(result.Diffs[0].Violations).To(Equal(validator.violations))
+	g.Expect(result.TotalViolations).To(Equal(1))
+}
+
+// fakePolicy implements PolicyEvaluator for testing.
+type fakePolicy struct {
+	findings []policy.Finding
+	calls    int
+}
+
+func (f *fakePolicy) Evaluate(env detector.Environment, base, head []byte) ([]policy.Finding, error) {
+	f.calls++
+	return f.findings, nil
+}
+
+func TestEngine_PolicyFindings(t *testing.T) {
+	g := NewWithT(t)
+
+	head := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true, "components/bar/staging": true},
+		yamls: map[string][]byte{
+			"components/foo/staging": []byte("apiVersion: v1\nkind: ConfigMap\ndata:\n  key: new\n"),
+			"components/bar/staging": []byte("apiVersion: v1\nkind: ConfigMap\n"),
+		},
+	}
+	base := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true, "components/bar/staging": true},
+		yamls: map[string][]byte{
+			"components/foo/staging": []byte("apiVersion: v1\nkind: ConfigMap\ndata:\n  key: old\n"),
+			"components/bar/staging": []byte("apiVersion: v1\nkind: ConfigMap\n"),
+		},
+	}
+	p := &fakePolicy{findings: []policy.Finding{
+		{Rule: "a", Severity: policy.SeverityDeny, Resource: "v1 ConfigMap x", Message: "no"},
+		{Rule: "b", Severity: policy.SeverityWarn, Resource: "v1 ConfigMap x", Message: "careful"},
+	}}
+
+	engine := NewEngine(head, base, 2, WithPolicy(p))
+	affected := map[detector.Environment][]appset.ComponentPath{
+		detector.Staging: {{Path: "components/foo/staging"}, {Path: "components/bar/staging"}},
+	}
+
+	result, err := engine.Run(context.Background(), affected)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(p.calls).To(Equal(1), "unchanged components are not evaluated")
+	g.Expect(result.Diffs).To(HaveLen(1))
+	g.Expect(result.Diffs[0].Findings).To(Equal(p.findings))
+	g.Expect(result.Diffs[0].Denied()).To(Equal(1))
+	g.Expect(result.TotalDenied).To(Equal(1))
+	g.Expect(result.TotalWarned).To(Equal(1))
+}
+
 func TestEngine_EmptyInput(t *testing.T) {
 	g := NewWithT(t)
 
@@ -288,3 +388,177 @@ func TestCountStats(t *testing.T) {
 	g.Expect(added).To(Equal(2))
 	g.Expect(removed).To(Equal(1))
 }
+
+func TestEngine_Redaction(t *testing.T) {
+	g := NewWithT(t)
+
+	head := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true},
+		yamls: map[string][]byte{"components/foo/staging": []byte("apiVersion: v1\nkind: Secret\ndata:\n  key: bmV3\n")},
+	}
+	base := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true},
+		yamls: map[string][]byte{"components/foo/staging": []byte("apiVersion: v1\nkind: Secret\ndata:\n  key: b2xk\n")},
+	}
+	validator := &fakeValidator{}
+	r, err := NewRedactor()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	engine := NewEngine(head, base, 2, WithRedactor(r), WithValidator(validator))
+	affected := map[detector.Environment][]appset.ComponentPath{
+		detector.Staging: {{Path: "components/foo/staging"}},
+	}
+
+	result, err := engine.Run(context.Background(), affected)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Diffs).To(HaveLen(1))
+	cd := result.Diffs[0]
+	g.Expect(cd.Added).To(Equal(1))
+	g.Expect(cd.Removed).To(Equal(1))
+	g.Expect(cd.Diff).To(ContainSubstring("+  key: <redacted hmac:"))
+	for _, s := range []string{cd.Diff, string(cd.BaseYAML), string(cd.HeadYAML)} {
+		g.Expect(s).NotTo(ContainSubstring("bmV3"))
+		g.Expect(s).NotTo(ContainSubstring("b2xk"))
+	}
+	g.Expect(string(validator.rendered)).To(ContainSubstring("bmV3"), "validation sees the unredacted render")
+}
+
+func TestEngine_Normalization(t *testing.T) {
+	g := NewWithT(t)
+
+	configMap := func(hash, level string) []byte {
+		return []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings-" + hash + "\ndata:\n  level: " + level + "\n")
+	}
+	head := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true, "components/bar/staging": true},
+		yamls: map[string][]byte{
+			"components/foo/staging": configMap("gh2t96b4m7", "info"),
+			"components/bar/staging": configMap("gh2t96b4m7", "debug"),
+		},
+	}
+	base := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true, "components/bar/staging": true},
+		yamls: map[string][]byte{
+			"components/foo/staging": configMap("5m8f7bd9kc", "debug"),
+			"components/bar/staging": configMap("5m8f7bd9kc", "debug"),
+		},
+	}
+	n, err := NewNormalizer(NormalizeRule{Name: "hashes", StripHashSuffixes: true})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	engine := NewEngine(head, base, 2, WithNormalizer(n))
+	affected := map[detector.Environment][]appset.ComponentPath{
+		detector.Staging: {{Path: "components/foo/staging"}, {Path: "components/bar/staging"}},
+	}
+
+	result, err := engine.Run(context.Background(), affected)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Diffs).To(HaveLen(1), "bar only differed in its hash suffix")
+	cd := result.Diffs[0]
+	g.Expect(cd.Path).To(Equal("components/foo/staging"))
+	g.Expect(cd.Added).To(Equal(1))
+	g.Expect(cd.Removed).To(Equal(1))
+	g.Expect(cd.Suppressed).To(Equal([]Suppression{{Rule: "hashes", Lines: 2}}))
+	g.Expect(result.Suppressed).To(Equal([]Suppression{{Rule: "hashes", Lines: 4}}))
+}
+
+// deprecationBuilder is a fakeBuilder that also lists deprecated fields.
+type deprecationBuilder struct {
+	fakeBuilder
+	deprecations map[string][]deptree.Deprecation
+}
+
+func (f *deprecationBuilder) Deprecations(rel string) ([]deptree.Deprecation, error) {
+	return f.deprecations[rel], nil
+}
+
+func TestEngine_Deprecations(t *testing.T) {
+	g := NewWithT(t)
+
+	configMap := []byte("apiVersion: v1\nkind: ConfigMap\ndata:\n  key: value\n")
+	commonLabels := deptree.Deprecation{File: "components/foo/base/kustomization.yaml", Field: "commonLabels", Replacement: "labels"}
+	patches := deptree.Deprecation{File: "components/foo/staging/kustomization.yaml", Field: "patchesStrategicMerge", Replacement: "patches"}
+	head := &deprecationBuilder{
+		fakeBuilder: fakeBuilder{
+			exist: map[string]bool{"components/foo/staging": true, "components/bar/staging": true},
+			yamls: map[string][]byte{"components/foo/staging": configMap, "components/bar/staging": configMap},
+		},
+		deprecations: map[string][]deptree.Deprecation{
+			"components/foo/staging": {commonLabels, patches},
+			"components/bar/staging": {commonLabels},
+		},
+	}
+	base := &deprecationBuilder{
+		fakeBuilder: fakeBuilder{
+			exist: map[string]bool{"components/foo/staging": true, "components/bar/staging": true},
+			yamls: map[string][]byte{"components/foo/staging": configMap, "components/bar/staging": configMap},
+		},
+		deprecations: map[string][]deptree.Deprecation{
+			"components/foo/staging": {commonLabels},
+			"components/bar/staging": {commonLabels},
+		},
+	}
+
+	engine := NewEngine(head, base, 2)
+	affected := map[detector.Environment][]appset.ComponentPath{
+		detector.Staging: {{Path: "components/foo/staging"}, {Path: "components/bar/staging"}},
+	}
+
+	result, err := engine.Run(context.Background(), affected)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Diffs).To(HaveLen(1), "bar has no diff and no new deprecations")
+	cd := result.Diffs[0]
+	g.Expect(cd.Path).To(Equal("components/foo/staging"))
+	g.Expect(cd.HasDiff()).To(BeFalse())
+	g.Expect(cd.Deprecations).To(Equal([]Deprecation{{Deprecation: commonLabels}, {Deprecation: patches, New: true}}))
+	g.Expect(cd.Deprecations[1].String()).To(HaveSuffix(" (new)"))
+	g.Expect(result.NewDeprecations()).To(Equal([]deptree.Deprecation{patches}))
+	g.Expect(DeprecationNote(result)).To(ContainSubstring("**1 new deprecated kustomization fields.**"))
+	g.Expect(DeprecationNote(result)).To(ContainSubstring("- `components/foo/staging/kustomization.yaml`: `patchesStrategicMerge` is deprecated, use `patches` instead"))
+}
+
+func TestEngine_ValidatesAgainstCRDsOfOtherComponents(t *testing.T) {
+	g := NewWithT(t)
+
+	crd := []byte(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+  versions:
+    - name: v1
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              properties:
+                size:
+                  type: integer
+`)
+	widget := []byte("apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\nspec:\n  size: large\n")
+	head := &fakeBuilder{
+		exist: map[string]bool{"components/crds/staging": true, "components/widget/staging": true},
+		yamls: map[string][]byte{"components/crds/staging": crd, "components/widget/staging": widget},
+	}
+	base := &fakeBuilder{
+		exist: map[string]bool{"components/crds/staging": true, "components/widget/staging": true},
+		yamls: map[string][]byte{"components/crds/staging": crd, "components/widget/staging": widget},
+	}
+
+	engine := NewEngine(head, base, 2, WithValidator(schema.New()))
+	affected := map[detector.Environment][]appset.ComponentPath{
+		detector.Staging: {{Path: "components/widget/staging"}, {Path: "components/crds/staging"}},
+	}
+
+	result, err := engine.Run(context.Background(), affected)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Diffs).To(HaveLen(1))
+	g.Expect(result.Diffs[0].Path).To(Equal("components/widget/staging"))
+	g.Expect(result.Diffs[0].Violations).To(HaveLen(1))
+	g.Expect(result.TotalViolations).To(Equal(1))
+}
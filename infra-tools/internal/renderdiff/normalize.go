@@ -17,11 +17,11 @@ type resourceKey struct {
 	name       string
 }
 
-// normalizeYAML sorts a multi-document YAML stream by resource identity
+// NormalizeYAML sorts a multi-document YAML stream by resource identity
 // (apiVersion, kind, namespace, name) to minimize diff noise from resource
 // reordering across kustomize builds. If parsing fails, the original input
 // is returned unchanged.
-func normalizeYAML(input []byte) []byte {
+func NormalizeYAML(input []byte) []byte {
 	if len(input) == 0 {
 		return input
 	}
@@ -51,17 +51,7 @@ func normalizeYAML(input []byte) []byte {
 	}
 
 	sort.SliceStable(docs, func(i, j int) bool {
-		a, b := docs[i].key, docs[j].key
-		if a.apiVersion != b.apiVersion {
-			return a.apiVersion < b.apiVersion
-		}
-		if a.kind != b.kind {
-			return a.kind < b.kind
-		}
-		if a.namespace != b.namespace {
-			return a.namespace < b.namespace
-		}
-		return a.name < b.name
+		return lessKey(docs[i].key, docs[j].key)
 	})
 
 	var buf bytes.Buffer
@@ -76,6 +66,20 @@ func normalizeYAML(input []byte) []byte {
 	return buf.Bytes()
 }
 
+// lessKey orders resource keys by apiVersion, kind, namespace, then name.
+func lessKey(a, b resourceKey) bool {
+	if a.apiVersion != b.apiVersion {
+		return a.apiVersion < b.apiVersion
+	}
+	if a.kind != b.kind {
+		return a.kind < b.kind
+	}
+	if a.namespace != b.namespace {
+		return a.namespace < b.namespace
+	}
+	return a.name < b.name
+}
+
 // extractKey pulls apiVersion, kind, namespace, and name from a YAML document node.
 func extractKey(node *yaml.Node) resourceKey {
 	if node == nil || node.Kind != yaml.DocumentNode || len(node.Content) == 0 {
@@ -122,3 +126,31 @@ func extractKey(node *yaml.Node) resourceKey {
 	}
 	return key
 }
+
+// mappingValue returns the value of key in mapping n, or nil.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// children returns the mapping values or list items of n.
+func children(n *yaml.Node) []*yaml.Node {
+	switch n.Kind {
+	case yaml.MappingNode:
+		var out []*yaml.Node
+		for i := 1; i < len(n.Content); i += 2 {
+			out = append(out, n.Content[i])
+		}
+		return out
+	case yaml.SequenceNode:
+		return n.Content
+	}
+	return nil
+}
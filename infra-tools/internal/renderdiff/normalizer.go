@@ -0,0 +1,435 @@
+package renderdiff
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fieldpath"
+)
+
+// NormalizeRule is one step of a Normalizer. Exactly one of the actions
+// StripHashSuffixes, SortList, DropAnnotations and DropLabels is set. Rules
+// are loaded from a YAML file:
+//
+//	rules:
+//	  - name: generator-hash-suffixes
+//	    stripHashSuffixes: true
+//	  - name: env-order
+//	    sortList:
+//	      path: "**.containers[*].env"
+//	      key: name
+//	  - name: sync-waves
+//	    kinds: [Deployment]
+//	    dropAnnotations: ["argocd.argoproj.io/*"]
+//
+// An empty Kinds matches every kind, except for StripHashSuffixes, where it
+// defaults to ConfigMap and Secret.
+type NormalizeRule struct {
+	Name  string   `yaml:"name"`
+	Kinds []string `yaml:"kinds"`
+	// StripHashSuffixes removes the hash suffix kustomize appends to the
+	// names of generated resources, from the names themselves and from every
+	// value that refers to them.
+	StripHashSuffixes bool `yaml:"stripHashSuffixes"`
+	// SortList sorts the lists at a path by a key of their items.
+	SortList *SortList `yaml:"sortList"`
+	// DropAnnotations and DropLabels remove the metadata annotations or
+	// labels matching these path.Match patterns, at any depth, so pod
+	// templates are covered too.
+	DropAnnotations []string `yaml:"dropAnnotations"`
+	DropLabels      []string `yaml:"dropLabels"`
+
+	apply func(docs []*yaml.Node)
+}
+
+// SortList selects the lists a NormalizeRule sorts.
+type SortList struct {
+	// Path is a field path (see fieldpath.Parse) selecting the lists.
+	Path string `yaml:"path"`
+	// Key is the item field to sort by. Items without it keep their order
+	// after the others.
+	Key string `yaml:"key"`
+}
+
+// Suppression is the number of diff lines a normalize rule removed.
+type Suppression struct {
+	Rule  string `json:"rule"`
+	Lines int    `json:"lines"`
+}
+
+// Normalizer rewrites both renders of a component to suppress differences
+// that are known noise, such as generator hash suffixes or reordered lists.
+type Normalizer struct {
+	rules []NormalizeRule
+}
+
+// NewNormalizer validates rules and returns a Normalizer applying them in
+// order.
+func NewNormalizer(rules ...NormalizeRule) (*Normalizer, error) {
+	rules = slices.Clone(rules)
+	seen := make(map[string]bool)
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" {
+			return nil, fmt.Errorf("normalize rule %d: name is required", i+1)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("normalize rule %s: duplicate name", r.Name)
+		}
+		seen[r.Name] = true
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("normalize rule %s: %w", r.Name, err)
+		}
+	}
+	return &Normalizer{rules: rules}, nil
+}
+
+// LoadNormalizer reads normalize rules from a YAML file.
+func LoadNormalizer(path string) (*Normalizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading normalize file: %w", err)
+	}
+	var file struct {
+		Rules []NormalizeRule `yaml:"rules"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("loading %s: parsing rules: %w", path, err)
+	}
+	n, err := NewNormalizer(file.Rules...)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", path, err)
+	}
+	return n, nil
+}
+
+// compile validates a rule and sets its apply function.
+func (r *NormalizeRule) compile() error {
+	actions := 0
+	if r.StripHashSuffixes {
+		actions++
+		kinds := r.Kinds
+		if len(kinds) == 0 {
+			kinds = []string{"ConfigMap", "Secret"}
+		}
+		r.apply = func(docs []*yaml.Node) { stripHashSuffixes(docs, kinds) }
+	}
+	if r.SortList != nil {
+		actions++
+		if r.SortList.Key == "" {
+			return fmt.Errorf("sortList: key is required")
+		}
+		p, err := fieldpath.Parse(r.SortList.Path)
+		if err != nil {
+			return fmt.Errorf("sortList: %w", err)
+		}
+		key := r.SortList.Key
+		r.apply = r.forKinds(func(doc *yaml.Node) { p.Walk(doc, func(n *yaml.Node) { sortItems(n, key) }) })
+	}
+	for field, patterns := range map[string][]string{"annotations": r.DropAnnotations, "labels": r.DropLabels} {
+		if len(patterns) == 0 {
+			continue
+		}
+		actions++
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("drop %s: invalid pattern %q", field, pattern)
+			}
+		}
+		p, _ := fieldpath.Parse("**.metadata." + field)
+		r.apply = r.forKinds(func(doc *yaml.Node) { p.Walk(doc, func(n *yaml.Node) { dropKeys(n, patterns) }) })
+	}
+	if actions != 1 {
+		return fmt.Errorf("exactly one of stripHashSuffixes, sortList, dropAnnotations or dropLabels must be set")
+	}
+	return nil
+}
+
+// forKinds returns an apply function calling fn for every document of the
+// rule's kinds.
+func (r *NormalizeRule) forKinds(fn func(doc *yaml.Node)) func(docs []*yaml.Node) {
+	kinds := r.Kinds
+	return func(docs []*yaml.Node) {
+		for _, doc := range docs {
+			if len(kinds) == 0 || slices.Contains(kinds, extractKey(doc).kind) {
+				fn(doc.Content[0])
+			}
+		}
+	}
+}
+
+// Normalize applies the rules to both renders of a component and returns
+// them, with the number of diff lines between them each rule removed. Rules
+// that removed nothing are left out. Renders that cannot be parsed are
+// returned unchanged, like NormalizeYAML does.
+func (n *Normalizer) Normalize(base, head []byte) ([]byte, []byte, []Suppression) {
+	baseDocs, err := decodeDocs(base)
+	if err == nil {
+		var headDocs []*yaml.Node
+		headDocs, err = decodeDocs(head)
+		if err == nil {
+			return n.normalize(baseDocs, headDocs)
+		}
+	}
+	slog.Debug("YAML normalization rules: parse error, returning original", "err", err)
+	return base, head, nil
+}
+
+func (n *Normalizer) normalize(baseDocs, headDocs []*yaml.Node) ([]byte, []byte, []Suppression) {
+	c := &lineCounter{seen: make(map[[2]string]int)}
+	lines := c.count(baseDocs, headDocs)
+	var suppressed []Suppression
+	for _, r := range n.rules {
+		r.apply(baseDocs)
+		r.apply(headDocs)
+		if lines == 0 {
+			continue
+		}
+		after := c.count(baseDocs, headDocs)
+		if after < lines {
+			suppressed = append(suppressed, Suppression{Rule: r.Name, Lines: lines - after})
+		}
+		lines = after
+	}
+	return encodeDocs(baseDocs), encodeDocs(headDocs), suppressed
+}
+
+// lineCounter counts the diff lines between two renders resource by
+// resource. It remembers the count of every pair of documents it has
+// diffed, so that after a rule only the resources the rule changed are
+// diffed again.
+type lineCounter struct {
+	seen map[[2]string]int
+}
+
+// count returns the number of lines the diffs of the documents in baseDocs
+// and headDocs add and remove. Documents are paired by resource identity;
+// those left over are paired by kind in name order, so that a renamed
+// resource, e.g. a ConfigMap with a new hash suffix, counts the lines that
+// changed rather than all of them, as in a diff of the whole render.
+func (c *lineCounter) count(baseDocs, headDocs []*yaml.Node) int {
+	base, head := encodeByKey(baseDocs), encodeByKey(headDocs)
+	lines := 0
+	for key, b := range base {
+		if h, ok := head[key]; ok {
+			lines += c.diff(b, h)
+			delete(base, key)
+			delete(head, key)
+		}
+	}
+	baseLeft, headLeft := byKind(base), byKind(head)
+	for kind, bs := range baseLeft {
+		hs := headLeft[kind]
+		for i := range max(len(bs), len(hs)) {
+			var b, h string
+			if i < len(bs) {
+				b = bs[i]
+			}
+			if i < len(hs) {
+				h = hs[i]
+			}
+			lines += c.diff(b, h)
+		}
+		delete(headLeft, kind)
+	}
+	for _, hs := range headLeft {
+		for _, h := range hs {
+			lines += c.diff("", h)
+		}
+	}
+	return lines
+}
+
+func (c *lineCounter) diff(base, head string) int {
+	if base == head {
+		return 0
+	}
+	pair := [2]string{base, head}
+	if lines, ok := c.seen[pair]; ok {
+		return lines
+	}
+	lines := diffLines([]byte(base), []byte(head))
+	c.seen[pair] = lines
+	return lines
+}
+
+// encodeByKey encodes each document on its own, keyed by resource
+// identity. Documents sharing an identity are encoded together in order.
+func encodeByKey(docs []*yaml.Node) map[resourceKey]string {
+	out := make(map[resourceKey]string, len(docs))
+	for _, doc := range docs {
+		key := extractKey(doc)
+		out[key] += string(encodeDocs([]*yaml.Node{doc}))
+	}
+	return out
+}
+
+// byKind groups encoded documents by apiVersion, kind and namespace, each
+// group in name order.
+func byKind(docs map[resourceKey]string) map[resourceKey][]string {
+	keys := slices.SortedFunc(maps.Keys(docs), func(a, b resourceKey) int {
+		return cmp.Compare(a.name, b.name)
+	})
+	out := make(map[resourceKey][]string)
+	for _, key := range keys {
+		kind := key
+		kind.name = ""
+		out[kind] = append(out[kind], docs[key])
+	}
+	return out
+}
+
+// decodeDocs parses a multi-document YAML stream, skipping empty documents.
+func decodeDocs(input []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(input))
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(node.Content) > 0 {
+			docs = append(docs, &node)
+		}
+	}
+}
+
+// encodeDocs encodes documents decoded by decodeDocs with the two-space
+// indentation kustomize uses. It returns nil for no documents, so that a
+// missing render stays missing.
+func encodeDocs(docs []*yaml.Node) []byte {
+	if len(docs) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		// Decoded nodes always re-encode.
+		_ = encoder.Encode(doc)
+	}
+	_ = encoder.Close()
+	return buf.Bytes()
+}
+
+// diffLines returns the number of lines the unified diff computeDiff makes
+// of the renders would add and remove.
+func diffLines(base, head []byte) int {
+	a, b := diffTexts(base, head)
+	if a == b {
+		return 0
+	}
+	m := difflib.NewMatcher(difflib.SplitLines(a), difflib.SplitLines(b))
+	lines := 0
+	for _, op := range m.GetOpCodes() {
+		if op.Tag != 'e' {
+			lines += op.I2 - op.I1 + op.J2 - op.J1
+		}
+	}
+	return lines
+}
+
+// hashSuffix matches a name ending in the hash suffix kustomize appends to
+// generated resources: ten characters of its hash alphabet.
+var hashSuffix = regexp.MustCompile(`^(.+)-[2456789bcdfghkmt]{10}$`)
+
+// stripHashSuffixes removes the hash suffixes of the names of the kinds of
+// resources among docs, and replaces every scalar equal to one of those
+// names, e.g. a configMapRef, with the stripped name.
+func stripHashSuffixes(docs []*yaml.Node, kinds []string) {
+	stripped := make(map[string]string)
+	for _, doc := range docs {
+		key := extractKey(doc)
+		if !slices.Contains(kinds, key.kind) {
+			continue
+		}
+		if m := hashSuffix.FindStringSubmatch(key.name); m != nil {
+			stripped[key.name] = m[1]
+		}
+	}
+	if len(stripped) == 0 {
+		return
+	}
+	var rewrite func(n *yaml.Node)
+	rewrite = func(n *yaml.Node) {
+		switch n.Kind {
+		case yaml.ScalarNode:
+			if name, ok := stripped[n.Value]; ok {
+				n.Value = name
+			}
+		case yaml.MappingNode:
+			for i := 1; i < len(n.Content); i += 2 {
+				rewrite(n.Content[i])
+			}
+		case yaml.SequenceNode, yaml.DocumentNode:
+			for _, child := range n.Content {
+				rewrite(child)
+			}
+		}
+	}
+	for _, doc := range docs {
+		rewrite(doc)
+	}
+}
+
+// sortItems stably sorts the mapping items of list n by their key field.
+// Items without the field sort last.
+func sortItems(n *yaml.Node, key string) {
+	if n.Kind != yaml.SequenceNode {
+		return
+	}
+	value := func(item *yaml.Node) (string, bool) {
+		v := mappingValue(item, key)
+		if v == nil || v.Kind != yaml.ScalarNode {
+			return "", false
+		}
+		return v.Value, true
+	}
+	slices.SortStableFunc(n.Content, func(a, b *yaml.Node) int {
+		av, aok := value(a)
+		bv, bok := value(b)
+		if aok != bok {
+			if aok {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(av, bv)
+	})
+}
+
+// dropKeys removes the entries of mapping n whose key matches a pattern.
+func dropKeys(n *yaml.Node, patterns []string) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	kept := n.Content[:0]
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		drop := slices.ContainsFunc(patterns, func(p string) bool {
+			ok, _ := path.Match(p, n.Content[i].Value)
+			return ok
+		})
+		if !drop {
+			kept = append(kept, n.Content[i], n.Content[i+1])
+		}
+	}
+	n.Content = kept
+}
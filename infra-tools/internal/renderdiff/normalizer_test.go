@@ -0,0 +1,210 @@
+package renderdiff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+func TestNormalize_StripHashSuffixes(t *testing.T) {
+	g := NewWithT(t)
+
+	n, err := NewNormalizer(NormalizeRule{Name: "hashes", StripHashSuffixes: true})
+	g.Expect(err).NotTo(HaveOccurred())
+	render := func(hash string) []byte {
+		return []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings-` + hash + `
+data:
+  level: debug
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app-gh2t96b4m7
+spec:
+  template:
+    spec:
+      volumes:
+        - name: settings
+          configMap:
+            name: settings-` + hash + `
+`)
+	}
+	base, head, suppressed := n.Normalize(render("5m8f7bd9kc"), render("gh2t96b4m7"))
+	g.Expect(string(base)).To(Equal(string(head)))
+	g.Expect(string(head)).To(ContainSubstring("name: settings\n"))
+	g.Expect(string(head)).To(ContainSubstring("name: app-gh2t96b4m7"), "only ConfigMap and Secret names are stripped by default")
+	g.Expect(suppressed).To(Equal([]Suppression{{Rule: "hashes", Lines: 4}}))
+}
+
+func TestNormalize_SortList(t *testing.T) {
+	g := NewWithT(t)
+
+	n, err := NewNormalizer(NormalizeRule{
+		Name:     "env-order",
+		Kinds:    []string{"Deployment"},
+		SortList: &SortList{Path: "**.containers[*].env", Key: "name"},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	base, head, suppressed := n.Normalize([]byte(`kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          env:
+            - name: B
+              value: "2"
+            - name: A
+              value: "1"
+`), []byte(`kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          env:
+            - name: A
+              value: "1"
+            - name: B
+              value: "2"
+`))
+	g.Expect(string(base)).To(Equal(string(head)))
+	g.Expect(suppressed).To(Equal([]Suppression{{Rule: "env-order", Lines: 4}}))
+}
+
+func TestNormalize_DropAnnotationsAndLabels(t *testing.T) {
+	g := NewWithT(t)
+
+	n, err := NewNormalizer(
+		NormalizeRule{Name: "sync-waves", DropAnnotations: []string{"argocd.argoproj.io/*"}},
+		NormalizeRule{Name: "versions", Kinds: []string{"Deployment"}, DropLabels: []string{"app.kubernetes.io/version"}},
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	base, head, suppressed := n.Normalize([]byte(`kind: Deployment
+metadata:
+  annotations:
+    argocd.argoproj.io/sync-wave: "1"
+  labels:
+    app.kubernetes.io/version: v1
+spec:
+  replicas: 1
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/version: v1
+`), []byte(`kind: Deployment
+metadata:
+  annotations:
+    argocd.argoproj.io/sync-wave: "2"
+  labels:
+    app.kubernetes.io/version: v2
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/version: v2
+`))
+	g.Expect(string(head)).NotTo(ContainSubstring("argocd.argoproj.io"))
+	g.Expect(string(head)).NotTo(ContainSubstring("app.kubernetes.io/version"))
+	g.Expect(string(base)).To(ContainSubstring("replicas: 1"))
+	g.Expect(string(head)).To(ContainSubstring("replicas: 2"))
+	g.Expect(suppressed).To(Equal([]Suppression{{Rule: "sync-waves", Lines: 2}, {Rule: "versions", Lines: 4}}))
+}
+
+func TestNormalize_NothingSuppressed(t *testing.T) {
+	g := NewWithT(t)
+
+	n, err := NewNormalizer(NormalizeRule{Name: "hashes", StripHashSuffixes: true})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, _, suppressed := n.Normalize([]byte("kind: ConfigMap\ndata:\n  a: \"1\"\n"), []byte("kind: ConfigMap\ndata:\n  a: \"2\"\n"))
+	g.Expect(suppressed).To(BeEmpty())
+}
+
+func TestNormalize_ParseError(t *testing.T) {
+	g := NewWithT(t)
+
+	n, err := NewNormalizer(NormalizeRule{Name: "hashes", StripHashSuffixes: true})
+	g.Expect(err).NotTo(HaveOccurred())
+	in := []byte("kind: ConfigMap\ndata: [unterminated\n")
+	base, head, suppressed := n.Normalize(in, in)
+	g.Expect(base).To(Equal(in))
+	g.Expect(head).To(Equal(in))
+	g.Expect(suppressed).To(BeNil())
+}
+
+func TestNewNormalizer_Invalid(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, tc := range []struct {
+		rules []NormalizeRule
+		err   string
+	}{
+		{[]NormalizeRule{{StripHashSuffixes: true}}, "normalize rule 1: name is required"},
+		{[]NormalizeRule{{Name: "a", StripHashSuffixes: true}, {Name: "a", StripHashSuffixes: true}}, "normalize rule a: duplicate name"},
+		{[]NormalizeRule{{Name: "a"}}, "exactly one of"},
+		{[]NormalizeRule{{Name: "a", StripHashSuffixes: true, DropLabels: []string{"x"}}}, "exactly one of"},
+		{[]NormalizeRule{{Name: "a", SortList: &SortList{Path: "spec.items"}}}, "key is required"},
+		{[]NormalizeRule{{Name: "a", SortList: &SortList{Path: "spec[", Key: "name"}}}, "sortList"},
+		{[]NormalizeRule{{Name: "a", DropAnnotations: []string{"["}}}, "invalid pattern"},
+	} {
+		_, err := NewNormalizer(tc.rules...)
+		g.Expect(err).To(MatchError(ContainSubstring(tc.err)), tc.err)
+	}
+}
+
+func TestLoadNormalizer(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "normalize.yaml")
+	g.Expect(os.WriteFile(file, []byte(`rules:
+  - name: hashes
+    stripHashSuffixes: true
+  - name: env-order
+    sortList:
+      path: "**.env"
+      key: name
+`), 0o644)).To(Succeed())
+	n, err := LoadNormalizer(file)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(n.rules).To(HaveLen(2))
+
+	g.Expect(os.WriteFile(file, []byte("rules:\n  - name: x\n    dropAnnotation: [a]\n"), 0o644)).To(Succeed())
+	_, err = LoadNormalizer(file)
+	g.Expect(err).To(MatchError(ContainSubstring("field dropAnnotation not found")))
+}
+
+func TestLoadNormalizer_RepositoryRules(t *testing.T) {
+	g := NewWithT(t)
+
+	n, err := LoadNormalizer("../../policies/render-diff-normalize.yaml")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(n.rules).NotTo(BeEmpty())
+}
+
+func TestLineCounter(t *testing.T) {
+	g := NewWithT(t)
+
+	docs := func(s string) []*yaml.Node {
+		d, err := decodeDocs([]byte(s))
+		g.Expect(err).NotTo(HaveOccurred())
+		return d
+	}
+	c := &lineCounter{seen: make(map[[2]string]int)}
+	base := docs("kind: ConfigMap\nmetadata:\n  name: a-5m8f7bd9kc\ndata:\n  x: \"1\"\n---\nkind: Service\nmetadata:\n  name: gone\n")
+	head := docs("kind: ConfigMap\nmetadata:\n  name: a-gh2t96b4m7\ndata:\n  x: \"1\"\n---\nkind: Secret\nmetadata:\n  name: new\n")
+
+	// The renamed ConfigMap counts its name line twice; the removed
+	// Service and the added Secret count all three of their lines.
+	g.Expect(c.count(base, head)).To(Equal(2 + 3 + 3))
+	g.Expect(c.seen).To(HaveLen(3))
+	g.Expect(c.count(base, head)).To(Equal(8))
+	g.Expect(c.seen).To(HaveLen(3), "unchanged pairs are not diffed again")
+}
@@ -0,0 +1,145 @@
+package renderdiff
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fieldpath"
+)
+
+// RedactedPrefix starts every value a Redactor replaced.
+const RedactedPrefix = "<redacted hmac:"
+
+// RedactRule selects fields whose values are replaced with hashes. Rules are
+// loaded from a YAML file:
+//
+//	rules:
+//	  - kinds: [ConfigMap]
+//	    path: data.token
+//	  - path: spec.template.spec.containers[*].env[name=API_KEY].value
+//
+// Path is a field path (see fieldpath.Parse). A path that selects a mapping or
+// list redacts every scalar under it. An empty Kinds matches every kind.
+type RedactRule struct {
+	Kinds []string `yaml:"kinds"`
+	Path  string   `yaml:"path"`
+
+	path fieldpath.Path
+}
+
+// DefaultRedactRules redact the values of every Secret.
+var DefaultRedactRules = []RedactRule{
+	{Kinds: []string{"Secret"}, Path: "data"},
+	{Kinds: []string{"Secret"}, Path: "stringData"},
+}
+
+// Redactor replaces sensitive values in rendered YAML with keyed hashes, so
+// a diff still shows that a value changed without revealing it. The key is
+// random and lives only as long as the Redactor: hashes are stable within a
+// run, but cannot be looked up against hashes of guessed values.
+type Redactor struct {
+	rules []RedactRule
+	key   []byte
+}
+
+// NewRedactor returns a Redactor for DefaultRedactRules and rules.
+func NewRedactor(rules ...RedactRule) (*Redactor, error) {
+	r := &Redactor{key: make([]byte, sha256.Size)}
+	if _, err := rand.Read(r.key); err != nil {
+		return nil, fmt.Errorf("generating redaction key: %w", err)
+	}
+	for _, rule := range DefaultRedactRules {
+		rule.path, _ = fieldpath.Parse(rule.Path)
+		r.rules = append(r.rules, rule)
+	}
+	for i, rule := range rules {
+		p, err := fieldpath.Parse(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("redact rule %d: %w", i+1, err)
+		}
+		rule.path = p
+		r.rules = append(r.rules, rule)
+	}
+	return r, nil
+}
+
+// LoadRedactor reads redact rules from a YAML file and returns a Redactor
+// for them and DefaultRedactRules.
+func LoadRedactor(path string) (*Redactor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading redact file: %w", err)
+	}
+	var file struct {
+		Rules []RedactRule `yaml:"rules"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("loading %s: parsing rules: %w", path, err)
+	}
+	r, err := NewRedactor(file.Rules...)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Redact returns input with every value the rules select replaced by
+// RedactedPrefix, the first 12 hex digits of the value's HMAC-SHA256 under
+// the Redactor's key, and ">".
+// The stream is re-encoded even when nothing matched, so that both sides of
+// a diff are formatted alike. Unlike NormalizeYAML, a parse error is
+// returned rather than the input, which must not be published.
+func (r *Redactor) Redact(input []byte) ([]byte, error) {
+	if len(input) == 0 {
+		return input, nil
+	}
+	docs, err := decodeDocs(input)
+	if err != nil {
+		return nil, fmt.Errorf("redacting: %w", err)
+	}
+	for _, doc := range docs {
+		kind := extractKey(doc).kind
+		for _, rule := range r.rules {
+			if len(rule.Kinds) == 0 || slices.Contains(rule.Kinds, kind) {
+				rule.path.Walk(doc.Content[0], r.hashScalars)
+			}
+		}
+	}
+	return encodeDocs(docs), nil
+}
+
+// hashScalars replaces every scalar under n, mapping keys excepted, with
+// its keyed hash. Already redacted values are left alone so overlapping
+// rules don't hash twice.
+func (r *Redactor) hashScalars(n *yaml.Node) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" || strings.HasPrefix(n.Value, RedactedPrefix) {
+			return
+		}
+		mac := hmac.New(sha256.New, r.key)
+		mac.Write([]byte(n.Value))
+		n.Value = RedactedPrefix + hex.EncodeToString(mac.Sum(nil)[:6]) + ">"
+		n.Tag = "!!str"
+		n.Style = 0
+	case yaml.AliasNode:
+		// Aliases point at a node redacted, or kept, on its own.
+	default:
+		for _, child := range children(n) {
+			r.hashScalars(child)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package renderdiff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRedact_Secret(t *testing.T) {
+	g := NewWithT(t)
+
+	r, err := NewRedactor()
+	g.Expect(err).NotTo(HaveOccurred())
+	r.key = []byte("test")
+	out, err := r.Redact([]byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+data:
+  password: aHVudGVyMg==
+stringData:
+  token: hunter2
+  empty: null
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  password: visible
+`))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).To(Equal(`apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+data:
+  password: <redacted hmac:8880c53033ce>
+stringData:
+  token: <redacted hmac:380cc705ae41>
+  empty: null
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  password: visible
+`))
+}
+
+func TestRedact_Stable(t *testing.T) {
+	g := NewWithT(t)
+
+	r, err := NewRedactor()
+	g.Expect(err).NotTo(HaveOccurred())
+	secret := func(v string) []byte {
+		return []byte("kind: Secret\nstringData:\n  token: " + v + "\n")
+	}
+	a, err := r.Redact(secret("one"))
+	g.Expect(err).NotTo(HaveOccurred())
+	b, err := r.Redact(secret("one"))
+	g.Expect(err).NotTo(HaveOccurred())
+	c, err := r.Redact(secret("two"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(a).To(Equal(b))
+	g.Expect(a).NotTo(Equal(c))
+	g.Expect(string(c)).NotTo(ContainSubstring("two"))
+}
+
+func TestRedact_Rules(t *testing.T) {
+	g := NewWithT(t)
+
+	r, err := NewRedactor(
+		RedactRule{Kinds: []string{"ConfigMap"}, Path: "$.data.token"},
+		RedactRule{Path: "spec.template.spec.containers[*].env[name=API_KEY].value"},
+		RedactRule{Path: `**.annotations["example.com/key"]`},
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	out, err := r.Redact([]byte(`kind: ConfigMap
+data:
+  token: abc
+  other: def
+---
+kind: Deployment
+metadata:
+  annotations:
+    example.com/key: k
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          env:
+            - name: API_KEY
+              value: abc
+            - name: LOG_LEVEL
+              value: debug
+`))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(out)).NotTo(ContainSubstring("abc"))
+	g.Expect(string(out)).To(ContainSubstring("other: def"))
+	g.Expect(string(out)).To(ContainSubstring("example.com/key: <redacted hmac:"))
+	g.Expect(string(out)).To(ContainSubstring("value: debug"))
+}
+
+func TestRedact_ParseError(t *testing.T) {
+	g := NewWithT(t)
+
+	r, err := NewRedactor()
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = r.Redact([]byte("kind: Secret\ndata: [unterminated\n"))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestNewRedactor_InvalidPath(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, p := range []string{"", "data..x", "data[", "data[x]", `data["x]`} {
+		_, err := NewRedactor(RedactRule{Path: p})
+		g.Expect(err).To(MatchError(ContainSubstring("redact rule 1")), p)
+	}
+}
+
+func TestLoadRedactor(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "redact.yaml")
+	g.Expect(os.WriteFile(file, []byte("rules:\n  - kinds: [ConfigMap]\n    path: data.token\n"), 0o644)).To(Succeed())
+	r, err := LoadRedactor(file)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.rules).To(HaveLen(len(DefaultRedactRules) + 1))
+
+	g.Expect(os.WriteFile(file, []byte("rules:\n  - kind: ConfigMap\n"), 0o644)).To(Succeed())
+	_, err = LoadRedactor(file)
+	g.Expect(err).To(MatchError(ContainSubstring("field kind not found")))
+}
+
+func TestRedact_KeyedPerRedactor(t *testing.T) {
+	g := NewWithT(t)
+
+	secret := []byte("kind: Secret\nstringData:\n  token: hunter2\n")
+	a, err := NewRedactor()
+	g.Expect(err).NotTo(HaveOccurred())
+	b, err := NewRedactor()
+	g.Expect(err).NotTo(HaveOccurred())
+	outA, err := a.Redact(secret)
+	g.Expect(err).NotTo(HaveOccurred())
+	outB, err := b.Redact(secret)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(outA).NotTo(Equal(outB), "a hash from one run cannot be matched against another")
+}
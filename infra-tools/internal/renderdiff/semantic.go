@@ -0,0 +1,356 @@
+package renderdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fieldpath"
+)
+
+// DiffFormat selects how ComponentDiff.Diff is computed.
+type DiffFormat string
+
+const (
+	// DiffFormatUnified is a line-based unified diff of the normalized YAML.
+	DiffFormatUnified DiffFormat = "unified"
+	// DiffFormatSemantic matches resources by identity and reports per-field
+	// changes instead of text hunks.
+	DiffFormatSemantic DiffFormat = "semantic"
+)
+
+// ChangeType describes how a resource or field differs between base and head.
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeRemoved  ChangeType = "removed"
+	ChangeModified ChangeType = "modified"
+)
+
+// FieldChange is a single leaf-level difference inside a resource.
+type FieldChange struct {
+	// Path identifies the field, e.g. spec.template.spec.containers[name=manager].image.
+	Path string `json:"path"`
+	// Type is added, removed, or modified.
+	Type ChangeType `json:"type"`
+	// Old is the base value rendered as a scalar or compact JSON (empty when added).
+	Old string `json:"old,omitempty"`
+	// New is the head value rendered as a scalar or compact JSON (empty when removed).
+	New string `json:"new,omitempty"`
+	// Diff holds the unified diff hunks between the lines of a modified
+	// multi-line string, without file headers.
+	Diff string `json:"diff,omitempty"`
+}
+
+// ResourceChange describes how a single Kubernetes resource differs between
+// base and head. Resources are matched by (apiVersion, kind, namespace, name).
+type ResourceChange struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Type is added, removed, or modified.
+	Type ChangeType `json:"type"`
+	// Fields lists the field-level changes. Only populated for modified resources.
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// ID returns a human-readable identity such as "apps/v1 Deployment ns/name".
+func (rc ResourceChange) ID() string {
+	name := rc.Name
+	if rc.Namespace != "" {
+		name = rc.Namespace + "/" + name
+	}
+	return fmt.Sprintf("%s %s %s", rc.APIVersion, rc.Kind, name)
+}
+
+// SemanticDiff decodes both YAML streams, pairs resources by identity, and
+// returns the resource-level changes sorted by identity. The renders need not
+// come from the same path, e.g. two environments' overlays of a component.
+func SemanticDiff(baseYAML, headYAML []byte) ([]ResourceChange, error) {
+	baseDocs, err := decodeResources(baseYAML)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base YAML: %w", err)
+	}
+	headDocs, err := decodeResources(headYAML)
+	if err != nil {
+		return nil, fmt.Errorf("decoding head YAML: %w", err)
+	}
+
+	keys := make(map[resourceKey]bool)
+	for k := range baseDocs {
+		keys[k] = true
+	}
+	for k := range headDocs {
+		keys[k] = true
+	}
+	sorted := make([]resourceKey, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return lessKey(sorted[i], sorted[j]) })
+
+	var changes []ResourceChange
+	for _, k := range sorted {
+		bs, hs := baseDocs[k], headDocs[k]
+		// Duplicate identities (rare, e.g. documents without metadata) are
+		// paired by their order of appearance.
+		for i := 0; i < max(len(bs), len(hs)); i++ {
+			rc := ResourceChange{APIVersion: k.apiVersion, Kind: k.kind, Namespace: k.namespace, Name: k.name}
+			switch {
+			case i >= len(bs):
+				rc.Type = ChangeAdded
+			case i >= len(hs):
+				rc.Type = ChangeRemoved
+			default:
+				rc.Fields = diffValues("", bs[i], hs[i], nil)
+				if len(rc.Fields) == 0 {
+					continue
+				}
+				rc.Type = ChangeModified
+			}
+			changes = append(changes, rc)
+		}
+	}
+	return changes, nil
+}
+
+// decodeResources parses a multi-document YAML stream and groups the decoded
+// documents by resource identity, preserving their order of appearance.
+func decodeResources(input []byte) (map[resourceKey][]interface{}, error) {
+	docs := make(map[resourceKey][]interface{})
+	decoder := yaml.NewDecoder(bytes.NewReader(input))
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if err := node.Decode(&v); err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		k := extractKey(&node)
+		docs[k] = append(docs[k], v)
+	}
+	return docs, nil
+}
+
+// diffValues recursively compares two decoded YAML values and appends the
+// leaf-level differences to changes.
+func diffValues(path string, base, head interface{}, changes []FieldChange) []FieldChange {
+	switch b := base.(type) {
+	case map[string]interface{}:
+		if h, ok := head.(map[string]interface{}); ok {
+			return diffMaps(path, b, h, changes)
+		}
+	case []interface{}:
+		if h, ok := head.([]interface{}); ok {
+			return diffLists(path, b, h, changes)
+		}
+	case string:
+		if h, ok := head.(string); ok {
+			if b == h {
+				return changes
+			}
+			return diffStrings(path, b, h, changes)
+		}
+	default:
+		// Scalars compare by value and type so that e.g. "1" → 1 is reported.
+		if reflect.DeepEqual(base, head) {
+			return changes
+		}
+	}
+	return append(changes, FieldChange{Path: path, Type: ChangeModified, Old: renderValue(base), New: renderValue(head)})
+}
+
+// diffMaps compares two mappings key by key in sorted order.
+func diffMaps(path string, base, head map[string]interface{}, changes []FieldChange) []FieldChange {
+	keys := make(map[string]bool, len(base)+len(head))
+	for k := range base {
+		keys[k] = true
+	}
+	for k := range head {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		p := fieldpath.JoinKey(path, k)
+		bv, inBase := base[k]
+		hv, inHead := head[k]
+		switch {
+		case !inBase:
+			changes = append(changes, FieldChange{Path: p, Type: ChangeAdded, New: renderValue(hv)})
+		case !inHead:
+			changes = append(changes, FieldChange{Path: p, Type: ChangeRemoved, Old: renderValue(bv)})
+		default:
+			changes = diffValues(p, bv, hv, changes)
+		}
+	}
+	return changes
+}
+
+// diffLists compares two sequences. When every item on both sides is a
+// mapping with a unique "name" key (containers, env vars, volumes, ports),
+// items are matched by name so reordering does not register as a change.
+// Otherwise items are compared by index.
+func diffLists(path string, base, head []interface{}, changes []FieldChange) []FieldChange {
+	baseByName, baseOK := indexByName(base)
+	headByName, headOK := indexByName(head)
+	if baseOK && headOK {
+		names := make(map[string]bool, len(baseByName)+len(headByName))
+		for n := range baseByName {
+			names[n] = true
+		}
+		for n := range headByName {
+			names[n] = true
+		}
+		sorted := make([]string, 0, len(names))
+		for n := range names {
+			sorted = append(sorted, n)
+		}
+		sort.Strings(sorted)
+
+		for _, n := range sorted {
+			p := fmt.Sprintf("%s[name=%s]", path, n)
+			bv, inBase := baseByName[n]
+			hv, inHead := headByName[n]
+			switch {
+			case !inBase:
+				changes = append(changes, FieldChange{Path: p, Type: ChangeAdded, New: renderValue(hv)})
+			case !inHead:
+				changes = append(changes, FieldChange{Path: p, Type: ChangeRemoved, Old: renderValue(bv)})
+			default:
+				changes = diffValues(p, bv, hv, changes)
+			}
+		}
+		return changes
+	}
+
+	for i := 0; i < max(len(base), len(head)); i++ {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(base):
+			changes = append(changes, FieldChange{Path: p, Type: ChangeAdded, New: renderValue(head[i])})
+		case i >= len(head):
+			changes = append(changes, FieldChange{Path: p, Type: ChangeRemoved, Old: renderValue(base[i])})
+		default:
+			changes = diffValues(p, base[i], head[i], changes)
+		}
+	}
+	return changes
+}
+
+// indexByName maps list items by their "name" field. It reports false when
+// any item is not a mapping, lacks a string name, or names are not unique.
+func indexByName(items []interface{}) (map[string]interface{}, bool) {
+	byName := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok || name == "" {
+			return nil, false
+		}
+		if _, dup := byName[name]; dup {
+			return nil, false
+		}
+		byName[name] = item
+	}
+	return byName, true
+}
+
+// renderValue formats a decoded YAML value for display: scalars as-is and
+// collections as compact JSON. Strings that YAML would read back as another
+// type (e.g. "1" or "true") are quoted so type changes stay visible.
+func renderValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		var reparsed interface{}
+		if err := yaml.Unmarshal([]byte(val), &reparsed); err != nil || reparsed != val {
+			return strconv.Quote(val)
+		}
+		return val
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// FormatSemantic renders resource changes as diff-like text so every output
+// mode can display it without special handling. Resource headers are prefixed
+// with +, - or ~; field changes use - for the old value and + for the new one.
+func FormatSemantic(changes []ResourceChange) string {
+	var b strings.Builder
+	for _, rc := range changes {
+		switch rc.Type {
+		case ChangeAdded:
+			fmt.Fprintf(&b, "+ %s\n", rc.ID())
+		case ChangeRemoved:
+			fmt.Fprintf(&b, "- %s\n", rc.ID())
+		case ChangeModified:
+			fmt.Fprintf(&b, "~ %s\n", rc.ID())
+			for _, fc := range rc.Fields {
+				switch fc.Type {
+				case ChangeAdded:
+					fmt.Fprintf(&b, "+   %s: %s\n", fc.Path, fc.New)
+				case ChangeRemoved:
+					fmt.Fprintf(&b, "-   %s: %s\n", fc.Path, fc.Old)
+				case ChangeModified:
+					if fc.Diff != "" {
+						formatLineDiff(&b, fc)
+						continue
+					}
+					fmt.Fprintf(&b, "-   %s: %s\n", fc.Path, fc.Old)
+					fmt.Fprintf(&b, "+   %s: %s\n", fc.Path, fc.New)
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// formatLineDiff renders the line diff of a modified multi-line string under
+// a ~ header for its path. Diff lines keep their + or - in the first column
+// so that they are counted and colored like field changes.
+func formatLineDiff(b *strings.Builder, fc FieldChange) {
+	fmt.Fprintf(b, "~   %s:\n", fc.Path)
+	for _, line := range strings.Split(strings.TrimSuffix(fc.Diff, "\n"), "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "@@"):
+			fmt.Fprintf(b, "      %s\n", line)
+		default:
+			fmt.Fprintf(b, "%c       %s\n", line[0], line[1:])
+		}
+	}
+}
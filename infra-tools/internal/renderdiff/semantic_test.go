@@ -0,0 +1,151 @@
+package renderdiff
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/appset"
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/detector"
+)
+
+const semanticBaseYAML = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: build
+  labels:
+    app.kubernetes.io/name: controller
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+        - name: sidecar
+          image: quay.io/sidecar:v1
+        - name: manager
+          image: quay.io/manager:v1
+          args: ["--leader-elect"]
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: old-svc
+  namespace: build
+`
+
+const semanticHeadYAML = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new-cm
+  namespace: build
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: build
+  labels:
+    app.kubernetes.io/name: controller
+spec:
+  replicas: "1"
+  template:
+    spec:
+      containers:
+        - name: manager
+          image: quay.io/manager:v2
+          args: ["--leader-elect", "--verbose"]
+        - name: sidecar
+          image: quay.io/sidecar:v1
+`
+
+func TestSemanticDiff_ResourceStatuses(t *testing.T) {
+	g := NewWithT(t)
+
+	changes, err := SemanticDiff([]byte(semanticBaseYAML), []byte(semanticHeadYAML))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changes).To(HaveLen(3))
+
+	g.Expect(changes[0].Kind).To(Equal("Deployment"))
+	g.Expect(changes[0].Type).To(Equal(ChangeModified))
+	g.Expect(changes[1].Kind).To(Equal("ConfigMap"))
+	g.Expect(changes[1].Type).To(Equal(ChangeAdded))
+	g.Expect(changes[1].Fields).To(BeEmpty())
+	g.Expect(changes[2].Kind).To(Equal("Service"))
+	g.Expect(changes[2].Type).To(Equal(ChangeRemoved))
+	g.Expect(changes[2].ID()).To(Equal("v1 Service build/old-svc"))
+}
+
+func TestSemanticDiff_FieldPaths(t *testing.T) {
+	g := NewWithT(t)
+
+	changes, err := SemanticDiff([]byte(semanticBaseYAML), []byte(semanticHeadYAML))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// Containers are matched by name, so swapping sidecar and manager is not
+	// reported; only the image, the appended arg, and the replicas type change are.
+	g.Expect(changes[0].Fields).To(Equal([]FieldChange{
+		{Path: "spec.replicas", Type: ChangeModified, Old: "1", New: `"1"`},
+		{Path: "spec.template.spec.containers[name=manager].args[1]", Type: ChangeAdded, New: "--verbose"},
+		{Path: "spec.template.spec.containers[name=manager].image", Type: ChangeModified, Old: "quay.io/manager:v1", New: "quay.io/manager:v2"},
+	}))
+}
+
+func TestSemanticDiff_NoChange(t *testing.T) {
+	g := NewWithT(t)
+
+	changes, err := SemanticDiff([]byte(semanticBaseYAML), []byte(semanticBaseYAML))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changes).To(BeEmpty())
+}
+
+func TestFormatSemantic(t *testing.T) {
+	g := NewWithT(t)
+
+	text := FormatSemantic([]ResourceChange{
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "a", Type: ChangeAdded},
+		{APIVersion: "v1", Kind: "Secret", Name: "b", Type: ChangeRemoved},
+		{
+			APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "c", Type: ChangeModified,
+			Fields: []FieldChange{{Path: "spec.replicas", Type: ChangeModified, Old: "1", New: "2"}},
+		},
+	})
+
+	g.Expect(text).To(Equal("+ v1 ConfigMap ns/a\n" +
+		"- v1 Secret b\n" +
+		"~ apps/v1 Deployment ns/c\n" +
+		"-   spec.replicas: 1\n" +
+		"+   spec.replicas: 2\n"))
+}
+
+func TestEngine_SemanticFormat(t *testing.T) {
+	g := NewWithT(t)
+
+	head := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true},
+		yamls: map[string][]byte{"components/foo/staging": []byte(semanticHeadYAML)},
+	}
+	base := &fakeBuilder{
+		exist: map[string]bool{"components/foo/staging": true},
+		yamls: map[string][]byte{"components/foo/staging": []byte(semanticBaseYAML)},
+	}
+
+	engine := NewEngine(head, base, 1, WithDiffFormat(DiffFormatSemantic))
+	affected := map[detector.Environment][]appset.ComponentPath{
+		detector.Staging: {{Path: "components/foo/staging"}},
+	}
+
+	result, err := engine.Run(context.Background(), affected)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Diffs).To(HaveLen(1))
+
+	cd := result.Diffs[0]
+	g.Expect(cd.Resources).To(HaveLen(3))
+	g.Expect(cd.Diff).To(ContainSubstring("~ apps/v1 Deployment build/controller"))
+	g.Expect(cd.Diff).To(ContainSubstring("+   spec.template.spec.containers[name=manager].image: quay.io/manager:v2"))
+	g.Expect(cd.Diff).NotTo(ContainSubstring("sidecar"))
+	// + ConfigMap, - Service, and the Deployment's 3 added / 2 removed field lines.
+	g.Expect(cd.Added).To(Equal(4))
+	g.Expect(cd.Removed).To(Equal(3))
+}
@@ -0,0 +1,116 @@
+package renderdiff
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+)
+
+// RowOp describes how a side-by-side row differs between base and head.
+type RowOp string
+
+const (
+	RowEqual   RowOp = "equal"
+	RowDelete  RowOp = "delete"
+	RowInsert  RowOp = "insert"
+	RowReplace RowOp = "replace"
+)
+
+// SideBySideRow is one line of a side-by-side diff. Line numbers are 1-based
+// and zero when the side has no line in this row.
+type SideBySideRow struct {
+	Op       RowOp
+	BaseLine int
+	Base     string
+	HeadLine int
+	Head     string
+	// Resource is the ID of the resource the row belongs to (see
+	// ResourceChange.ID), taken from the head side when it has a line.
+	Resource string
+}
+
+// SideBySideHunk is a group of changed rows with surrounding context.
+type SideBySideHunk struct {
+	Rows []SideBySideRow
+}
+
+// SideBySide aligns the normalized base and head YAML into hunks of paired
+// rows, keeping context unchanged lines around each change. Replaced blocks
+// of different lengths are padded with one-sided rows.
+func (cd *ComponentDiff) SideBySide(context int) []SideBySideHunk {
+	baseLines := splitLines(NormalizeYAML(cd.BaseYAML))
+	headLines := splitLines(NormalizeYAML(cd.HeadYAML))
+	baseRes := lineResources(baseLines)
+	headRes := lineResources(headLines)
+
+	m := difflib.NewMatcher(baseLines, headLines)
+	var hunks []SideBySideHunk
+	for _, group := range m.GetGroupedOpCodes(context) {
+		var h SideBySideHunk
+		for _, op := range group {
+			n := max(op.I2-op.I1, op.J2-op.J1)
+			for k := 0; k < n; k++ {
+				row := SideBySideRow{Op: rowOp(op.Tag)}
+				if i := op.I1 + k; i < op.I2 {
+					row.BaseLine, row.Base, row.Resource = i+1, baseLines[i], baseRes[i]
+				}
+				if j := op.J1 + k; j < op.J2 {
+					row.HeadLine, row.Head, row.Resource = j+1, headLines[j], headRes[j]
+				}
+				h.Rows = append(h.Rows, row)
+			}
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+func rowOp(tag byte) RowOp {
+	switch tag {
+	case 'd':
+		return RowDelete
+	case 'i':
+		return RowInsert
+	case 'r':
+		return RowReplace
+	default:
+		return RowEqual
+	}
+}
+
+// splitLines splits text into lines without their trailing newlines.
+func splitLines(b []byte) []string {
+	s := strings.TrimSuffix(string(b), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// lineResources returns, for each line of a YAML stream, the ID of the
+// document it belongs to. Separator lines belong to the following document.
+func lineResources(lines []string) []string {
+	ids := make([]string, len(lines))
+	start := 0
+	flush := func(end int) {
+		var node yaml.Node
+		id := ""
+		if err := yaml.Unmarshal([]byte(strings.Join(lines[start:end], "\n")), &node); err == nil {
+			if k := extractKey(&node); k != (resourceKey{}) {
+				id = ResourceChange{APIVersion: k.apiVersion, Kind: k.kind, Namespace: k.namespace, Name: k.name}.ID()
+			}
+		}
+		for i := start; i < end; i++ {
+			ids[i] = id
+		}
+	}
+	for i, line := range lines {
+		if line == "---" && i > start {
+			flush(i)
+			start = i
+		}
+	}
+	flush(len(lines))
+	return ids
+}
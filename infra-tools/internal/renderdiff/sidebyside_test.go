@@ -0,0 +1,62 @@
+package renderdiff
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSideBySide_PairsReplacedLines(t *testing.T) {
+	g := NewWithT(t)
+
+	cd := ComponentDiff{
+		BaseYAML: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: ns\ndata:\n  key: old\n"),
+		HeadYAML: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: ns\ndata:\n  key: new\n  extra: x\n"),
+	}
+
+	hunks := cd.SideBySide(1)
+	g.Expect(hunks).To(HaveLen(1))
+	rows := hunks[0].Rows
+	g.Expect(rows).To(HaveLen(3))
+
+	g.Expect(rows[0].Op).To(Equal(RowEqual))
+	g.Expect(rows[0].Base).To(Equal("data:"))
+	g.Expect(rows[1]).To(Equal(SideBySideRow{
+		Op: RowReplace, BaseLine: 7, Base: "  key: old", HeadLine: 7, Head: "  key: new", Resource: "v1 ConfigMap ns/a",
+	}))
+	// The extra head line is padded with an empty base side.
+	g.Expect(rows[2]).To(Equal(SideBySideRow{
+		Op: RowReplace, HeadLine: 8, Head: "  extra: x", Resource: "v1 ConfigMap ns/a",
+	}))
+}
+
+func TestSideBySide_TagsRowsWithResource(t *testing.T) {
+	g := NewWithT(t)
+
+	cd := ComponentDiff{
+		BaseYAML: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n"),
+		HeadYAML: []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: b\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n"),
+	}
+
+	var inserted []SideBySideRow
+	for _, h := range cd.SideBySide(0) {
+		for _, row := range h.Rows {
+			if row.Op == RowInsert {
+				inserted = append(inserted, row)
+			}
+		}
+	}
+	g.Expect(inserted).NotTo(BeEmpty())
+	for _, row := range inserted {
+		g.Expect(row.BaseLine).To(BeZero())
+		g.Expect(row.Resource).To(Equal("v1 Secret b"))
+	}
+}
+
+func TestSideBySide_NoChanges(t *testing.T) {
+	g := NewWithT(t)
+
+	yml := []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n")
+	cd := ComponentDiff{BaseYAML: yml, HeadYAML: yml}
+	g.Expect(cd.SideBySide(3)).To(BeEmpty())
+}
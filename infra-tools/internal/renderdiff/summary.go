@@ -0,0 +1,173 @@
+package renderdiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/policy"
+)
+
+// SortDiffs sorts diffs by environment then path for consistent output.
+func SortDiffs(diffs []ComponentDiff) {
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Env != diffs[j].Env {
+			return diffs[i].Env < diffs[j].Env
+		}
+		return diffs[i].Path < diffs[j].Path
+	})
+}
+
+// IssueSuffix returns ", N schema violations, N policy denials, N policy
+// warnings, N new deprecated fields" with only the non-zero counts, or an
+// empty string.
+func IssueSuffix(cd ComponentDiff) string {
+	var b strings.Builder
+	if n := len(cd.Violations); n > 0 {
+		fmt.Fprintf(&b, ", %d schema violations", n)
+	}
+	denied := cd.Denied()
+	if denied > 0 {
+		fmt.Fprintf(&b, ", %d policy denials", denied)
+	}
+	if n := len(cd.Findings) - denied; n > 0 {
+		fmt.Fprintf(&b, ", %d policy warnings", n)
+	}
+	if n := cd.NewDeprecations(); n > 0 {
+		fmt.Fprintf(&b, ", %d new deprecated fields", n)
+	}
+	return b.String()
+}
+
+// Summary returns the markdown summary of a non-empty result that PR
+// descriptions and comments share: destructive changes first, so they are
+// not lost below a long component table, then one row per component with
+// its line counts, the totals and any policy denials. It sorts result.Diffs.
+func Summary(result *DiffResult) string {
+	var b strings.Builder
+
+	SortDiffs(result.Diffs)
+	fmt.Fprint(&b, DangerousNote(result))
+
+	fmt.Fprintln(&b, "| Component | Environment | Changes |")
+	fmt.Fprintln(&b, "|-----------|-------------|---------|")
+	for _, d := range result.Diffs {
+		if d.SkipOutput {
+			continue
+		}
+		if d.Error != "" {
+			fmt.Fprintf(&b, "| `%s` | %s | build error |\n", d.Path, d.Env)
+		} else {
+			fmt.Fprintf(&b, "| `%s` | %s | +%d -%d%s |\n", d.Path, d.Env, d.Added, d.Removed, IssueSuffix(d))
+		}
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "**Total:** %d components, +%d -%d lines\n\n", len(result.Diffs), result.TotalAdded, result.TotalRemoved)
+	if result.TotalViolations > 0 {
+		fmt.Fprintf(&b, "⚠️ **%d schema violations** in rendered manifests\n\n", result.TotalViolations)
+	}
+	fmt.Fprint(&b, PolicyNote(result))
+	fmt.Fprint(&b, DeprecationNote(result))
+	fmt.Fprint(&b, SuppressedNote(result))
+	if result.TotalDenied > 0 {
+		// Denials block the change, so list them inline rather than only in
+		// the workflow summary.
+		for _, d := range result.Diffs {
+			for _, f := range d.Findings {
+				if f.Severity == policy.SeverityDeny {
+					fmt.Fprintf(&b, "- `%s` (%s): `%s`\n", d.Path, d.Env, f)
+				}
+			}
+		}
+		fmt.Fprintln(&b)
+	}
+	return b.String()
+}
+
+// maxDangerousRows caps the destructive-change table so a mass deletion does
+// not push a PR comment over GitHub's size limit.
+const maxDangerousRows = 50
+
+// DangerousNote returns a markdown table of resource deletions and
+// immutable-field changes across all components, or an empty string when
+// there are none.
+func DangerousNote(result *DiffResult) string {
+	if result.TotalDangerous == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "#### ⚠️ %d destructive changes\n\n", result.TotalDangerous)
+	if guarded := len(result.GuardedDeletions()); guarded > 0 {
+		fmt.Fprintf(&b, "🛑 **%d Namespace, CustomResourceDefinition or PersistentVolumeClaim deletions.** Everything inside them is deleted too.\n\n", guarded)
+	}
+	fmt.Fprintln(&b, "Argo CD will prune these resources, or fail to sync them unless they are recreated:")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "| Component | Environment | Change | Resource |")
+	fmt.Fprintln(&b, "|-----------|-------------|--------|----------|")
+	rows := 0
+	for _, d := range result.Diffs {
+		for _, c := range d.DangerousChanges() {
+			if rows == maxDangerousRows {
+				fmt.Fprintf(&b, "\n…and %d more.\n", result.TotalDangerous-rows)
+				fmt.Fprintln(&b)
+				return b.String()
+			}
+			rows++
+			resource := fmt.Sprintf("`%s`", c.ID())
+			if len(c.ImmutableFields) > 0 {
+				resource += fmt.Sprintf(" (`%s`)", strings.Join(c.ImmutableFields, "`, `"))
+			}
+			class := string(c.Class)
+			if c.GuardedDeletion() {
+				class = "🛑 " + class
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", d.Path, d.Env, class, resource)
+		}
+	}
+	fmt.Fprintln(&b)
+	return b.String()
+}
+
+// PolicyNote returns a markdown paragraph with the policy finding totals, or
+// an empty string when there are none.
+func PolicyNote(result *DiffResult) string {
+	switch {
+	case result.TotalDenied > 0:
+		return fmt.Sprintf("⛔ **%d policy denials**, %d warnings\n\n", result.TotalDenied, result.TotalWarned)
+	case result.TotalWarned > 0:
+		return fmt.Sprintf("⚠️ **%d policy warnings**\n\n", result.TotalWarned)
+	default:
+		return ""
+	}
+}
+
+// SuppressedNote returns a markdown paragraph with the diff lines each
+// normalize rule suppressed, or an empty string when none did.
+func SuppressedNote(result *DiffResult) string {
+	if len(result.Suppressed) == 0 {
+		return ""
+	}
+	total := 0
+	rules := make([]string, 0, len(result.Suppressed))
+	for _, s := range result.Suppressed {
+		total += s.Lines
+		rules = append(rules, fmt.Sprintf("`%s` %d", s.Rule, s.Lines))
+	}
+	return fmt.Sprintf("_Normalize rules suppressed %d diff lines: %s._\n\n", total, strings.Join(rules, ", "))
+}
+
+// DeprecationNote returns a markdown list of the deprecated kustomization
+// fields introduced on HEAD, or an empty string when there are none.
+func DeprecationNote(result *DiffResult) string {
+	deprecations := result.NewDeprecations()
+	if len(deprecations) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "⚠️ **%d new deprecated kustomization fields.** `kustomize-fix` rewrites most of them:\n\n", len(deprecations))
+	for _, d := range deprecations {
+		fmt.Fprintf(&b, "- `%s`: `%s` is deprecated, use `%s` instead\n", d.File, d.Field, d.Replacement)
+	}
+	fmt.Fprintln(&b)
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package renderdiff
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSortDiffs(t *testing.T) {
+	g := NewWithT(t)
+
+	diffs := []ComponentDiff{
+		{Path: "components/b/staging", Env: "staging"},
+		{Path: "components/a/production", Env: "production"},
+		{Path: "components/a/staging", Env: "staging"},
+		{Path: "components/c/production", Env: "production"},
+	}
+
+	SortDiffs(diffs)
+
+	g.Expect(diffs[0].Path).To(Equal("components/a/production"))
+	g.Expect(diffs[1].Path).To(Equal("components/c/production"))
+	g.Expect(diffs[2].Path).To(Equal("components/a/staging"))
+	g.Expect(diffs[3].Path).To(Equal("components/b/staging"))
+}
+
+func TestDangerousNote_GuardedDeletions(t *testing.T) {
+	g := NewWithT(t)
+
+	result := &DiffResult{
+		Diffs: []ComponentDiff{{
+			Path: "components/foo/staging", Env: "staging",
+			Changes: []ClassifiedChange{
+				{APIVersion: "v1", Kind: "Namespace", Name: "tenant", Class: ClassDelete},
+				{APIVersion: "v1", Kind: "Secret", Namespace: "tenant", Name: "s", Class: ClassDelete},
+			},
+		}},
+		TotalDangerous: 2,
+	}
+
+	note := DangerousNote(result)
+
+	g.Expect(note).To(ContainSubstring("🛑 **1 Namespace, CustomResourceDefinition or PersistentVolumeClaim deletions.**"))
+	g.Expect(note).To(ContainSubstring("| 🛑 delete | `v1 Namespace tenant` |"))
+	g.Expect(note).To(ContainSubstring("| delete | `v1 Secret tenant/s` |"))
+}
+
+func TestDangerousNote_Truncates(t *testing.T) {
+	g := NewWithT(t)
+
+	var changes []ClassifiedChange
+	for range maxDangerousRows + 5 {
+		changes = append(changes, ClassifiedChange{APIVersion: "v1", Kind: "Secret", Name: "s", Class: ClassDelete})
+	}
+	result := &DiffResult{
+		Diffs:          []ComponentDiff{{Path: "components/foo/staging", Env: "staging", Changes: changes}},
+		TotalDangerous: len(changes),
+	}
+
+	note := DangerousNote(result)
+
+	g.Expect(strings.Count(note, "| delete |")).To(Equal(maxDangerousRows))
+	g.Expect(note).To(ContainSubstring("…and 5 more."))
+	g.Expect(DangerousNote(&DiffResult{})).To(BeEmpty())
+}
@@ -0,0 +1,297 @@
+// Package schema validates rendered Kubernetes manifests against OpenAPI and
+// CRD schemas loaded from a local directory, so validation works offline.
+//
+// Supported schema sources (JSON or YAML, searched recursively):
+//   - Kubernetes OpenAPI v2 documents (e.g. `kubectl get --raw /openapi/v2`),
+//     whose definitions carry x-kubernetes-group-version-kind.
+//   - OpenAPI v3 documents (e.g. `kubectl get --raw /openapi/v3/apis/apps/v1`),
+//     using components.schemas in the same way.
+//   - CustomResourceDefinition manifests (apiextensions.k8s.io/v1 or v1beta1).
+//
+// Only the subset of OpenAPI needed for Kubernetes manifests is implemented:
+// type, properties, required, additionalProperties, items, enum, allOf, $ref
+// and the x-kubernetes-int-or-string, x-kubernetes-preserve-unknown-fields and
+// x-kubernetes-embedded-resource extensions. Null values are treated as unset,
+// matching how the API server handles them.
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Violation describes a single schema error in a rendered resource.
+type Violation struct {
+	// Resource identifies the document, e.g. "apps/v1 Deployment ns/name".
+	Resource string `json:"resource"`
+	// Path is the field path within the resource, e.g. spec.replicas.
+	Path string `json:"path"`
+	// Message explains what is wrong.
+	Message string `json:"message"`
+}
+
+// String formats the violation as "resource: path: message".
+func (v Violation) String() string {
+	if v.Path == "" {
+		return fmt.Sprintf("%s: %s", v.Resource, v.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", v.Resource, v.Path, v.Message)
+}
+
+// gvk is a group/version/kind identity expressed as (apiVersion, kind).
+type gvk struct {
+	apiVersion string
+	kind       string
+}
+
+// Validator holds schemas indexed by apiVersion and kind, plus the shared
+// definitions that $ref pointers resolve against. It is safe for concurrent
+// use once loaded.
+type Validator struct {
+	kinds map[gvk]map[string]interface{}
+	refs  map[string]map[string]interface{}
+}
+
+// New returns a Validator without schemas. Until AddCRDs indexes some, it
+// only validates custom resources against CRDs in the same stream.
+func New() *Validator {
+	return &Validator{
+		kinds: make(map[gvk]map[string]interface{}),
+		refs:  make(map[string]map[string]interface{}),
+	}
+}
+
+// Load reads every .json, .yaml and .yml file under dir and indexes the
+// schemas they contain. Files that contain no recognised schemas are ignored.
+func Load(dir string) (*Validator, error) {
+	v := New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json", ".yaml", ".yml":
+		default:
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		docs, err := decodeDocuments(data)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+		for _, doc := range docs {
+			v.addDocument(doc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading schemas from %s: %w", dir, err)
+	}
+	return v, nil
+}
+
+// Kinds returns the number of (apiVersion, kind) pairs with a schema.
+func (v *Validator) Kinds() int {
+	return len(v.kinds)
+}
+
+// AddCRDs indexes the CustomResourceDefinitions in a rendered multi-document
+// YAML stream, so that custom resources rendered by other components are
+// validated against them. It must not be called concurrently with itself or
+// Validate. Streams that cannot be parsed are ignored; Validate reports them.
+func (v *Validator) AddCRDs(rendered []byte) {
+	docs, err := decodeDocuments(rendered)
+	if err != nil {
+		return
+	}
+	for _, doc := range docs {
+		if kind, _ := doc["kind"].(string); kind == "CustomResourceDefinition" {
+			addCRD(doc, v.kinds)
+		}
+	}
+}
+
+// addDocument indexes a single decoded schema document.
+func (v *Validator) addDocument(doc map[string]interface{}) {
+	if kind, _ := doc["kind"].(string); kind == "CustomResourceDefinition" {
+		addCRD(doc, v.kinds)
+		return
+	}
+	if defs, ok := doc["definitions"].(map[string]interface{}); ok {
+		v.addDefinitions(defs, "#/definitions/")
+	}
+	if components, ok := doc["components"].(map[string]interface{}); ok {
+		if defs, ok := components["schemas"].(map[string]interface{}); ok {
+			v.addDefinitions(defs, "#/components/schemas/")
+		}
+	}
+}
+
+// quantityDefinition is the OpenAPI definition of resource quantities such
+// as resources.limits.cpu.
+const quantityDefinition = "io.k8s.apimachinery.pkg.api.resource.Quantity"
+
+// addDefinitions registers OpenAPI definitions for $ref resolution and
+// indexes those that declare x-kubernetes-group-version-kind.
+func (v *Validator) addDefinitions(defs map[string]interface{}, refPrefix string) {
+	for name, raw := range defs {
+		s, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name == quantityDefinition {
+			// The API server accepts numbers for quantities, but OpenAPI v2
+			// only says string.
+			s = maps.Clone(s)
+			s["format"] = "quantity"
+		}
+		v.refs[refPrefix+name] = s
+		gvks, _ := s["x-kubernetes-group-version-kind"].([]interface{})
+		for _, g := range gvks {
+			m, ok := g.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			group, _ := m["group"].(string)
+			version, _ := m["version"].(string)
+			kind, _ := m["kind"].(string)
+			if version == "" || kind == "" {
+				continue
+			}
+			apiVersion := version
+			if group != "" {
+				apiVersion = group + "/" + version
+			}
+			v.kinds[gvk{apiVersion, kind}] = s
+		}
+	}
+}
+
+// addCRD indexes the openAPIV3Schema of every version served by a CRD.
+func addCRD(doc map[string]interface{}, kinds map[gvk]map[string]interface{}) {
+	spec, _ := doc["spec"].(map[string]interface{})
+	group, _ := spec["group"].(string)
+	names, _ := spec["names"].(map[string]interface{})
+	kind, _ := names["kind"].(string)
+	if group == "" || kind == "" {
+		return
+	}
+
+	// apiextensions.k8s.io/v1beta1 allowed a single top-level schema.
+	var shared map[string]interface{}
+	if validation, ok := spec["validation"].(map[string]interface{}); ok {
+		shared, _ = validation["openAPIV3Schema"].(map[string]interface{})
+	}
+	if version, _ := spec["version"].(string); version != "" && shared != nil {
+		kinds[gvk{group + "/" + version, kind}] = shared
+	}
+
+	versions, _ := spec["versions"].([]interface{})
+	for _, raw := range versions {
+		ver, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := ver["name"].(string)
+		if name == "" {
+			continue
+		}
+		s := shared
+		if sch, ok := ver["schema"].(map[string]interface{}); ok {
+			if o, ok := sch["openAPIV3Schema"].(map[string]interface{}); ok {
+				s = o
+			}
+		}
+		if s != nil {
+			kinds[gvk{group + "/" + name, kind}] = s
+		}
+	}
+}
+
+// Validate checks every document in a rendered multi-document YAML stream.
+// CRDs contained in the same stream are honoured for the custom resources
+// next to them, so a component that ships its own CRD is validated without
+// vendoring it. Documents whose kind has no known schema are skipped.
+// An error is returned only when the YAML cannot be parsed.
+func (v *Validator) Validate(rendered []byte) ([]Violation, error) {
+	docs, err := decodeDocuments(rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy-on-write: the shared index is only cloned when the stream
+	// contains CRDs, and the clone never leaks to other callers.
+	kinds := v.kinds
+	copied := false
+	for _, doc := range docs {
+		if k, _ := doc["kind"].(string); k == "CustomResourceDefinition" {
+			if !copied {
+				kinds = maps.Clone(v.kinds)
+				copied = true
+			}
+			addCRD(doc, kinds)
+		}
+	}
+
+	var violations []Violation
+	for _, doc := range docs {
+		apiVersion, _ := doc["apiVersion"].(string)
+		kind, _ := doc["kind"].(string)
+		s, ok := kinds[gvk{apiVersion, kind}]
+		if !ok {
+			continue
+		}
+		w := walker{refs: v.refs, resource: resourceID(doc)}
+		w.validate("", doc, s, true)
+		violations = append(violations, w.violations...)
+	}
+	return violations, nil
+}
+
+// resourceID formats a document identity as "apiVersion Kind ns/name".
+func resourceID(doc map[string]interface{}) string {
+	apiVersion, _ := doc["apiVersion"].(string)
+	kind, _ := doc["kind"].(string)
+	md, _ := doc["metadata"].(map[string]interface{})
+	name, _ := md["name"].(string)
+	if ns, _ := md["namespace"].(string); ns != "" {
+		name = ns + "/" + name
+	}
+	return fmt.Sprintf("%s %s %s", apiVersion, kind, name)
+}
+
+// decodeDocuments decodes a multi-document YAML (or JSON) stream into maps,
+// skipping empty documents and documents that are not mappings.
+func decodeDocuments(data []byte) ([]map[string]interface{}, error) {
+	var docs []map[string]interface{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := doc.(map[string]interface{}); ok {
+			docs = append(docs, m)
+		}
+	}
+	return docs, nil
+}
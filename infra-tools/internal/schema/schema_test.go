@@ -0,0 +1,249 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// swaggerJSON is a trimmed-down Kubernetes OpenAPI v2 document with a
+// Deployment definition that references shared definitions via $ref.
+const swaggerJSON = `{
+  "definitions": {
+    "io.k8s.api.apps.v1.Deployment": {
+      "type": "object",
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "spec": {"$ref": "#/definitions/io.k8s.api.apps.v1.DeploymentSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"group": "apps", "kind": "Deployment", "version": "v1"}]
+    },
+    "io.k8s.api.apps.v1.DeploymentSpec": {
+      "type": "object",
+      "required": ["selector"],
+      "properties": {
+        "replicas": {"type": "integer"},
+        "selector": {"type": "object", "additionalProperties": {"type": "string"}},
+        "strategy": {
+          "type": "object",
+          "properties": {
+            "type": {"type": "string", "enum": ["Recreate", "RollingUpdate"]},
+            "maxSurge": {"type": "string", "format": "int-or-string"}
+          }
+        },
+        "paused": {"type": "boolean"}
+      }
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "namespace": {"type": "string"},
+        "creationTimestamp": {"type": "string"},
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    }
+  }
+}`
+
+const widgetCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+  versions:
+    - name: v1
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [size]
+              properties:
+                size:
+                  type: integer
+                extra:
+                  type: object
+                  x-kubernetes-preserve-unknown-fields: true
+`
+
+func loadTestValidator(t *testing.T, files map[string]string) *Validator {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestLoad_IndexesKinds(t *testing.T) {
+	g := NewWithT(t)
+
+	v := loadTestValidator(t, map[string]string{
+		"swagger.json": swaggerJSON,
+		"README.md":    "ignored",
+		"widget.yaml":  widgetCRD,
+	})
+	g.Expect(v.Kinds()).To(Equal(2))
+}
+
+func TestValidate_ValidDeployment(t *testing.T) {
+	g := NewWithT(t)
+
+	v := loadTestValidator(t, map[string]string{"swagger.json": swaggerJSON})
+	violations, err := v.Validate([]byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  creationTimestamp: null
+spec:
+  replicas: 2
+  selector:
+    app: controller
+  strategy:
+    type: RollingUpdate
+    maxSurge: 25%
+`))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(violations).To(BeEmpty())
+}
+
+func TestValidate_ReportsViolations(t *testing.T) {
+	g := NewWithT(t)
+
+	v := loadTestValidator(t, map[string]string{"swagger.json": swaggerJSON})
+	violations, err := v.Validate([]byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: build
+spec:
+  replicas: "2"
+  replica: 3
+  strategy:
+    type: Rolling
+    maxSurge: true
+`))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var paths []string
+	for _, vi := range violations {
+		g.Expect(vi.Resource).To(Equal("apps/v1 Deployment build/controller"))
+		paths = append(paths, vi.Path+": "+vi.Message)
+	}
+	g.Expect(paths).To(ConsistOf(
+		"spec.selector: required field is missing",
+		"spec.replica: unknown field",
+		"spec.replicas: expected integer, got string",
+		"spec.strategy.type: value is not one of [Recreate RollingUpdate]",
+		"spec.strategy.maxSurge: expected integer or string, got boolean",
+	))
+}
+
+func TestValidate_VendoredSchemas(t *testing.T) {
+	g := NewWithT(t)
+
+	v, err := Load("../../schemas")
+	g.Expect(err).NotTo(HaveOccurred())
+	violations, err := v.Validate([]byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: build
+spec:
+  replicas: "2"
+  selector:
+    matchLabels:
+      app: controller
+  template:
+    metadata:
+      labels:
+        app: controller
+    spec:
+      containers:
+        - image: quay.io/konflux-ci/controller:v1
+          imagePullPolicy: Always
+          resources:
+            limits:
+              cpu: 1
+              memory: 512Mi
+          livenessProbe:
+            httpGet:
+              port: 8080
+              paht: /healthz
+`))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var paths []string
+	for _, vi := range violations {
+		paths = append(paths, vi.Path+": "+vi.Message)
+	}
+	g.Expect(paths).To(ConsistOf(
+		"spec.replicas: expected integer, got string",
+		"spec.template.spec.containers[0].name: required field is missing",
+		"spec.template.spec.containers[0].livenessProbe.httpGet.paht: unknown field",
+	))
+}
+
+func TestValidate_SkipsUnknownKinds(t *testing.T) {
+	g := NewWithT(t)
+
+	v := loadTestValidator(t, map[string]string{"swagger.json": swaggerJSON})
+	violations, err := v.Validate([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: x\nbogus: true\n"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(violations).To(BeEmpty())
+}
+
+func TestValidate_UsesCRDsFromSameRender(t *testing.T) {
+	g := NewWithT(t)
+
+	v := loadTestValidator(t, nil)
+	rendered := widgetCRD + `---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+spec:
+  extra:
+    anything: goes
+  colour: red
+`
+	violations, err := v.Validate([]byte(rendered))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(violations).To(HaveLen(2))
+	g.Expect(violations[0].String()).To(Equal("example.com/v1 Widget w: spec.size: required field is missing"))
+	g.Expect(violations[1].String()).To(Equal("example.com/v1 Widget w: spec.colour: unknown field"))
+
+	// The CRD from one render must not leak into the shared index.
+	g.Expect(v.Kinds()).To(Equal(0))
+}
+
+func TestAddCRDs(t *testing.T) {
+	g := NewWithT(t)
+
+	v := New()
+	widget := "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\nspec:\n  size: large\n"
+	violations, err := v.Validate([]byte(widget))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(violations).To(BeEmpty(), "no schema for Widget yet")
+
+	v.AddCRDs([]byte(widgetCRD))
+	g.Expect(v.Kinds()).To(Equal(1))
+	violations, err = v.Validate([]byte(widget))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(violations).NotTo(BeEmpty())
+}
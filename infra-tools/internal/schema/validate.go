@@ -0,0 +1,213 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/redhat-appstudio/infra-deployments/infra-tools/internal/fieldpath"
+)
+
+// walker validates one document and accumulates its violations.
+type walker struct {
+	refs       map[string]map[string]interface{}
+	resource   string
+	violations []Violation
+}
+
+// maxRefDepth bounds $ref resolution to guard against cyclic definitions.
+const maxRefDepth = 32
+
+func (w *walker) fail(path, format string, args ...any) {
+	w.violations = append(w.violations, Violation{Resource: w.resource, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// resolve follows $ref pointers until it reaches a concrete schema.
+func (w *walker) resolve(s map[string]interface{}) map[string]interface{} {
+	for i := 0; i < maxRefDepth; i++ {
+		ref, ok := s["$ref"].(string)
+		if !ok {
+			return s
+		}
+		target, ok := w.refs[ref]
+		if !ok {
+			// Unknown reference: accept anything rather than report noise.
+			return map[string]interface{}{}
+		}
+		s = target
+	}
+	return map[string]interface{}{}
+}
+
+// validate checks value against schema s. root is true for the top-level
+// document, where apiVersion, kind and metadata are always allowed.
+func (w *walker) validate(path string, value interface{}, s map[string]interface{}, root bool) {
+	s = w.resolve(s)
+
+	// allOf entries (used by generated schemas to wrap a $ref with a
+	// description) must all hold.
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if subSchema, ok := sub.(map[string]interface{}); ok {
+				w.validate(path, value, subSchema, root)
+			}
+		}
+	}
+
+	// A null value means "unset" to the API server; required-ness is
+	// checked by the parent object.
+	if value == nil {
+		return
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok && len(enum) > 0 && !inEnum(value, enum) {
+		// The value is left out: validation runs before redaction, and
+		// violations are published in every output mode.
+		w.fail(path, "value is not one of %v", enum)
+		return
+	}
+
+	intOrString := s["x-kubernetes-int-or-string"] == true || s["format"] == "int-or-string"
+	if intOrString {
+		if _, isStr := value.(string); !isStr && !isInteger(value) {
+			w.fail(path, "expected integer or string, got %s", typeName(value))
+		}
+		return
+	}
+
+	typ, _ := s["type"].(string)
+	switch typ {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			w.fail(path, "expected object, got %s", typeName(value))
+			return
+		}
+		w.validateObject(path, obj, s, root)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			w.fail(path, "expected array, got %s", typeName(value))
+			return
+		}
+		if itemSchema, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range items {
+				w.validate(fmt.Sprintf("%s[%d]", path, i), item, itemSchema, false)
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok && (s["format"] != "quantity" || !isNumber(value)) {
+			w.fail(path, "expected string, got %s", typeName(value))
+		}
+	case "integer":
+		if !isInteger(value) {
+			w.fail(path, "expected integer, got %s", typeName(value))
+		}
+	case "number":
+		if !isNumber(value) {
+			w.fail(path, "expected number, got %s", typeName(value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			w.fail(path, "expected boolean, got %s", typeName(value))
+		}
+	case "":
+		// Untyped schema: validate structure only if properties are given.
+		if obj, ok := value.(map[string]interface{}); ok {
+			if _, hasProps := s["properties"]; hasProps {
+				w.validateObject(path, obj, s, root)
+			}
+		}
+	}
+}
+
+// validateObject checks required fields, known properties and unknown fields.
+func (w *walker) validateObject(path string, obj map[string]interface{}, s map[string]interface{}, root bool) {
+	props, _ := s["properties"].(map[string]interface{})
+
+	required, _ := s["required"].([]interface{})
+	for _, r := range required {
+		name, _ := r.(string)
+		if v, ok := obj[name]; !ok || v == nil {
+			w.fail(fieldpath.JoinKey(path, name), "required field is missing")
+		}
+	}
+
+	preserveUnknown := s["x-kubernetes-preserve-unknown-fields"] == true
+	embedded := root || s["x-kubernetes-embedded-resource"] == true
+	additional, hasAdditional := s["additionalProperties"]
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		child := fieldpath.JoinKey(path, k)
+		if ps, ok := props[k].(map[string]interface{}); ok {
+			w.validate(child, obj[k], ps, false)
+			continue
+		}
+		switch {
+		case embedded && (k == "apiVersion" || k == "kind" || k == "metadata"):
+		case hasAdditional:
+			if as, ok := additional.(map[string]interface{}); ok {
+				w.validate(child, obj[k], as, false)
+			} else if additional == false {
+				w.fail(child, "unknown field")
+			}
+		case preserveUnknown:
+		case props != nil:
+			w.fail(child, "unknown field")
+		}
+	}
+}
+
+func inEnum(value interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(value, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func isInteger(v interface{}) bool {
+	switch n := v.(type) {
+	case int, int64, uint64:
+		return true
+	case float64:
+		return n == math.Trunc(n)
+	}
+	return false
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int, int64, uint64, float64:
+		return true
+	}
+	return false
+}
+
+// typeName returns the JSON type name of a decoded YAML value.
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}